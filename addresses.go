@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/registry"
+)
+
+// predeployPrefix is the namespace of the L2 predeploys (0x4200...), which are never L1 contracts. Pasting one, e.g.
+// the L2StandardBridge, into --portal-address is a recurring mistake.
+var predeployPrefix = common.FromHex("0x42000000000000000000000000000000000000")
+
+// knownAddresses are the known-good L1 contract addresses of each built-in chain by L2 chain ID, keyed by the flag
+// that overrides them. Captured before any networks file is loaded, so that overrides can be checked against them.
+var knownAddresses = builtinAddresses()
+
+func builtinAddresses() map[uint64]map[string]common.Address {
+	known := make(map[uint64]map[string]common.Address)
+	for _, n := range networks {
+		addrs := make(map[string]common.Address)
+		for name, value := range map[string]string{
+			"portal-address": n.portalAddress,
+			"dgf-address":    n.disputeGameFactory,
+			"l2oo-address":   n.l2OOAddress,
+		} {
+			if addr := common.HexToAddress(value); addr != (common.Address{}) {
+				addrs[name] = addr
+			}
+		}
+		known[n.chainID] = addrs
+	}
+	return known
+}
+
+// parseAddress parses an L1 contract address given with the named flag or networks file setting. Mixed-case
+// addresses must have a valid EIP-55 checksum, as a bad one means the address was mistyped, and L2 predeploy
+// addresses are rejected.
+func parseAddress(name, value string) (common.Address, error) {
+	if !common.IsHexAddress(value) {
+		return common.Address{}, fmt.Errorf("invalid %s %q", name, value)
+	}
+	hex := strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	if hex != strings.ToLower(hex) && hex != strings.ToUpper(hex) {
+		mixed, err := common.NewMixedcaseAddressFromString(value)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("invalid %s %q: %w", name, value, err)
+		}
+		if !mixed.ValidChecksum() {
+			return common.Address{}, fmt.Errorf("%s %s has an invalid checksum, it was likely mistyped (the checksummed address is %s)", name, value, mixed.Address())
+		}
+	}
+	addr := common.HexToAddress(value)
+	if bytes.HasPrefix(addr.Bytes(), predeployPrefix) {
+		return common.Address{}, fmt.Errorf("%s %s is an L2 predeploy address, not the L1 contract", name, addr)
+	}
+	return addr, nil
+}
+
+// warnIfUnknownAddress warns if the chain has a known-good address for the named setting and addr is not it.
+func warnIfUnknownAddress(chainID uint64, name string, addr common.Address) {
+	known, ok := knownAddresses[chainID][name]
+	if !ok || known == addr {
+		return
+	}
+	fields := []interface{}{"setting", name, "address", addr, "known", known, "chainID", chainID}
+	for other, otherAddr := range knownAddresses[chainID] {
+		if otherAddr == addr {
+			fields = append(fields, "matches", other)
+		}
+	}
+	log.Warn("Address differs from the chain's known-good address, double check it", fields...)
+}
+
+// checkAddressOverrides checks the contract addresses given with the custom network flags, failing on malformed or
+// mistyped ones, and warning about those that differ from the addresses of the L2 at l2Rpc in the superchain registry
+// at registryURL or, if the registry doesn't list it, from its built-in known-good ones.
+func checkAddressOverrides(ctx context.Context, l2Rpc string, registryURL string, overrides map[string]string) error {
+	addrs := make(map[string]common.Address)
+	for name, value := range overrides {
+		if value == "" {
+			continue
+		}
+		addr, err := parseAddress("--"+name, value)
+		if err != nil {
+			return err
+		}
+		addrs[name] = addr
+	}
+	if len(addrs) == 0 || l2Rpc == "" {
+		return nil
+	}
+
+	l2Client, err := dialEth(ctx, l2Rpc)
+	if err != nil {
+		log.Warn("Unable to check the given addresses against the chain's known-good ones", "error", err)
+		return nil
+	}
+	defer l2Client.Close()
+	chainID, err := l2Client.ChainID(ctx)
+	if err != nil {
+		log.Warn("Unable to check the given addresses against the chain's known-good ones", "error", err)
+		return nil
+	}
+
+	chain, err := registry.Lookup(ctx, registryURL, chainID.Uint64())
+	if err != nil {
+		log.Debug("Unable to check the given addresses against the superchain registry", "chainID", chainID, "error", err)
+		for name, addr := range addrs {
+			warnIfUnknownAddress(chainID.Uint64(), name, addr)
+		}
+		return nil
+	}
+	registered := map[string]common.Address{
+		"portal-address": chain.OptimismPortal,
+		"dgf-address":    chain.DisputeGameFactory,
+		"l2oo-address":   chain.L2OutputOracle,
+	}
+	for name, addr := range addrs {
+		if want := registered[name]; want != (common.Address{}) && want != addr {
+			log.Warn("ADDRESS MISMATCH: the given address differs from the superchain registry's, transactions may be sent to the wrong contract",
+				"flag", name, "address", addr, "registry", want, "chain", chain.Name, "chainID", chainID)
+		}
+	}
+	return nil
+}
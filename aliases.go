@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// flagAlias is a deprecated flag name, kept so that automation built on an older flag set keeps working.
+type flagAlias struct {
+	replacement string // Current name of the flag
+	removed     bool   // Past its deprecation window, only accepted with --compat
+}
+
+// flagAliases maps deprecated flag names to the flags that replaced them. When a flag is renamed, its old name is
+// added here, e.g. "old-name": {replacement: "new-name"}, and marked removed once its deprecation window ends.
+var flagAliases = map[string]flagAlias{}
+
+// deprecatedFlagUse is a deprecated flag name found in one of the settings sources.
+type deprecatedFlagUse struct {
+	name   string
+	source string
+}
+
+// deprecatedFlagUses collects the deprecated flag names found while applying the settings sources, which are
+// reported together once --compat is known.
+var deprecatedFlagUses []deprecatedFlagUse
+
+// registerFlagAliases registers each deprecated name on fs as a hidden flag sharing the value of its replacement, so
+// it is accepted on the command line, in WITHDRAWER_* variables and in config files alike.
+func registerFlagAliases(fs *flag.FlagSet) {
+	for name, alias := range flagAliases {
+		f := fs.Lookup(alias.replacement)
+		if f == nil {
+			panic(fmt.Sprintf("deprecated flag --%s is an alias of unknown flag --%s", name, alias.replacement))
+		}
+		fs.Var(f.Value, name, fmt.Sprintf("Deprecated, use --%s", alias.replacement))
+	}
+}
+
+// canonicalFlagName returns the current name of the flag, which differs from name for deprecated names.
+func canonicalFlagName(name string) string {
+	if alias, ok := flagAliases[name]; ok {
+		return alias.replacement
+	}
+	return name
+}
+
+// isFlagSet reports whether the flag was set, under its current name or a deprecated one.
+func isFlagSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if canonicalFlagName(f.Name) == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// recordCommandLineAliases records the deprecated flag names set on the command line.
+func recordCommandLineAliases(fs *flag.FlagSet) {
+	fs.Visit(func(f *flag.Flag) {
+		if _, ok := flagAliases[f.Name]; ok {
+			deprecatedFlagUses = append(deprecatedFlagUses, deprecatedFlagUse{name: f.Name, source: "command line"})
+		}
+	})
+}
+
+// reportDeprecatedFlags warns about each deprecated flag name in use, and fails if any is past its deprecation window.
+// With compat, removed names are accepted too and nothing is logged above debug level, so that automation relying on
+// the old names keeps running unchanged.
+func reportDeprecatedFlags(compat bool) error {
+	var removed []string
+	for _, use := range deprecatedFlagUses {
+		alias := flagAliases[use.name]
+		switch {
+		case compat:
+			log.Debug("Accepting deprecated flag in compat mode", "flag", use.name, "replacement", alias.replacement, "source", use.source)
+		case alias.removed:
+			removed = append(removed, fmt.Sprintf("--%s (%s, use --%s)", use.name, use.source, alias.replacement))
+		default:
+			log.Warn("Flag is deprecated and will be removed, use its replacement", "flag", use.name, "replacement", alias.replacement, "source", use.source)
+		}
+	}
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		return errors.New("deprecated flags are no longer supported, pass --compat to accept them: " + strings.Join(removed, ", "))
+	}
+	return nil
+}
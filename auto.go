@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+const (
+	// defaultMaxWait bounds how long the auto command waits for the withdrawal to become provable or finalizable.
+	defaultMaxWait = time.Hour
+	// provablePollInterval is how often the auto command checks whether a withdrawal it waits on became provable.
+	provablePollInterval = time.Minute
+	// networkProbeTimeout bounds each L2 lookup while detecting the network a withdrawal was sent on.
+	networkProbeTimeout = 10 * time.Second
+)
+
+// autoDefaults are the flag values the auto command uses unless they were set, so that one-off users don't overpay
+// during a fee spike or run out of gas on a tight estimate.
+var autoDefaults = []struct {
+	name, value string
+	unless      []string // Flags that make the default redundant
+}{
+	{name: "max-gas-price", value: "100000000000"}, // 100 gwei
+	{name: "gas-multiplier", value: "1.2", unless: []string{"gas-limit"}},
+}
+
+// applyAutoDefaults sets the auto command's defaults on fs for the flags that weren't set on the command line, in the
+// environment or in a config file.
+func applyAutoDefaults(fs *flag.FlagSet) error {
+	for _, d := range autoDefaults {
+		set := isFlagSet(fs, d.name)
+		for _, name := range d.unless {
+			set = set || isFlagSet(fs, name)
+		}
+		if set {
+			continue
+		}
+		if err := fs.Set(d.name, d.value); err != nil {
+			return fmt.Errorf("error setting default --%s: %w", d.name, err)
+		}
+		log.Info("Using auto default", "flag", d.name, "value", d.value)
+	}
+	return nil
+}
+
+// detectNetwork returns the name of the built-in or user-defined network the withdrawal was sent on, by looking the
+// transaction up on the L2 of each network settling on the L1 of l1Rpc.
+func detectNetwork(ctx context.Context, l1Rpc string, withdrawal common.Hash) (string, error) {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return "", fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	l1ChainID, err := l1Client.ChainID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+
+	var names []string
+	for name, n := range networks {
+		if n.l1ChainID == 0 || n.l1ChainID == l1ChainID.Uint64() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var found []string
+	for _, name := range names {
+		if sentOn(ctx, networks[name].l2RPC, withdrawal) {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", fmt.Errorf("withdrawal %s was not found on any network settling on L1 chain %s (checked %s), please provide the --network flag",
+			withdrawal, l1ChainID, strings.Join(names, ", "))
+	case 1:
+		return found[0], nil
+	default:
+		return "", fmt.Errorf("withdrawal %s was found on several networks (%s), please provide the --network flag", withdrawal, strings.Join(found, ", "))
+	}
+}
+
+// sentOn reports whether the transaction was included on the L2 of l2Rpc. Unreachable L2s are treated as not having it.
+func sentOn(ctx context.Context, l2Rpc string, tx common.Hash) bool {
+	ctx, cancel := context.WithTimeout(ctx, networkProbeTimeout)
+	defer cancel()
+	l2Client, err := dialEth(ctx, l2Rpc)
+	if err != nil {
+		log.Debug("Unable to dial L2 while detecting the network", "l2Rpc", l2Rpc, "error", err)
+		return false
+	}
+	defer l2Client.Close()
+	if _, err := l2Client.TransactionReceipt(ctx, tx); err != nil {
+		log.Debug("Withdrawal not found on L2", "l2Rpc", l2Rpc, "error", err)
+		return false
+	}
+	return true
+}
+
+// confirmTransaction asks on the terminal whether to send the action's transaction, unless yes. Without a terminal to
+// ask on, it fails rather than send unconfirmed.
+func confirmTransaction(action withdraw.Action, withdrawal withdrawalRef, networkName string, from common.Address, yes bool) error {
+	if yes {
+		return nil
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return errors.New("no terminal to confirm the transaction on, pass --yes to send it without confirmation")
+	}
+	fmt.Fprintf(os.Stderr, "Send the %s transaction for withdrawal %s on %s from %s? [y/N] ", action, withdrawal, networkName, from)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("error reading confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return fmt.Errorf("%s transaction not confirmed", action)
+}
+
+// waitUntilProvable checks whether the withdrawal can be proven until it can, or until maxWait elapses, e.g. while
+// no dispute game covers it yet. The waits are timed by timing's clock.
+func waitUntilProvable(ctx context.Context, withdrawer withdraw.WithdrawHelper, maxWait time.Duration, timing *withdraw.Timing) error {
+	deadline := timing.Now().Add(maxWait)
+	for {
+		err := withdrawer.CheckIfProvable()
+		if err == nil {
+			return nil
+		}
+		if timing.Now().Add(provablePollInterval).After(deadline) {
+			return fmt.Errorf("withdrawal did not become provable within --max-wait %s: %w", maxWait, err)
+		}
+		log.Info("Withdrawal is not provable yet, waiting", "reason", err, "retryIn", provablePollInterval)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timing.After(provablePollInterval):
+		}
+	}
+}
+
+// waitUntilFinalizable waits until the withdrawal can be finalized, if it can be within maxWait, for withdrawers that
+// can estimate it. The estimate is refreshed after each wait, as an unresolved game's resolution is only estimated.
+// The waits are timed by timing's clock.
+func waitUntilFinalizable(ctx context.Context, withdrawer withdraw.WithdrawHelper, maxWait time.Duration, timing *withdraw.Timing) error {
+	estimator, ok := withdrawer.(finalizationEstimator)
+	if !ok {
+		return nil
+	}
+	deadline := timing.Now().Add(maxWait)
+	for {
+		e, err := estimator.FinalizationEstimate()
+		if err != nil {
+			return fmt.Errorf("error estimating when the withdrawal can be finalized: %w", err)
+		}
+		remaining := e.Remaining(timing.Now())
+		if remaining == 0 {
+			return nil
+		}
+		finalizableAt := time.Unix(int64(e.FinalizableAt), 0)
+		if finalizableAt.After(deadline) {
+			return fmt.Errorf("withdrawal is finalizable at %s, in %s, past --max-wait %s", finalizableAt.UTC().Format(time.RFC3339), remaining.Round(time.Second), maxWait)
+		}
+		log.Info("Waiting for the withdrawal to become finalizable", "finalizableAt", finalizableAt.UTC(), "remaining", remaining.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timing.After(remaining):
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// fakeClock is a withdraw.Clock whose time only moves when a wait is made on it: After advances it by the wait and
+// fires at once, unless it interrupts, so the waits run instantly and the test sees each one.
+type fakeClock struct {
+	now       time.Time
+	waits     []time.Duration
+	interrupt context.CancelFunc // If set, After cancels the context with it instead of firing
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if c.interrupt != nil {
+		c.interrupt()
+		return ch
+	}
+	c.now = c.now.Add(d)
+	ch <- c.now
+	return ch
+}
+
+// WithTimeout doesn't time out, as the auto command's waits bound themselves by the clock's time instead.
+func (c *fakeClock) WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithCancel(ctx)
+}
+
+var clockStart = time.Unix(1_700_000_000, 0)
+
+// fakeWithdrawer is a withdrawer that becomes provable after a number of checks. Its other methods aren't called.
+type fakeWithdrawer struct {
+	withdraw.WithdrawHelper
+	unprovable int // Checks to fail before the withdrawal is provable (-1 means it never is)
+	checks     int
+}
+
+func (w *fakeWithdrawer) CheckIfProvable() error {
+	w.checks++
+	if w.unprovable < 0 || w.checks <= w.unprovable {
+		return errors.New("no dispute game covers the withdrawal yet")
+	}
+	return nil
+}
+
+// fakeEstimator is a withdrawer that can estimate when it's finalizable. Each estimate is the next of finalizableAt,
+// as seconds after clockStart, the last one repeating, e.g. as an unresolved game's estimated resolution moves.
+type fakeEstimator struct {
+	fakeWithdrawer
+	finalizableAt []int64
+	err           error
+	estimates     int
+}
+
+func (w *fakeEstimator) FinalizationEstimate() (*withdraw.FinalizationEstimate, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	at := w.finalizableAt[min(w.estimates, len(w.finalizableAt)-1)]
+	w.estimates++
+	return &withdraw.FinalizationEstimate{FinalizableAt: uint64(clockStart.Unix() + at)}, nil
+}
+
+func TestWaitUntilProvable(t *testing.T) {
+	tests := []struct {
+		name       string
+		unprovable int
+		maxWait    time.Duration
+		interrupt  bool
+		wantChecks int
+		wantWaits  []time.Duration
+		wantErr    string
+	}{
+		{
+			name:       "provable at once",
+			maxWait:    time.Hour,
+			wantChecks: 1,
+		},
+		{
+			name:       "provable after two polls",
+			unprovable: 2,
+			maxWait:    time.Hour,
+			wantChecks: 3,
+			wantWaits:  []time.Duration{provablePollInterval, provablePollInterval},
+		},
+		{
+			name:       "not provable within max wait",
+			unprovable: -1,
+			maxWait:    150 * time.Second,
+			wantChecks: 3,
+			wantWaits:  []time.Duration{provablePollInterval, provablePollInterval},
+			wantErr:    "withdrawal did not become provable within --max-wait 2m30s: no dispute game covers the withdrawal yet",
+		},
+		{
+			name:       "max wait shorter than a poll",
+			unprovable: -1,
+			maxWait:    30 * time.Second,
+			wantChecks: 1,
+			wantErr:    "withdrawal did not become provable within --max-wait 30s",
+		},
+		{
+			name:       "interrupted while waiting",
+			unprovable: -1,
+			maxWait:    time.Hour,
+			interrupt:  true,
+			wantChecks: 1,
+			wantWaits:  []time.Duration{provablePollInterval},
+			wantErr:    context.Canceled.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := &fakeClock{now: clockStart}
+			if tt.interrupt {
+				clock.interrupt = cancel
+			}
+			w := &fakeWithdrawer{unprovable: tt.unprovable}
+
+			err := waitUntilProvable(ctx, w, tt.maxWait, &withdraw.Timing{Clock: clock})
+			checkErr(t, err, tt.wantErr)
+			if w.checks != tt.wantChecks {
+				t.Errorf("got %d provability checks, want %d", w.checks, tt.wantChecks)
+			}
+			if !reflect.DeepEqual(clock.waits, tt.wantWaits) {
+				t.Errorf("got waits %v, want %v", clock.waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+func TestWaitUntilFinalizable(t *testing.T) {
+	tests := []struct {
+		name          string
+		withdrawer    withdraw.WithdrawHelper
+		maxWait       time.Duration
+		wantEstimates int
+		wantWaits     []time.Duration
+		wantErr       string
+	}{
+		{
+			name:       "withdrawer without estimates",
+			withdrawer: &fakeWithdrawer{},
+			maxWait:    time.Hour,
+		},
+		{
+			name:          "already finalizable",
+			withdrawer:    &fakeEstimator{finalizableAt: []int64{-60}},
+			maxWait:       time.Hour,
+			wantEstimates: 1,
+		},
+		{
+			name:          "finalizable within max wait",
+			withdrawer:    &fakeEstimator{finalizableAt: []int64{1800}},
+			maxWait:       time.Hour,
+			wantEstimates: 2,
+			wantWaits:     []time.Duration{30 * time.Minute},
+		},
+		{
+			name:          "estimate moved later while waiting",
+			withdrawer:    &fakeEstimator{finalizableAt: []int64{1800, 2400}},
+			maxWait:       time.Hour,
+			wantEstimates: 3,
+			wantWaits:     []time.Duration{30 * time.Minute, 10 * time.Minute},
+		},
+		{
+			name:          "finalizable past max wait",
+			withdrawer:    &fakeEstimator{finalizableAt: []int64{7200}},
+			maxWait:       time.Hour,
+			wantEstimates: 1,
+			wantErr:       "withdrawal is finalizable at 2023-11-15T00:13:20Z, in 2h0m0s, past --max-wait 1h0m0s",
+		},
+		{
+			name:          "estimate moved past max wait while waiting",
+			withdrawer:    &fakeEstimator{finalizableAt: []int64{1800, 5400}},
+			maxWait:       time.Hour,
+			wantEstimates: 2,
+			wantWaits:     []time.Duration{30 * time.Minute},
+			wantErr:       "past --max-wait 1h0m0s",
+		},
+		{
+			name:       "estimate failed",
+			withdrawer: &fakeEstimator{err: errors.New("execution reverted")},
+			maxWait:    time.Hour,
+			wantErr:    "error estimating when the withdrawal can be finalized: execution reverted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{now: clockStart}
+
+			err := waitUntilFinalizable(context.Background(), tt.withdrawer, tt.maxWait, &withdraw.Timing{Clock: clock})
+			checkErr(t, err, tt.wantErr)
+			if e, ok := tt.withdrawer.(*fakeEstimator); ok && e.estimates != tt.wantEstimates {
+				t.Errorf("got %d estimates, want %d", e.estimates, tt.wantEstimates)
+			}
+			if !reflect.DeepEqual(clock.waits, tt.wantWaits) {
+				t.Errorf("got waits %v, want %v", clock.waits, tt.wantWaits)
+			}
+		})
+	}
+}
+
+// checkErr fails the test unless err contains want, or is nil if want is empty.
+func checkErr(t *testing.T, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Fatalf("unexpected error: %v", err)
+	case want != "" && err == nil:
+		t.Fatalf("got no error, want %q", want)
+	case want != "" && !strings.Contains(err.Error(), want):
+		t.Fatalf("got error %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// backfillEntry is a line of the backfill output, a past prove or finalize transaction of the address.
+type backfillEntry struct {
+	Action         string      `json:"action"` // "prove" or "finalize"
+	WithdrawalHash common.Hash `json:"withdrawalHash"`
+	L1TxHash       common.Hash `json:"l1TxHash"`
+	BlockNumber    uint64      `json:"blockNumber"`
+	GasUsed        uint64      `json:"gasUsed"`
+	CostWei        string      `json:"costWei"`
+	Success        bool        `json:"success"`
+}
+
+// runBackfill reconstructs the proofs and finalizations the address sent on the network since fromBlock, from the
+// portal's events, and prints them to stdout as JSON lines, followed by a summary on stderr.
+func runBackfill(ctx context.Context, l1Rpc string, n network, address common.Address, fromBlock uint64) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	head, err := l1Client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L1 head: %w", err)
+	}
+	if fromBlock > head {
+		return fmt.Errorf("--from-block %d is past the L1 head %d", fromBlock, head)
+	}
+
+	activity, err := withdraw.FindActivity(ctx, l1Client, common.HexToAddress(n.portalAddress), address, fromBlock, head)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	costs := make([]withdraw.TxCost, 0, len(activity))
+	proven, finalized := 0, 0
+	for _, a := range activity {
+		if err := enc.Encode(backfillEntry{
+			Action:         a.Action,
+			WithdrawalHash: a.WithdrawalHash,
+			L1TxHash:       a.TxHash,
+			BlockNumber:    a.BlockNumber.Uint64(),
+			GasUsed:        a.GasUsed,
+			CostWei:        a.Cost.String(),
+			Success:        a.Success,
+		}); err != nil {
+			return err
+		}
+		costs = append(costs, a.TxCost)
+		if a.Action == "prove" {
+			proven++
+		} else {
+			finalized++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%s proved %d and finalized %d withdrawals in L1 blocks %d-%d, spending %s ETH on gas\n",
+		address, proven, finalized, fromBlock, head, withdraw.FormatEther(withdraw.TotalCost(costs)))
+	return nil
+}
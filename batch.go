@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// outcomeSkipped is the outcome of the withdrawals of a batch left unprocessed once it was interrupted.
+const outcomeSkipped = "skipped"
+
+// hashList is a flag holding withdrawal tx hashes, which may be repeated or comma-separated.
+type hashList []common.Hash
+
+func (l *hashList) String() string {
+	if l == nil {
+		return ""
+	}
+	hashes := make([]string, len(*l))
+	for i, h := range *l {
+		hashes[i] = h.Hex()
+	}
+	return strings.Join(hashes, ",")
+}
+
+func (l *hashList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		h, err := parseWithdrawalHash(v)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, h)
+	}
+	return nil
+}
+
+// parseWithdrawalHash parses an L2 withdrawal tx hash, with or without the 0x prefix.
+func parseWithdrawalHash(value string) (common.Hash, error) {
+	value = strings.TrimSpace(value)
+	raw := strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	b, err := hex.DecodeString(raw)
+	if err != nil || len(b) != common.HashLength {
+		return common.Hash{}, fmt.Errorf("invalid withdrawal tx hash %q", value)
+	}
+	return common.BytesToHash(b), nil
+}
+
+// collectWithdrawals returns the withdrawals given with --withdrawal followed by those in the file at path, if set,
+// or on stdin if it's "-", without duplicates.
+func collectWithdrawals(flags hashList, path string) ([]common.Hash, error) {
+	all := append([]common.Hash{}, flags...)
+	if path != "" {
+		fromFile, err := readWithdrawalsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, fromFile...)
+	}
+	seen := make(map[common.Hash]bool)
+	var withdrawals []common.Hash
+	for _, h := range all {
+		if seen[h] {
+			log.Warn("Ignoring duplicate withdrawal", "withdrawal", h)
+			continue
+		}
+		seen[h] = true
+		withdrawals = append(withdrawals, h)
+	}
+	return withdrawals, nil
+}
+
+// readWithdrawalsFile reads a withdrawal tx hash from each line of the file, skipping blank lines and # comments.
+func readWithdrawalsFile(path string) ([]common.Hash, error) {
+	var r io.Reader = os.Stdin
+	name := "stdin"
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("error opening withdrawals file: %w", err)
+		}
+		defer f.Close()
+		r, name = f, path
+	}
+	var hashes []common.Hash
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text, _, _ := strings.Cut(scanner.Text(), "#")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		h, err := parseWithdrawalHash(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", name, line, err)
+		}
+		hashes = append(hashes, h)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading withdrawals from %s: %w", name, err)
+	}
+	return hashes, nil
+}
+
+// withdrawalRef is a withdrawal to prove or finalize: the L2 tx that initiated it and, for txs that initiated several
+// withdrawals, the log index of its MessagePassed event.
+type withdrawalRef struct {
+	l2TxHash common.Hash
+	logIndex *uint // nil means the tx's only, or first, withdrawal
+}
+
+func (r withdrawalRef) String() string {
+	if r.logIndex == nil {
+		return r.l2TxHash.Hex()
+	}
+	return fmt.Sprintf("%s (log %d)", r.l2TxHash, *r.logIndex)
+}
+
+// journalKey is what the transactions sent for the withdrawal are journaled under: the L2 tx hash, or for a withdrawal
+// selected by log index, the hash of the L2 tx hash and the index, so the withdrawals of a tx don't share entries.
+func (r withdrawalRef) journalKey() common.Hash {
+	if r.logIndex == nil {
+		return r.l2TxHash
+	}
+	return crypto.Keccak256Hash(r.l2TxHash.Bytes(), binary.BigEndian.AppendUint64(nil, uint64(*r.logIndex)))
+}
+
+// resolveWithdrawals looks up the withdrawals each L2 tx initiated: the one at logIndex if set, every one of them with
+// all, or else its only one. Txs that initiated several withdrawals are rejected unless one is selected or all are
+// processed, as proving just the first would leave the others behind unnoticed.
+func resolveWithdrawals(ctx context.Context, l2Rpc string, txs []common.Hash, logIndex *uint, all bool) ([]withdrawalRef, error) {
+	l2Client, err := dialEth(ctx, l2Rpc)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	defer l2Client.Close()
+
+	var refs []withdrawalRef
+	for _, tx := range txs {
+		if logIndex != nil {
+			refs = append(refs, withdrawalRef{l2TxHash: tx, logIndex: logIndex})
+			continue
+		}
+		receipt, err := l2Client.TransactionReceipt(ctx, tx)
+		if err != nil {
+			return nil, fmt.Errorf("error querying withdrawal %s: %w", tx, err)
+		}
+		indexes := withdraw.MessagePassedLogIndexes(receipt)
+		switch {
+		case len(indexes) == 0:
+			return nil, fmt.Errorf("L2 tx %s initiated no withdrawals", tx)
+		case len(indexes) == 1:
+			refs = append(refs, withdrawalRef{l2TxHash: tx})
+		case all:
+			log.Info("L2 tx initiated several withdrawals, processing each", "l2TxHash", tx, "logIndexes", indexes)
+			for _, i := range indexes {
+				refs = append(refs, withdrawalRef{l2TxHash: tx, logIndex: &i})
+			}
+		default:
+			return nil, fmt.Errorf("L2 tx %s initiated %d withdrawals, at log indexes %v: select one with --log-index or process them all with --all", tx, len(indexes), indexes)
+		}
+	}
+	return refs, nil
+}
+
+// appendUnfinished appends the withdrawals of the network the state store records as not finalized yet to refs,
+// skipping those already in it.
+func appendUnfinished(refs []withdrawalRef, store *stateStore, networkName string) ([]withdrawalRef, error) {
+	unfinished, err := store.unfinished(networkName)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[common.Hash]bool)
+	for _, r := range refs {
+		seen[r.journalKey()] = true
+	}
+	resumed := 0
+	for _, r := range unfinished {
+		if seen[r.journalKey()] {
+			continue
+		}
+		seen[r.journalKey()] = true
+		refs = append(refs, r)
+		resumed++
+	}
+	log.Info("Resuming unfinished withdrawals from the state file", "withdrawals", resumed)
+	return refs, nil
+}
+
+// batchEntry is the outcome of a withdrawal of a batch.
+type batchEntry struct {
+	Withdrawal common.Hash  `json:"withdrawal"`
+	LogIndex   *uint        `json:"logIndex,omitempty"`
+	Outcome    string       `json:"outcome"`          // success, failure, interrupted or skipped
+	Action     string       `json:"action,omitempty"` // The action taken, or being taken when it failed
+	L1TxHash   *common.Hash `json:"l1TxHash,omitempty"`
+	Error      string       `json:"error,omitempty"`
+}
+
+// batchSummary is the machine-readable outcome of a batch, printed to stdout as a single JSON object after the
+// results of its withdrawals.
+type batchSummary struct {
+	Action      string       `json:"action"` // Always "batch"
+	DryRun      bool         `json:"dryRun,omitempty"`
+	Succeeded   int          `json:"succeeded"`
+	Failed      int          `json:"failed"`
+	Withdrawals []batchEntry `json:"withdrawals"`
+}
+
+// runBatch takes each withdrawal its next step through its lifecycle, one after the other so the signer's
+// transactions are sequenced, and goes on with the next when one fails. The metrics of each are recorded to the
+// metrics file at metricsPath. Once done, or interrupted, a summary is printed to stdout and stderr, and the process
+// exits with an error if any withdrawal failed.
+func runBatch(ctx context.Context, cfg runSettings, withdrawals []withdrawalRef, metricsPath string) {
+	log.Info("Processing batch of withdrawals", "withdrawals", len(withdrawals), "network", cfg.networkName)
+	summary := batchSummary{Action: "batch", DryRun: cfg.dryRun}
+	for i, withdrawal := range withdrawals {
+		entry := batchEntry{Withdrawal: withdrawal.l2TxHash, LogIndex: withdrawal.logIndex}
+		if ctx.Err() != nil {
+			entry.Outcome = outcomeSkipped
+			summary.Withdrawals = append(summary.Withdrawals, entry)
+			continue
+		}
+
+		if rec, err := cfg.store.get(withdrawal); err != nil {
+			log.Warn("Unable to read the withdrawal from the state file", "withdrawal", withdrawal, "error", err)
+		} else if rec != nil && rec.Stage == withdraw.StateFinalized {
+			log.Info("Withdrawal already finalized according to the state file, skipping", "withdrawal", withdrawal, "finalizeTx", rec.FinalizeTxHash)
+			entry.Outcome, entry.Action, entry.L1TxHash = outcomeSuccess, string(withdraw.ActionNone), rec.FinalizeTxHash
+			summary.Succeeded++
+			summary.Withdrawals = append(summary.Withdrawals, entry)
+			continue
+		}
+
+		log.Info("Processing withdrawal", "withdrawal", withdrawal, "index", i+1, "of", len(withdrawals))
+		metrics := newMetricsRecorder(metricsPath, time.Now(), cfg.networkName, withdrawal.l2TxHash, cfg.dryRun)
+		metrics.rpcBase = rpcRequests.Load()
+		onCrit = metrics.finishOnCrit(ctx)
+		r, err := runWithdrawal(ctx, cfg, withdrawal, metrics)
+		entry.Action = metrics.metrics.Action
+		switch {
+		case err == nil:
+			entry.Outcome = outcomeSuccess
+			entry.L1TxHash = r.L1TxHash
+			summary.Succeeded++
+		case ctx.Err() != nil:
+			entry.Outcome = outcomeInterrupted
+			entry.Error = err.Error()
+			summary.Failed++
+		default:
+			entry.Outcome = outcomeFailure
+			entry.Error = err.Error()
+			summary.Failed++
+			log.Error("Withdrawal failed, continuing with the next", "withdrawal", withdrawal, "error", err)
+		}
+		metrics.finish(entry.Outcome, entry.Error)
+		summary.Withdrawals = append(summary.Withdrawals, entry)
+	}
+	onCrit = nil
+
+	printBatchSummary(summary)
+	if err := json.NewEncoder(os.Stdout).Encode(summary); err != nil {
+		log.Error("Error writing batch summary", "error", err)
+	}
+	if summary.Failed > 0 || ctx.Err() != nil {
+		log.Crit("Batch did not complete", "succeeded", summary.Succeeded, "failed", summary.Failed, "withdrawals", len(withdrawals))
+	}
+	log.Info("Batch complete", "withdrawals", len(withdrawals))
+}
+
+// printBatchSummary writes the outcome of each withdrawal of the batch to stderr.
+func printBatchSummary(summary batchSummary) {
+	fmt.Fprintf(os.Stderr, "Batch summary (%d succeeded, %d failed):\n", summary.Succeeded, summary.Failed)
+	for _, e := range summary.Withdrawals {
+		detail := e.Error
+		if e.L1TxHash != nil {
+			detail = "l1 tx " + e.L1TxHash.Hex()
+		}
+		withdrawal := withdrawalRef{l2TxHash: e.Withdrawal, logIndex: e.LogIndex}
+		fmt.Fprintf(os.Stderr, "  %s  %-11s %-9s %s\n", withdrawal, e.Outcome, e.Action, detail)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// userOpSender sends the portal calls built by dry runs from a smart account as ERC-4337 UserOperations through a
+// bundler, signed by the account's owner, instead of sending them from an EOA.
+type userOpSender struct {
+	bundler *withdraw.Bundler
+	account common.Address
+	owner   signer.Signer
+}
+
+// send sends the portal call of the withdrawer's last dry run from the smart account, at the fees of the transaction
+// the dry run built, and waits for it to be confirmed. A nil sender sends nothing and returns a nil cost.
+func (u *userOpSender) send(ctx context.Context, cfg runSettings, action withdraw.Action, withdrawer withdraw.WithdrawHelper) (*withdraw.TxCost, error) {
+	if u == nil {
+		return nil, nil
+	}
+	callReader, ok := withdrawer.(dryRunCallReader)
+	txReader, _ := withdrawer.(dryRunTxReader)
+	if !ok || txReader == nil || callReader.DryRunCall() == nil || txReader.DryRunTx() == nil {
+		return nil, errors.New("no portal call was built to send")
+	}
+	call, tx := callReader.DryRunCall(), txReader.DryRunTx()
+
+	l1Client, err := dialEth(ctx, cfg.l1Rpc)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	op, err := u.bundler.NewUserOperation(ctx, l1Client, u.account, call, tx.GasFeeCap(), tx.GasTipCap())
+	if err != nil {
+		return nil, err
+	}
+	hash, err := u.bundler.Hash(ctx, l1Client, op)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Signing UserOperation", "account", u.account, "method", call.Method, "to", call.To, "nonce", op.Nonce, "userOpHash", hash, "owner", u.owner.Address())
+	if op.Signature, err = u.owner.SignText(hash.Bytes()); err != nil {
+		return nil, fmt.Errorf("error signing UserOperation: %w", err)
+	}
+
+	timeout := cfg.txConfig.FinalizeTimeout
+	if action == withdraw.ActionProve {
+		timeout = cfg.txConfig.ProveTimeout
+	}
+	return u.bundler.Send(ctx, l1Client, op, action, timeout, cfg.txConfig.Confirmations, cfg.txConfig.WaitFinalized)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// runCancel explains what can be done about a withdrawal that its sender wants to cancel. Withdrawals can't be
+// cancelled once initiated, so this only checks how far along the withdrawal is and prints what that means for the
+// funds, without sending anything.
+func runCancel(ctx context.Context, l1Rpc string, n network, withdrawal common.Hash) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	l2Client, err := dialEth(ctx, n.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+
+	receipt, err := l2Client.TransactionReceipt(ctx, withdrawal)
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal receipt: %w", err)
+	}
+	details, err := withdraw.DecodeWithdrawal(receipt)
+	if err != nil {
+		return fmt.Errorf("error decoding withdrawal: %w", err)
+	}
+	if !details.ReceiptSuccess {
+		fmt.Fprintf(os.Stderr, "The L2 transaction %s reverted, so no withdrawal was initiated and there is nothing to cancel.\n", withdrawal)
+		fmt.Fprintln(os.Stderr, "Any value sent with it stayed with the sender on L2, minus the L2 fees.")
+		return nil
+	}
+
+	state, err := newWithdrawalStateReader(l1Client, n)
+	if err != nil {
+		return err
+	}
+	status, err := state.get(details.Hash)
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal status: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Withdrawal hash:  %s\n", details.Hash)
+	fmt.Fprintf(os.Stderr, "Value:            %s\n", n.gasToken.FormatValue(details.Event.Value))
+	fmt.Fprintf(os.Stderr, "L1 recipient:     %s\n", details.Recipient())
+	fmt.Fprintf(os.Stderr, "Status:           %s\n", status)
+	fmt.Fprintln(os.Stderr)
+
+	if status == withdraw.StateFinalized {
+		fmt.Fprintln(os.Stderr, "This withdrawal is finalized: it was executed on L1 and can't be cancelled or reversed.")
+		fmt.Fprintln(os.Stderr, "The funds were delivered to the L1 recipient above. To move them back to L2, deposit them again.")
+		return nil
+	}
+
+	fmt.Fprintln(os.Stderr, "Withdrawals can't be cancelled once initiated. The L2 transaction has already removed the funds on L2:")
+	if details.MessengerCall != nil {
+		fmt.Fprintln(os.Stderr, "bridged ETH was burned, and bridged tokens were burned or locked by the L2 bridge.")
+	} else {
+		fmt.Fprintf(os.Stderr, "the %s sent to the L2ToL1MessagePasser was burned.\n", n.gasToken.Unit())
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "The only alternative to completing the withdrawal is to never prove or finalize it. Nothing is")
+	fmt.Fprintln(os.Stderr, "returned on L2 in that case: the funds stay burned or locked forever and are effectively lost.")
+	if status == withdraw.StateProven {
+		fmt.Fprintln(os.Stderr, "It has already been proven, so only finalizing remains to receive the funds on L1.")
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "To recover the funds, complete the withdrawal on L1 and, if they are needed on L2, deposit them again.")
+	if details.MessengerCall != nil {
+		fmt.Fprintln(os.Stderr, "If the L1 call of a bridge withdrawal fails when finalizing, the message can be replayed later through")
+		fmt.Fprintln(os.Stderr, "the L1CrossDomainMessenger, so finalizing does not put the funds at risk.")
+	}
+	return nil
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// checkStatus is the outcome of a single preflight check.
+type checkStatus string
+
+const (
+	checkPass checkStatus = "PASS"
+	checkWarn checkStatus = "WARN"
+	checkFail checkStatus = "FAIL"
+	checkSkip checkStatus = "SKIP"
+)
+
+// checkReport collects preflight check results and prints them as they are recorded.
+type checkReport struct {
+	failed bool
+}
+
+func (r *checkReport) add(status checkStatus, name string, format string, args ...interface{}) {
+	if status == checkFail {
+		r.failed = true
+	}
+	fmt.Printf("[%s] %-22s %s\n", status, name, fmt.Sprintf(format, args...))
+}
+
+// runCheck performs every read-only validation the tool can do for a withdrawal without signing anything,
+// printing a pass/fail report. It returns an error if any check failed. The address is used for the proof
+// status and balance checks, and may be the zero address if unknown.
+func runCheck(ctx context.Context, l1Rpc string, n network, ref withdrawalRef, address common.Address, l2HaltThreshold time.Duration, implementationsPath string) error {
+	r := &checkReport{}
+
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	l2Client, err := dialEth(ctx, n.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+
+	// L2 liveness
+	if head, age, err := withdraw.L2HeadAge(ctx, l2Client); err != nil {
+		r.add(checkFail, "L2 liveness", "%v", err)
+	} else if age > l2HaltThreshold {
+		r.add(checkWarn, "L2 liveness", "latest L2 block %d is %s old, the chain may be halted and proposals will stall", head, age.Round(time.Second))
+	} else {
+		r.add(checkPass, "L2 liveness", "latest L2 block %d is %s old", head, age.Round(time.Second))
+	}
+
+	// Contract implementations
+	if impls, err := inspectImplementations(ctx, l1Client, n, implementationsPath); err != nil {
+		r.add(checkWarn, "Implementations", "%v", err)
+	} else {
+		for _, impl := range impls {
+			switch {
+			case !impl.IsProxy():
+				r.add(checkPass, impl.name, "%s is not a proxy%s", impl.Address, formatVersion(impl.Version))
+			case impl.previous != (common.Address{}):
+				r.add(checkWarn, impl.name, "implementation changed from %s to %s%s since the last run, it may have been upgraded", impl.previous, impl.Implementation, formatVersion(impl.Version))
+			default:
+				r.add(checkPass, impl.name, "proxy %s, implementation %s%s", impl.Address, impl.Implementation, formatVersion(impl.Version))
+			}
+		}
+	}
+
+	// Guardian pause
+	if err := withdraw.CheckPaused(l1Client, common.HexToAddress(n.portalAddress)); errors.Is(err, withdraw.ErrWithdrawalsPaused) {
+		r.add(checkFail, "Portal paused", "%v", err)
+	} else if err != nil {
+		r.add(checkWarn, "Portal paused", "%v", err)
+	} else {
+		r.add(checkPass, "Portal paused", "withdrawals are not paused")
+	}
+
+	// L2 receipt and withdrawal event
+	receipt, err := l2Client.TransactionReceipt(ctx, ref.l2TxHash)
+	if err != nil {
+		r.add(checkFail, "L2 receipt", "%v", err)
+		return errors.New("preflight checks failed")
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		r.add(checkFail, "L2 receipt", "transaction reverted in L2 block %s", receipt.BlockNumber)
+		return errors.New("preflight checks failed")
+	}
+	r.add(checkPass, "L2 receipt", "included in L2 block %s", receipt.BlockNumber)
+
+	// the withdrawal checked must be selected among those of a tx that initiated several
+	receipt, err = withdraw.SelectOnlyWithdrawal(receipt, ref.logIndex)
+	if err != nil {
+		r.add(checkFail, "MessagePassed event", "%v, with --log-index", err)
+		return errors.New("preflight checks failed")
+	}
+	details, err := withdraw.DecodeWithdrawal(receipt)
+	if err != nil {
+		r.add(checkFail, "MessagePassed event", "%v", err)
+		return errors.New("preflight checks failed")
+	}
+	r.add(checkPass, "MessagePassed event", "withdrawal hash %s, value %s", details.Hash, n.gasToken.FormatValue(details.Event.Value))
+	if n.gasToken.IsCustom() {
+		r.add(checkWarn, "Gas token", "custom gas token %s (%s), the value is paid out in it on L1 instead of ETH", n.gasToken.Unit(), n.gasToken.Address)
+	}
+	call := details.L1Call()
+	method := call.Method
+	if method == "" {
+		method = "plain transfer"
+	}
+	r.add(checkPass, "L1 call", "%s on %s with %s, called by the %s", method, call.Target, n.gasToken.FormatValue(call.Value), call.Caller)
+	if sim, err := withdraw.SimulateFinalization(ctx, l1Client, common.HexToAddress(n.portalAddress), details, n.gasToken); err != nil {
+		r.add(checkWarn, "Finalize call", "%v", err)
+	} else if sim == nil {
+		r.add(checkSkip, "Finalize call", "the withdrawal's message could not be decoded")
+	} else if sim.Reverted() && sim.Replayable {
+		r.add(checkWarn, "Finalize call", "the message may fail when finalized and need replaying on L1: %s", sim.Error)
+	} else if sim.Reverted() {
+		r.add(checkWarn, "Finalize call", "direct withdrawal, finalizing may not deliver the value: %s", sim.Error)
+	} else {
+		r.add(checkPass, "Finalize call", "%s call to %s with %s succeeds in simulation", sim.Call, sim.Target, n.gasToken.FormatValue(details.Event.Value))
+	}
+
+	if n.faultProofs {
+		checkFaultProofs(r, ctx, l1Client, l2Client.Client(), n, details, address)
+	} else {
+		checkLegacy(r, ctx, l1Client, l2Client.Client(), n, details, address)
+	}
+
+	// Signer balance
+	if address == (common.Address{}) {
+		r.add(checkSkip, "Signer balance", "no signer or --address configured")
+	} else {
+		balance, err := l1Client.BalanceAt(ctx, address, nil)
+		if err != nil {
+			r.add(checkFail, "Signer balance", "%v", err)
+		} else if balance.Sign() == 0 {
+			r.add(checkFail, "Signer balance", "%s has no ETH to pay for gas", address)
+		} else {
+			r.add(checkPass, "Signer balance", "%s has %s ETH", address, withdraw.FormatEther(balance))
+		}
+	}
+
+	if r.failed {
+		return errors.New("preflight checks failed")
+	}
+	return nil
+}
+
+func checkFaultProofs(r *checkReport, ctx context.Context, l1Client *ethclient.Client, l2Client *rpc.Client, n network, details *withdraw.WithdrawalDetails, address common.Address) {
+	portal, err := bindingspreview.NewOptimismPortal2Caller(common.HexToAddress(n.portalAddress), l1Client)
+	if err != nil {
+		r.add(checkFail, "OptimismPortal", "%v", err)
+		return
+	}
+	factory, err := bindings.NewDisputeGameFactoryCaller(common.HexToAddress(n.disputeGameFactory), l1Client)
+	if err != nil {
+		r.add(checkFail, "DisputeGameFactory", "%v", err)
+		return
+	}
+
+	if delays, err := withdraw.ReadDelays(portal); err != nil {
+		r.add(checkWarn, "Portal delays", "%v", err)
+	} else {
+		r.add(checkPass, "Portal delays", "proof maturity delay %s, dispute game finality delay %s", delays.ProofMaturityDelay(), delays.DisputeGameFinalityDelay())
+	}
+
+	finalized, err := portal.FinalizedWithdrawals(&bind.CallOpts{}, details.Hash)
+	if err != nil {
+		r.add(checkFail, "Finalization status", "%v", err)
+	} else if finalized {
+		r.add(checkPass, "Finalization status", "withdrawal is already finalized")
+		return
+	} else {
+		r.add(checkPass, "Finalization status", "withdrawal is not finalized yet")
+	}
+
+	target, superRoots, err := withdraw.GameTarget(ctx, l1Client, ethclient.NewClient(l2Client), common.HexToAddress(n.portalAddress), details.L2BlockNumber)
+	if err != nil {
+		r.add(checkFail, "Dispute game", "%v", err)
+		return
+	}
+	covered := fmt.Sprintf("L2 block %s", details.L2BlockNumber)
+	if superRoots {
+		// super root games propose the timestamp of the L2 block rather than its number
+		covered = fmt.Sprintf("timestamp %s of L2 block %s", target, details.L2BlockNumber)
+	}
+	game, err := withdraw.FindEarliestGame(ctx, factory, portal, target)
+	if err != nil {
+		r.add(checkWarn, "Dispute game", "no game covers %s yet: %v", covered, err)
+		if e, err := withdraw.EstimateGameCoverage(factory, portal, target); err == nil {
+			r.add(checkWarn, "Dispute game ETA", "a covering game is expected around %s (in about %s, %d more games)", e.EstimatedAt.UTC().Format(time.RFC3339), e.Remaining(time.Now()).Round(time.Minute), e.GamesNeeded)
+		}
+	} else {
+		r.add(checkPass, "Dispute game", "game %s covers %s", game.Index, covered)
+		if superRoots {
+			r.add(checkSkip, "Output root", "game %s claims a super root, which is checked against the supervisor when proving", game.Index)
+		} else if err := withdraw.VerifyGame(ctx, l2Client, *game); errors.Is(err, withdraw.ErrOutputRootMismatch) {
+			r.add(checkFail, "Output root", "refusing to prove against game %s: %v", game.Index, err)
+		} else if err != nil {
+			r.add(checkWarn, "Output root", "%v", err)
+		} else {
+			r.add(checkPass, "Output root", "root claim of game %s matches the output root recomputed from L2", game.Index)
+		}
+	}
+
+	numSubmitters, err := portal.NumProofSubmitters(&bind.CallOpts{}, details.Hash)
+	if err != nil {
+		r.add(checkFail, "Proof submitters", "%v", err)
+		return
+	}
+	submitters := []common.Address{}
+	for i := int64(0); i < numSubmitters.Int64(); i++ {
+		submitter, err := portal.ProofSubmitters(&bind.CallOpts{}, details.Hash, big.NewInt(i))
+		if err != nil {
+			r.add(checkFail, "Proof submitters", "%v", err)
+			return
+		}
+		submitters = append(submitters, submitter)
+	}
+	if len(submitters) == 0 {
+		r.add(checkPass, "Proof status", "withdrawal has not been proven by anyone yet")
+	}
+	for _, submitter := range submitters {
+		proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, details.Hash, submitter)
+		if err != nil {
+			r.add(checkFail, "Proof status", "%v", err)
+			continue
+		}
+		r.add(checkPass, "Proof status", "proven by %s at %d against game %s", submitter, proven.Timestamp, proven.DisputeGameProxy)
+		if err := withdraw.CheckProofValidity(portal, l1Client, details.Hash, submitter); err != nil {
+			r.add(checkFail, "Proof validity", "%v", err)
+			continue
+		}
+		if e, err := withdraw.EstimateFinalization(portal, l1Client, details.Hash, submitter); err != nil {
+			r.add(checkFail, "Finalization ETA", "%v", err)
+		} else if remaining := e.Remaining(time.Now()); remaining > 0 {
+			estimated := ""
+			if e.GameContested {
+				estimated = ", at the latest, as the game's root claim was countered and it may resolve in favor of the challenger"
+			} else if e.GameResolutionEstimated {
+				estimated = ", assuming the game resolves unchallenged"
+			}
+			r.add(checkWarn, "Finalization ETA", "finalizable by %s at %s (in %s)%s", submitter, time.Unix(int64(e.FinalizableAt), 0).UTC(), remaining.Round(time.Second), estimated)
+		}
+		if final, err := withdraw.ProofFinalizedOnL1(ctx, l1Client, proven.Timestamp); err != nil {
+			r.add(checkWarn, "Proof L1 finality", "%v", err)
+		} else if !final {
+			r.add(checkWarn, "Proof L1 finality", "the proof by %s is not finalized on L1 yet, a reorg could restart its countdown", submitter)
+		}
+		if err := portal.CheckWithdrawal(&bind.CallOpts{}, details.Hash, submitter); err != nil {
+			r.add(checkWarn, "Finalizable", "not yet finalizable by %s: %v", submitter, err)
+		} else {
+			r.add(checkPass, "Finalizable", "can be finalized by %s now", submitter)
+		}
+	}
+	if address != (common.Address{}) && len(submitters) > 0 && !containsAddress(submitters, address) {
+		r.add(checkWarn, "Proof status", "%s has not proven this withdrawal, and must prove it before finalizing", address)
+	}
+}
+
+func checkLegacy(r *checkReport, ctx context.Context, l1Client *ethclient.Client, l2Client *rpc.Client, n network, details *withdraw.WithdrawalDetails, address common.Address) {
+	portal, err := bindings.NewOptimismPortalCaller(common.HexToAddress(n.portalAddress), l1Client)
+	if err != nil {
+		r.add(checkFail, "OptimismPortal", "%v", err)
+		return
+	}
+	oracle, err := bindings.NewL2OutputOracleCaller(common.HexToAddress(n.l2OOAddress), l1Client)
+	if err != nil {
+		r.add(checkFail, "L2OutputOracle", "%v", err)
+		return
+	}
+
+	finalized, err := portal.FinalizedWithdrawals(&bind.CallOpts{}, details.Hash)
+	if err != nil {
+		r.add(checkFail, "Finalization status", "%v", err)
+	} else if finalized {
+		r.add(checkPass, "Finalization status", "withdrawal is already finalized")
+		return
+	} else {
+		r.add(checkPass, "Finalization status", "withdrawal is not finalized yet")
+	}
+
+	latest, err := oracle.LatestBlockNumber(&bind.CallOpts{})
+	if err != nil {
+		r.add(checkFail, "L2 output", "%v", err)
+	} else if latest.Cmp(details.L2BlockNumber) < 0 {
+		r.add(checkWarn, "L2 output", "latest output is for L2 block %s, not yet past L2 block %s", latest, details.L2BlockNumber)
+	} else {
+		r.add(checkPass, "L2 output", "latest output for L2 block %s covers the withdrawal", latest)
+		checkLegacyOutputRoot(r, ctx, oracle, l2Client, details.L2BlockNumber)
+	}
+
+	proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, details.Hash)
+	if err != nil {
+		r.add(checkFail, "Proof status", "%v", err)
+	} else if proven.Timestamp.Sign() == 0 {
+		r.add(checkPass, "Proof status", "withdrawal has not been proven yet")
+	} else {
+		r.add(checkPass, "Proof status", "proven at %s against L2 output %s", proven.Timestamp, proven.L2OutputIndex)
+		if e, err := withdraw.EstimateLegacyFinalization(portal, oracle, details.Hash); err != nil {
+			r.add(checkFail, "Finalization ETA", "%v", err)
+		} else if remaining := e.Remaining(time.Now()); remaining > 0 {
+			r.add(checkWarn, "Finalizable", "finalizable at %s (in %s), once the finalization period has passed since the proof and the L2 output", time.Unix(int64(e.FinalizableAt), 0).UTC(), remaining.Round(time.Second))
+		} else {
+			r.add(checkPass, "Finalizable", "finalization period has passed since the proof and the L2 output, can be finalized now")
+		}
+	}
+}
+
+// checkLegacyOutputRoot checks the earliest L2 output covering the withdrawal's L2 block against the output root
+// recomputed from L2.
+func checkLegacyOutputRoot(r *checkReport, ctx context.Context, oracle *bindings.L2OutputOracleCaller, l2Client *rpc.Client, l2BlockNumber *big.Int) {
+	index, err := oracle.GetL2OutputIndexAfter(&bind.CallOpts{}, l2BlockNumber)
+	if err != nil {
+		r.add(checkWarn, "Output root", "%v", err)
+		return
+	}
+	output, err := oracle.GetL2Output(&bind.CallOpts{}, index)
+	if err != nil {
+		r.add(checkWarn, "Output root", "%v", err)
+		return
+	}
+	if err := withdraw.VerifyProposal(ctx, l2Client, output.L2BlockNumber, output.OutputRoot); errors.Is(err, withdraw.ErrOutputRootMismatch) {
+		r.add(checkFail, "Output root", "refusing to prove against L2 output %s: %v", index, err)
+	} else if err != nil {
+		r.add(checkWarn, "Output root", "%v", err)
+	} else {
+		r.add(checkPass, "Output root", "L2 output %s matches the output root recomputed from L2", index)
+	}
+}
+
+func containsAddress(addresses []common.Address, address common.Address) bool {
+	for _, a := range addresses {
+		if a == address {
+			return true
+		}
+	}
+	return false
+}
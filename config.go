@@ -0,0 +1,164 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	defaultConfigFile = ".withdrawer.toml"
+	envPrefix         = "WITHDRAWER_"
+)
+
+// defaultConfigPath returns ~/.withdrawer.toml, or an empty string if the home directory cannot be determined.
+func defaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultConfigFile)
+}
+
+// envVarName returns the environment variable that can be used to set the given flag,
+// e.g. WITHDRAWER_PRIVATE_KEY for --private-key.
+func envVarName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// applyEnv sets any flags in fs that were not explicitly set on the command line from their
+// WITHDRAWER_* environment variables. It must run before applyConfigFile so that environment
+// variables take precedence over config file values.
+func applyEnv(fs *flag.FlagSet) error {
+	values := make(map[string]interface{})
+	fs.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envVarName(f.Name)); ok {
+			values[f.Name] = v
+		}
+	})
+	return applyFlagValues(fs, values, "environment")
+}
+
+// applyConfigFile loads the TOML config file at path and applies its values to any flags in fs
+// that were not already set on the command line or from the environment.
+//
+// Top-level keys are shared settings. Named profiles live under [profiles.<name>] and override
+// the shared settings; the profile is chosen by the profile argument or the file's
+// "default-profile" key. Keys are flag names without the leading dashes, e.g.:
+//
+//	default-profile = "base"
+//	rpc = "https://ethereum-rpc.publicnode.com"
+//
+//	[profiles.base]
+//	network = "base-mainnet"
+//	ledger = true
+//
+// A missing file is only an error if it was explicitly requested.
+func applyConfigFile(fs *flag.FlagSet, path string, explicit bool, profile string) error {
+	values, err := readConfigFile(path, explicit, profile)
+	if err != nil {
+		return err
+	}
+	return applyFlagValues(fs, values, "config file")
+}
+
+// readConfigFile returns the settings of the TOML config file at path, with the profile's overriding the shared ones,
+// keyed by flag name. A missing file has no settings, unless it was explicitly requested.
+func readConfigFile(path string, explicit bool, profile string) (map[string]interface{}, error) {
+	if path == "" {
+		if profile != "" {
+			return nil, errors.New("--profile requires a config file")
+		}
+		return nil, nil
+	}
+
+	raw := make(map[string]interface{})
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		if errors.Is(err, os.ErrNotExist) && !explicit && profile == "" {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading config file %s: %w", path, err)
+	}
+
+	profiles := make(map[string]interface{})
+	if p, ok := raw["profiles"]; ok {
+		profiles, ok = p.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("config key \"profiles\" must be a table")
+		}
+		delete(raw, "profiles")
+	}
+	if profile == "" {
+		if p, ok := raw["default-profile"].(string); ok {
+			profile = p
+		}
+	}
+	delete(raw, "default-profile")
+
+	values := raw
+	if profile != "" {
+		p, ok := profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found in config file %s", profile, path)
+		}
+		overrides, ok := p.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("profile %q must be a table", profile)
+		}
+		for k, v := range overrides {
+			values[k] = v
+		}
+	}
+
+	return values, nil
+}
+
+// applyFlagValues sets each named flag in fs to the given value, unless the flag was already set on
+// the command line (or by a higher precedence source). Keys that don't correspond to a flag in fs are rejected.
+// Deprecated flag names are accepted as their replacement and recorded for reportDeprecatedFlags.
+func applyFlagValues(fs *flag.FlagSet, values map[string]interface{}, source string) error {
+	alreadySet := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		alreadySet[canonicalFlagName(f.Name)] = true
+	})
+
+	// apply in a stable order so errors are deterministic
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if fs.Lookup(k) == nil {
+			return fmt.Errorf("unknown setting %q in %s", k, source)
+		}
+		name := canonicalFlagName(k)
+		if alreadySet[name] {
+			continue
+		}
+		if name != k {
+			deprecatedFlagUses = append(deprecatedFlagUses, deprecatedFlagUse{name: k, source: source})
+			// the current name takes precedence over a deprecated one from the same source
+			if _, ok := values[name]; ok {
+				continue
+			}
+		}
+		vs, ok := values[k].([]interface{})
+		if !ok {
+			vs = []interface{}{values[k]}
+		}
+		for _, v := range vs {
+			if err := fs.Set(k, fmt.Sprint(v)); err != nil {
+				return fmt.Errorf("invalid value for %q in %s: %w", k, source, err)
+			}
+		}
+	}
+	return nil
+}
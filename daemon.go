@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+const (
+	// daemonHeartbeat is how often the daemon logs that it's still waiting for its withdrawal's next step.
+	daemonHeartbeat = time.Hour
+	// daemonUnhealthyFailures is the number of consecutive failed steps after which the daemon reports itself
+	// unhealthy.
+	daemonUnhealthyFailures = 3
+)
+
+// daemonConfig holds the settings of the daemon command.
+type daemonConfig struct {
+	HealthAddr   string          // Address to serve the health endpoint on (empty means no endpoint)
+	PollInterval time.Duration   // Time between checks on the withdrawal while waiting for its next step
+	MetricsPath  string          // Metrics file the daemon's prove and finalize runs are recorded to
+	IndexPath    string          // File the index of portal and factory events is checkpointed to (empty means no index)
+	ArchiveAfter time.Duration   // Time after which finalized withdrawals are archived in the state store (zero means never)
+	Reloader     *configReloader // Reloads the config file while the daemon runs (nil means never)
+}
+
+// daemonEntry is the progress of a daemon's withdrawal, as kept in the state store.
+type daemonEntry struct {
+	relayEntry
+	Network        string
+	WithdrawalHash common.Hash
+	LastCheck      time.Time
+}
+
+// daemonHealth is the status the daemon serves on its health endpoint, updated as it goes.
+type daemonHealth struct {
+	mu     sync.Mutex
+	status daemonStatus
+}
+
+// daemonStatus is the body of the health endpoint's responses.
+type daemonStatus struct {
+	Healthy        bool            `json:"healthy"` // Whether the last steps didn't fail
+	L2TxHash       common.Hash     `json:"l2TxHash"`
+	LogIndex       *uint           `json:"logIndex,omitempty"`
+	WithdrawalHash common.Hash     `json:"withdrawalHash"`
+	State          withdraw.State  `json:"state,omitempty"`
+	Next           withdraw.Action `json:"next,omitempty"`
+	NextAttempt    time.Time       `json:"nextAttempt"`
+	LastCheck      time.Time       `json:"lastCheck"`
+	Failures       int             `json:"failures,omitempty"`
+	LastError      string          `json:"lastError,omitempty"`
+}
+
+func (h *daemonHealth) update(ref withdrawalRef, e *daemonEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.status = daemonStatus{
+		Healthy:        e.Failures < daemonUnhealthyFailures,
+		L2TxHash:       ref.l2TxHash,
+		LogIndex:       ref.logIndex,
+		WithdrawalHash: e.WithdrawalHash,
+		State:          e.State,
+		NextAttempt:    e.NextAttempt,
+		LastCheck:      e.LastCheck,
+		Failures:       e.Failures,
+		LastError:      e.LastError,
+	}
+	if e.State != "" {
+		h.status.Next = e.State.NextAction()
+	}
+}
+
+// ServeHTTP responds with the daemon's status as JSON, with a 503 status code once it's unhealthy.
+func (h *daemonHealth) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	h.mu.Lock()
+	status := h.status
+	h.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if !status.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// serveHealth serves the daemon's health endpoint on addr until ctx is cancelled.
+func serveHealth(ctx context.Context, addr string, health *daemonHealth) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening on --health-addr: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", health)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error("Health endpoint stopped", "error", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+	log.Info("Serving health endpoint", "url", fmt.Sprintf("http://%s/healthz", listener.Addr()))
+	return nil
+}
+
+// runDaemon keeps running until the withdrawal is finalized: it proves it once provable, sleeps until its proof
+// matures and finalizes it, retrying failed steps with a backoff. While waiting, it checks on the withdrawal every poll
+// interval, in case someone else finalized it. Its progress is kept in the state store, so a restarted daemon resumes
+// waiting where it left off, and its status is served on the health endpoint, if any.
+func runDaemon(ctx context.Context, cfg runSettings, ref withdrawalRef, dc daemonConfig) error {
+	if cfg.store == nil {
+		return errors.New("missing --state-file")
+	}
+	l1Client, err := dialEth(ctx, cfg.l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	stateReader, err := newWithdrawalStateReader(l1Client, cfg.network)
+	if err != nil {
+		return err
+	}
+	if stateReader.index, err = openIndex(ctx, l1Client, cfg.network, dc.IndexPath); err != nil {
+		return err
+	}
+	l2Client, err := dialEth(ctx, cfg.network.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	receipt, err := l2Client.TransactionReceipt(ctx, ref.l2TxHash)
+	l2Client.Close()
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal receipt: %w", err)
+	}
+	receipt, err = withdraw.SelectWithdrawal(receipt, ref.logIndex)
+	if err != nil {
+		return err
+	}
+	details, err := withdraw.DecodeWithdrawal(receipt)
+	if err != nil {
+		return fmt.Errorf("error decoding withdrawal: %w", err)
+	}
+	hash := details.Hash
+
+	key := ref.journalKey()
+	e, err := cfg.store.loadDaemon(key)
+	if err != nil {
+		return err
+	}
+	if e != nil {
+		log.Info("Resuming daemon", "withdrawal", ref, "state", e.State, "nextAttempt", e.NextAttempt.UTC(), "stateFile", cfg.store.path)
+	} else {
+		e = &daemonEntry{
+			relayEntry: relayEntry{
+				L2TxHash:    ref.l2TxHash,
+				LogIndex:    details.LogIndex,
+				L2Block:     details.L2BlockNumber.Uint64(),
+				NextAttempt: cfg.timing.Now(),
+			},
+			Network:        cfg.networkName,
+			WithdrawalHash: hash,
+		}
+		log.Info("Starting daemon", "withdrawal", ref, "withdrawalHash", hash, "stateFile", cfg.store.path)
+	}
+
+	health := &daemonHealth{}
+	health.update(ref, e)
+	if dc.HealthAddr != "" {
+		if err := serveHealth(ctx, dc.HealthAddr, health); err != nil {
+			return err
+		}
+	}
+	store := &daemonStore{store: cfg.store, key: key, hash: hash, entry: e, saved: func() { health.update(ref, e) }}
+	stepper := &withdrawalStepper{cfg: &cfg, stateReader: stateReader, ref: &ref, metricsPath: dc.MetricsPath}
+	scheduler, err := withdraw.NewStepScheduler(store, stepper.step, withdraw.SchedulerOptions{
+		PollInterval: dc.PollInterval,
+		Timing:       cfg.timing,
+		OnStep:       stepper.finish,
+	})
+	if err != nil {
+		return err
+	}
+
+	lastHeartbeat := cfg.timing.Now()
+	for {
+		if games := syncIndex(ctx, stateReader.index); games > 0 {
+			scheduler.ProvableSoon()
+		}
+		now := cfg.timing.Now()
+		finalized := false
+		if w, ok := scheduler.Withdrawals()[hash]; ok && !w.NextAttempt.After(now) {
+			e.LastCheck = now
+			scheduler.RunDue(ctx)
+			_, tracked := scheduler.Withdrawals()[hash]
+			finalized = !tracked
+		} else if s, err := stateReader.get(hash); err != nil {
+			log.Warn("Unable to check on the withdrawal", "error", err)
+		} else {
+			e.LastCheck, e.State = now, s
+			finalized = s == withdraw.StateFinalized
+			if now.Sub(lastHeartbeat) >= daemonHeartbeat {
+				log.Info("Waiting for the withdrawal's next step", "withdrawal", ref, "state", s, "nextAttempt", e.NextAttempt.UTC(),
+					"remaining", e.NextAttempt.Sub(now).Round(time.Second))
+				lastHeartbeat = now
+			}
+			if err := store.save(); err != nil {
+				log.Error("Error saving daemon state", "error", err)
+			}
+		}
+		if finalized {
+			log.Info("Withdrawal finalized, stopping daemon", "withdrawal", ref, "withdrawalHash", hash)
+			health.update(ref, e)
+			if stateReader.index != nil {
+				stateReader.index.Forget(hash)
+			}
+			if err := cfg.store.removeDaemon(key); err != nil {
+				log.Error("Error removing daemon state", "error", err)
+			}
+			return nil
+		}
+		archiveWithdrawals(cfg.store, now, dc.ArchiveAfter)
+
+		wait := min(e.NextAttempt.Sub(cfg.timing.Now()), dc.PollInterval)
+		hup := false
+		select {
+		case <-ctx.Done():
+			log.Info("Stopping daemon, run it again to resume", "withdrawal", ref, "state", e.State, "nextAttempt", e.NextAttempt.UTC())
+			return nil
+		case <-cfg.timing.After(max(wait, 0)):
+		case <-dc.Reloader.hangups():
+			hup = true
+		}
+		if f := dc.Reloader.reload(ctx, &cfg, hup); f != nil {
+			dc.PollInterval, dc.ArchiveAfter = f.pollInterval, f.archiveAfter
+			scheduler.SetPollInterval(dc.PollInterval)
+		}
+	}
+}
+
+// daemonStore keeps the daemon's withdrawal, as its scheduler tracks it, in the state store.
+type daemonStore struct {
+	store *stateStore
+	key   common.Hash // Key of the daemon's progress in the state store
+	hash  common.Hash // Withdrawal hash the scheduler tracks the withdrawal under
+	entry *daemonEntry
+	saved func() // Called before the progress is saved, e.g. to update the health endpoint
+}
+
+func (s *daemonStore) Load() (map[common.Hash]*withdraw.ScheduledWithdrawal, error) {
+	e := s.entry.relayEntry
+	return map[common.Hash]*withdraw.ScheduledWithdrawal{s.hash: &e}, nil
+}
+
+// Save saves the daemon's progress, unless the withdrawal is finalized, which stops the daemon.
+func (s *daemonStore) Save(withdrawals map[common.Hash]*withdraw.ScheduledWithdrawal) error {
+	w, ok := withdrawals[s.hash]
+	if !ok {
+		return nil
+	}
+	s.entry.relayEntry = *w
+	return s.save()
+}
+
+// save saves the daemon's progress, along with the last check on the withdrawal.
+func (s *daemonStore) save() error {
+	s.saved()
+	return s.store.saveDaemon(s.key, s.entry)
+}
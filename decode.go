@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// runDecode prints the full withdrawal message emitted by the given L2 transaction: the one at logIndex if set, or
+// every one of them with all, for transactions that initiated several.
+func runDecode(ctx context.Context, l2Rpc string, withdrawal common.Hash, logIndex *uint, all bool) error {
+	l2Client, err := dialEth(ctx, l2Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+
+	receipt, err := l2Client.TransactionReceipt(ctx, withdrawal)
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal receipt: %w", err)
+	}
+
+	indexes := withdraw.MessagePassedLogIndexes(receipt)
+	if !all || len(indexes) <= 1 {
+		if logIndex == nil && len(indexes) > 1 {
+			fmt.Printf("The L2 tx initiated %d withdrawals, at log indexes %v, showing the first (select one with --log-index or show them all with --all)\n\n", len(indexes), indexes)
+		}
+		return decodeWithdrawalAt(receipt, logIndex)
+	}
+	for i, index := range indexes {
+		if i > 0 {
+			fmt.Println()
+		}
+		if err := decodeWithdrawalAt(receipt, &index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeWithdrawalAt prints the withdrawal of the receipt's MessagePassed event at logIndex (nil means the first).
+func decodeWithdrawalAt(receipt *types.Receipt, logIndex *uint) error {
+	selected, err := withdraw.SelectWithdrawal(receipt, logIndex)
+	if err != nil {
+		return err
+	}
+	details, err := withdraw.DecodeWithdrawal(selected)
+	if err != nil {
+		return fmt.Errorf("error decoding withdrawal: %w", err)
+	}
+
+	ev := details.Event
+	fmt.Printf("L2 tx hash:       %s\n", receipt.TxHash)
+	fmt.Printf("Log index:        %d\n", details.LogIndex)
+	fmt.Printf("L2 block:         %s\n", details.L2BlockNumber)
+	fmt.Printf("L2 tx succeeded:  %t\n", details.ReceiptSuccess)
+	fmt.Printf("Withdrawal hash:  %s\n", details.Hash)
+	if details.Direct {
+		fmt.Printf("Type:             direct L2ToL1MessagePasser withdrawal (the target is called with the value on L1)\n")
+	} else {
+		fmt.Printf("Type:             CrossDomainMessenger message\n")
+	}
+	fmt.Printf("Nonce:            %s\n", ev.Nonce)
+	fmt.Printf("Sender:           %s\n", ev.Sender)
+	fmt.Printf("Target:           %s\n", ev.Target)
+	fmt.Printf("Value:            %s ETH (%s wei)\n", withdraw.FormatEther(ev.Value), ev.Value)
+	fmt.Printf("Gas limit:        %s\n", ev.GasLimit)
+	fmt.Printf("Data:             %s\n", hexutil.Encode(ev.Data))
+	if details.Hash != ev.WithdrawalHash {
+		fmt.Printf("WARNING: computed withdrawal hash does not match the event (%s)\n", common.Hash(ev.WithdrawalHash))
+	}
+
+	if msg := details.MessengerCall; msg != nil {
+		fmt.Println()
+		fmt.Println("CrossDomainMessenger message:")
+		fmt.Printf("  Nonce:          %s (version %d)\n", msg.Nonce, msg.Version)
+		fmt.Printf("  Sender:         %s\n", msg.Sender)
+		fmt.Printf("  Target:         %s\n", msg.Target)
+		fmt.Printf("  Value:          %s ETH (%s wei)\n", withdraw.FormatEther(msg.Value), msg.Value)
+		fmt.Printf("  Min gas limit:  %s\n", msg.MinGasLimit)
+		fmt.Printf("  Message:        %s\n", hexutil.Encode(msg.Message))
+	}
+
+	call := details.L1Call()
+	fmt.Println()
+	fmt.Println("Executed on L1 when finalized:")
+	caller := "OptimismPortal"
+	if call.Caller == withdraw.L1CallerMessenger {
+		caller = "L1CrossDomainMessenger"
+	}
+	fmt.Printf("  Called by:      %s\n", caller)
+	fmt.Printf("  Target:         %s\n", call.Target)
+	fmt.Printf("  Value:          %s ETH (%s wei)\n", withdraw.FormatEther(call.Value), call.Value)
+	if call.Method != "" {
+		fmt.Printf("  Method:         %s\n", call.Method)
+	} else {
+		fmt.Printf("  Method:         none (plain transfer)\n")
+	}
+	fmt.Printf("  Gas limit:      %s\n", call.GasLimit)
+
+	if transfer := details.BridgeTransfer(); transfer != nil {
+		fmt.Println()
+		fmt.Printf("%s bridge transfer:\n", transfer.Kind)
+		fmt.Printf("  From:           %s\n", transfer.From)
+		fmt.Printf("  To:             %s\n", transfer.To)
+		switch transfer.Kind {
+		case withdraw.BridgeETH:
+			fmt.Printf("  Amount:         %s ETH (%s wei)\n", withdraw.FormatEther(transfer.Amount), transfer.Amount)
+		case withdraw.BridgeERC20:
+			fmt.Printf("  L1 token:       %s\n", transfer.L1Token)
+			fmt.Printf("  L2 token:       %s\n", transfer.L2Token)
+			fmt.Printf("  Amount:         %s (base units)\n", transfer.Amount)
+		case withdraw.BridgeERC721:
+			fmt.Printf("  L1 token:       %s\n", transfer.L1Token)
+			fmt.Printf("  L2 token:       %s\n", transfer.L2Token)
+			fmt.Printf("  Token ID:       %s\n", transfer.TokenID)
+		}
+		if len(transfer.ExtraData) > 0 {
+			fmt.Printf("  Extra data:     %s\n", transfer.ExtraData)
+		}
+	}
+	return nil
+}
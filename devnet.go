@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// devnetNetwork is the name of the local devnet preset, whose contracts come from deployment artifacts instead of
+// being built in.
+const devnetNetwork = "devnet"
+
+const (
+	// defaultDevnetAddresses is where the op-e2e devnet writes its L1 deployment addresses.
+	defaultDevnetAddresses = ".devnet/addresses.json"
+	// defaultDevnetL2RPC is the L2 RPC of the op-e2e devnet.
+	defaultDevnetL2RPC = "http://127.0.0.1:9545"
+)
+
+// devnetContractKeys lists the keys each contract may have in a deployment artifact, proxies first.
+var devnetContractKeys = map[string][]string{
+	"portal": {"OptimismPortalProxy", "OptimismPortal"},
+	"dgf":    {"DisputeGameFactoryProxy", "DisputeGameFactory"},
+	"l2oo":   {"L2OutputOracleProxy", "L2OutputOracle"},
+}
+
+// loadDevnet builds the devnet network from an addresses.json deployment artifact, as written by the op-e2e devnet
+// and Kurtosis deployments: a JSON object mapping contract names to addresses. Keys are matched case-insensitively
+// and other entries are ignored. The chain IDs are left unknown, as every devnet picks its own, so they aren't
+// checked.
+func loadDevnet(path string, l2Rpc string) (network, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return network{}, fmt.Errorf("error reading devnet addresses: %w", err)
+	}
+	var entries map[string]interface{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return network{}, fmt.Errorf("error decoding devnet addresses %s: %w", path, err)
+	}
+	addresses := make(map[string]string)
+	for key, value := range entries {
+		if s, ok := value.(string); ok && common.IsHexAddress(s) && common.HexToAddress(s) != (common.Address{}) {
+			addresses[strings.ToLower(key)] = s
+		}
+	}
+	lookup := func(contract string) string {
+		for _, key := range devnetContractKeys[contract] {
+			if address, ok := addresses[strings.ToLower(key)]; ok {
+				return address
+			}
+		}
+		return ""
+	}
+
+	if l2Rpc == "" {
+		l2Rpc = defaultDevnetL2RPC
+	}
+	n := network{
+		l2RPC:              l2Rpc,
+		portalAddress:      lookup("portal"),
+		disputeGameFactory: lookup("dgf"),
+		l2OOAddress:        lookup("l2oo"),
+		devnet:             true,
+	}
+	if n.portalAddress == "" {
+		return network{}, fmt.Errorf("devnet addresses %s have no OptimismPortalProxy", path)
+	}
+	// the withdrawal flow is detected from the portal and missing contracts are discovered from it, this is only
+	// the fallback
+	n.faultProofs = n.disputeGameFactory != ""
+	return n, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// proofExporter is implemented by the withdrawers that can export the withdrawal's proof.
+type proofExporter interface {
+	ExportProof() (*withdraw.ExportedProof, error)
+}
+
+// runExportProof computes the withdrawal's proof against the dispute game it would be proven against, with the same
+// game selection and checks as proving, and writes it as JSON to the file at path, or stdout if path is empty,
+// without sending anything.
+func runExportProof(ctx context.Context, cfg runSettings, ref withdrawalRef, path string) error {
+	// the withdrawer only simulates, and has nothing to notify about
+	cfg.dryRun, cfg.notifier = true, nil
+	withdrawer, closeClients, err := CreateWithdrawHelper(ctx, cfg, ref)
+	if err != nil {
+		return fmt.Errorf("error creating withdrawer: %w", err)
+	}
+	defer closeClients()
+	exporter, ok := withdrawer.(proofExporter)
+	if !ok {
+		return errors.New("exporting proofs is only supported with fault proofs")
+	}
+	if cfg.proverConfig.Prover != nil {
+		return errors.New("exporting proofs is not supported with --prover-url, as the prover service builds the proof")
+	}
+
+	state, err := withdraw.CurrentState(withdrawer)
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal state: %w", err)
+	}
+	switch state {
+	case withdraw.StateFinalized:
+		return errors.New("withdrawal is already finalized")
+	case withdraw.StateProven:
+		log.Warn("Withdrawal is already proven, exporting a new proof anyway", "withdrawal", ref)
+	}
+	if err := withdrawer.CheckIfProvable(); err != nil {
+		return fmt.Errorf("withdrawal is not provable: %w", err)
+	}
+
+	proof, err := exporter.ExportProof()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(proof, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing proof file %s: %w", path, err)
+	}
+	log.Info("Exported withdrawal proof", "withdrawal", ref, "withdrawalHash", proof.WithdrawalHash, "gameIndex", proof.Game.GameIndex,
+		"gameAddress", proof.Game.GameAddress, "proofFile", path)
+	return nil
+}
+
+// readExportedProof reads a proof written by the export-proof command, to be proven with.
+func readExportedProof(path string) (*withdraw.ExportedProof, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading proof file %s: %w", path, err)
+	}
+	var proof withdraw.ExportedProof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return nil, fmt.Errorf("error decoding proof file %s: %w", path, err)
+	}
+	return &proof, nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// defaultGamesLimit is the number of games the games command lists unless --games-limit is given.
+const defaultGamesLimit = 20
+
+// gameEntry is a line of the games output.
+type gameEntry struct {
+	withdraw.GameInfo
+	Selected bool `json:"selected,omitempty"` // Whether the withdrawal would be proven against this game
+}
+
+// runGames lists the latest dispute games of the portal's respected game type, newest first, starting at factory
+// index before (nil means the latest game). Each game is printed to stdout as a JSON line and to stderr as a table
+// row. If a withdrawal is given, the game it would be proven against is highlighted.
+func runGames(ctx context.Context, l1Rpc string, n network, withdrawal *common.Hash, before *big.Int, limit int) error {
+	if !n.faultProofs {
+		return errors.New("the network does not use fault proofs, so there are no dispute games to list")
+	}
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	portal, err := bindingspreview.NewOptimismPortal2Caller(common.HexToAddress(n.portalAddress), l1Client)
+	if err != nil {
+		return fmt.Errorf("error binding OptimismPortal2 contract: %w", err)
+	}
+	factory, err := bindings.NewDisputeGameFactoryCaller(common.HexToAddress(n.disputeGameFactory), l1Client)
+	if err != nil {
+		return fmt.Errorf("error binding DisputeGameFactory contract: %w", err)
+	}
+
+	var selected *big.Int
+	if withdrawal != nil {
+		l2Client, err := dialEth(ctx, n.l2RPC)
+		if err != nil {
+			return fmt.Errorf("error dialing L2 client: %w", err)
+		}
+		defer l2Client.Close()
+		selected, err = selectedGame(ctx, l1Client, l2Client, factory, portal, common.HexToAddress(n.portalAddress), *withdrawal)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Withdrawal %s cannot be proven yet: %v\n", *withdrawal, err)
+		} else {
+			fmt.Fprintf(os.Stderr, "Withdrawal %s would be proven against game %s (marked with *)\n", *withdrawal, selected)
+		}
+	}
+
+	games, err := withdraw.ListGames(factory, portal, l1Client, before, limit)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	fmt.Fprintf(os.Stderr, "  %-8s %-12s %-20s %-16s %-20s %s\n", "index", "l2 block", "created", "status", "resolved", "notes")
+	for _, game := range games {
+		entry := gameEntry{GameInfo: game, Selected: selected != nil && selected.Cmp(game.Index) == 0}
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+		printGame(entry)
+	}
+	if len(games) > 0 {
+		oldest := games[len(games)-1].Index
+		if oldest.Sign() > 0 {
+			fmt.Fprintf(os.Stderr, "Pass --games-before %s to list older games\n", new(big.Int).Sub(oldest, common.Big1))
+		}
+	}
+	return nil
+}
+
+// printGame writes the game to stderr as a table row.
+func printGame(entry gameEntry) {
+	formatTime := func(t uint64) string {
+		if t == 0 {
+			return "-"
+		}
+		return time.Unix(int64(t), 0).UTC().Format("2006-01-02 15:04:05")
+	}
+	mark := " "
+	if entry.Selected {
+		mark = "*"
+	}
+	notes := ""
+	switch {
+	case entry.Rejection != "":
+		notes = entry.Rejection
+	case entry.Retired:
+		notes = "created before the respected game type was set, the portal rejects it"
+	}
+	fmt.Fprintf(os.Stderr, "%s %-8s %-12s %-20s %-16s %-20s %s\n", mark, entry.Index, entry.L2Block, formatTime(entry.CreatedAt), entry.Status, formatTime(entry.ResolvedAt), notes)
+}
+
+// selectedGame returns the index of the game the withdrawal would be proven against, found the same way as when
+// proving it.
+func selectedGame(ctx context.Context, l1Client, l2Client *ethclient.Client, factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, portalAddress common.Address, withdrawal common.Hash) (*big.Int, error) {
+	receipt, err := l2Client.TransactionReceipt(ctx, withdrawal)
+	if err != nil {
+		return nil, fmt.Errorf("error querying withdrawal tx receipt: %w", err)
+	}
+	l2BlockNumber, _, err := withdraw.GameTarget(ctx, l1Client, l2Client, portalAddress, receipt.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	game, err := withdraw.FindEarliestGame(ctx, factory, portal, l2BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	game, err = withdraw.NextUsableGame(factory, portal, l1Client, game, l2BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	return game.Index, nil
+}
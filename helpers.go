@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// portalKind identifies the withdrawal semantics of an OptimismPortal generation, which decide how withdrawals are
+// proven and finalized against it.
+type portalKind string
+
+const (
+	portalL2OutputOracle portalKind = "l2-output-oracle" // OptimismPortal, proving against the L2OutputOracle's outputs
+	portalFaultProofs    portalKind = "fault-proofs"     // OptimismPortal2, proving against dispute games, or super roots on interop portals
+)
+
+// helperDeps are the clients and settings shared by the WithdrawHelpers of every portal kind.
+type helperDeps struct {
+	runSettings
+	ctx            context.Context
+	l1Client       *ethclient.Client
+	l2Client       *rpc.Client
+	verifyL2Client *rpc.Client
+	backend        bind.ContractBackend
+	opts           *bind.TransactOpts
+	withdrawal     common.Hash
+	logIndex       *uint
+	proofSources   []withdraw.ProofSource
+	clients        *rpcClients // Clients dialed for the withdrawer, closed once it's done with
+}
+
+// rpcClients are the clients dialed for a withdrawer. The relay and daemon create a withdrawer on every step, so they
+// must be closed rather than left to the end of the process.
+type rpcClients []*rpc.Client
+
+// add adds client to the clients to close.
+func (c *rpcClients) add(client *rpc.Client) {
+	*c = append(*c, client)
+}
+
+// Close closes the clients.
+func (c rpcClients) Close() {
+	for _, client := range c {
+		client.Close()
+	}
+}
+
+// helperFactory binds the portal's contracts and creates the WithdrawHelper for a portal kind.
+type helperFactory func(d helperDeps) (withdraw.WithdrawHelper, error)
+
+// helperFactories maps each supported portal kind to the factory of its WithdrawHelper. Supporting a new portal
+// generation means adding its kind and factory here, and having portalKindOf select it.
+var helperFactories = map[portalKind]helperFactory{
+	portalL2OutputOracle: newWithdrawer,
+	portalFaultProofs:    newFPWithdrawer,
+}
+
+// portalKindOf returns the kind of the network's portal, as detected from the portal or set with --fault-proofs.
+func portalKindOf(n network) portalKind {
+	if n.faultProofs {
+		return portalFaultProofs
+	}
+	return portalL2OutputOracle
+}
+
+// dialProofSources dials the L2 RPCs to fetch the withdrawal proof from before the L2 RPC, checking they serve the same
+// chain, and adds them to clients.
+func dialProofSources(ctx context.Context, l2Client *rpc.Client, urls []string, clients *rpcClients) ([]withdraw.ProofSource, error) {
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	l2ChainID, err := ethclient.NewClient(l2Client).ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying L2 chain ID: %w", err)
+	}
+	var sources []withdraw.ProofSource
+	for i, url := range urls {
+		client, err := dialRPC(ctx, url)
+		if err != nil {
+			return nil, fmt.Errorf("Error dialing proof L2 client %s: %w", url, err)
+		}
+		clients.add(client)
+		chainID, err := ethclient.NewClient(client).ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error querying proof L2 chain ID of %s: %w", url, err)
+		}
+		if chainID.Cmp(l2ChainID) != 0 {
+			return nil, fmt.Errorf("proof L2 RPC %s is for chain %s, but the L2 RPC is for chain %s", url, chainID, l2ChainID)
+		}
+		// named by position, as RPC urls often embed API keys
+		sources = append(sources, withdraw.NewRPCProofSource(fmt.Sprintf("proof RPC %d", i+1), client))
+	}
+	log.Info("Fetching the withdrawal proof from the proof L2 RPCs first", "count", len(sources))
+	return sources, nil
+}
+
+// newWithdrawer creates the WithdrawHelper of portals proving against the L2OutputOracle.
+func newWithdrawer(d helperDeps) (withdraw.WithdrawHelper, error) {
+	portal, err := bindings.NewOptimismPortal(common.HexToAddress(d.network.portalAddress), d.backend)
+	if err != nil {
+		return nil, fmt.Errorf("Error binding OptimismPortal contract: %w", err)
+	}
+
+	l2oo, err := bindings.NewL2OutputOracle(common.HexToAddress(d.network.l2OOAddress), d.backend)
+	if err != nil {
+		return nil, fmt.Errorf("Error binding L2OutputOracle contract: %w", err)
+	}
+
+	return &withdraw.Withdrawer{
+		Ctx:             d.ctx,
+		L1Client:        d.l1Client,
+		L2Client:        d.l2Client,
+		L2TxHash:        d.withdrawal,
+		Portal:          portal,
+		Oracle:          l2oo,
+		Opts:            d.opts,
+		GasMultiplier:   d.gasConfig.GasMultiplier,
+		UserGasLimit:    d.gasConfig.GasLimit,
+		DryRun:          d.dryRun,
+		ProveTimeout:    d.txConfig.ProveTimeout,
+		FinalizeTimeout: d.txConfig.FinalizeTimeout,
+		Confirmations:   d.txConfig.Confirmations,
+		WaitFinalized:   d.txConfig.WaitFinalized,
+		Faults:          d.faults,
+		VerifyL2Client:  d.verifyL2Client,
+		ETHUSD:          d.ethUSD,
+		PortalAddress:   common.HexToAddress(d.network.portalAddress),
+		BaseFeeMax:      d.gasConfig.BaseFeeMax,
+		MinBalance:      d.gasConfig.MinBalance,
+		Quorum:          d.quorum,
+		GasToken:        d.network.gasToken,
+		Notifier:        d.notifier,
+		Prover:          d.proverConfig.Prover,
+		L2OutputIndex:   d.proverConfig.L2OutputIndex,
+		ProofSources:    d.proofSources,
+		LogIndex:        d.logIndex,
+		Timing:          d.timing,
+	}, nil
+}
+
+// newFPWithdrawer creates the WithdrawHelper of portals proving against dispute games.
+func newFPWithdrawer(d helperDeps) (withdraw.WithdrawHelper, error) {
+	var supervisor *rpc.Client
+	if d.proverConfig.SupervisorRPC != "" {
+		var err error
+		supervisor, err = dialRPC(d.ctx, d.proverConfig.SupervisorRPC)
+		if err != nil {
+			return nil, fmt.Errorf("Error dialing supervisor client: %w", err)
+		}
+		d.clients.add(supervisor)
+	}
+
+	portal, err := bindingspreview.NewOptimismPortal2(common.HexToAddress(d.network.portalAddress), d.backend)
+	if err != nil {
+		return nil, fmt.Errorf("Error binding OptimismPortal2 contract: %w", err)
+	}
+
+	dgf, err := bindings.NewDisputeGameFactory(common.HexToAddress(d.network.disputeGameFactory), d.backend)
+	if err != nil {
+		return nil, fmt.Errorf("Error binding DisputeGameFactory contract: %w", err)
+	}
+	// the quorum's providers also share the load of large dispute game searches
+	var dgfShards []*bindings.DisputeGameFactoryCaller
+	if d.quorum != nil {
+		for _, client := range d.quorum.Clients {
+			shard, err := bindings.NewDisputeGameFactoryCaller(common.HexToAddress(d.network.disputeGameFactory), client)
+			if err != nil {
+				return nil, fmt.Errorf("Error binding DisputeGameFactory contract: %w", err)
+			}
+			dgfShards = append(dgfShards, shard)
+		}
+	}
+
+	return &withdraw.FPWithdrawer{
+		Ctx:             d.ctx,
+		L1Client:        d.l1Client,
+		L2Client:        d.l2Client,
+		L2TxHash:        d.withdrawal,
+		Portal:          portal,
+		Factory:         dgf,
+		FactoryShards:   dgfShards,
+		Backend:         d.backend,
+		Opts:            d.opts,
+		GasMultiplier:   d.gasConfig.GasMultiplier,
+		UserGasLimit:    d.gasConfig.GasLimit,
+		DryRun:          d.dryRun,
+		ProveTimeout:    d.txConfig.ProveTimeout,
+		FinalizeTimeout: d.txConfig.FinalizeTimeout,
+		Confirmations:   d.txConfig.Confirmations,
+		WaitFinalized:   d.txConfig.WaitFinalized,
+		Faults:          d.faults,
+		VerifyL2Client:  d.verifyL2Client,
+		ETHUSD:          d.ethUSD,
+		PortalAddress:   common.HexToAddress(d.network.portalAddress),
+		BaseFeeMax:      d.gasConfig.BaseFeeMax,
+		MinBalance:      d.gasConfig.MinBalance,
+		Quorum:          d.quorum,
+		GasToken:        d.network.gasToken,
+		Notifier:        d.notifier,
+		Prover:          d.proverConfig.Prover,
+		ProofSubmitter:  d.proverConfig.ProofSubmitter,
+		GameType:        d.proverConfig.GameType,
+		GameIndex:       d.proverConfig.GameIndex,
+		Supervisor:      supervisor,
+		ProofSources:    d.proofSources,
+		LogIndex:        d.logIndex,
+		ImportedProof:   d.proverConfig.ImportedProof,
+		Timing:          d.timing,
+	}, nil
+}
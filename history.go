@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// runHistory reconstructs the withdrawals proven on the network since fromBlock that the address sent on L2 or
+// submitted a proof of, from the portal's events. Each is printed to stdout as a JSON line and to stderr as a table
+// row with its latest proof and its finalization, for reconciliation, followed by a count of those finalized.
+func runHistory(ctx context.Context, l1Rpc string, n network, address common.Address, fromBlock uint64) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	head, err := l1Client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L1 head: %w", err)
+	}
+	if fromBlock > head {
+		return fmt.Errorf("--from-block %d is past the L1 head %d", fromBlock, head)
+	}
+
+	entries, err := withdraw.FindHistory(ctx, l1Client, common.HexToAddress(n.portalAddress), address, fromBlock, head)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	finalized := 0
+	fmt.Fprintf(os.Stderr, "  %-66s  %-66s  %-20s  %-66s  %-20s\n", "withdrawal hash", "prove tx", "proven at", "finalize tx", "finalized at")
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		proof := e.Proofs[len(e.Proofs)-1]
+		finalizeTx, finalizedAt := "-", "-"
+		if e.FinalizeTxHash != nil {
+			finalized++
+			finalizeTx, finalizedAt = e.FinalizeTxHash.Hex(), e.FinalizedAt.Format(time.DateTime)
+			if !*e.Success {
+				finalizedAt += " (call failed)"
+			}
+		}
+		fmt.Fprintf(os.Stderr, "  %s  %s  %-20s  %-66s  %s\n", e.WithdrawalHash, proof.TxHash, proof.Time.Format(time.DateTime), finalizeTx, finalizedAt)
+	}
+	fmt.Fprintf(os.Stderr, "%s sent or proved %d withdrawals in L1 blocks %d-%d, %d of them finalized\n",
+		address, len(entries), fromBlock, head, finalized)
+	return nil
+}
+
+// runArchivedHistory lists the withdrawals of the network archived in the state store at path, latest archived first,
+// each printed to stdout as a JSON line and to stderr as a table row with its prove and finalize txs.
+func runArchivedHistory(path string, networkName string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("error opening state store: %w", err)
+	}
+	store, err := openStateStore(path)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	records, err := store.archived(networkName)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	fmt.Fprintf(os.Stderr, "  %-66s  %-66s  %-66s  %-20s  %-20s\n", "l2 tx hash", "prove tx", "finalize tx", "finalized at", "archived at")
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+		proveTx, finalizeTx, finalizedAt := "-", "-", "-"
+		if r.ProveTxHash != nil {
+			proveTx = r.ProveTxHash.Hex()
+		}
+		if r.FinalizeTxHash != nil {
+			finalizeTx = r.FinalizeTxHash.Hex()
+		}
+		if r.FinalizedAt != nil {
+			finalizedAt = r.FinalizedAt.Format(time.DateTime)
+		}
+		fmt.Fprintf(os.Stderr, "  %s  %-66s  %-66s  %-20s  %s\n", r.L2TxHash, proveTx, finalizeTx, finalizedAt, r.ArchivedAt.Format(time.DateTime))
+	}
+	fmt.Fprintf(os.Stderr, "%d withdrawals on %s archived in %s\n", len(records), networkName, path)
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// initiateResult is the machine-readable outcome of the initiate command, printed to stdout as a single JSON object.
+type initiateResult struct {
+	Action      string          `json:"action"` // Always "initiate"
+	Withdrawal  *common.Hash    `json:"withdrawal,omitempty"`
+	DryRun      bool            `json:"dryRun,omitempty"`
+	Token       *common.Address `json:"token,omitempty"` // Unset for ETH withdrawals
+	L1Token     *common.Address `json:"l1Token,omitempty"`
+	To          common.Address  `json:"to"`                // L1 recipient
+	Amount      string          `json:"amount,omitempty"`  // In wei, or the token's base units, for ETH and ERC-20 withdrawals
+	TokenID     string          `json:"tokenId,omitempty"` // For ERC-721 withdrawals
+	BlockNumber uint64          `json:"blockNumber,omitempty"`
+	GasUsed     uint64          `json:"gasUsed,omitempty"`
+}
+
+// runInitiate withdraws amount of ETH, or of the L2 token if set, to the L1 address to through the L2StandardBridge,
+// approving the bridge first if needed, or, if tokenID is set, that NFT of the collection through the L2ERC721Bridge.
+// l1Token is only needed for ERC-20s native to L2. A recipient other than the signer must be confirmed, unless yes
+// is set. The signer's own address is the recipient if to is zero.
+func runInitiate(ctx context.Context, l2Rpc string, networkName string, s signer.Signer, token, l1Token, to common.Address, amount string, tokenID *big.Int, gasConfig GasConfig, txTimeout time.Duration, dryRun bool, yes bool) error {
+	l2Client, err := dialEth(ctx, l2Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	defer l2Client.Close()
+	l2ChainID, err := l2Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L2 chain ID: %w", err)
+	}
+	opts := &bind.TransactOpts{
+		From:    s.Address(),
+		Signer:  s.SignerFn(l2ChainID),
+		Context: ctx,
+	}
+	if to == (common.Address{}) {
+		to = s.Address()
+	}
+
+	r := initiateResult{Action: "initiate", DryRun: dryRun, To: to}
+	// initiate confirms the recipient of the described withdrawal and sends it, so tokens are resolved and amounts
+	// parsed before the recipient is asked for
+	var initiate func() (*types.Receipt, error)
+	var description string
+	switch {
+	case token == (common.Address{}):
+		wei, err := withdraw.ParseEther(amount)
+		if err != nil {
+			return err
+		}
+		if wei.Sign() == 0 {
+			return fmt.Errorf("invalid ETH amount %q", amount)
+		}
+		w := &withdraw.ETHWithdrawal{
+			Ctx:           ctx,
+			L2Client:      l2Client,
+			Opts:          opts,
+			Amount:        wei,
+			To:            to,
+			GasMultiplier: gasConfig.GasMultiplier,
+			UserGasLimit:  gasConfig.GasLimit,
+			DryRun:        dryRun,
+			Timeout:       txTimeout,
+		}
+		initiate = w.Initiate
+		description = fmt.Sprintf("%s ETH", withdraw.FormatEther(wei))
+		r.Amount = wei.String()
+	case tokenID != nil:
+		t, err := withdraw.ResolveL2NFT(l2Client, token, l1Token)
+		if err != nil {
+			return err
+		}
+		w := &withdraw.NFTWithdrawal{
+			Ctx:           ctx,
+			L2Client:      l2Client,
+			Opts:          opts,
+			Token:         t,
+			TokenID:       tokenID,
+			To:            to,
+			GasMultiplier: gasConfig.GasMultiplier,
+			UserGasLimit:  gasConfig.GasLimit,
+			DryRun:        dryRun,
+			Timeout:       txTimeout,
+		}
+		initiate = w.Initiate
+		description = fmt.Sprintf("%s token %s", t.Symbol, tokenID)
+		r.Token, r.L1Token = &t.Address, &t.L1Token
+		r.TokenID = tokenID.String()
+	default:
+		t, err := withdraw.ResolveL2Token(l2Client, token, l1Token)
+		if err != nil {
+			return err
+		}
+		units, err := t.ParseAmount(amount)
+		if err != nil {
+			return err
+		}
+		w := &withdraw.TokenWithdrawal{
+			Ctx:           ctx,
+			L2Client:      l2Client,
+			Opts:          opts,
+			Token:         t,
+			Amount:        units,
+			To:            to,
+			GasMultiplier: gasConfig.GasMultiplier,
+			UserGasLimit:  gasConfig.GasLimit,
+			DryRun:        dryRun,
+			Timeout:       txTimeout,
+		}
+		initiate = w.Initiate
+		description = fmt.Sprintf("%s %s", t.FormatAmount(units), t.Symbol)
+		r.Token, r.L1Token = &t.Address, &t.L1Token
+		r.Amount = units.String()
+	}
+
+	if to != s.Address() && !dryRun {
+		if err := confirmRecipient(description, to, networkName, s.Address(), yes); err != nil {
+			return err
+		}
+	}
+	receipt, err := initiate()
+	if err != nil {
+		return err
+	}
+	if receipt != nil {
+		r.Withdrawal = &receipt.TxHash
+		r.BlockNumber = receipt.BlockNumber.Uint64()
+		r.GasUsed = receipt.GasUsed
+		log.Info("Withdrawal initiated, prove it with --withdrawal once it is covered by an L2 output or dispute game",
+			"withdrawal", receipt.TxHash, "amount", description, "to", to)
+	}
+	return json.NewEncoder(os.Stdout).Encode(r)
+}
+
+// confirmRecipient asks to retype the L1 recipient of a withdrawal that isn't sent to the signer's own address, as
+// funds withdrawn to a wrong address can't be recovered, unless yes is set.
+func confirmRecipient(description string, to common.Address, networkName string, from common.Address, yes bool) error {
+	if yes {
+		return nil
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return errors.New("no terminal to confirm the recipient on, pass --yes to withdraw to --to without confirmation")
+	}
+	fmt.Fprintf(os.Stderr, "Withdrawing %s on %s from %s to a different L1 address:\n\n    %s\n\n", description, networkName, from, to)
+	fmt.Fprintf(os.Stderr, "Funds sent to a wrong address can't be recovered. Type the recipient address to confirm: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return fmt.Errorf("error reading confirmation: %w", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if !common.IsHexAddress(answer) || common.HexToAddress(answer) != to {
+		return errors.New("recipient not confirmed, the typed address does not match --to")
+	}
+	return nil
+}
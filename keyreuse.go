@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// mainnetL1ChainID is Ethereum mainnet's chain ID. Signers used on any other L1 are considered test keys.
+const mainnetL1ChainID = 1
+
+// checkKeyReuse remembers the L1 chains each signer address was used on in the signers file at path, and refuses to
+// use an address on mainnet that was used on a testnet before or vice versa, as a key handled in lower environments
+// should never hold mainnet funds. With allow, the reuse is only warned about. An empty path disables the check.
+func checkKeyReuse(ctx context.Context, l1Rpc string, path string, address common.Address, allow bool) error {
+	if path == "" {
+		return nil
+	}
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	chainID, err := l1Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+
+	known, err := readSigners(path)
+	if err != nil {
+		return err
+	}
+	used := known[address]
+	mainnet := chainID.Uint64() == mainnetL1ChainID
+	for _, other := range used {
+		if (other == mainnetL1ChainID) == mainnet {
+			continue
+		}
+		if !allow {
+			return fmt.Errorf("signer %s was used on L1 chain %d before and is now used on L1 chain %d, refusing to reuse a key between mainnet and testnets (pass --allow-key-reuse to override)", address, other, chainID)
+		}
+		log.Warn("Signer is reused between mainnet and testnets", "signer", address, "previousL1ChainID", other, "l1ChainID", chainID)
+		break
+	}
+
+	if slices.Contains(used, chainID.Uint64()) {
+		return nil
+	}
+	known[address] = append(used, chainID.Uint64())
+	return writeSigners(path, known)
+}
+
+func readSigners(path string) (map[common.Address][]uint64, error) {
+	known := make(map[common.Address][]uint64)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return known, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading signers file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, fmt.Errorf("error decoding signers file %s: %w", path, err)
+	}
+	return known, nil
+}
+
+func writeSigners(path string, known map[common.Address][]uint64) error {
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing signers file %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	oplog "github.com/ethereum-optimism/optimism/op-service/log"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// logFile is an append-only log file that can be reopened, so external tools like logrotate can move it aside and
+// signal the process with SIGHUP to start a new file.
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+func openLogFile(path string) (*logFile, error) {
+	l := &logFile{path: path}
+	if err := l.reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Write(p)
+}
+
+func (l *logFile) reopen() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening log file: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+	}
+	l.f = f
+	return nil
+}
+
+// reopenOnHangup reopens the log file whenever the process receives SIGHUP.
+func (l *logFile) reopenOnHangup() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := l.reopen(); err != nil {
+				log.Error("Error reopening log file", "path", l.path, "error", err)
+			}
+		}
+	}()
+}
+
+// onCrit, if set, is called with each critical record before it is logged, as the process exits right after.
+var onCrit func(slog.Record)
+
+// critHook calls onCrit for the critical records it handles.
+type critHook struct {
+	slog.Handler
+}
+
+func (h critHook) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= log.LevelCrit && onCrit != nil {
+		onCrit(r)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h critHook) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return critHook{h.Handler.WithAttrs(attrs)}
+}
+
+func (h critHook) WithGroup(name string) slog.Handler {
+	return critHook{h.Handler.WithGroup(name)}
+}
+
+// currentLogFile is the open --log-file, if any, kept so reconfiguring the logger reuses it.
+var currentLogFile *logFile
+
+// setupLogger configures the default logger with the given level and format, writing to stderr or, if path is
+// set, appending to that file.
+func setupLogger(level slog.Level, format oplog.FormatType, path string) error {
+	cfg := oplog.DefaultCLIConfig()
+	cfg.Level = level
+	cfg.Format = format
+
+	var out io.Writer = os.Stderr
+	if path != "" {
+		if currentLogFile == nil || currentLogFile.path != path {
+			f, err := openLogFile(path)
+			if err != nil {
+				return err
+			}
+			f.reopenOnHangup()
+			currentLogFile = f
+		}
+		out = currentLogFile
+		cfg.Color = false
+	}
+
+	log.SetDefault(log.NewLogger(critHook{oplog.NewLogHandler(out, cfg)}))
+	return nil
+}
@@ -2,34 +2,32 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"math/big"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ethereum/go-ethereum/log"
 
-	"github.com/ethereum-optimism/optimism/op-node/bindings"
-	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
 	oplog "github.com/ethereum-optimism/optimism/op-service/log"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/rpc"
 
+	"github.com/base/withdrawer/price"
+	"github.com/base/withdrawer/registry"
 	"github.com/base/withdrawer/signer"
 	"github.com/base/withdrawer/withdraw"
 )
 
-type network struct {
-	l2RPC              string
-	portalAddress      string
-	l2OOAddress        string
-	disputeGameFactory string
-	faultProofs        bool
-}
-
 // GasConfig holds gas-related configuration for transactions
 type GasConfig struct {
 	GasLimit       uint64   // Override automatic gas estimation
@@ -38,318 +36,1517 @@ type GasConfig struct {
 	MaxPriorityFee *big.Int // EIP-1559 max priority fee
 	GasMultiplier  float64  // Multiplier for estimated gas (default 1.0)
 	MaxGasPrice    *big.Int // Safety cap on gas price
+	BaseFeeMax     *big.Int // Defer submissions while the L1 base fee is above this
+	MinBalance     *big.Int // Pause submissions while the signer's L1 balance is below this
+}
+
+// TxConfig holds configuration for tracking submitted transactions
+type TxConfig struct {
+	ProveTimeout    time.Duration          // Max time to wait for the prove tx to confirm
+	FinalizeTimeout time.Duration          // Max time to wait for the finalize tx to confirm
+	Confirmations   uint64                 // Number of L1 confirmations to wait for
+	WaitFinalized   bool                   // Wait for the L1 block containing the tx to be finalized
+	SendRPC         string                 // L1 RPC url transactions are sent through (empty means the L1 RPC)
+	Journal         *withdraw.Journal      // Records sent transactions until they are confirmed (nil means no journal)
+	Nonces          *withdraw.NonceTracker // Sequences the signer's transactions across withdrawers (nil means each withdrawer starts at the pending nonce)
 }
 
-var networks = map[string]network{
-	"base-mainnet": {
-		l2RPC:              "https://mainnet.base.org",
-		portalAddress:      "0x49048044D57e1C92A77f79988d21Fa8fAF74E97e",
-		l2OOAddress:        "0x0000000000000000000000000000000000000000",
-		disputeGameFactory: "0x43edB88C4B80fDD2AdFF2412A7BebF9dF42cB40e",
-		faultProofs:        true,
-	},
-	"base-sepolia": {
-		l2RPC:              "https://sepolia.base.org",
-		portalAddress:      "0x49f53e41452C74589E85cA1677426Ba426459e85",
-		l2OOAddress:        "0x0000000000000000000000000000000000000000",
-		disputeGameFactory: "0xd6E6dBf4F7EA0ac412fD8b65ED297e64BB7a06E1",
-		faultProofs:        true,
-	},
-	"op-mainnet": {
-		l2RPC:              "https://mainnet.optimism.io",
-		portalAddress:      "0xbEb5Fc579115071764c7423A4f12eDde41f106Ed",
-		l2OOAddress:        "0x0000000000000000000000000000000000000000",
-		disputeGameFactory: "0xe5965Ab5962eDc7477C8520243A95517CD252fA9",
-		faultProofs:        true,
-	},
-	"op-sepolia": {
-		l2RPC:              "https://sepolia.optimism.io",
-		portalAddress:      "0x16Fc5058F25648194471939df75CF27A2fdC48BC",
-		l2OOAddress:        "0x0000000000000000000000000000000000000000",
-		disputeGameFactory: "0x05F9613aDB30026FFd634f38e5C4dFd30a197Fa1",
-		faultProofs:        true,
-	},
+// ProverConfig holds configuration for the prove step, which may be delegated to a prover service
+type ProverConfig struct {
+	Prover         withdraw.Prover         // Service that submits the prove tx (nil means prove locally)
+	ProofSubmitter common.Address          // Address whose proof is finalized (zero means the signer's own)
+	GameType       *uint32                 // Dispute game type to prove against (nil means the portal's respected game type)
+	GameIndex      *big.Int                // Index of the dispute game to prove against (nil means the earliest usable one)
+	L2OutputIndex  *big.Int                // Index of the L2 output to prove against, without fault proofs (nil means the latest)
+	SupervisorRPC  string                  // op-supervisor RPC url to fetch super roots from, for interop portals (optional)
+	ProofRPCs      []string                // L2 RPC urls to fetch the withdrawal proof from before the L2 RPC, e.g. light clients (optional)
+	ImportedProof  *withdraw.ExportedProof // Proof exported earlier to prove with, once checked against the chain (nil means build the proof)
+}
+
+// commands lists the supported subcommands and their descriptions. Running without a subcommand proves or
+// finalizes the given withdrawal.
+var commands = map[string]string{
+	"auto":              "Prove or finalize the withdrawal with conservative defaults: detects the network, waits up to --max-wait and asks before sending",
+	"check":             "Run every read-only validation for the withdrawal and print a pass/fail report, without signing anything",
+	"initiate":          "Start an ETH (--amount) or ERC-20 (--token, --amount) withdrawal on L2 through the L2StandardBridge, approving the bridge first if needed, or an ERC-721 one (--token, --token-id) through the L2ERC721Bridge, to the signer or --to on L1",
+	"decode":            "Print the full withdrawal message emitted by the L2 transaction, without needing an L1 RPC or signer",
+	"backfill":          "List the proves and finalizes the signer (or --address) sent since --from-block, reconstructed from portal events",
+	"history":           "List the withdrawals the signer (or --address) sent on L2 or submitted proofs of since --from-block, with their prove and finalize txs, from portal events",
+	"scan":              "List every withdrawal --from (or the signer or --address) initiated on L2 since --from-block, with its stage on L1",
+	"daemon":            "Keep running until the withdrawal is finalized: prove it, wait out the proof's maturity (resuming after restarts from --state-file) and finalize it",
+	"relay":             "Run as a relayer: watch L2 for withdrawals (optionally from --relay-sender or to --relay-target) and prove and finalize each once possible",
+	"cancel-withdrawal": "Explain what can be done about a withdrawal that should not have been sent, based on how far along it is",
+	"reconcile":         "Reconcile a CSV of expected withdrawals (--expected-csv) against on-chain state and report any discrepancies",
+	"games":             "List the latest dispute games of the respected type with their status, marking the one --withdrawal would be proven against",
+	"networks":          "List the built-in and user-defined networks with their contract addresses and whether fault proofs are active",
+	"stats":             "Summarize the runs recorded in --metrics-file: outcomes, transactions, gas and ETH spent, RPC requests and time taken",
+	"sign":              "Sign the unsigned transaction of --unsigned-tx-file with the signer and write it as raw hex to --raw-tx-file (or stdout), without using any RPC",
+	"broadcast":         "Send the signed raw transaction of --raw-tx-file through the L1 RPC and wait for it to be confirmed, without needing a key",
+	"export-proof":      "Compute the withdrawal's proof against the dispute game it would be proven against and write it to --proof-file (or stdout), without sending anything",
+	"status":            "Print the withdrawal's timeline (initiated, covered by a game or output, proven, matured, finalized) with blocks and tx hashes",
+	"selftest":          "Sign a throwaway transaction with the configured signer and check RPC connectivity, without sending anything",
 }
 
 func main() {
+	start := time.Now()
+
 	var networkKeys []string
 	for n := range networks {
 		networkKeys = append(networkKeys, n)
 	}
+	networkKeys = append(networkKeys, devnetNetwork)
 
 	var rpcFlag string
 	var networkFlag string
 	var l2RpcFlag string
+	var verifyRpcFlag string
+	var sendRpcFlag string
 	var faultProofs bool
 	var portalAddress string
 	var l2OOAddress string
 	var dgfAddress string
-	var withdrawalFlag string
+	var withdrawalFlags hashList
+	var logIndexFlag string
+	var archived bool
+	var healthAddr string
+	var indexPath string
+	var allWithdrawals bool
+	var withdrawalsFile string
+	var scanFrom string
 	var privateKey string
 	var ledger bool
 	var mnemonic string
 	var hdPath string
 	var dryRun bool
-
-	// Gas configuration flags
-	var gasLimit uint64
-	var gasPrice string
-	var maxFeePerGas string
-	var maxPriorityFee string
-	var gasMultiplier float64
-	var maxGasPrice string
+	var calldataOnly bool
+	var unsignedPath string
+	var rawTxPath string
+	var safeFlag string
+	var safeService string
+	var bundlerRpc string
+	var smartAccountFlag string
+	var paymasterURL string
+	var entryPointFlag string
+	var txTimeout time.Duration
+	var proveTxTimeout time.Duration
+	var finalizeTxTimeout time.Duration
+	var confirmations uint64
+	var waitFinalized bool
+	var address string
+	var configPath string
+	var profile string
+	var networksPath string
+	var devnetAddresses string
+	var fromBlock uint64
+	var faults withdraw.Faults
+	var priceFeed string
+	var l2HaltThreshold time.Duration
+	logLevel := oplog.NewLevelFlagValue(log.LevelInfo)
+	logFormat := oplog.NewFormatFlagValue(oplog.FormatText)
+	var logFile string
+	var priceFeedPath string
+	var proverURL string
+	var skipChainIDCheck bool
+	var expectedCSV string
+	var implementationsPath string
+	var signersPath string
+	var pendingPath string
+	var metricsPath string
+	var statePath string
+	var resume bool
+	var allowKeyReuse bool
+	var useRegistry bool
+	var registryURL string
+	var quorumSpec string
+	var quorumRpcs string
+	var proofSubmitter string
+	var gameType string
+	var gameIndex string
+	var l2OutputIndex string
+	var supervisorRpc string
+	var proofRpcs string
+	var compat bool
+	var yes bool
+	var gamesLimit int
+	var gamesBefore string
+	var maxWait time.Duration
+	var token string
+	var l1Token string
+	var amount string
+	var tokenID string
+	var to string
+	var proofPath string
+	var rf reloadableFlags
 
 	flag.StringVar(&rpcFlag, "rpc", "", "Ethereum L1 RPC url")
-	flag.StringVar(&networkFlag, "network", "base-mainnet", fmt.Sprintf("op-stack network to withdraw.go from (one of: %s)", strings.Join(networkKeys, ", ")))
+	flag.StringVar(&networkFlag, "network", "base-mainnet", fmt.Sprintf("op-stack network to withdraw.go from, by name or L2 chain ID (one of: %s, or a network from --networks-file)", strings.Join(networkKeys, ", ")))
+	flag.BoolVar(&useRegistry, "superchain-registry", false, "Resolve the network's contract addresses from the superchain registry by L2 chain ID, instead of the built-in ones")
+	flag.StringVar(&registryURL, "superchain-registry-url", registry.DefaultURL, "Base URL (or local checkout path) of the superchain registry")
 	flag.StringVar(&l2RpcFlag, "l2-rpc", "", "Custom network L2 RPC url")
-	flag.BoolVar(&faultProofs, "fault-proofs", false, "Use fault proofs")
-	flag.StringVar(&portalAddress, "portal-address", "", "Custom network OptimismPortal address")
-	flag.StringVar(&l2OOAddress, "l2oo-address", "", "Custom network L2OutputOracle address")
-	flag.StringVar(&dgfAddress, "dgf-address", "", "Custom network DisputeGameFactory address")
-	flag.StringVar(&withdrawalFlag, "withdrawal", "", "TX hash of the L2 withdrawal transaction")
+	flag.StringVar(&devnetAddresses, "devnet-addresses", defaultDevnetAddresses, "Path to the addresses.json deployment artifact of a local devnet, for --network devnet")
+	flag.StringVar(&quorumSpec, "quorum", "", "Require M/N L1 providers (--rpc and --quorum-rpcs) to agree on finalized status, proven timestamp and output root, e.g. 2/3")
+	flag.StringVar(&quorumRpcs, "quorum-rpcs", "", "Comma-separated additional L1 RPC urls for --quorum reads")
+	flag.StringVar(&sendRpcFlag, "send-rpc", "", "L1 RPC url to send transactions through only, e.g. a private relay, while reads go to --rpc")
+	flag.StringVar(&verifyRpcFlag, "verify-rpc", "", "Second L2 RPC url to independently recompute the proof against, refusing to proceed if it disagrees")
+	flag.BoolVar(&faultProofs, "fault-proofs", false, "Use the fault proofs withdrawal flow (detected from the portal by default, set to override)")
+	flag.BoolVar(&skipChainIDCheck, "skip-chain-id-check", false, "Don't refuse to run when the L1 or L2 RPC chain ID doesn't match the selected network")
+	flag.StringVar(&portalAddress, "portal-address", "", "Custom network OptimismPortal address (discovered through --l2-rpc if not given)")
+	flag.StringVar(&l2OOAddress, "l2oo-address", "", "Custom network L2OutputOracle address (discovered from the portal if not given)")
+	flag.StringVar(&dgfAddress, "dgf-address", "", "Custom network DisputeGameFactory address (discovered from the portal if not given)")
+	flag.Var(&withdrawalFlags, "withdrawal", "TX hash of the L2 withdrawal transaction, may be repeated to prove or finalize a batch")
+	flag.StringVar(&withdrawalsFile, "withdrawals-file", "", "Path to file with a batch of withdrawal tx hashes to prove or finalize, one per line (- reads them from stdin)")
+	flag.StringVar(&logIndexFlag, "log-index", "", "Log index of the MessagePassed event of the withdrawal to prove, finalize, decode, check or trace, for L2 txs that initiated several")
+	flag.BoolVar(&allWithdrawals, "all", false, "Prove, finalize or decode every withdrawal the L2 txs initiated, for txs that initiated several (proven or finalized as a batch)")
 	flag.StringVar(&privateKey, "private-key", "", "Private key to use for signing transactions")
 	flag.BoolVar(&ledger, "ledger", false, "Use ledger device for signing transactions")
 	flag.StringVar(&mnemonic, "mnemonic", "", "Mnemonic to use for signing transactions")
 	flag.StringVar(&hdPath, "hd-path", "m/44'/60'/0'/0/0", "Hierarchical deterministic derivation path for mnemonic or ledger")
 
-	// Gas configuration flags
-	flag.Uint64Var(&gasLimit, "gas-limit", 0, "Gas limit for transactions (overrides automatic estimation)")
-	flag.StringVar(&gasPrice, "gas-price", "", "Gas price in wei for legacy transactions")
-	flag.StringVar(&maxFeePerGas, "max-fee-per-gas", "", "Maximum fee per gas in wei for EIP-1559 transactions")
-	flag.StringVar(&maxPriorityFee, "max-priority-fee", "", "Maximum priority fee per gas in wei for EIP-1559 transactions")
-	flag.Float64Var(&gasMultiplier, "gas-multiplier", 1.0, "Multiplier for estimated gas limit (default 1.0)")
-	flag.StringVar(&maxGasPrice, "max-gas-price", "", "Maximum gas price cap in wei (safety limit)")
+	// Gas configuration, notification and relay flags, which the relay and daemon commands reload
+	rf.register(flag.CommandLine)
+
 	flag.BoolVar(&dryRun, "dry-run", false, "Simulate transactions and print details without submitting")
+	flag.BoolVar(&calldataOnly, "calldata-only", false, "Print the portal call of the prove or finalize transaction (target, value and ABI-encoded calldata) in the result instead of sending it, for a Safe or cast send; needs no key, only the signer or --address that will send it")
+	flag.StringVar(&unsignedPath, "unsigned-tx-file", "", "Path to JSON file to write the prove or finalize transaction to, unsigned with its nonce, gas and EIP-1559 fees, instead of sending it, for signing offline with the sign command; needs no key, only the signer or --address that will sign it")
+	flag.StringVar(&safeFlag, "safe", "", "Safe to prove or finalize the withdrawal from: the portal call is proposed to the Safe Transaction Service, signed by the signer as one of its owners, instead of sent")
+	flag.StringVar(&safeService, "safe-tx-service", "", "Safe Transaction Service URL to propose --safe transactions to (defaults to the service of the L1 chain, for Ethereum mainnet and Sepolia)")
+	flag.StringVar(&bundlerRpc, "bundler-rpc", "", "ERC-4337 bundler RPC to send the prove or finalize call through, as a UserOperation from --smart-account signed by the signer as its owner, instead of a transaction from the signer")
+	flag.StringVar(&smartAccountFlag, "smart-account", "", "Deployed smart account to prove or finalize the withdrawal from with --bundler-rpc, calling the portal through its execute(address,uint256,bytes) method")
+	flag.StringVar(&paymasterURL, "paymaster-url", "", "ERC-7677 paymaster service URL to sponsor the gas of --bundler-rpc UserOperations, so the smart account needs no ETH")
+	flag.StringVar(&entryPointFlag, "entry-point", withdraw.EntryPointV07.Hex(), "ERC-4337 v0.7 EntryPoint the --bundler-rpc bundler serves")
+	flag.StringVar(&rawTxPath, "raw-tx-file", "", "Path to file the sign command writes the signed raw transaction to as hex (defaults to stdout), and the broadcast command sends it from")
 
-	flag.Parse()
+	// Confirmation flags
+	flag.DurationVar(&txTimeout, "tx-timeout", withdraw.DefaultTxTimeout, "Max time to wait for a submitted transaction to confirm")
+	flag.DurationVar(&proveTxTimeout, "prove-tx-timeout", 0, "Max time to wait for the prove transaction to confirm (overrides --tx-timeout)")
+	flag.DurationVar(&finalizeTxTimeout, "finalize-tx-timeout", 0, "Max time to wait for the finalize transaction to confirm (overrides --tx-timeout)")
 
-	log.SetDefault(oplog.NewLogger(os.Stderr, oplog.DefaultCLIConfig()))
+	flag.Uint64Var(&confirmations, "confirmations", 1, "Number of L1 block confirmations to wait for before considering a transaction confirmed")
+	flag.BoolVar(&waitFinalized, "wait-finalized", false, "Wait for the L1 block containing the transaction to be finalized (consider raising --tx-timeout)")
 
-	n, ok := networks[networkFlag]
-	if !ok {
+	flag.BoolVar(&yes, "yes", false, "Send transactions without asking for confirmation (auto only), or withdraw to --to without confirming the recipient (initiate only)")
+	flag.DurationVar(&maxWait, "max-wait", defaultMaxWait, "Max time to wait for the withdrawal to become provable or finalizable before exiting (auto only)")
+
+	flag.IntVar(&gamesLimit, "games-limit", defaultGamesLimit, "Number of dispute games to list (games only)")
+	flag.StringVar(&gamesBefore, "games-before", "", "Factory index of the newest dispute game to list, to page through older games (games only, defaults to the latest game)")
+
+	flag.StringVar(&token, "token", "", "L2 address of the ERC-20 or ERC-721 to withdraw, ETH is withdrawn if unset (initiate only)")
+	flag.StringVar(&amount, "amount", "", "Amount of ETH or the token to withdraw, in whole tokens with up to the token's decimals, e.g. 1.5 (initiate only)")
+	flag.StringVar(&tokenID, "token-id", "", "ID of the ERC-721 token to withdraw through the L2ERC721Bridge, instead of an --amount of an ERC-20 (initiate only)")
+	flag.StringVar(&to, "to", "", "L1 address to withdraw to, if not the signer's, which must be confirmed unless --yes is set (initiate only)")
+	flag.StringVar(&l1Token, "l1-token", "", "L1 address of the token, only needed for tokens native to L2, as the L1 token of OptimismMintableERC20s is resolved (initiate only)")
+
+	flag.StringVar(&proverURL, "prover-url", "", "Prover service URL to delegate the prove transaction to, after which only the finalize transaction is sent locally")
+	flag.StringVar(&gameType, "game-type", "", "Dispute game type to prove against, e.g. 1 for permissioned games (fault proofs only, defaults to the portal's respected game type)")
+	flag.StringVar(&gameIndex, "game-index", "", "Index of the dispute game to prove against, which must cover the withdrawal and be of a game type the portal accepts (fault proofs only, defaults to the earliest usable game)")
+	flag.StringVar(&l2OutputIndex, "l2-output-index", "", "Index of the L2OutputOracle output to prove against, which must cover the withdrawal (without fault proofs only, defaults to the latest output)")
+	flag.StringVar(&supervisorRpc, "supervisor-rpc", "", "op-supervisor RPC url to fetch super roots from, needed to prove on chains whose portal proves against interop super roots")
+	flag.StringVar(&proofRpcs, "proof-rpcs", "", "Comma-separated L2 RPC urls, e.g. of light clients, to fetch the withdrawal proof from before the L2 RPC, which is verified against L1 so they needn't be trusted")
+	flag.StringVar(&proofPath, "proof-file", "", "Path to JSON file the export-proof command writes the withdrawal's proof to (defaults to stdout), or to prove the withdrawal with a proof exported earlier, checked against the chain first (fault proofs only)")
+	flag.StringVar(&proofSubmitter, "proof-submitter", "", "Address whose proof to check and finalize with, e.g. the prover service's (fault proofs only, defaults to the signer's proof, or else the first to mature of other addresses')")
+
+	flag.StringVar(&priceFeed, "price-feed", "", "ETH/USD price source for cost estimates in USD: chainlink, chainlink:<aggregator address> or an http(s) URL returning JSON")
+	flag.StringVar(&priceFeedPath, "price-feed-path", "", "Dot-separated path to the price in the JSON returned by an HTTP --price-feed (e.g. ethereum.usd)")
+
+	flag.DurationVar(&l2HaltThreshold, "l2-halt-threshold", withdraw.DefaultL2HaltThreshold, "Warn that the L2 chain may be halted if its latest block is older than this")
+
+	flag.StringVar(&expectedCSV, "expected-csv", "", "CSV of expected withdrawals for the reconcile command, with hash, amount (ETH), recipient and optional status columns")
+
+	flag.StringVar(&address, "address", "", "L1 address to check proof status and balance for with the check command, to trace the proof of with the status command, to backfill the activity or history of, or to build --calldata-only or --unsigned-tx-file for (defaults to the signer address)")
+	flag.Uint64Var(&fromBlock, "from-block", 0, "L1 block to start reconstructing activity from, for the backfill and history commands, or L2 block to start scanning withdrawals from, for the scan command (defaults to the L2 genesis), or watching them from, for a relay command's first start on the chain (defaults to the L2 head)")
+	flag.BoolVar(&archived, "archived", false, "List the withdrawals of the network archived in --state-file with the history command, instead of reconstructing them from portal events")
+	flag.StringVar(&healthAddr, "health-addr", "", "Address to serve the daemon command's health endpoint on, e.g. :8080 for http://localhost:8080/healthz (disabled by default)")
+	flag.StringVar(&indexPath, "index-file", "", "Path to JSON file the relay and daemon commands checkpoint an index of portal and dispute game factory events to, to follow their withdrawals from each poll's new L1 blocks (disabled by default)")
+	flag.StringVar(&scanFrom, "from", "", "L2 address to list the withdrawals initiated by, for the scan command (defaults to the signer or --address)")
+
+	flag.Var(logLevel, "log-level", "Log level (one of: trace, debug, info, warn, error, crit)")
+	flag.Var(logFormat, "log-format", "Log format (one of: text, terminal, logfmt, logfmtms, json, jsonms)")
+	flag.StringVar(&logFile, "log-file", "", "Append logs to this file instead of stderr (reopened on SIGHUP for log rotation)")
+
+	// Config file flags
+	flag.StringVar(&configPath, "config", defaultConfigPath(), "Path to TOML config file with default settings and named profiles")
+	flag.StringVar(&profile, "profile", "", "Named profile to load from the config file")
+	flag.StringVar(&implementationsPath, "implementations-file", "", "Path to JSON file remembering the contract implementations seen behind proxies, to warn when they are upgraded, e.g. ~/.withdrawer-implementations.json (disabled by default)")
+	flag.StringVar(&signersPath, "signers-file", "", "Path to JSON file remembering the L1 chains each signer was used on, to catch keys reused between mainnet and testnets, e.g. ~/.withdrawer-signers.json (disabled by default)")
+	flag.BoolVar(&allowKeyReuse, "allow-key-reuse", false, "Only warn, instead of refusing, when the signer was used on both mainnet and a testnet")
+	flag.StringVar(&pendingPath, "pending-file", defaultPendingPath(), "Path to JSON file journaling signed transactions until they are confirmed, to resume waiting for them if a run dies")
+	flag.StringVar(&metricsPath, "metrics-file", "", "Path to JSON lines file each prove or finalize run appends its duration, RPC requests, gas spent and outcome to, for the stats command, e.g. ~/.withdrawer-metrics.jsonl (disabled by default)")
+	flag.StringVar(&statePath, "state-file", "", "Path to SQLite database recording each withdrawal's stage, proof, prove and finalize transactions and the outcome of its latest runs, so batches skip finalized withdrawals, and keeping the progress of the relay and daemon commands (disabled by default, except for the relay and daemon commands, which default to ~/.withdrawer-state.db)")
+	flag.BoolVar(&resume, "resume", false, "Continue every withdrawal of the network recorded in --state-file that isn't finalized yet, along with any given with --withdrawal, re-checking each on L1 first")
+	flag.StringVar(&networksPath, "networks-file", defaultNetworksPath(), "Path to TOML (or .json) file with custom networks, adding to or overriding the built-in ones")
+
+	flag.BoolVar(&compat, "compat", false, "Accept deprecated flag names even past their deprecation window, without warnings, so older automation keeps working")
+
+	// Test-only flags, hidden from the usage output
+	flag.Var(&faults, "inject-fault", fmt.Sprintf("Fault to inject for rehearsals in test environments, may be repeated (one of: %s)", withdraw.FaultNames()))
+	flag.Usage = usage
+	registerFlagAliases(flag.CommandLine)
+
+	// the first argument may name a subcommand, otherwise the withdrawal is proven or finalized
+	command, args := "", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	recordCommandLineAliases(flag.CommandLine)
+
+	if err := setupLogger(logLevel.Level(), logFormat.FormatType(), logFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting up logging: %v\n", err)
+		os.Exit(1)
+	}
+
+	// cancel the root context on SIGINT/SIGTERM so that long waits exit cleanly; a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+		log.Warn("Received shutdown signal, stopping (send again to force exit)")
+	}()
+
+	if _, ok := commands[command]; !ok && command != "" {
+		log.Crit("Unknown command", "command", command)
+	}
+
+	if err := applyEnv(flag.CommandLine); err != nil {
+		log.Crit("Error loading environment variables", "error", err)
+	}
+
+	// the settings given on the command line or in the environment take precedence over the config file, also when
+	// the relay and daemon commands reload it
+	pinned := make(map[string]bool)
+	flag.CommandLine.Visit(func(f *flag.Flag) {
+		pinned[canonicalFlagName(f.Name)] = true
+	})
+	configExplicit := isFlagSet(flag.CommandLine, "config")
+	if err := applyConfigFile(flag.CommandLine, configPath, configExplicit, profile); err != nil {
+		log.Crit("Error loading config file", "error", err)
+	}
+	// the log settings may have come from the environment or config file
+	if err := setupLogger(logLevel.Level(), logFormat.FormatType(), logFile); err != nil {
+		log.Crit("Error setting up logging", "error", err)
+	}
+	if err := reportDeprecatedFlags(compat); err != nil {
+		log.Crit("Unsupported flags", "error", err)
+	}
+
+	auto := command == "auto"
+	if auto {
+		if err := applyAutoDefaults(flag.CommandLine); err != nil {
+			log.Crit("Error applying auto defaults", "error", err)
+		}
+	}
+
+	// several withdrawals are proven or finalized as a batch, while other commands take a single one
+	withdrawals, err := collectWithdrawals(withdrawalFlags, withdrawalsFile)
+	if err != nil {
+		log.Crit("Error reading withdrawals", "error", err)
+	}
+	batch := len(withdrawals) > 1 || withdrawalsFile != ""
+	if batch && command != "" && !auto {
+		log.Crit("Batches of withdrawals can only be proven or finalized, pass a single --withdrawal", "command", command, "withdrawals", len(withdrawals))
+	}
+	var logIndex *uint
+	if logIndexFlag != "" {
+		index, err := strconv.ParseUint(logIndexFlag, 10, 32)
+		if err != nil {
+			log.Crit("Invalid --log-index value", "value", logIndexFlag)
+		}
+		i := uint(index)
+		logIndex = &i
+	}
+	if logIndex != nil || allWithdrawals {
+		if command != "" && !auto && command != "decode" && command != "daemon" && command != "export-proof" && command != "check" && command != "status" {
+			log.Crit("--log-index and --all only apply when proving, finalizing, decoding, checking or tracing", "command", command)
+		}
+		if allWithdrawals && (command == "daemon" || command == "export-proof" || command == "check" || command == "status") {
+			log.Crit("--all is not supported by the command, select each withdrawal with --log-index", "command", command)
+		}
+		if logIndex != nil && allWithdrawals {
+			log.Crit("--log-index and --all are mutually exclusive")
+		}
+		if logIndex != nil && batch {
+			log.Crit("--log-index selects a withdrawal of a single L2 tx, pass --all to process every withdrawal of a batch's txs")
+		}
+	}
+	// the withdrawals of a tx are proven or finalized as a batch with --all, even if it only initiated one
+	batch = batch || allWithdrawals
+	if resume {
+		if command != "" && !auto {
+			log.Crit("--resume only applies when proving or finalizing", "command", command)
+		}
+		if statePath == "" {
+			log.Crit("--resume needs the --state-file to resume from")
+		}
+		if logIndex != nil {
+			log.Crit("--log-index selects a withdrawal of a single L2 tx, pass --all to process every withdrawal of the txs to resume with")
+		}
+		// the resumed withdrawals are only known once the state file is read, so they're processed as a batch
+		batch = true
+	}
+	// a proof exported earlier is proven with as is, and tells which withdrawal it is of
+	var importedProof *withdraw.ExportedProof
+	if proofPath != "" && command != "export-proof" {
+		if command != "" && !auto {
+			log.Crit("--proof-file only applies when exporting or proving a withdrawal", "command", command)
+		}
+		if batch {
+			log.Crit("--proof-file holds the proof of a single withdrawal, pass a single --withdrawal")
+		}
+		if importedProof, err = readExportedProof(proofPath); err != nil {
+			log.Crit("Error reading exported proof", "error", err)
+		}
+		if len(withdrawals) == 0 {
+			withdrawals = []common.Hash{importedProof.L2TxHash}
+		} else if withdrawals[0] != importedProof.L2TxHash {
+			log.Crit("--proof-file is of another withdrawal", "withdrawal", withdrawals[0], "proofWithdrawal", importedProof.L2TxHash)
+		}
+		if logIndex == nil {
+			logIndex = importedProof.LogIndex
+		} else if importedProof.LogIndex == nil || *importedProof.LogIndex != *logIndex {
+			log.Crit("--proof-file is of another withdrawal of the L2 tx", "logIndex", *logIndex, "proofLogIndex", importedProof.LogIndex)
+		}
+	}
+	// the calldata is built by simulating the transaction, which needs no key, from the address that will send it
+	if calldataOnly {
+		if command != "" && !auto {
+			log.Crit("--calldata-only only applies when proving or finalizing", "command", command)
+		}
+		if proverURL != "" {
+			log.Crit("--calldata-only is not supported with --prover-url, as the prover service sends the prove transaction")
+		}
+		dryRun = true
+	}
+	// the unsigned transaction is built the same way, with the nonce and fees it is sent with once signed
+	if unsignedPath != "" && command != "sign" {
+		if command != "" && !auto {
+			log.Crit("--unsigned-tx-file only applies when proving or finalizing", "command", command)
+		}
+		if batch {
+			log.Crit("--unsigned-tx-file holds a single transaction, pass a single --withdrawal")
+		}
+		if proverURL != "" {
+			log.Crit("--unsigned-tx-file is not supported with --prover-url, as the prover service sends the prove transaction")
+		}
+		if rf.gasPrice != "" {
+			log.Crit("--unsigned-tx-file exports EIP-1559 transactions, use --max-fee-per-gas and --max-priority-fee instead of --gas-price")
+		}
+		dryRun = true
+	}
+	// the Safe's transaction is built the same way, simulated from the Safe
+	var safeAddress common.Address
+	if safeFlag != "" {
+		if command != "" && !auto {
+			log.Crit("--safe only applies when proving or finalizing", "command", command)
+		}
+		if safeAddress, err = parseAddress("--safe", safeFlag); err != nil {
+			log.Crit("Invalid --safe value", "error", err)
+		}
+		if proverURL != "" {
+			log.Crit("--safe is not supported with --prover-url, as the prover service sends the prove transaction")
+		}
+		if unsignedPath != "" {
+			log.Crit("--safe and --unsigned-tx-file are mutually exclusive, as the Safe's owners sign its transactions")
+		}
+		dryRun = true
+	} else if safeService != "" {
+		log.Crit("--safe-tx-service only applies with --safe")
+	}
+	// and the smart account's UserOperation, simulated from the account
+	var smartAccount common.Address
+	if bundlerRpc != "" {
+		if command != "" && !auto {
+			log.Crit("--bundler-rpc only applies when proving or finalizing", "command", command)
+		}
+		if smartAccountFlag == "" {
+			log.Crit("Missing --smart-account flag to send UserOperations from")
+		}
+		if smartAccount, err = parseAddress("--smart-account", smartAccountFlag); err != nil {
+			log.Crit("Invalid --smart-account value", "error", err)
+		}
+		if proverURL != "" {
+			log.Crit("--bundler-rpc is not supported with --prover-url, as the prover service sends the prove transaction")
+		}
+		if unsignedPath != "" || safeFlag != "" {
+			log.Crit("--bundler-rpc is mutually exclusive with --unsigned-tx-file and --safe, as the UserOperation is sent right away")
+		}
+		if dryRun || calldataOnly {
+			log.Crit("--bundler-rpc sends the UserOperation, don't pass --dry-run or --calldata-only")
+		}
+		dryRun = true
+	} else if smartAccountFlag != "" || paymasterURL != "" || isFlagSet(flag.CommandLine, "entry-point") {
+		log.Crit("--smart-account, --paymaster-url and --entry-point only apply with --bundler-rpc")
+	}
+	entryPoint, err := parseAddress("--entry-point", entryPointFlag)
+	if err != nil {
+		log.Crit("Invalid --entry-point value", "error", err)
+	}
+	withdrawalFlag := ""
+	if len(withdrawals) > 0 {
+		withdrawalFlag = withdrawals[0].Hex()
+	}
+
+	networksExplicit := isFlagSet(flag.CommandLine, "networks-file")
+	if err := loadNetworksFile(networksPath, networksExplicit); err != nil {
+		log.Crit("Error loading networks file", "error", err)
+	}
+
+	if len(faults) > 0 {
+		log.Warn("Injecting faults, never use this against a production withdrawal", "faults", faults.String())
+	}
+
+	if command == "networks" {
+		if err := runNetworks(ctx, rpcFlag, implementationsPath); err != nil {
+			log.Crit("Error listing networks", "error", err)
+		}
+		return
+	}
+
+	if command == "stats" {
+		if err := runStatsCommand(metricsPath); err != nil {
+			log.Crit("Error summarizing runs", "error", err)
+		}
+		return
+	}
+
+	// how many of the signer flags are set, of which commands that sign need exactly one
+	signerFlags := 0
+	for _, set := range []bool{privateKey != "", ledger, mnemonic != ""} {
+		if set {
+			signerFlags++
+		}
+	}
+
+	// signing offline needs neither an RPC nor a network, and broadcasting only the L1 RPC, so the online machine never
+	// needs a key
+	if command == "sign" {
+		if unsignedPath == "" {
+			log.Crit("Missing --unsigned-tx-file flag")
+		}
+		if signerFlags != 1 {
+			log.Crit("One (and only one) of --private-key, --ledger, --mnemonic must be set")
+		}
+		s, err := signer.CreateSigner(privateKey, mnemonic, hdPath)
+		if err != nil {
+			log.Crit("Error creating signer", "error", err)
+		}
+		if err := runSign(s, unsignedPath, rawTxPath); err != nil {
+			log.Crit("Error signing transaction", "error", err)
+		}
+		return
+	}
+
+	if command == "broadcast" {
+		if rawTxPath == "" {
+			log.Crit("Missing --raw-tx-file flag")
+		}
+		if rpcFlag == "" {
+			log.Crit("Missing --rpc flag")
+		}
+		if txTimeout <= 0 {
+			log.Crit("--tx-timeout must be positive", "value", txTimeout)
+		}
+		if err := runBroadcast(ctx, rpcFlag, sendRpcFlag, rawTxPath, txTimeout, confirmations, waitFinalized); err != nil {
+			log.Crit("Error broadcasting transaction", "error", err)
+		}
+		return
+	}
+
+	// the auto command finds the network the withdrawal was sent on, unless one was given
+	if auto && !isFlagSet(flag.CommandLine, "network") && l2RpcFlag == "" && portalAddress == "" {
+		if rpcFlag == "" {
+			log.Crit("Missing --rpc flag")
+		}
+		if withdrawalFlag == "" {
+			log.Crit("Missing --withdrawal flag")
+		}
+		detected, err := detectNetwork(ctx, rpcFlag, common.HexToHash(withdrawalFlag))
+		if err != nil {
+			log.Crit("Unable to detect the network", "error", err)
+		}
+		networkFlag = detected
+		log.Info("Detected network", "network", networkFlag)
+	}
+
+	n, ok := lookupNetwork(networkFlag)
+	if networkFlag == devnetNetwork && !ok {
+		var err error
+		if n, err = loadDevnet(devnetAddresses, l2RpcFlag); err != nil {
+			log.Crit("Error loading devnet deployment", "error", err)
+		}
+		ok = true
+		log.Info("Using local devnet, skipping the chain ID, L2 liveness, L1 finality and contract upgrade checks", "l2Rpc", n.l2RPC, "portal", n.portalAddress)
+		// every redeploy replaces the implementations, so tracking them would only raise false upgrade warnings
+		implementationsPath = ""
+		// devnets use well-known test keys on their own L1 chain
+		signersPath = ""
+		if waitFinalized {
+			log.Warn("Devnet L1s may never finalize blocks, --wait-finalized can wait forever")
+		}
+	}
+	if useRegistry {
+		var err error
+		if n, err = resolveFromRegistry(ctx, registryURL, networkFlag, n, ok); err != nil {
+			log.Crit("Error resolving network from the superchain registry", "network", networkFlag, "error", err)
+		}
+	} else if !ok {
 		log.Crit("Unknown network", "network", networkFlag)
 	}
 
-	// check for non-compatible networks with given flags
-	if faultProofs {
-		if n.faultProofs == false {
-			log.Crit("Fault proofs are not supported on this network")
+	if command == "decode" {
+		if withdrawalFlag == "" {
+			log.Crit("Missing --withdrawal flag")
 		}
-	} else {
-		if n.faultProofs == true {
-			log.Crit("Fault proofs are required on this network, please provide the --fault-proofs flag")
+		l2Rpc := n.l2RPC
+		if l2RpcFlag != "" {
+			l2Rpc = l2RpcFlag
+		}
+		if !skipChainIDCheck {
+			if err := verifyChainIDs(ctx, "", l2Rpc, n); err != nil {
+				log.Crit("Chain ID mismatch, pass --skip-chain-id-check to override", "network", networkFlag, "error", err)
+			}
 		}
+		if err := runDecode(ctx, l2Rpc, common.HexToHash(withdrawalFlag), logIndex, allWithdrawals); err != nil {
+			log.Crit("Error decoding withdrawal", "error", err)
+		}
+		return
 	}
 
-	// check for non-empty flags for non-fault proof networks
-	if !faultProofs && (l2RpcFlag != "" || portalAddress != "" || l2OOAddress != "") {
-		if l2RpcFlag == "" {
-			log.Crit("Missing --l2-rpc flag")
+	if command == "initiate" {
+		if (amount == "") == (tokenID == "") {
+			log.Crit("One (and only one) of --amount, --token-id must be set")
 		}
-		if portalAddress == "" {
-			log.Crit("Missing --portal-address flag")
+		if token == "" && tokenID != "" {
+			log.Crit("Missing --token flag for --token-id")
 		}
-		if l2OOAddress == "" {
-			log.Crit("Missing --l2oo-address flag")
+		if token == "" && l1Token != "" {
+			log.Crit("--l1-token can only be used with --token")
 		}
-		n = network{
-			l2RPC:         l2RpcFlag,
-			portalAddress: portalAddress,
-			l2OOAddress:   l2OOAddress,
-			faultProofs:   faultProofs,
+		var nftID *big.Int
+		if tokenID != "" {
+			var ok bool
+			if nftID, ok = new(big.Int).SetString(tokenID, 10); !ok || nftID.Sign() < 0 {
+				log.Crit("Invalid --token-id value", "value", tokenID)
+			}
+		}
+		var err error
+		var tokenAddr common.Address
+		if token != "" {
+			if tokenAddr, err = parseAddress("--token", token); err != nil {
+				log.Crit("Invalid --token value", "error", err)
+			}
+		}
+		var toAddr common.Address
+		if to != "" {
+			if toAddr, err = parseAddress("--to", to); err != nil {
+				log.Crit("Invalid --to value", "error", err)
+			}
+			if toAddr == (common.Address{}) {
+				log.Crit("--to must not be the zero address")
+			}
+		}
+		var l1TokenAddr common.Address
+		if l1Token != "" {
+			if l1TokenAddr, err = parseAddress("--l1-token", l1Token); err != nil {
+				log.Crit("Invalid --l1-token value", "error", err)
+			}
+		}
+		l2Rpc := n.l2RPC
+		if l2RpcFlag != "" {
+			l2Rpc = l2RpcFlag
+		}
+		if !skipChainIDCheck {
+			if err := verifyChainIDs(ctx, "", l2Rpc, n); err != nil {
+				log.Crit("Chain ID mismatch, pass --skip-chain-id-check to override", "network", networkFlag, "error", err)
+			}
+		}
+		if signerFlags != 1 {
+			log.Crit("One (and only one) of --private-key, --ledger, --mnemonic must be set")
+		}
+		s, err := signer.CreateSigner(privateKey, mnemonic, hdPath)
+		if err != nil {
+			log.Crit("Error creating signer", "error", err)
+		}
+		if rf.gasMultiplier < 1.0 {
+			log.Crit("--gas-multiplier must be >= 1.0", "value", rf.gasMultiplier)
+		}
+		if txTimeout <= 0 {
+			log.Crit("--tx-timeout must be positive", "value", txTimeout)
+		}
+		gasConfig := GasConfig{GasLimit: rf.gasLimit, GasMultiplier: rf.gasMultiplier}
+		if err := runInitiate(ctx, l2Rpc, networkFlag, s, tokenAddr, l1TokenAddr, toAddr, amount, nftID, gasConfig, txTimeout, dryRun, yes); err != nil {
+			log.Crit("Error initiating withdrawal", "error", err)
 		}
+		return
 	}
 
-	// check for non-empty flags for fault proof networks
-	if faultProofs && (l2RpcFlag != "" || dgfAddress != "" || portalAddress != "") {
+	if rpcFlag == "" {
+		log.Crit("Missing --rpc flag")
+	}
+
+	// custom networks only need the L2 RPC, any L1 contracts not given are discovered through it
+	custom := (l2RpcFlag != "" && !n.devnet) || portalAddress != "" || dgfAddress != "" || l2OOAddress != ""
+	if custom {
 		if l2RpcFlag == "" {
 			log.Crit("Missing --l2-rpc flag")
 		}
-		if dgfAddress == "" {
-			log.Crit("Missing --dgf-address flag")
-		}
-		if portalAddress == "" {
-			log.Crit("Missing --portal-address flag")
+		overrides := map[string]string{"portal-address": portalAddress, "dgf-address": dgfAddress, "l2oo-address": l2OOAddress}
+		if err := checkAddressOverrides(ctx, l2RpcFlag, registryURL, overrides); err != nil {
+			log.Crit("Invalid contract address", "error", err)
 		}
 		n = network{
 			l2RPC:              l2RpcFlag,
 			portalAddress:      portalAddress,
+			l2OOAddress:        l2OOAddress,
 			disputeGameFactory: dgfAddress,
-			faultProofs:        faultProofs,
+		}
+		if portalAddress == "" {
+			portal, err := discoverPortal(ctx, rpcFlag, l2RpcFlag)
+			if err != nil {
+				log.Crit("Unable to discover the OptimismPortal from the L2, please provide the --portal-address flag", "error", err)
+			}
+			n.portalAddress = portal.Hex()
+			log.Info("Discovered OptimismPortal from the L2", "portal", portal)
 		}
 	}
 
-	if rpcFlag == "" {
-		log.Crit("Missing --rpc flag")
+	networkName := networkFlag
+	if custom {
+		networkName = n.l2RPC
 	}
 
-	if withdrawalFlag == "" {
-		log.Crit("Missing --withdrawal flag")
+	// record the metrics of runs proving or finalizing the withdrawal once they succeed or, through the critical log
+	// right before exiting, fail
+	// the runs of a batch record their metrics each
+	runMetricsPath := ""
+	if (command == "" || auto) && !batch {
+		runMetricsPath = metricsPath
+	}
+	metrics := newMetricsRecorder(runMetricsPath, start, networkName, common.HexToHash(withdrawalFlag), dryRun)
+	onCrit = metrics.finishOnCrit(ctx)
+	defer metrics.finish(outcomeSuccess, "")
+
+	// the portal tells which withdrawal flow to use, unless overridden with --fault-proofs. Built-in networks preset
+	// their flow, and the portal only overrides it, with a warning, once the chain has migrated
+	faultProofsExplicit := isFlagSet(flag.CommandLine, "fault-proofs")
+	detected, err := detectFaultProofs(ctx, rpcFlag, n.portalAddress)
+	switch {
+	case err != nil && faultProofsExplicit:
+		log.Warn("Unable to detect whether the portal uses fault proofs, using --fault-proofs", "error", err, "faultProofs", faultProofs)
+	case err != nil && custom:
+		log.Crit("Unable to detect whether the portal uses fault proofs, please provide --fault-proofs or --fault-proofs=false", "error", err)
+	case err != nil:
+		log.Warn("Unable to detect whether the portal uses fault proofs, using the network's default", "error", err, "faultProofs", n.faultProofs)
+		faultProofs = n.faultProofs
+	case faultProofsExplicit && faultProofs != detected:
+		log.Warn("Overriding the withdrawal flow detected from the portal with --fault-proofs", "detected", detected, "faultProofs", faultProofs)
+	case !faultProofsExplicit && !custom && !n.devnet && detected != n.faultProofs:
+		log.Warn("The portal's withdrawal flow differs from the network's preset, the chain may have migrated, using the portal's",
+			"network", networkFlag, "preset", n.faultProofs, "detected", detected)
+		faultProofs = detected
+	case !faultProofsExplicit:
+		faultProofs = detected
+		log.Debug("Detected withdrawal flow from the portal", "portal", n.portalAddress, "faultProofs", faultProofs)
+	}
+	n.faultProofs = faultProofs
+
+	// custom networks, devnets and presets whose chain migrated may lack the contract the withdrawal flow needs
+	if err := discoverProofContract(ctx, rpcFlag, &n); err != nil {
+		log.Crit("Unable to discover the network's contracts from the OptimismPortal, please provide the --dgf-address or --l2oo-address flag", "error", err)
+	}
+
+	if skipChainIDCheck {
+		log.Warn("Skipping the L1 and L2 chain ID check")
+	} else if err := verifyChainIDs(ctx, rpcFlag, n.l2RPC, n); err != nil {
+		log.Crit("Chain ID mismatch, pass --skip-chain-id-check to override", "network", networkFlag, "error", err)
+	}
+
+	gasToken, err := detectGasToken(ctx, rpcFlag, n.portalAddress)
+	if err != nil {
+		log.Warn("Unable to detect the chain's gas paying token, assuming ETH", "error", err)
+	} else if gasToken.IsCustom() {
+		log.Warn("Chain uses a custom gas token, withdrawal values are paid out in it on L1 instead of ETH, while L1 fees are still paid in ETH",
+			"token", gasToken.Address, "symbol", gasToken.Symbol)
+	}
+	n.gasToken = gasToken
+
+	if command == "cancel-withdrawal" {
+		if withdrawalFlag == "" {
+			log.Crit("Missing --withdrawal flag")
+		}
+		if err := runCancel(ctx, rpcFlag, n, common.HexToHash(withdrawalFlag)); err != nil {
+			log.Crit("Error checking withdrawal", "error", err)
+		}
+		return
+	}
+
+	if command == "reconcile" {
+		if expectedCSV == "" {
+			log.Crit("Missing --expected-csv flag")
+		}
+		if err := runReconcile(ctx, rpcFlag, n, expectedCSV); err != nil {
+			log.Crit("Reconciliation failed", "error", err)
+		}
+		return
+	}
+
+	// the signer is optional for read-only commands, and only used to determine the address
+	readOnlyAddress := func() common.Address {
+		if address != "" {
+			if !common.IsHexAddress(address) {
+				log.Crit("Invalid --address value", "value", address)
+			}
+			return common.HexToAddress(address)
+		}
+		if signerFlags != 1 {
+			return common.Address{}
+		}
+		s, err := signer.CreateSigner(privateKey, mnemonic, hdPath)
+		if err != nil {
+			log.Crit("Error creating signer", "error", err)
+		}
+		return s.Address()
 	}
-	withdrawal := common.HexToHash(withdrawalFlag)
 
-	options := 0
-	if privateKey != "" {
-		options++
+	if command == "backfill" {
+		if !isFlagSet(flag.CommandLine, "from-block") {
+			log.Crit("Missing --from-block flag")
+		}
+		addr := readOnlyAddress()
+		if addr == (common.Address{}) {
+			log.Crit("Missing --address flag or signer to backfill the activity of")
+		}
+		if err := runBackfill(ctx, rpcFlag, n, addr, fromBlock); err != nil {
+			log.Crit("Error backfilling activity", "error", err)
+		}
+		return
 	}
-	if ledger {
-		options++
+
+	if command == "history" {
+		if archived {
+			path := statePath
+			if path == "" {
+				path = defaultStatePath()
+			}
+			if err := runArchivedHistory(path, networkName); err != nil {
+				log.Crit("Error listing archived withdrawals", "error", err)
+			}
+			return
+		}
+		if !isFlagSet(flag.CommandLine, "from-block") {
+			log.Crit("Missing --from-block flag")
+		}
+		addr := readOnlyAddress()
+		if addr == (common.Address{}) {
+			log.Crit("Missing --address flag or signer to list the history of")
+		}
+		if err := runHistory(ctx, rpcFlag, n, addr, fromBlock); err != nil {
+			log.Crit("Error listing withdrawal history", "error", err)
+		}
+		return
 	}
-	if mnemonic != "" {
-		options++
+
+	if command == "scan" {
+		var from common.Address
+		if scanFrom != "" {
+			if from, err = parseAddress("--from", scanFrom); err != nil {
+				log.Crit("Invalid --from value", "error", err)
+			}
+		} else {
+			from = readOnlyAddress()
+		}
+		if from == (common.Address{}) {
+			log.Crit("Missing --from flag")
+		}
+		if err := runScan(ctx, rpcFlag, n, from, fromBlock); err != nil {
+			log.Crit("Error scanning withdrawals", "error", err)
+		}
+		return
 	}
-	if options != 1 {
+
+	if command == "check" {
+		if withdrawalFlag == "" {
+			log.Crit("Missing --withdrawal flag")
+		}
+		addr := readOnlyAddress()
+		if err := runCheck(ctx, rpcFlag, n, withdrawalRef{l2TxHash: common.HexToHash(withdrawalFlag), logIndex: logIndex}, addr, l2HaltThreshold, implementationsPath); err != nil {
+			log.Crit("Preflight checks failed", "error", err)
+		}
+		return
+	}
+
+	if command == "status" {
+		if withdrawalFlag == "" {
+			log.Crit("Missing --withdrawal flag")
+		}
+		addr := readOnlyAddress()
+		if err := runStatus(ctx, rpcFlag, n, withdrawalRef{l2TxHash: common.HexToHash(withdrawalFlag), logIndex: logIndex}, addr, pendingPath); err != nil {
+			log.Crit("Error tracing withdrawal", "error", err)
+		}
+		return
+	}
+
+	if command == "games" {
+		var before *big.Int
+		if gamesBefore != "" {
+			var ok bool
+			if before, ok = new(big.Int).SetString(gamesBefore, 10); !ok || before.Sign() < 0 {
+				log.Crit("Invalid --games-before value", "value", gamesBefore)
+			}
+		}
+		if gamesLimit <= 0 {
+			log.Crit("--games-limit must be positive", "value", gamesLimit)
+		}
+		var w *common.Hash
+		if withdrawalFlag != "" {
+			h := common.HexToHash(withdrawalFlag)
+			w = &h
+		}
+		if err := runGames(ctx, rpcFlag, n, w, before, gamesLimit); err != nil {
+			log.Crit("Error listing dispute games", "error", err)
+		}
+		return
+	}
+
+	// exporting a proof, calldata or an unsigned transaction signs nothing, so the signer is optional and only tells
+	// whose proof to expect or who sends the transaction
+	keyless := command == "export-proof" || calldataOnly || unsignedPath != ""
+	if signerFlags != 1 && !(keyless && signerFlags == 0) {
 		log.Crit("One (and only one) of --private-key, --ledger, --mnemonic must be set")
 	}
+	if calldataOnly && readOnlyAddress() == (common.Address{}) {
+		log.Crit("Missing --address flag or signer to build the calldata for, e.g. the Safe that will send it")
+	}
+	if unsignedPath != "" && readOnlyAddress() == (common.Address{}) {
+		log.Crit("Missing --address flag or signer to build the unsigned transaction for")
+	}
 
 	// Parse and validate gas configuration
-	gasConfig := GasConfig{
-		GasLimit:      gasLimit,
-		GasMultiplier: gasMultiplier,
+	gasConfig, err := rf.gasConfig()
+	if err != nil {
+		log.Crit("Invalid gas configuration", "error", err)
 	}
 
-	// Parse gas price (legacy transactions)
-	if gasPrice != "" {
-		gasPriceBig, ok := new(big.Int).SetString(gasPrice, 10)
-		if !ok {
-			log.Crit("Invalid --gas-price value", "value", gasPrice)
+	// Resolve confirmation timeouts, falling back to --tx-timeout for each operation
+	if txTimeout <= 0 {
+		log.Crit("--tx-timeout must be positive", "value", txTimeout)
+	}
+	txConfig := TxConfig{
+		ProveTimeout:    txTimeout,
+		FinalizeTimeout: txTimeout,
+		Confirmations:   confirmations,
+		WaitFinalized:   waitFinalized,
+		Nonces:          &withdraw.NonceTracker{},
+	}
+	if proveTxTimeout > 0 {
+		txConfig.ProveTimeout = proveTxTimeout
+	}
+	if finalizeTxTimeout > 0 {
+		txConfig.FinalizeTimeout = finalizeTxTimeout
+	}
+	txConfig.SendRPC = sendRpcFlag
+
+	var proverConfig ProverConfig
+	if proofSubmitter != "" {
+		if !faultProofs {
+			log.Crit("--proof-submitter is only supported with fault proofs, as legacy proofs can be finalized by anyone")
+		}
+		if !common.IsHexAddress(proofSubmitter) {
+			log.Crit("Invalid --proof-submitter value", "value", proofSubmitter)
+		}
+		proverConfig.ProofSubmitter = common.HexToAddress(proofSubmitter)
+	}
+	if proverURL != "" {
+		if faultProofs && proofSubmitter == "" {
+			log.Crit("Missing --proof-submitter flag, the prover service's address is needed as fault proofs are stored per submitter")
+		}
+		proverConfig.Prover = &withdraw.HTTPProver{URL: proverURL}
+	}
+	if gameType != "" {
+		if !faultProofs {
+			log.Crit("--game-type is only supported with fault proofs")
+		}
+		if proverURL != "" {
+			log.Crit("--game-type is not supported with --prover-url, as the prover service picks the game")
+		}
+		parsed, err := strconv.ParseUint(gameType, 10, 32)
+		if err != nil {
+			log.Crit("Invalid --game-type value", "value", gameType, "error", err)
+		}
+		t := uint32(parsed)
+		proverConfig.GameType = &t
+	}
+	if gameIndex != "" {
+		if !faultProofs {
+			log.Crit("--game-index is only supported with fault proofs")
+		}
+		if proverURL != "" {
+			log.Crit("--game-index is not supported with --prover-url, as the prover service picks the game")
+		}
+		index, ok := new(big.Int).SetString(gameIndex, 10)
+		if !ok || index.Sign() < 0 {
+			log.Crit("Invalid --game-index value", "value", gameIndex)
+		}
+		proverConfig.GameIndex = index
+	}
+	if l2OutputIndex != "" {
+		if faultProofs {
+			log.Crit("--l2-output-index is only supported without fault proofs, use --game-index instead")
+		}
+		if proverURL != "" {
+			log.Crit("--l2-output-index is not supported with --prover-url, as the prover service picks the output")
+		}
+		index, ok := new(big.Int).SetString(l2OutputIndex, 10)
+		if !ok || index.Sign() < 0 {
+			log.Crit("Invalid --l2-output-index value", "value", l2OutputIndex)
+		}
+		proverConfig.L2OutputIndex = index
+	}
+	if supervisorRpc != "" {
+		if !faultProofs {
+			log.Crit("--supervisor-rpc is only supported with fault proofs")
+		}
+		proverConfig.SupervisorRPC = supervisorRpc
+	}
+	if proofRpcs != "" {
+		if proverURL != "" {
+			log.Crit("--proof-rpcs is not supported with --prover-url, as the prover service fetches the proof")
+		}
+		for _, url := range strings.Split(proofRpcs, ",") {
+			if url = strings.TrimSpace(url); url != "" {
+				proverConfig.ProofRPCs = append(proverConfig.ProofRPCs, url)
+			}
 		}
-		gasConfig.GasPrice = gasPriceBig
 	}
 
-	// Parse max fee per gas (EIP-1559)
-	if maxFeePerGas != "" {
-		maxFeeBig, ok := new(big.Int).SetString(maxFeePerGas, 10)
-		if !ok {
-			log.Crit("Invalid --max-fee-per-gas value", "value", maxFeePerGas)
+	if importedProof != nil {
+		if !faultProofs {
+			log.Crit("--proof-file is only supported with fault proofs")
 		}
-		gasConfig.MaxFeePerGas = maxFeeBig
+		if proverURL != "" || gameType != "" || gameIndex != "" || proofRpcs != "" {
+			log.Crit("--proof-file is not supported with --prover-url, --game-type, --game-index or --proof-rpcs, as the file holds the proof and its game")
+		}
+		proverConfig.ImportedProof = importedProof
+	}
+
+	// instantiate shared variables
+	var s signer.Signer
+	if keyless && signerFlags == 0 {
+		s = signer.NewAddressSigner(readOnlyAddress())
+	} else if s, err = signer.CreateSigner(privateKey, mnemonic, hdPath); err != nil {
+		log.Crit("Error creating signer", "error", err)
 	}
 
-	// Parse max priority fee (EIP-1559)
-	if maxPriorityFee != "" {
-		maxPriorityBig, ok := new(big.Int).SetString(maxPriorityFee, 10)
-		if !ok {
-			log.Crit("Invalid --max-priority-fee value", "value", maxPriorityFee)
+	if command == "selftest" {
+		if err := runSelfTest(ctx, rpcFlag, n, s); err != nil {
+			log.Crit("Self-test failed", "error", err)
 		}
-		gasConfig.MaxPriorityFee = maxPriorityBig
+		return
 	}
 
-	// Parse max gas price (safety cap)
-	if maxGasPrice != "" {
-		maxGasPriceBig, ok := new(big.Int).SetString(maxGasPrice, 10)
-		if !ok {
-			log.Crit("Invalid --max-gas-price value", "value", maxGasPrice)
+	// a Safe's transactions are proposed to its owners rather than sent, so runs simulate them from the Safe and the
+	// signer only signs the proposal, as one of the owners
+	var safe *safeProposer
+	if safeFlag != "" {
+		safe = &safeProposer{safe: safeAddress, owner: s, serviceURL: safeService}
+		s = signer.NewAddressSigner(safeAddress)
+	}
+	// likewise, a smart account's calls are sent as UserOperations its owner signs
+	var userOps *userOpSender
+	if bundlerRpc != "" {
+		bundlerClient, err := dialRPC(ctx, bundlerRpc)
+		if err != nil {
+			log.Crit("Error dialing bundler RPC", "error", err)
+		}
+		bundler := &withdraw.Bundler{Client: bundlerClient, EntryPoint: entryPoint}
+		if paymasterURL != "" {
+			if bundler.Paymaster, err = dialRPC(ctx, paymasterURL); err != nil {
+				log.Crit("Error dialing paymaster service", "error", err)
+			}
 		}
-		gasConfig.MaxGasPrice = maxGasPriceBig
+		userOps = &userOpSender{bundler: bundler, account: smartAccount, owner: s}
+		s = signer.NewAddressSigner(smartAccount)
 	}
 
-	// Validate gas configuration
-	if gasConfig.GasPrice != nil && (gasConfig.MaxFeePerGas != nil || gasConfig.MaxPriorityFee != nil) {
-		log.Crit("Cannot use --gas-price with EIP-1559 flags (--max-fee-per-gas, --max-priority-fee)")
+	if !keyless && safe == nil && userOps == nil {
+		if err := checkKeyReuse(ctx, rpcFlag, signersPath, s.Address(), allowKeyReuse); err != nil {
+			log.Crit("Error checking signer reuse", "error", err)
+		}
 	}
 
-	// If one EIP-1559 flag is set, both should be set for clarity
-	if (gasConfig.MaxFeePerGas != nil) != (gasConfig.MaxPriorityFee != nil) {
-		log.Crit("Both --max-fee-per-gas and --max-priority-fee must be set for EIP-1559 transactions")
+	var ethUSD float64
+	if priceFeed != "" {
+		ethUSD, err = fetchETHUSD(ctx, rpcFlag, priceFeed, priceFeedPath)
+		if err != nil {
+			log.Warn("Unable to fetch the ETH price, costs will only be shown in ETH", "error", err)
+		} else {
+			log.Info("Fetched ETH price", "usd", ethUSD)
+		}
 	}
 
-	// Validate gas multiplier
-	if gasConfig.GasMultiplier < 1.0 {
-		log.Crit("--gas-multiplier must be >= 1.0", "value", gasConfig.GasMultiplier)
+	quorum, err := dialQuorum(ctx, quorumSpec, quorumRpcs)
+	if err != nil {
+		log.Crit("Error setting up quorum reads", "error", err)
 	}
 
-	// Warn if gas multiplier is set but explicit gas limit is also provided
-	if gasConfig.GasMultiplier > 1.0 && gasConfig.GasLimit > 0 {
-		log.Warn("--gas-multiplier is ignored when --gas-limit is explicitly set", "gas-multiplier", gasConfig.GasMultiplier, "gas-limit", gasConfig.GasLimit)
+	notifier := rf.notifier()
+
+	if !n.devnet {
+		warnIfL2Halted(ctx, n.l2RPC, l2HaltThreshold)
 	}
+	warnIfUpgraded(ctx, rpcFlag, n, implementationsPath)
 
-	// Validate max gas price cap against configured gas prices
-	if gasConfig.MaxGasPrice != nil {
-		if gasConfig.GasPrice != nil && gasConfig.GasPrice.Cmp(gasConfig.MaxGasPrice) > 0 {
-			log.Crit("--gas-price exceeds --max-gas-price safety cap", "gas-price", gasConfig.GasPrice, "max-gas-price", gasConfig.MaxGasPrice)
+	if statePath == "" && (command == "relay" || command == "daemon") {
+		// the relay and daemon commands keep their progress in the state store, so they always have one
+		statePath = defaultStatePath()
+	}
+	store, err := openStateStore(statePath)
+	if err != nil {
+		log.Crit("Error opening state file", "error", err)
+	}
+	defer store.Close()
+
+	settings := runSettings{
+		l1Rpc:        rpcFlag,
+		verifyL2Rpc:  verifyRpcFlag,
+		network:      n,
+		networkName:  networkName,
+		signer:       s,
+		gasConfig:    gasConfig,
+		txConfig:     txConfig,
+		proverConfig: proverConfig,
+		pendingPath:  pendingPath,
+		dryRun:       dryRun,
+		auto:         auto,
+		yes:          yes,
+		maxWait:      maxWait,
+		faults:       faults,
+		ethUSD:       ethUSD,
+		quorum:       quorum,
+		notifier:     notifier,
+		store:        store,
+		unsignedPath: unsignedPath,
+		safe:         safe,
+		userOps:      userOps,
+	}
+	var reloader *configReloader
+	if command == "relay" || command == "daemon" {
+		if reloader, err = newConfigReloader(flag.CommandLine, configPath, configExplicit, profile, pinned); err != nil {
+			log.Crit("Error loading config file", "error", err)
 		}
-		if gasConfig.MaxFeePerGas != nil && gasConfig.MaxFeePerGas.Cmp(gasConfig.MaxGasPrice) > 0 {
-			log.Crit("--max-fee-per-gas exceeds --max-gas-price safety cap", "max-fee-per-gas", gasConfig.MaxFeePerGas, "max-gas-price", gasConfig.MaxGasPrice)
+		if !keyless && safe == nil && userOps == nil {
+			reloader.checkSigner = func(address common.Address) error {
+				return checkKeyReuse(ctx, rpcFlag, signersPath, address, allowKeyReuse)
+			}
 		}
+		reloader.notifyHangup()
+	}
+	if command == "relay" {
+		rc := relayConfig{
+			Senders:      rf.relaySenders,
+			Targets:      rf.relayTargets,
+			PollInterval: rf.pollInterval,
+			MetricsPath:  metricsPath,
+			IndexPath:    indexPath,
+			ArchiveAfter: rf.archiveAfter,
+			Reloader:     reloader,
+		}
+		if isFlagSet(flag.CommandLine, "from-block") {
+			rc.FromBlock = &fromBlock
+		}
+		if err := runRelay(ctx, settings, rc); err != nil {
+			log.Crit("Error running relayer", "error", err)
+		}
+		return
 	}
 
-	// instantiate shared variables
-	s, err := signer.CreateSigner(privateKey, mnemonic, hdPath)
+	if len(withdrawals) == 0 && !resume {
+		log.Crit("Missing --withdrawal flag")
+	}
+	var refs []withdrawalRef
+	if len(withdrawals) > 0 {
+		refs, err = resolveWithdrawals(ctx, n.l2RPC, withdrawals, logIndex, allWithdrawals)
+		if err != nil {
+			log.Crit("Error resolving withdrawals", "error", err)
+		}
+	}
+	if resume {
+		refs, err = appendUnfinished(refs, store, networkName)
+		if err != nil {
+			log.Crit("Error reading the withdrawals to resume", "error", err)
+		}
+		if len(refs) == 0 {
+			log.Info("No unfinished withdrawals to resume", "network", networkName, "stateFile", statePath)
+			return
+		}
+	}
+	if command == "export-proof" {
+		if err := runExportProof(ctx, settings, refs[0], proofPath); err != nil {
+			log.Crit("Error exporting withdrawal proof", "error", err)
+		}
+		return
+	}
+	if command == "daemon" {
+		dc := daemonConfig{
+			HealthAddr:   healthAddr,
+			PollInterval: rf.pollInterval,
+			MetricsPath:  metricsPath,
+			IndexPath:    indexPath,
+			ArchiveAfter: rf.archiveAfter,
+			Reloader:     reloader,
+		}
+		if err := runDaemon(ctx, settings, refs[0], dc); err != nil {
+			log.Crit("Error running daemon", "error", err)
+		}
+		return
+	}
+	if batch {
+		runBatch(ctx, settings, refs, metricsPath)
+		return
+	}
+	if _, err := runWithdrawal(ctx, settings, refs[0], metrics); err != nil {
+		critRunError(err)
+	}
+}
+
+// printCostSummary prints to stderr the gas used, effective gas price and ETH spent by each transaction and in total, and
+// the USD equivalent if the ETH price is known.
+func printCostSummary(costs []withdraw.TxCost, ethUSD float64) {
+	if len(costs) == 0 {
+		return
+	}
+	usd := func(wei *big.Int) string {
+		if ethUSD <= 0 {
+			return ""
+		}
+		return fmt.Sprintf(" ($%.2f)", price.ToUSD(wei, ethUSD))
+	}
+	fmt.Fprintf(os.Stderr, "Transaction costs:\n")
+	for _, c := range costs {
+		fmt.Fprintf(os.Stderr, "  %-9s %s  block %s  gas used %d @ %s gwei  %s ETH%s\n", c.Action, c.TxHash, c.BlockNumber, c.GasUsed, withdraw.FormatGwei(c.EffectiveGasPrice), withdraw.FormatEther(c.Cost), usd(c.Cost))
+	}
+	total := withdraw.TotalCost(costs)
+	fmt.Fprintf(os.Stderr, "  %-9s %s ETH%s\n", "total", withdraw.FormatEther(total), usd(total))
+}
+
+// fetchETHUSD queries the configured price feed for the current ETH price in USD.
+func fetchETHUSD(ctx context.Context, l1Rpc, spec, jsonPath string) (float64, error) {
+	l1Client, err := dialEth(ctx, l1Rpc)
 	if err != nil {
-		log.Crit("Error creating signer", "error", err)
+		return 0, fmt.Errorf("error dialing L1 client: %w", err)
 	}
+	defer l1Client.Close()
+	feed, err := price.CreateFeed(spec, jsonPath, l1Client)
+	if err != nil {
+		return 0, err
+	}
+	return feed.ETHUSD(ctx)
+}
+
+// detectFaultProofs probes the portal through the L1 RPC to determine whether the network uses fault proofs.
+func detectFaultProofs(ctx context.Context, l1Rpc string, portal string) (bool, error) {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return false, fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	return withdraw.DetectFaultProofs(common.HexToAddress(portal), l1Client)
+}
+
+// detectGasToken reads the chain's gas paying token through the L1 RPC, returning nil for ETH.
+func detectGasToken(ctx context.Context, l1Rpc string, portal string) (*withdraw.GasToken, error) {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	return withdraw.DetectGasToken(common.HexToAddress(portal), l1Client)
+}
+
+// dialQuorum dials the additional L1 providers for quorum reads, returning nil if no quorum is configured.
+func dialQuorum(ctx context.Context, spec string, rpcs string) (*withdraw.Quorum, error) {
+	if spec == "" {
+		if rpcs != "" {
+			return nil, errors.New("--quorum-rpcs requires --quorum")
+		}
+		return nil, nil
+	}
+	required, total, err := withdraw.ParseQuorum(spec)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	if rpcs != "" {
+		urls = strings.Split(rpcs, ",")
+	}
+	if total != len(urls)+1 {
+		return nil, fmt.Errorf("quorum %s needs %d providers, but --rpc and --quorum-rpcs give %d", spec, total, len(urls)+1)
+	}
+	if required <= total/2 {
+		log.Warn("Quorum is not a majority of the L1 providers, conflicting values may both reach it and fail the read", "quorum", spec)
+	}
+	quorum := &withdraw.Quorum{Required: required}
+	for _, url := range urls {
+		client, err := dialEth(ctx, strings.TrimSpace(url))
+		if err != nil {
+			return nil, fmt.Errorf("error dialing quorum L1 client: %w", err)
+		}
+		quorum.Clients = append(quorum.Clients, client)
+	}
+	log.Info("Critical L1 state will be read from multiple providers", "quorum", spec)
+	return quorum, nil
+}
+
+// discoverPortal finds the OptimismPortal of a custom network through its L2.
+func discoverPortal(ctx context.Context, l1Rpc string, l2Rpc string) (common.Address, error) {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	l2Client, err := dialEth(ctx, l2Rpc)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	defer l2Client.Close()
+	return withdraw.DiscoverPortal(l1Client, l2Client)
+}
 
-	withdrawer, err := CreateWithdrawHelper(rpcFlag, withdrawal, n, s, gasConfig, dryRun)
+// discoverProofContract fills in the DisputeGameFactory or L2OutputOracle of a network, whichever its withdrawal flow
+// needs, from its portal if it wasn't given or is the zero address.
+func discoverProofContract(ctx context.Context, l1Rpc string, n *network) error {
+	isSet := func(address string) bool {
+		return common.HexToAddress(address) != (common.Address{})
+	}
+	if (n.faultProofs && isSet(n.disputeGameFactory)) || (!n.faultProofs && isSet(n.l2OOAddress)) {
+		return nil
+	}
+	l1Client, err := dialEth(ctx, l1Rpc)
 	if err != nil {
-		log.Crit("Error creating withdrawer", "error", err)
+		return fmt.Errorf("error dialing L1 client: %w", err)
 	}
+	defer l1Client.Close()
+
+	portal := common.HexToAddress(n.portalAddress)
+	if n.faultProofs {
+		factory, err := withdraw.DiscoverDisputeGameFactory(portal, l1Client)
+		if err != nil {
+			return err
+		}
+		n.disputeGameFactory = factory.Hex()
+		log.Info("Discovered DisputeGameFactory from the OptimismPortal", "disputeGameFactory", factory)
+		return nil
+	}
+	oracle, err := withdraw.DiscoverL2OutputOracle(portal, l1Client)
+	if err != nil {
+		return err
+	}
+	n.l2OOAddress = oracle.Hex()
+	log.Info("Discovered L2OutputOracle from the OptimismPortal", "l2OutputOracle", oracle)
+	return nil
+}
 
-	// handle withdrawals with or without the fault proofs withdrawer
-	isFinalized, err := withdrawer.IsProofFinalized()
+// warnIfL2Halted logs a prominent warning if the L2's latest block is older than threshold, so a halted chain isn't
+// mistaken for the tool being broken.
+func warnIfL2Halted(ctx context.Context, l2Rpc string, threshold time.Duration) {
+	l2Client, err := dialEth(ctx, l2Rpc)
 	if err != nil {
-		log.Crit("Error querying withdrawal finalization status", "error", err)
+		log.Warn("Unable to check L2 liveness", "error", err)
+		return
 	}
-	if isFinalized {
-		log.Info("Withdrawal already finalized")
+	defer l2Client.Close()
+	head, age, err := withdraw.L2HeadAge(ctx, l2Client)
+	if err != nil {
+		log.Warn("Unable to check L2 liveness", "error", err)
 		return
 	}
+	if age > threshold {
+		log.Warn("L2 CHAIN MAY BE HALTED: the latest L2 block is stale, output proposals and dispute games will stall until it recovers",
+			"l2Head", head, "age", age.Round(time.Second), "threshold", threshold)
+	}
+}
 
-	// TODO: Add functionality to generate output root proposal and prove to that proposal for FPs
-	err = withdrawer.CheckIfProvable()
+// checkNotPaused returns an error wrapping withdraw.ErrWithdrawalsPaused if the guardian paused withdrawals, as the
+// prove and finalize transactions would then revert and only waste gas. Failing to check is only warned about.
+func checkNotPaused(ctx context.Context, l1Rpc string, n network) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
 	if err != nil {
-		log.Crit("Withdrawal is not provable", "error", err)
+		log.Warn("Unable to check whether withdrawals are paused", "error", err)
+		return nil
 	}
+	defer l1Client.Close()
+	err = withdraw.CheckPaused(l1Client, common.HexToAddress(n.portalAddress))
+	if errors.Is(err, withdraw.ErrWithdrawalsPaused) {
+		return err
+	} else if err != nil {
+		log.Warn("Unable to check whether withdrawals are paused", "error", err)
+	}
+	return nil
+}
+
+// hiddenFlags are left out of the usage output, as they are only meant for test environments.
+var hiddenFlags = map[string]bool{
+	"inject-fault": true,
+}
+
+// usage prints the commands and every flag that isn't hidden or deprecated.
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-18s %s\n", name, commands[name])
+	}
+	fmt.Fprintf(out, "\nFlags:\n")
+	visible := flag.NewFlagSet(flag.CommandLine.Name(), flag.ContinueOnError)
+	visible.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if _, deprecated := flagAliases[f.Name]; !hiddenFlags[f.Name] && !deprecated {
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
+}
 
-	proofTime, err := withdrawer.GetProvenWithdrawalTime()
+// proofValidator is implemented by the withdrawers whose proofs can be invalidated after they were submitted.
+type proofValidator interface {
+	CheckProofValidity() error
+}
+
+// nextAction returns the action to take on a withdrawal in the state: the state's next action, except that a proven
+// withdrawal whose proof was invalidated is proven again.
+func nextAction(withdrawer withdraw.WithdrawHelper, state withdraw.State, proverConfig ProverConfig, signer common.Address) (withdraw.Action, error) {
+	action := state.NextAction()
+	if action != withdraw.ActionFinalize {
+		return action, nil
+	}
+	reprove, err := reproveIfInvalidated(withdrawer, proverConfig, signer)
 	if err != nil {
-		log.Crit("Error querying withdrawal proof", "error", err)
+		return "", err
+	}
+	if reprove {
+		return withdraw.ActionProve, nil
 	}
+	return action, nil
+}
 
-	if proofTime == 0 {
-		err = withdrawer.ProveWithdrawal()
+// reproveIfInvalidated reports whether a proven withdrawal must be proven again, because its proof can no longer be
+// finalized. Proofs by another submitter than the signer or the prover service can't be replaced, so an error with
+// instructions is returned instead.
+func reproveIfInvalidated(withdrawer withdraw.WithdrawHelper, proverConfig ProverConfig, signer common.Address) (bool, error) {
+	validator, ok := withdrawer.(proofValidator)
+	if !ok {
+		return false, nil
+	}
+	err := validator.CheckProofValidity()
+	var invalidated *withdraw.ProofInvalidatedError
+	if !errors.As(err, &invalidated) {
 		if err != nil {
-			log.Crit("Error proving withdrawal", "error", err)
+			log.Warn("Unable to check whether the proof is still valid", "error", err)
 		}
+		return false, nil
+	}
+	if proverConfig.Prover == nil && proverConfig.ProofSubmitter != (common.Address{}) && proverConfig.ProofSubmitter != signer {
+		return false, newRunError("Proof can no longer be finalized, the proof submitter must re-prove the withdrawal (or run without --proof-submitter to prove it with the signer)",
+			"proofSubmitter", proverConfig.ProofSubmitter, "game", invalidated.Game, "reason", invalidated.Reason)
+	}
+	log.Warn("Proof can no longer be finalized, re-proving the withdrawal", "game", invalidated.Game, "reason", invalidated.Reason)
+	return true, nil
+}
 
-		if faultProofs {
-			log.Info("Withdrawal successfully proven, finalize once dispute game finishes and finalization period elapses")
+// finalizationEstimator is implemented by the withdrawers that can tell when a proven withdrawal can be finalized.
+type finalizationEstimator interface {
+	FinalizationEstimate() (*withdraw.FinalizationEstimate, error)
+}
+
+// logFinalizationCountdown logs when a proven withdrawal can be finalized, for withdrawers that can estimate it. With
+// l1Finality, it also warns while the L1 block the proof was included in isn't finalized.
+func logFinalizationCountdown(withdrawer withdraw.WithdrawHelper, l1Finality bool) {
+	estimator, ok := withdrawer.(finalizationEstimator)
+	if !ok {
+		return
+	}
+	e, err := estimator.FinalizationEstimate()
+	if err != nil {
+		log.Warn("Unable to estimate when the withdrawal can be finalized", "error", err)
+		return
+	}
+	if l1Finality && !e.ProofFinalized {
+		log.Warn("The L1 block the withdrawal was proven in is not finalized yet, an L1 reorg could drop the proof and restart the countdown (use --wait-finalized to wait for finality)")
+	}
+	remaining := e.Remaining(time.Now())
+	if remaining == 0 {
+		if e.Legacy() {
+			log.Info("Withdrawal proof and L2 output have passed the finalization period")
 		} else {
-			log.Info("Withdrawal successfully proven, finalize once finalization period elapses")
+			log.Info("Withdrawal proof has matured and the dispute game is final")
 		}
 		return
 	}
+	fields := []interface{}{
+		"finalizableAt", time.Unix(int64(e.FinalizableAt), 0).UTC(),
+		"remaining", remaining.Round(time.Second),
+		"proofMaturesAt", time.Unix(int64(e.ProofMaturesAt), 0).UTC(),
+	}
+	if e.Legacy() {
+		fields = append(fields, "outputFinalAt", time.Unix(int64(e.OutputFinalAt), 0).UTC())
+	} else {
+		fields = append(fields,
+			"game", e.Game,
+			"gameStatus", e.GameStatus,
+			"gameFinalAt", time.Unix(int64(e.GameFinalAt), 0).UTC(),
+			"gameResolutionEstimated", e.GameResolutionEstimated,
+			"gameContested", e.GameContested)
+	}
+	log.Info("Withdrawal is not finalizable yet", append(fields, "proofFinalizedOnL1", e.ProofFinalized)...)
+}
 
-	// TODO: Add edge-case handling for FPs if a withdrawal needs to be re-proven due to blacklisted / failed dispute game resolution
-	err = withdrawer.FinalizeWithdrawal()
+// CreateWithdrawHelper dials the RPCs of the run's settings and creates the withdrawer of the withdrawal, for the
+// network's portal, with the run's signer and gas, transaction and prover settings. The returned func closes the
+// clients dialed, once the withdrawer is done with.
+func CreateWithdrawHelper(ctx context.Context, cfg runSettings, ref withdrawalRef) (withdraw.WithdrawHelper, func(), error) {
+	var clients rpcClients
+	withdrawer, err := createWithdrawHelper(ctx, cfg, ref, &clients)
 	if err != nil {
-		log.Crit("Error completing withdrawal", "error", err)
+		clients.Close()
+		return nil, nil, err
 	}
+	return withdrawer, clients.Close, nil
 }
 
-func CreateWithdrawHelper(l1Rpc string, withdrawal common.Hash, n network, s signer.Signer, gasConfig GasConfig, dryRun bool) (withdraw.WithdrawHelper, error) {
-	ctx := context.Background()
-
-	l1Client, err := ethclient.DialContext(ctx, l1Rpc)
+// createWithdrawHelper creates the withdrawer of CreateWithdrawHelper, adding the clients it dials to clients.
+func createWithdrawHelper(ctx context.Context, cfg runSettings, ref withdrawalRef, clients *rpcClients) (withdraw.WithdrawHelper, error) {
+	s, gasConfig, txConfig := cfg.signer, cfg.gasConfig, cfg.txConfig
+	l1Client, err := dialEth(ctx, cfg.l1Rpc)
 	if err != nil {
 		return nil, fmt.Errorf("Error dialing L1 client: %w", err)
 	}
+	clients.add(l1Client.Client())
 
 	l1ChainID, err := l1Client.ChainID(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("Error querying chain ID: %w", err)
 	}
 
-	l1Nonce, err := l1Client.PendingNonceAt(ctx, s.Address())
-	if err != nil {
-		return nil, fmt.Errorf("Error querying nonce: %w", err)
-	}
-
 	l1opts := &bind.TransactOpts{
 		From:    s.Address(),
 		Signer:  s.SignerFn(l1ChainID),
 		Context: ctx,
-		Nonce:   big.NewInt(int64(l1Nonce)),
+	}
+
+	// with a nonce tracker, each transaction picks its nonce through the backend, so those sent by earlier withdrawers
+	// of the run are accounted for
+	if txConfig.Nonces == nil {
+		l1Nonce, err := l1Client.PendingNonceAt(ctx, s.Address())
+		if err != nil {
+			return nil, fmt.Errorf("Error querying nonce: %w", err)
+		}
+		l1opts.Nonce = big.NewInt(int64(l1Nonce))
 	}
 
 	// Apply gas configuration to TransactOpts
@@ -406,56 +1603,79 @@ func CreateWithdrawHelper(l1Rpc string, withdrawal common.Hash, n network, s sig
 		log.Info("Max gas price safety cap enabled", "max-gas-price", gasConfig.MaxGasPrice.String())
 	}
 
-	l2Client, err := rpc.DialContext(ctx, n.l2RPC)
+	// bindings send transactions through the backend, which reads from the L1 client and journals what it sends
+	var backend bind.ContractBackend = l1Client
+	if txConfig.SendRPC != "" {
+		sendClient, err := dialEth(ctx, txConfig.SendRPC)
+		if err != nil {
+			return nil, fmt.Errorf("Error dialing send L1 client: %w", err)
+		}
+		clients.add(sendClient.Client())
+		sendChainID, err := sendClient.ChainID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error querying send L1 chain ID: %w", err)
+		}
+		if sendChainID.Cmp(l1ChainID) != 0 {
+			return nil, fmt.Errorf("send L1 RPC is for chain %s, but the L1 RPC is for chain %s", sendChainID, l1ChainID)
+		}
+		backend = &withdraw.SendBackend{Client: l1Client, Sender: sendClient}
+		log.Info("Sending transactions through the send L1 RPC", "chainID", l1ChainID)
+	}
+	if txConfig.Journal != nil {
+		backend = &withdraw.JournalBackend{ContractBackend: backend, Journal: txConfig.Journal}
+	}
+	if txConfig.Nonces != nil {
+		backend = &withdraw.NonceBackend{ContractBackend: backend, Nonces: txConfig.Nonces}
+	}
+
+	l2Client, err := dialRPC(ctx, cfg.network.l2RPC)
 	if err != nil {
 		return nil, fmt.Errorf("Error dialing L2 client: %w", err)
 	}
+	clients.add(l2Client)
 
-	if n.faultProofs {
-		portal, err := bindingspreview.NewOptimismPortal2(common.HexToAddress(n.portalAddress), l1Client)
+	var verifyL2Client *rpc.Client
+	if cfg.verifyL2Rpc != "" {
+		verifyL2Client, err = dialRPC(ctx, cfg.verifyL2Rpc)
 		if err != nil {
-			return nil, fmt.Errorf("Error binding OptimismPortal2 contract: %w", err)
+			return nil, fmt.Errorf("Error dialing verification L2 client: %w", err)
 		}
-
-		dgf, err := bindings.NewDisputeGameFactory(common.HexToAddress(n.disputeGameFactory), l1Client)
+		clients.add(verifyL2Client)
+		l2ChainID, err := ethclient.NewClient(l2Client).ChainID(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("Error binding DisputeGameFactory contract: %w", err)
-		}
-
-		return &withdraw.FPWithdrawer{
-			Ctx:           ctx,
-			L1Client:      l1Client,
-			L2Client:      l2Client,
-			L2TxHash:      withdrawal,
-			Portal:        portal,
-			Factory:       dgf,
-			Opts:          l1opts,
-			GasMultiplier: gasConfig.GasMultiplier,
-			UserGasLimit:  gasConfig.GasLimit,
-			DryRun:        dryRun,
-		}, nil
-	} else {
-		portal, err := bindings.NewOptimismPortal(common.HexToAddress(n.portalAddress), l1Client)
+			return nil, fmt.Errorf("Error querying L2 chain ID: %w", err)
+		}
+		verifyChainID, err := ethclient.NewClient(verifyL2Client).ChainID(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("Error binding OptimismPortal contract: %w", err)
+			return nil, fmt.Errorf("Error querying verification L2 chain ID: %w", err)
+		}
+		if l2ChainID.Cmp(verifyChainID) != 0 {
+			return nil, fmt.Errorf("verification L2 RPC is for chain %s, but the L2 RPC is for chain %s", verifyChainID, l2ChainID)
 		}
+		log.Info("Cross-checking withdrawal data against verification L2 RPC", "chainID", l2ChainID)
+	}
 
-		l2oo, err := bindings.NewL2OutputOracle(common.HexToAddress(n.l2OOAddress), l1Client)
-		if err != nil {
-			return nil, fmt.Errorf("Error binding L2OutputOracle contract: %w", err)
-		}
-
-		return &withdraw.Withdrawer{
-			Ctx:           ctx,
-			L1Client:      l1Client,
-			L2Client:      l2Client,
-			L2TxHash:      withdrawal,
-			Portal:        portal,
-			Oracle:        l2oo,
-			Opts:          l1opts,
-			GasMultiplier: gasConfig.GasMultiplier,
-			UserGasLimit:  gasConfig.GasLimit,
-			DryRun:        dryRun,
-		}, nil
+	proofSources, err := dialProofSources(ctx, l2Client, cfg.proverConfig.ProofRPCs, clients)
+	if err != nil {
+		return nil, err
+	}
+
+	kind := portalKindOf(cfg.network)
+	factory, ok := helperFactories[kind]
+	if !ok {
+		return nil, fmt.Errorf("unsupported portal kind %q", kind)
 	}
+	return factory(helperDeps{
+		runSettings:    cfg,
+		ctx:            ctx,
+		l1Client:       l1Client,
+		l2Client:       l2Client,
+		verifyL2Client: verifyL2Client,
+		backend:        backend,
+		opts:           l1opts,
+		withdrawal:     ref.l2TxHash,
+		logIndex:       ref.logIndex,
+		proofSources:   proofSources,
+		clients:        clients,
+	})
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// fakeValidator is a withdrawer whose proof validity check returns validity.
+type fakeValidator struct {
+	fakeWithdrawer
+	validity error
+	checked  bool
+}
+
+func (w *fakeValidator) CheckProofValidity() error {
+	w.checked = true
+	return w.validity
+}
+
+// stubProver is a prover service that is never asked to prove.
+type stubProver struct{}
+
+func (stubProver) Prove(ctx context.Context, req withdraw.ProveRequest) (common.Hash, error) {
+	return common.Hash{}, errors.New("not implemented")
+}
+
+func TestNextAction(t *testing.T) {
+	signer := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	invalidated := &withdraw.ProofInvalidatedError{Game: common.HexToAddress("0x3333333333333333333333333333333333333333"), Reason: "game was blacklisted"}
+	tests := []struct {
+		name        string
+		state       withdraw.State
+		withdrawer  withdraw.WithdrawHelper
+		prover      ProverConfig
+		want        withdraw.Action
+		wantChecked bool // Whether the proof's validity is checked
+		wantErr     string
+	}{
+		{
+			name:       "initiated",
+			state:      withdraw.StateInitiated,
+			withdrawer: &fakeValidator{validity: invalidated},
+			want:       withdraw.ActionProve,
+		},
+		{
+			name:       "finalized",
+			state:      withdraw.StateFinalized,
+			withdrawer: &fakeValidator{validity: invalidated},
+			want:       withdraw.ActionNone,
+		},
+		{
+			name:       "proven without fault proofs",
+			state:      withdraw.StateProven,
+			withdrawer: &fakeWithdrawer{},
+			want:       withdraw.ActionFinalize,
+		},
+		{
+			name:        "proven with a valid proof",
+			state:       withdraw.StateProven,
+			withdrawer:  &fakeValidator{},
+			want:        withdraw.ActionFinalize,
+			wantChecked: true,
+		},
+		{
+			name:        "proven, validity unknown",
+			state:       withdraw.StateProven,
+			withdrawer:  &fakeValidator{validity: errors.New("connection refused")},
+			want:        withdraw.ActionFinalize,
+			wantChecked: true,
+		},
+		{
+			name:        "proven by the signer, then invalidated",
+			state:       withdraw.StateProven,
+			withdrawer:  &fakeValidator{validity: invalidated},
+			want:        withdraw.ActionProve,
+			wantChecked: true,
+		},
+		{
+			name:        "proven by the signer as proof submitter, then invalidated",
+			state:       withdraw.StateProven,
+			withdrawer:  &fakeValidator{validity: invalidated},
+			prover:      ProverConfig{ProofSubmitter: signer},
+			want:        withdraw.ActionProve,
+			wantChecked: true,
+		},
+		{
+			name:        "proven by the prover service, then invalidated",
+			state:       withdraw.StateProven,
+			withdrawer:  &fakeValidator{validity: invalidated},
+			prover:      ProverConfig{Prover: stubProver{}, ProofSubmitter: other},
+			want:        withdraw.ActionProve,
+			wantChecked: true,
+		},
+		{
+			name:        "proven by another submitter, then invalidated",
+			state:       withdraw.StateProven,
+			withdrawer:  &fakeValidator{validity: invalidated},
+			prover:      ProverConfig{ProofSubmitter: other},
+			wantChecked: true,
+			wantErr:     "the proof submitter must re-prove the withdrawal",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := nextAction(tt.withdrawer, tt.state, tt.prover, signer)
+			checkErr(t, err, tt.wantErr)
+			if got != tt.want {
+				t.Errorf("got action %q, want %q", got, tt.want)
+			}
+			if v, ok := tt.withdrawer.(*fakeValidator); ok && v.checked != tt.wantChecked {
+				t.Errorf("got proof validity checked: %v, want %v", v.checked, tt.wantChecked)
+			}
+		})
+	}
+}
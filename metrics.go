@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// Outcomes of a run recorded in the metrics file.
+const (
+	outcomeSuccess     = "success"
+	outcomeFailure     = "failure"
+	outcomeInterrupted = "interrupted"
+	outcomeWaiting     = "waiting" // The relay or daemon found the withdrawal not provable or finalizable yet, or withdrawals paused
+)
+
+// rpcRequests counts the HTTP POST requests the RPC clients sent, i.e. their JSON-RPC calls (a batch counts once).
+var rpcRequests atomic.Uint64
+
+// countingTransport counts the POST requests sent through it in rpcRequests.
+type countingTransport struct {
+	http.RoundTripper
+}
+
+func (t countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost {
+		rpcRequests.Add(1)
+	}
+	return t.RoundTripper.RoundTrip(req)
+}
+
+// rpcHTTPClient is the HTTP client of every RPC client the tool dials, which counts their requests. Other HTTP
+// clients, such as the prover service's and notification webhooks', aren't counted.
+var rpcHTTPClient = &http.Client{Transport: countingTransport{RoundTripper: http.DefaultTransport}}
+
+// dialRPC dials the RPC at url, counting its requests if it's served over HTTP.
+func dialRPC(ctx context.Context, url string) (*rpc.Client, error) {
+	return rpc.DialOptions(ctx, url, rpc.WithHTTPClient(rpcHTTPClient))
+}
+
+// dialEth dials the eth client of the RPC at url, counting its requests if it's served over HTTP.
+func dialEth(ctx context.Context, url string) (*ethclient.Client, error) {
+	client, err := dialRPC(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(client), nil
+}
+
+// runMetrics is a line of the metrics file, appended at the end of each run that proves or finalizes a withdrawal.
+type runMetrics struct {
+	Time         time.Time   `json:"time"`
+	Network      string      `json:"network"`
+	Withdrawal   common.Hash `json:"withdrawal"`
+	Action       string      `json:"action,omitempty"` // The action the run took, or was taking when it failed
+	DryRun       bool        `json:"dryRun,omitempty"`
+	Outcome      string      `json:"outcome"`
+	Error        string      `json:"error,omitempty"`
+	Duration     float64     `json:"durationSeconds"`
+	RPCRequests  uint64      `json:"rpcRequests"`
+	Transactions int         `json:"transactions"`
+	GasUsed      uint64      `json:"gasUsed"`
+	CostWei      string      `json:"costWei"`
+}
+
+// metricsRecorder records a run's metrics to the metrics file once, when it succeeds or fails.
+type metricsRecorder struct {
+	path       string
+	start      time.Time
+	metrics    runMetrics
+	withdrawer withdraw.WithdrawHelper // Set once created, to read the costs of the transactions it sent
+	rpcBase    uint64                  // Requests the process sent before the run, for the runs of a batch
+	once       sync.Once
+}
+
+// newMetricsRecorder returns a recorder of the run started at start, appending to the metrics file at path. An empty
+// path disables recording.
+func newMetricsRecorder(path string, start time.Time, networkName string, withdrawal common.Hash, dryRun bool) *metricsRecorder {
+	return &metricsRecorder{
+		path:    path,
+		start:   start,
+		metrics: runMetrics{Network: networkName, Withdrawal: withdrawal, DryRun: dryRun},
+	}
+}
+
+// setAction records the action the run is taking.
+func (r *metricsRecorder) setAction(action withdraw.Action) {
+	r.metrics.Action = string(action)
+}
+
+// finish records the run's outcome and appends its metrics to the file, only the first time it's called.
+func (r *metricsRecorder) finish(outcome string, reason string) {
+	if r.path == "" {
+		return
+	}
+	r.once.Do(func() {
+		m := r.metrics
+		m.Time = time.Now().UTC()
+		m.Outcome = outcome
+		m.Error = reason
+		m.Duration = time.Since(r.start).Round(time.Millisecond).Seconds()
+		m.RPCRequests = rpcRequests.Load() - r.rpcBase
+		cost := new(big.Int)
+		if r.withdrawer != nil {
+			for _, c := range r.withdrawer.TxCosts() {
+				m.Transactions++
+				m.GasUsed += c.GasUsed
+				cost.Add(cost, c.Cost)
+			}
+		}
+		m.CostWei = cost.String()
+		if err := appendMetrics(r.path, m); err != nil {
+			log.Warn("Error recording run metrics", "error", err)
+		}
+	})
+}
+
+// finishOnCrit records the run as failed, or interrupted if ctx was cancelled, when a critical error is logged right
+// before the process exits.
+func (r *metricsRecorder) finishOnCrit(ctx context.Context) func(slog.Record) {
+	return func(rec slog.Record) {
+		reason := rec.Message
+		rec.Attrs(func(a slog.Attr) bool {
+			if a.Key == "error" {
+				reason = fmt.Sprintf("%s: %v", rec.Message, a.Value)
+				return false
+			}
+			return true
+		})
+		outcome := outcomeFailure
+		if ctx.Err() != nil {
+			outcome = outcomeInterrupted
+		}
+		r.finish(outcome, reason)
+	}
+}
+
+func appendMetrics(path string, m runMetrics) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening metrics file %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing metrics file %s: %w", path, err)
+	}
+	return nil
+}
+
+func readMetrics(path string) ([]runMetrics, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading metrics file %s: %w", path, err)
+	}
+	defer f.Close()
+	var runs []runMetrics
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var m runMetrics
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("error decoding metrics file %s line %d: %w", path, line, err)
+		}
+		runs = append(runs, m)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading metrics file %s: %w", path, err)
+	}
+	return runs, nil
+}
+
+// runStats is the summary of the recorded runs printed by the stats command.
+type runStats struct {
+	Network      string         `json:"network,omitempty"` // Empty for the summary of all networks
+	Runs         int            `json:"runs"`
+	Outcomes     map[string]int `json:"outcomes"`
+	Actions      map[string]int `json:"actions"`
+	DryRuns      int            `json:"dryRuns"`
+	Transactions int            `json:"transactions"`
+	GasUsed      uint64         `json:"gasUsed"`
+	CostWei      string         `json:"costWei"`
+	RPCRequests  uint64         `json:"rpcRequests"`
+	Duration     float64        `json:"durationSeconds"`
+	First        *time.Time     `json:"first,omitempty"`
+	Last         *time.Time     `json:"last,omitempty"`
+	cost         *big.Int
+}
+
+func (s *runStats) add(m runMetrics) {
+	s.Runs++
+	s.Outcomes[m.Outcome]++
+	if m.Action != "" {
+		s.Actions[m.Action]++
+	}
+	if m.DryRun {
+		s.DryRuns++
+	}
+	s.Transactions += m.Transactions
+	s.GasUsed += m.GasUsed
+	if cost, ok := new(big.Int).SetString(m.CostWei, 10); ok {
+		s.cost.Add(s.cost, cost)
+	}
+	s.RPCRequests += m.RPCRequests
+	s.Duration += m.Duration
+	if s.First == nil || m.Time.Before(*s.First) {
+		t := m.Time
+		s.First = &t
+	}
+	if s.Last == nil || m.Time.After(*s.Last) {
+		t := m.Time
+		s.Last = &t
+	}
+	s.CostWei = s.cost.String()
+}
+
+// runStatsCommand summarizes the runs recorded in the metrics file at path, in total and per network. The summaries
+// are printed to stdout as JSON lines, the total first, and to stderr as a report.
+func runStatsCommand(path string) error {
+	if path == "" {
+		return errors.New("no metrics file, please provide the --metrics-file flag")
+	}
+	runs, err := readMetrics(path)
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(os.Stderr, "No runs recorded in %s yet\n", path)
+		return nil
+	}
+
+	newStats := func(network string) *runStats {
+		return &runStats{Network: network, Outcomes: map[string]int{}, Actions: map[string]int{}, CostWei: "0", cost: new(big.Int)}
+	}
+	total := newStats("")
+	byNetwork := make(map[string]*runStats)
+	for _, m := range runs {
+		total.add(m)
+		if byNetwork[m.Network] == nil {
+			byNetwork[m.Network] = newStats(m.Network)
+		}
+		byNetwork[m.Network].add(m)
+	}
+	var names []string
+	for name := range byNetwork {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	enc := json.NewEncoder(os.Stdout)
+	if err := enc.Encode(total); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Runs recorded in %s:\n", path)
+	printStats("all networks", total)
+	for _, name := range names {
+		if err := enc.Encode(byNetwork[name]); err != nil {
+			return err
+		}
+		printStats(name, byNetwork[name])
+	}
+	return nil
+}
+
+// printStats writes the summary to stderr.
+func printStats(name string, s *runStats) {
+	fmt.Fprintf(os.Stderr, "\n%s (%s to %s)\n", name, s.First.Format(time.DateOnly), s.Last.Format(time.DateOnly))
+	fmt.Fprintf(os.Stderr, "  runs           %d (%d succeeded, %d failed, %d interrupted, %d waiting, %d dry runs)\n",
+		s.Runs, s.Outcomes[outcomeSuccess], s.Outcomes[outcomeFailure], s.Outcomes[outcomeInterrupted], s.Outcomes[outcomeWaiting], s.DryRuns)
+	fmt.Fprintf(os.Stderr, "  actions        %d prove, %d finalize, %d none\n",
+		s.Actions[string(withdraw.ActionProve)], s.Actions[string(withdraw.ActionFinalize)], s.Actions[string(withdraw.ActionNone)])
+	fmt.Fprintf(os.Stderr, "  transactions   %d\n", s.Transactions)
+	fmt.Fprintf(os.Stderr, "  gas used       %d\n", s.GasUsed)
+	fmt.Fprintf(os.Stderr, "  spent          %s ETH\n", withdraw.FormatEther(s.cost))
+	fmt.Fprintf(os.Stderr, "  rpc requests   %d (%.0f per run)\n", s.RPCRequests, float64(s.RPCRequests)/float64(s.Runs))
+	fmt.Fprintf(os.Stderr, "  time           %s (%s per run)\n",
+		seconds(s.Duration).Round(time.Second), seconds(s.Duration/float64(s.Runs)).Round(time.Second))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
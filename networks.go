@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/registry"
+	"github.com/base/withdrawer/withdraw"
+)
+
+const defaultNetworksFile = ".withdrawer-networks.toml"
+
+type network struct {
+	chainID            uint64
+	l1ChainID          uint64
+	l2RPC              string
+	portalAddress      string
+	l2OOAddress        string
+	disputeGameFactory string
+	faultProofs        bool
+	gasToken           *withdraw.GasToken // detected from the SystemConfig at runtime, nil means ETH
+	devnet             bool               // local devnet, relaxing checks that assume a long-lived public chain
+}
+
+var networks = map[string]network{
+	"base-mainnet": {
+		chainID:            8453,
+		l1ChainID:          1,
+		l2RPC:              "https://mainnet.base.org",
+		portalAddress:      "0x49048044D57e1C92A77f79988d21Fa8fAF74E97e",
+		l2OOAddress:        "0x0000000000000000000000000000000000000000",
+		disputeGameFactory: "0x43edB88C4B80fDD2AdFF2412A7BebF9dF42cB40e",
+		faultProofs:        true,
+	},
+	"base-sepolia": {
+		chainID:            84532,
+		l1ChainID:          11155111,
+		l2RPC:              "https://sepolia.base.org",
+		portalAddress:      "0x49f53e41452C74589E85cA1677426Ba426459e85",
+		l2OOAddress:        "0x0000000000000000000000000000000000000000",
+		disputeGameFactory: "0xd6E6dBf4F7EA0ac412fD8b65ED297e64BB7a06E1",
+		faultProofs:        true,
+	},
+	"op-mainnet": {
+		chainID:            10,
+		l1ChainID:          1,
+		l2RPC:              "https://mainnet.optimism.io",
+		portalAddress:      "0xbEb5Fc579115071764c7423A4f12eDde41f106Ed",
+		l2OOAddress:        "0x0000000000000000000000000000000000000000",
+		disputeGameFactory: "0xe5965Ab5962eDc7477C8520243A95517CD252fA9",
+		faultProofs:        true,
+	},
+	"op-sepolia": {
+		chainID:            11155420,
+		l1ChainID:          11155111,
+		l2RPC:              "https://sepolia.optimism.io",
+		portalAddress:      "0x16Fc5058F25648194471939df75CF27A2fdC48BC",
+		l2OOAddress:        "0x0000000000000000000000000000000000000000",
+		disputeGameFactory: "0x05F9613aDB30026FFd634f38e5C4dFd30a197Fa1",
+		faultProofs:        true,
+	},
+	// chains still proving withdrawals against an L2OutputOracle
+	"zora": {
+		chainID:            7777777,
+		l1ChainID:          1,
+		l2RPC:              "https://rpc.zora.energy",
+		portalAddress:      "0x1a0ad011913A150f69f6A19DF447A0CfD9551054",
+		l2OOAddress:        "0x9E6204F750cD866b299594e2aC9eA824E2e5f95c",
+		disputeGameFactory: "0x0000000000000000000000000000000000000000",
+		faultProofs:        false,
+	},
+	"mode": {
+		chainID:            34443,
+		l1ChainID:          1,
+		l2RPC:              "https://mainnet.mode.network",
+		portalAddress:      "0x8B34b14c7c7123459Cf3076b8Cb929BE097d0C07",
+		l2OOAddress:        "0x4317ba146D4933D889518a3e5E11Fe7a53199b04",
+		disputeGameFactory: "0x0000000000000000000000000000000000000000",
+		faultProofs:        false,
+	},
+	"lisk": {
+		chainID:            1135,
+		l1ChainID:          1,
+		l2RPC:              "https://rpc.api.lisk.com",
+		portalAddress:      "0x26dB93F8b8b4f7016240af62F7730979d353f9A7",
+		l2OOAddress:        "0x113cB99283AF242Da0A0C54347667edF531Aa7d6",
+		disputeGameFactory: "0x0000000000000000000000000000000000000000",
+		faultProofs:        false,
+	},
+}
+
+// lookupNetwork returns the built-in network with the given name or L2 chain ID.
+func lookupNetwork(nameOrChainID string) (network, bool) {
+	if n, ok := networks[nameOrChainID]; ok {
+		return n, true
+	}
+	chainID, err := strconv.ParseUint(nameOrChainID, 10, 64)
+	if err != nil {
+		return network{}, false
+	}
+	for _, n := range networks {
+		if n.chainID == chainID {
+			return n, true
+		}
+	}
+	return network{}, false
+}
+
+// networkSources records where networks that didn't come built in were defined, for the networks command.
+var networkSources = map[string]string{}
+
+// networkDefinition is an entry in a networks file. Keys match the custom network flags. Fields that are not set
+// keep their built-in values when overriding an existing network.
+type networkDefinition struct {
+	ChainID            *uint64 `toml:"chain-id" json:"chain-id"`
+	L1ChainID          *uint64 `toml:"l1-chain-id" json:"l1-chain-id"`
+	L2RPC              *string `toml:"l2-rpc" json:"l2-rpc"`
+	PortalAddress      *string `toml:"portal-address" json:"portal-address"`
+	L2OOAddress        *string `toml:"l2oo-address" json:"l2oo-address"`
+	DisputeGameFactory *string `toml:"dgf-address" json:"dgf-address"`
+	FaultProofs        *bool   `toml:"fault-proofs" json:"fault-proofs"`
+}
+
+// defaultNetworksPath returns ~/.withdrawer-networks.toml, or an empty string if the home directory cannot be
+// determined.
+func defaultNetworksPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultNetworksFile)
+}
+
+// loadNetworksFile reads user-defined networks from a TOML file, or a JSON file if path ends in .json, and adds
+// them to the built-in networks, overriding any with the same name. Each network is a table keyed by its name:
+//
+//	[my-chain]
+//	chain-id = 12345
+//	l1-chain-id = 1
+//	l2-rpc = "https://rpc.my-chain.example"
+//	portal-address = "0x..."
+//	dgf-address = "0x..."
+//	fault-proofs = true
+//
+// A missing file is only an error if it was explicitly requested.
+func loadNetworksFile(path string, explicit bool) error {
+	if path == "" {
+		return nil
+	}
+
+	defs := make(map[string]networkDefinition)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err := decodeNetworksJSON(path, &defs)
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading networks file %s: %w", path, err)
+		}
+	} else {
+		md, err := toml.DecodeFile(path, &defs)
+		if errors.Is(err, os.ErrNotExist) && !explicit {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading networks file %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown setting %q in networks file %s", undecoded[0].String(), path)
+		}
+	}
+
+	// apply in a stable order so errors are deterministic
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		n := defs[name].apply(networks[name])
+		if err := validateNetwork(n); err != nil {
+			return fmt.Errorf("invalid network %q in networks file %s: %w", name, path, err)
+		}
+		if _, ok := networks[name]; ok {
+			networkSources[name] = "overridden by " + path
+		} else {
+			networkSources[name] = "defined in " + path
+		}
+		for setting, value := range map[string]string{"portal-address": n.portalAddress, "dgf-address": n.disputeGameFactory, "l2oo-address": n.l2OOAddress} {
+			if common.IsHexAddress(value) {
+				warnIfUnknownAddress(n.chainID, setting, common.HexToAddress(value))
+			}
+		}
+		networks[name] = n
+	}
+	return nil
+}
+
+func decodeNetworksJSON(path string, defs *map[string]networkDefinition) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	return dec.Decode(defs)
+}
+
+// apply returns n with the fields set in the definition overridden.
+func (d networkDefinition) apply(n network) network {
+	if d.ChainID != nil {
+		n.chainID = *d.ChainID
+	}
+	if d.L1ChainID != nil {
+		n.l1ChainID = *d.L1ChainID
+	}
+	if d.L2RPC != nil {
+		n.l2RPC = *d.L2RPC
+	}
+	if d.PortalAddress != nil {
+		n.portalAddress = *d.PortalAddress
+	}
+	if d.L2OOAddress != nil {
+		n.l2OOAddress = *d.L2OOAddress
+	}
+	if d.DisputeGameFactory != nil {
+		n.disputeGameFactory = *d.DisputeGameFactory
+	}
+	if d.FaultProofs != nil {
+		n.faultProofs = *d.FaultProofs
+	}
+	return n
+}
+
+// validateNetwork checks that a network has everything needed to withdraw from it.
+func validateNetwork(n network) error {
+	if n.l2RPC == "" {
+		return errors.New("missing l2-rpc")
+	}
+	if _, err := parseAddress("portal-address", n.portalAddress); err != nil {
+		return err
+	}
+	var err error
+	if n.faultProofs {
+		_, err = parseAddress("dgf-address", n.disputeGameFactory)
+	} else {
+		_, err = parseAddress("l2oo-address", n.l2OOAddress)
+	}
+	return err
+}
+
+// runNetworks prints every network the tool knows about, with the contracts it will talk to on each. If an L1 RPC
+// is given, the implementations behind the contracts of the networks settling to that L1 are also shown.
+func runNetworks(ctx context.Context, l1Rpc string, implementationsPath string) error {
+	var l1Client *ethclient.Client
+	var l1ChainID uint64
+	if l1Rpc != "" {
+		var err error
+		if l1Client, err = dialEth(ctx, l1Rpc); err != nil {
+			return fmt.Errorf("error dialing L1 client: %w", err)
+		}
+		defer l1Client.Close()
+		chainID, err := l1Client.ChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("error querying L1 chain ID: %w", err)
+		}
+		l1ChainID = chainID.Uint64()
+	}
+
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		n := networks[name]
+		if i > 0 {
+			fmt.Println()
+		}
+		source, ok := networkSources[name]
+		if !ok {
+			source = "built-in"
+		}
+		fmt.Printf("%s (%s)\n", name, source)
+		fmt.Printf("  L1 chain ID:         %s\n", formatChainID(n.l1ChainID))
+		fmt.Printf("  L2 chain ID:         %s\n", formatChainID(n.chainID))
+		fmt.Printf("  L2 RPC:              %s\n", n.l2RPC)
+		fmt.Printf("  Fault proofs:        %t\n", n.faultProofs)
+		fmt.Printf("  OptimismPortal:      %s\n", n.portalAddress)
+		if n.faultProofs {
+			fmt.Printf("  DisputeGameFactory:  %s\n", n.disputeGameFactory)
+		} else {
+			fmt.Printf("  L2OutputOracle:      %s\n", n.l2OOAddress)
+		}
+
+		if l1Client == nil || n.l1ChainID != l1ChainID {
+			continue
+		}
+		impls, err := inspectImplementations(ctx, l1Client, n, implementationsPath)
+		if err != nil {
+			return err
+		}
+		for _, impl := range impls {
+			if !impl.IsProxy() {
+				fmt.Printf("  %-20s not a proxy%s\n", impl.name+":", formatVersion(impl.Version))
+				continue
+			}
+			fmt.Printf("  %-20s implementation %s%s\n", impl.name+":", impl.Implementation, formatVersion(impl.Version))
+			if impl.previous != (common.Address{}) {
+				fmt.Printf("  %-20s WARNING: changed from %s since the last run, it may have been upgraded\n", "", impl.previous)
+			}
+		}
+	}
+	return nil
+}
+
+func formatChainID(chainID uint64) string {
+	if chainID == 0 {
+		return "unknown"
+	}
+	return strconv.FormatUint(chainID, 10)
+}
+
+// verifyChainIDs checks that the L1 and L2 RPCs serve the chains the network expects, so a testnet RPC can't be
+// mixed up with a mainnet network or vice versa. An empty RPC url or an unknown expected chain ID skips that side.
+func verifyChainIDs(ctx context.Context, l1Rpc, l2Rpc string, n network) error {
+	check := func(layer, url string, want uint64) error {
+		if url == "" || want == 0 {
+			return nil
+		}
+		client, err := dialEth(ctx, url)
+		if err != nil {
+			return fmt.Errorf("error dialing %s client: %w", layer, err)
+		}
+		defer client.Close()
+		got, err := client.ChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("error querying %s chain ID: %w", layer, err)
+		}
+		if !got.IsUint64() || got.Uint64() != want {
+			return fmt.Errorf("%s RPC reports chain ID %s, but the network expects %d", layer, got, want)
+		}
+		return nil
+	}
+	if err := check("L1", l1Rpc, n.l1ChainID); err != nil {
+		return err
+	}
+	return check("L2", l2Rpc, n.chainID)
+}
+
+// resolveFromRegistry overrides the network's contract addresses with the ones the superchain registry at
+// registryURL lists for its L2 chain ID, so they stay correct as chains upgrade their contracts. The chain ID is
+// taken from the network, or from nameOrChainID if the network isn't known, in which case the L2 RPC also comes
+// from the registry.
+func resolveFromRegistry(ctx context.Context, registryURL string, nameOrChainID string, n network, known bool) (network, error) {
+	chainID := n.chainID
+	if !known {
+		var err error
+		if chainID, err = strconv.ParseUint(nameOrChainID, 10, 64); err != nil {
+			return network{}, fmt.Errorf("unknown network %q, networks that aren't built in must be given by L2 chain ID", nameOrChainID)
+		}
+	}
+	if chainID == 0 {
+		return network{}, fmt.Errorf("network %q has no L2 chain ID to look up", nameOrChainID)
+	}
+
+	chain, err := registry.Lookup(ctx, registryURL, chainID)
+	if err != nil {
+		return network{}, err
+	}
+	resolved := network{
+		chainID:            chainID,
+		l1ChainID:          chain.L1ChainID,
+		l2RPC:              n.l2RPC,
+		portalAddress:      chain.OptimismPortal.Hex(),
+		l2OOAddress:        chain.L2OutputOracle.Hex(),
+		disputeGameFactory: chain.DisputeGameFactory.Hex(),
+		faultProofs:        chain.FaultProofs,
+	}
+	if resolved.l2RPC == "" {
+		if len(chain.RPC) == 0 {
+			return network{}, fmt.Errorf("superchain registry has no RPC for chain ID %d", chainID)
+		}
+		resolved.l2RPC = chain.RPC[0]
+	}
+	if err := validateNetwork(resolved); err != nil {
+		return network{}, fmt.Errorf("invalid superchain registry entry for chain ID %d: %w", chainID, err)
+	}
+
+	if known {
+		for _, c := range networkContracts(n) {
+			for _, r := range networkContracts(resolved) {
+				if c.name == r.name && c.address != r.address {
+					log.Warn("Superchain registry address differs from the built-in one, using the registry's", "contract", c.name, "builtIn", c.address, "registry", r.address)
+				}
+			}
+		}
+	}
+	log.Info("Resolved contract addresses from the superchain registry", "chain", chain.Name, "chainID", chainID, "portal", resolved.portalAddress, "faultProofs", resolved.faultProofs)
+	return resolved, nil
+}
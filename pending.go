@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+const defaultPendingFile = ".withdrawer-pending.json"
+
+// defaultPendingPath returns ~/.withdrawer-pending.json, or an empty string if the home directory cannot be
+// determined.
+func defaultPendingPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultPendingFile)
+}
+
+// resumePending settles the transaction a previous run broadcast for the withdrawal and recorded in the journal,
+// before the withdrawal's state is read and the signer's nonce is picked for a new one.
+func resumePending(ctx context.Context, l1Rpc string, txConfig TxConfig) error {
+	if txConfig.Journal == nil {
+		return nil
+	}
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return err
+	}
+	defer l1Client.Close()
+	var sender bind.ContractTransactor = l1Client
+	if txConfig.SendRPC != "" {
+		sendClient, err := dialEth(ctx, txConfig.SendRPC)
+		if err != nil {
+			return err
+		}
+		defer sendClient.Close()
+		sender = sendClient
+	}
+	return withdraw.ResumePending(ctx, l1Client, sender, txConfig.Journal, max(txConfig.ProveTimeout, txConfig.FinalizeTimeout), txConfig.Confirmations, txConfig.WaitFinalized)
+}
+
+// clearPending removes the withdrawal's transaction from the journal once it is confirmed.
+func clearPending(txConfig TxConfig) {
+	if txConfig.Journal == nil {
+		return
+	}
+	if err := txConfig.Journal.Clear(); err != nil {
+		log.Warn("Error clearing confirmed transaction from the journal", "error", err)
+	}
+}
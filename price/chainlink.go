@@ -0,0 +1,74 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const aggregatorABI = `[
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"latestRoundData","stateMutability":"view","inputs":[],"outputs":[
+		{"name":"roundId","type":"uint80"},
+		{"name":"answer","type":"int256"},
+		{"name":"startedAt","type":"uint256"},
+		{"name":"updatedAt","type":"uint256"},
+		{"name":"answeredInRound","type":"uint80"}]}
+]`
+
+// staleAfter is how old a Chainlink answer can be before a warning is logged.
+const staleAfter = 3 * time.Hour
+
+var aggregator = func() abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(aggregatorABI))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}()
+
+// chainlinkFeed reads the ETH price from a Chainlink ETH/USD aggregator.
+type chainlinkFeed struct {
+	address  common.Address
+	contract *bind.BoundContract
+}
+
+func newChainlinkFeed(address common.Address, caller bind.ContractCaller) *chainlinkFeed {
+	return &chainlinkFeed{
+		address:  address,
+		contract: bind.NewBoundContract(address, aggregator, caller, nil, nil),
+	}
+}
+
+func (f *chainlinkFeed) ETHUSD(ctx context.Context) (float64, error) {
+	opts := &bind.CallOpts{Context: ctx}
+
+	var out []interface{}
+	if err := f.contract.Call(opts, &out, "decimals"); err != nil {
+		return 0, fmt.Errorf("error querying decimals of Chainlink aggregator %s: %w", f.address, err)
+	}
+	decimals := out[0].(uint8)
+
+	out = nil
+	if err := f.contract.Call(opts, &out, "latestRoundData"); err != nil {
+		return 0, fmt.Errorf("error querying Chainlink aggregator %s: %w", f.address, err)
+	}
+	answer, updatedAt := out[1].(*big.Int), out[3].(*big.Int)
+	if answer.Sign() <= 0 {
+		return 0, fmt.Errorf("chainlink aggregator %s returned invalid price %s", f.address, answer)
+	}
+	if age := time.Since(time.Unix(updatedAt.Int64(), 0)); age > staleAfter {
+		log.Warn("Chainlink ETH/USD price is stale", "aggregator", f.address, "age", age.Round(time.Minute))
+	}
+
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(decimals)), nil))
+	usd, _ := new(big.Float).Quo(new(big.Float).SetInt(answer), scale).Float64()
+	return usd, nil
+}
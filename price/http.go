@@ -0,0 +1,69 @@
+package price
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// httpTimeout bounds how long to wait for an HTTP price oracle.
+const httpTimeout = 10 * time.Second
+
+// httpFeed reads the ETH price from a JSON HTTP endpoint, e.g. CoinGecko's simple price API with the path
+// "ethereum.usd".
+type httpFeed struct {
+	url  string
+	path []string
+}
+
+func (f *httpFeed) ETHUSD(ctx context.Context) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error querying price oracle: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("price oracle returned %s", resp.Status)
+	}
+
+	var value interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&value); err != nil {
+		return 0, fmt.Errorf("error decoding price oracle response: %w", err)
+	}
+	for _, key := range f.path {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return 0, fmt.Errorf("price oracle response has no field %q", strings.Join(f.path, "."))
+		}
+		value = obj[key]
+	}
+
+	var usd float64
+	switch v := value.(type) {
+	case float64:
+		usd = v
+	case string:
+		// some oracles return decimal strings to avoid float precision loss
+		if usd, err = strconv.ParseFloat(v, 64); err != nil {
+			return 0, fmt.Errorf("invalid price %q from price oracle", v)
+		}
+	default:
+		return 0, fmt.Errorf("price oracle response has no numeric field %q", strings.Join(f.path, "."))
+	}
+	if usd <= 0 {
+		return 0, fmt.Errorf("invalid price %v from price oracle", usd)
+	}
+	return usd, nil
+}
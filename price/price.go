@@ -0,0 +1,48 @@
+package price
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultChainlinkETHUSD is the Chainlink ETH/USD aggregator on Ethereum mainnet.
+const DefaultChainlinkETHUSD = "0x5f4eC3Df9cbd43714FE2740f5E3616155c5b8419"
+
+// Feed defines the interface for querying the ETH price.
+type Feed interface {
+	ETHUSD(ctx context.Context) (float64, error) // ETHUSD returns the current price of 1 ETH in USD.
+}
+
+// CreateFeed creates a price feed from its spec, which is either "chainlink" for the mainnet Chainlink ETH/USD
+// aggregator, "chainlink:<address>" for another aggregator on L1, or an http(s) URL returning JSON, in which case
+// the price is read from the dot-separated jsonPath.
+func CreateFeed(spec, jsonPath string, l1 bind.ContractCaller) (Feed, error) {
+	switch {
+	case spec == "chainlink":
+		return newChainlinkFeed(common.HexToAddress(DefaultChainlinkETHUSD), l1), nil
+	case strings.HasPrefix(spec, "chainlink:"):
+		address := strings.TrimPrefix(spec, "chainlink:")
+		if !common.IsHexAddress(address) {
+			return nil, fmt.Errorf("invalid Chainlink aggregator address %q", address)
+		}
+		return newChainlinkFeed(common.HexToAddress(address), l1), nil
+	case strings.HasPrefix(spec, "http://") || strings.HasPrefix(spec, "https://"):
+		if jsonPath == "" {
+			return nil, fmt.Errorf("missing JSON path for HTTP price feed %s", spec)
+		}
+		return &httpFeed{url: spec, path: strings.Split(jsonPath, ".")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported price feed %q, expected chainlink, chainlink:<address> or an http(s) URL", spec)
+	}
+}
+
+// ToUSD converts a wei amount to USD at the given ETH price.
+func ToUSD(wei *big.Int, ethUSD float64) float64 {
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(1e18)).Float64()
+	return eth * ethUSD
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// networkContract is an L1 contract the tool talks to on a network.
+type networkContract struct {
+	name    string
+	address common.Address
+}
+
+// networkContracts returns the L1 contracts used on the network.
+func networkContracts(n network) []networkContract {
+	contracts := []networkContract{{"OptimismPortal", common.HexToAddress(n.portalAddress)}}
+	if n.faultProofs {
+		return append(contracts, networkContract{"DisputeGameFactory", common.HexToAddress(n.disputeGameFactory)})
+	}
+	return append(contracts, networkContract{"L2OutputOracle", common.HexToAddress(n.l2OOAddress)})
+}
+
+// contractImplementation is what a network contract resolves to, and whether that changed since it was last seen.
+type contractImplementation struct {
+	networkContract
+	*withdraw.ProxyInfo
+	previous common.Address // implementation seen on the last run, if it differs
+}
+
+// inspectImplementations resolves the implementations behind the network's proxied contracts, and compares them
+// against the ones remembered in the implementations file at path from previous runs, so that contract upgrades
+// which may change withdrawal semantics don't go unnoticed. Newly seen implementations are remembered. An empty
+// path disables the comparison.
+func inspectImplementations(ctx context.Context, l1Client *ethclient.Client, n network, path string) ([]contractImplementation, error) {
+	chainID, err := l1Client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	known, err := readImplementations(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var impls []contractImplementation
+	changed := false
+	for _, c := range networkContracts(n) {
+		info, err := withdraw.InspectProxy(ctx, l1Client, c.address)
+		if err != nil {
+			return nil, err
+		}
+		impl := contractImplementation{networkContract: c, ProxyInfo: info}
+		if info.IsProxy() {
+			key := fmt.Sprintf("%s/%s", chainID, c.address)
+			if previous, ok := known[key]; ok && previous != info.Implementation {
+				impl.previous = previous
+			}
+			if known[key] != info.Implementation {
+				known[key] = info.Implementation
+				changed = true
+			}
+		}
+		impls = append(impls, impl)
+	}
+
+	if changed && path != "" {
+		if err := writeImplementations(path, known); err != nil {
+			return nil, err
+		}
+	}
+	return impls, nil
+}
+
+// warnIfUpgraded logs the implementations behind the network's contracts, and a warning for each one that changed
+// since the last run.
+func warnIfUpgraded(ctx context.Context, l1Rpc string, n network, path string) {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		log.Warn("Unable to check contract implementations", "error", err)
+		return
+	}
+	defer l1Client.Close()
+	impls, err := inspectImplementations(ctx, l1Client, n, path)
+	if err != nil {
+		log.Warn("Unable to check contract implementations", "error", err)
+		return
+	}
+	for _, impl := range impls {
+		if !impl.IsProxy() {
+			continue
+		}
+		log.Debug("Contract implementation", "contract", impl.name, "proxy", impl.Address, "implementation", impl.Implementation, "version", impl.Version)
+		if impl.previous != (common.Address{}) {
+			log.Warn("Contract implementation changed since the last run, it may have been upgraded with different withdrawal semantics",
+				"contract", impl.name, "proxy", impl.Address, "previous", impl.previous, "implementation", impl.Implementation, "version", impl.Version)
+		}
+	}
+}
+
+func readImplementations(path string) (map[string]common.Address, error) {
+	known := make(map[string]common.Address)
+	if path == "" {
+		return known, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return known, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading implementations file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return nil, fmt.Errorf("error decoding implementations file %s: %w", path, err)
+	}
+	return known, nil
+}
+
+func writeImplementations(path string, known map[string]common.Address) error {
+	data, err := json.MarshalIndent(known, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing implementations file %s: %w", path, err)
+	}
+	return nil
+}
+
+// formatVersion returns " (version <v>)", or an empty string if the version is unknown.
+func formatVersion(version string) string {
+	if version == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (version %s)", version)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// expectedWithdrawal is a row of a reconciliation CSV.
+type expectedWithdrawal struct {
+	line      int
+	l2TxHash  common.Hash
+	amount    *big.Int       // in wei
+	recipient common.Address // L1 address expected to receive the ETH
+	status    withdraw.State // expected state, or empty if not tracked
+}
+
+// readExpectedWithdrawals reads a CSV with a header row and the columns hash (the L2 withdrawal tx hash), amount (in
+// ETH, or in the gas paying token on custom gas token chains), recipient and optionally status (initiated, proven or
+// finalized). Columns may be in any order and other columns are ignored.
+func readExpectedWithdrawals(path string) ([]expectedWithdrawal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header of %s: %w", path, err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"hash", "amount", "recipient"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("%s has no %q column", path, name)
+		}
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var expected []expectedWithdrawal
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return expected, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+
+		e := expectedWithdrawal{line: line}
+		hash := field(record, "hash")
+		if len(common.FromHex(hash)) != common.HashLength {
+			return nil, fmt.Errorf("%s line %d: invalid hash %q", path, line, hash)
+		}
+		e.l2TxHash = common.HexToHash(hash)
+		if e.amount, err = withdraw.ParseEther(field(record, "amount")); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		recipient := field(record, "recipient")
+		if !common.IsHexAddress(recipient) {
+			return nil, fmt.Errorf("%s line %d: invalid recipient %q", path, line, recipient)
+		}
+		e.recipient = common.HexToAddress(recipient)
+		if status := field(record, "status"); status != "" {
+			if e.status, err = withdraw.ParseState(status); err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+			}
+		}
+		expected = append(expected, e)
+	}
+}
+
+// runReconcile checks every withdrawal in the CSV against on-chain state, printing a line per withdrawal. It reports
+// withdrawals that are missing on L2, whose amount or recipient differ, and that are finalized although not expected
+// to be, and returns an error if there were any.
+func runReconcile(ctx context.Context, l1Rpc string, n network, path string) error {
+	expected, err := readExpectedWithdrawals(path)
+	if err != nil {
+		return err
+	}
+
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	l2Client, err := dialEth(ctx, n.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	state, err := newWithdrawalStateReader(l1Client, n)
+	if err != nil {
+		return err
+	}
+
+	discrepancies := 0
+	report := func(status string, e expectedWithdrawal, format string, args ...interface{}) {
+		if status != "OK" {
+			discrepancies++
+		}
+		fmt.Printf("[%s] line %d %s %s\n", status, e.line, e.l2TxHash, fmt.Sprintf(format, args...))
+	}
+
+	for _, e := range expected {
+		receipt, err := l2Client.TransactionReceipt(ctx, e.l2TxHash)
+		if errors.Is(err, ethereum.NotFound) {
+			report("MISSING", e, "no such transaction on L2")
+			continue
+		} else if err != nil {
+			return fmt.Errorf("error querying withdrawal %s: %w", e.l2TxHash, err)
+		}
+		details, err := withdraw.DecodeWithdrawal(receipt)
+		if err != nil {
+			report("MISSING", e, "transaction has no withdrawal: %v", err)
+			continue
+		}
+		if !details.ReceiptSuccess {
+			report("MISSING", e, "transaction reverted in L2 block %s", details.L2BlockNumber)
+			continue
+		}
+
+		actual, err := state.get(details.Hash)
+		if err != nil {
+			return fmt.Errorf("error querying state of withdrawal %s: %w", e.l2TxHash, err)
+		}
+
+		var mismatches []string
+		if details.Event.Value.Cmp(e.amount) != 0 {
+			mismatches = append(mismatches, fmt.Sprintf("amount is %s, expected %s", n.gasToken.FormatValue(details.Event.Value), n.gasToken.FormatValue(e.amount)))
+		}
+		if recipient := details.Recipient(); recipient != e.recipient {
+			mismatches = append(mismatches, fmt.Sprintf("recipient is %s, expected %s", recipient, e.recipient))
+		}
+		unexpectedlyFinalized := e.status != "" && e.status != withdraw.StateFinalized && actual == withdraw.StateFinalized
+		if e.status != "" && e.status != actual {
+			mismatches = append(mismatches, fmt.Sprintf("withdrawal is %s, expected %s", actual, e.status))
+		}
+		switch {
+		case unexpectedlyFinalized:
+			report("UNEXPECTED-FINALIZED", e, "%s", strings.Join(mismatches, "; "))
+		case len(mismatches) > 0:
+			report("MISMATCH", e, "%s", strings.Join(mismatches, "; "))
+		default:
+			report("OK", e, "%s to %s, %s", n.gasToken.FormatValue(details.Event.Value), e.recipient, actual)
+		}
+	}
+
+	fmt.Printf("%d withdrawals checked, %d discrepancies\n", len(expected), discrepancies)
+	if discrepancies > 0 {
+		return fmt.Errorf("%d discrepancies found", discrepancies)
+	}
+	return nil
+}
+
+// withdrawalStateReader determines how far along withdrawals are on L1.
+type withdrawalStateReader struct {
+	portal   *bindings.OptimismPortalCaller
+	portalFP *bindingspreview.OptimismPortal2Caller
+	index    *withdraw.Indexer // Answers for the withdrawals it watches, which are read once then watched (optional)
+}
+
+func newWithdrawalStateReader(l1Client *ethclient.Client, n network) (*withdrawalStateReader, error) {
+	address := common.HexToAddress(n.portalAddress)
+	if n.faultProofs {
+		portal, err := bindingspreview.NewOptimismPortal2Caller(address, l1Client)
+		if err != nil {
+			return nil, fmt.Errorf("error binding OptimismPortal2 contract: %w", err)
+		}
+		return &withdrawalStateReader{portalFP: portal}, nil
+	}
+	portal, err := bindings.NewOptimismPortalCaller(address, l1Client)
+	if err != nil {
+		return nil, fmt.Errorf("error binding OptimismPortal contract: %w", err)
+	}
+	return &withdrawalStateReader{portal: portal}, nil
+}
+
+// get returns whether the withdrawal is initiated, proven (by anyone) or finalized.
+func (r *withdrawalStateReader) get(hash common.Hash) (withdraw.State, error) {
+	if r.index == nil {
+		return r.read(hash)
+	}
+	if w, ok := r.index.Withdrawal(hash); ok {
+		return w.State, nil
+	}
+	s, err := r.read(hash)
+	if err != nil {
+		return "", err
+	}
+	r.index.Watch(hash, s)
+	return s, nil
+}
+
+// observe moves the withdrawal forward in the index, if any, once this process took it a step, without waiting for
+// the index to reach the step's L1 block.
+func (r *withdrawalStateReader) observe(hash common.Hash, s withdraw.State) {
+	if r.index != nil {
+		r.index.Watch(hash, s)
+	}
+}
+
+// read reads the withdrawal's state from the portal.
+func (r *withdrawalStateReader) read(hash common.Hash) (withdraw.State, error) {
+	if r.portalFP != nil {
+		finalized, err := r.portalFP.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+		if err != nil {
+			return "", err
+		}
+		if finalized {
+			return withdraw.StateFinalized, nil
+		}
+		submitters, err := r.portalFP.NumProofSubmitters(&bind.CallOpts{}, hash)
+		if err != nil {
+			return "", err
+		}
+		return withdraw.StateFromEvidence(false, submitters.Sign() > 0), nil
+	}
+
+	finalized, err := r.portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return "", err
+	}
+	if finalized {
+		return withdraw.StateFinalized, nil
+	}
+	proven, err := r.portal.ProvenWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return "", err
+	}
+	return withdraw.StateFromEvidence(false, proven.Timestamp.Sign() > 0), nil
+}
@@ -0,0 +1,139 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultURL is the base URL the superchain registry's published files are fetched from.
+const DefaultURL = "https://raw.githubusercontent.com/ethereum-optimism/superchain-registry/main"
+
+const (
+	chainListPath = "chainList.json"
+	addressesPath = "superchain/extra/addresses/addresses.json"
+)
+
+// httpTimeout bounds how long to wait for each registry file.
+const httpTimeout = 30 * time.Second
+
+// l1ChainIDs maps the registry's superchain names to the L1 chain they settle to.
+var l1ChainIDs = map[string]uint64{
+	"mainnet": 1,
+	"sepolia": 11155111,
+}
+
+// Chain is an L2 chain's entry in the superchain registry.
+type Chain struct {
+	ChainID            uint64
+	Name               string
+	RPC                []string
+	L1ChainID          uint64 // 0 if the registry's superchain is unknown
+	OptimismPortal     common.Address
+	DisputeGameFactory common.Address
+	L2OutputOracle     common.Address
+	FaultProofs        bool
+}
+
+// chainListEntry is an entry of the registry's chainList.json.
+type chainListEntry struct {
+	Name    string   `json:"name"`
+	ChainID uint64   `json:"chainId"`
+	RPC     []string `json:"rpc"`
+	Parent  struct {
+		Chain string `json:"chain"`
+	} `json:"parent"`
+	FaultProofs *struct {
+		Status string `json:"status"`
+	} `json:"faultProofs"`
+}
+
+// Lookup fetches the chain with the given L2 chain ID from the superchain registry published at baseURL, which may
+// also be the path of a local checkout of the registry.
+func Lookup(ctx context.Context, baseURL string, chainID uint64) (*Chain, error) {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+
+	var chains []chainListEntry
+	if err := fetchJSON(ctx, baseURL+"/"+chainListPath, &chains); err != nil {
+		return nil, err
+	}
+	var entry *chainListEntry
+	for i := range chains {
+		if chains[i].ChainID == chainID {
+			entry = &chains[i]
+			break
+		}
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("chain ID %d is not in the superchain registry", chainID)
+	}
+
+	var addresses map[string]map[string]common.Address
+	if err := fetchJSON(ctx, baseURL+"/"+addressesPath, &addresses); err != nil {
+		return nil, err
+	}
+	chainAddresses, ok := addresses[strconv.FormatUint(chainID, 10)]
+	if !ok {
+		return nil, fmt.Errorf("superchain registry has no addresses for chain ID %d", chainID)
+	}
+
+	chain := &Chain{
+		ChainID:            chainID,
+		Name:               entry.Name,
+		RPC:                entry.RPC,
+		L1ChainID:          l1ChainIDs[entry.Parent.Chain],
+		OptimismPortal:     chainAddresses["OptimismPortalProxy"],
+		DisputeGameFactory: chainAddresses["DisputeGameFactoryProxy"],
+		L2OutputOracle:     chainAddresses["L2OutputOracleProxy"],
+	}
+	if entry.FaultProofs != nil {
+		chain.FaultProofs = entry.FaultProofs.Status != "" && entry.FaultProofs.Status != "none"
+	} else {
+		chain.FaultProofs = chain.DisputeGameFactory != (common.Address{})
+	}
+	if chain.OptimismPortal == (common.Address{}) {
+		return nil, fmt.Errorf("superchain registry has no OptimismPortal address for chain ID %d", chainID)
+	}
+	return chain, nil
+}
+
+func fetchJSON(ctx context.Context, url string, out interface{}) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		data, err := os.ReadFile(url)
+		if err != nil {
+			return fmt.Errorf("error reading superchain registry: %w", err)
+		}
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("error decoding %s: %w", url, err)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying superchain registry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("superchain registry returned %s for %s", resp.Status, url)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding %s: %w", url, err)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// addressList is a flag holding addresses, which may be repeated or comma-separated.
+type addressList []common.Address
+
+// String returns the addresses comma-separated, as Set accepts them.
+func (l *addressList) String() string {
+	if l == nil {
+		return ""
+	}
+	hexes := make([]string, len(*l))
+	for i, a := range *l {
+		hexes[i] = a.Hex()
+	}
+	return strings.Join(hexes, ",")
+}
+
+func (l *addressList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		a, err := parseAddress("address", strings.TrimSpace(v))
+		if err != nil {
+			return err
+		}
+		*l = append(*l, a)
+	}
+	return nil
+}
+
+// relayConfig holds the settings of the relay command.
+type relayConfig struct {
+	Senders      []common.Address // Only relay withdrawals sent by these L2 addresses (empty means any)
+	Targets      []common.Address // Only relay withdrawals to these L1 addresses (empty means any)
+	FromBlock    *uint64          // L2 block to start watching from, the first time on the chain (nil means the L2 head)
+	PollInterval time.Duration    // Time between checks for new withdrawals and withdrawals to take a step
+	MetricsPath  string           // Metrics file the relayer's prove and finalize runs are recorded to
+	IndexPath    string           // File the index of portal and factory events is checkpointed to (empty means no index)
+	ArchiveAfter time.Duration    // Time after which finalized withdrawals are archived in the state store (zero means never)
+	Reloader     *configReloader  // Reloads the config file while the relayer runs (nil means never)
+}
+
+// relayState is the relayer's progress on an L2 chain, saved to the state store after every change.
+type relayState struct {
+	L2ChainID   uint64
+	NextBlock   uint64                      // First L2 block not watched yet
+	Withdrawals map[common.Hash]*relayEntry // Withdrawals not finalized yet, keyed by withdrawal hash
+}
+
+// relayEntry is a withdrawal the relayer tracks until it's finalized, scheduled like the withdrawals of a
+// withdraw.Scheduler.
+type relayEntry = withdraw.ScheduledWithdrawal
+
+// runRelay watches the network's L2 for withdrawals, filtered by sender and target, and takes each through its
+// lifecycle: it's proven once provable and finalized once finalizable, with the signer of cfg paying for both. Every
+// tracked withdrawal that is due is given its next step each poll interval, and retried later if it isn't ready yet
+// or failed. Progress is kept in the state store, per L2 chain, so the relayer resumes where it left off after a
+// restart. It runs until ctx is cancelled.
+func runRelay(ctx context.Context, cfg runSettings, rc relayConfig) error {
+	if cfg.store == nil {
+		return errors.New("missing --state-file")
+	}
+	l1Client, err := dialEth(ctx, cfg.l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	l2Client, err := dialEth(ctx, cfg.network.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	defer l2Client.Close()
+	l2ChainID, err := l2Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L2 chain ID: %w", err)
+	}
+	stateReader, err := newWithdrawalStateReader(l1Client, cfg.network)
+	if err != nil {
+		return err
+	}
+
+	if stateReader.index, err = openIndex(ctx, l1Client, cfg.network, rc.IndexPath); err != nil {
+		return err
+	}
+
+	state, err := cfg.store.loadRelay(l2ChainID.Uint64())
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		state = &relayState{L2ChainID: l2ChainID.Uint64(), Withdrawals: make(map[common.Hash]*relayEntry)}
+		if rc.FromBlock != nil {
+			state.NextBlock = *rc.FromBlock
+		} else {
+			head, err := relayHead(ctx, l2Client)
+			if err != nil {
+				return err
+			}
+			state.NextBlock = head + 1
+		}
+		log.Info("Starting relayer", "network", cfg.networkName, "fromBlock", state.NextBlock, "state", cfg.store.path)
+	} else {
+		if rc.FromBlock != nil {
+			log.Warn("Ignoring --from-block, resuming from the state file", "state", cfg.store.path)
+		}
+		log.Info("Resuming relayer", "network", cfg.networkName, "fromBlock", state.NextBlock, "tracked", len(state.Withdrawals), "state", cfg.store.path)
+	}
+	if err := cfg.store.saveRelay(state); err != nil {
+		return err
+	}
+
+	r := &relayer{cfg: cfg, rc: rc, l2: l2Client, stateReader: stateReader, state: state}
+	stepper := &withdrawalStepper{cfg: &r.cfg, stateReader: stateReader, metricsPath: rc.MetricsPath}
+	r.scheduler, err = withdraw.NewStepScheduler(&relayStore{store: cfg.store, state: state}, stepper.step, withdraw.SchedulerOptions{
+		PollInterval: rc.PollInterval,
+		Timing:       cfg.timing,
+		OnStep:       stepper.finish,
+		OnFinalized: func(hash common.Hash, e relayEntry) {
+			log.Info("Withdrawal finalized, no longer tracking it", "withdrawalHash", hash, "l2TxHash", e.L2TxHash)
+			if stateReader.index != nil {
+				stateReader.index.Forget(hash)
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		if err := r.watch(ctx); err != nil && ctx.Err() == nil {
+			log.Error("Error watching L2 for withdrawals, retrying next poll", "error", err)
+		}
+		if games := syncIndex(ctx, stateReader.index); games > 0 {
+			r.scheduler.ProvableSoon()
+		}
+		r.scheduler.RunDue(ctx)
+		archiveWithdrawals(r.cfg.store, r.cfg.timing.Now(), r.rc.ArchiveAfter)
+		hup := false
+		select {
+		case <-ctx.Done():
+			log.Info("Stopping relayer", "nextBlock", state.NextBlock, "tracked", len(state.Withdrawals))
+			return nil
+		case <-r.cfg.timing.After(r.rc.PollInterval):
+		case <-r.rc.Reloader.hangups():
+			hup = true
+		}
+		r.reload(ctx, hup)
+	}
+}
+
+// relayer holds the clients and progress of a running relay command.
+type relayer struct {
+	cfg         runSettings
+	rc          relayConfig
+	l2          *ethclient.Client
+	stateReader *withdrawalStateReader
+	state       *relayState
+	scheduler   *withdraw.Scheduler // Takes the tracked withdrawals their next steps, keeping them in state
+}
+
+// relayStore keeps the withdrawals a relayer's scheduler tracks in its relay state, saved to the state store.
+type relayStore struct {
+	store *stateStore
+	state *relayState
+}
+
+func (s *relayStore) Load() (map[common.Hash]*withdraw.ScheduledWithdrawal, error) {
+	return s.state.Withdrawals, nil
+}
+
+func (s *relayStore) Save(withdrawals map[common.Hash]*withdraw.ScheduledWithdrawal) error {
+	s.state.Withdrawals = withdrawals
+	return s.store.saveRelay(s.state)
+}
+
+// reload applies the changes to the config file, if it was modified or hup is set. Changing the senders and targets
+// only filters the withdrawals of the L2 blocks watched from then on, the ones tracked already keep being relayed.
+func (r *relayer) reload(ctx context.Context, hup bool) {
+	f := r.rc.Reloader.reload(ctx, &r.cfg, hup)
+	if f == nil {
+		return
+	}
+	r.rc.Senders, r.rc.Targets = f.relaySenders, f.relayTargets
+	r.rc.PollInterval, r.rc.ArchiveAfter = f.pollInterval, f.archiveAfter
+	r.scheduler.SetPollInterval(r.rc.PollInterval)
+}
+
+// watch starts tracking the withdrawals initiated in the L2 blocks up to the safe head that weren't watched yet.
+func (r *relayer) watch(ctx context.Context) error {
+	head, err := relayHead(ctx, r.l2)
+	if err != nil {
+		return err
+	}
+	if head < r.state.NextBlock {
+		return nil
+	}
+	messages, err := withdraw.FindMessagesPassed(ctx, r.l2, r.state.NextBlock, head, r.rc.Senders, r.rc.Targets)
+	if err != nil {
+		return err
+	}
+	tracked := r.scheduler.Withdrawals()
+	for _, m := range messages {
+		if _, ok := tracked[m.WithdrawalHash]; ok {
+			continue
+		}
+		log.Info("Tracking new withdrawal", "withdrawalHash", m.WithdrawalHash, "l2TxHash", m.L2TxHash, "logIndex", m.LogIndex,
+			"l2Block", m.L2BlockNumber, "sender", m.Sender, "target", m.Target)
+		if err := r.scheduler.Add(m.WithdrawalHash, relayEntry{L2TxHash: m.L2TxHash, LogIndex: m.LogIndex, L2Block: m.L2BlockNumber}); err != nil {
+			return err
+		}
+	}
+	log.Debug("Watched L2 for withdrawals", "fromBlock", r.state.NextBlock, "toBlock", head, "found", len(messages))
+	r.state.NextBlock = head + 1
+	return r.cfg.store.saveRelay(r.state)
+}
+
+// withdrawalStepper takes the withdrawals of the relay and daemon commands their next step, as their scheduler's
+// StepFunc, with a run like one without a command, and records each run to the metrics file once its outcome is known.
+type withdrawalStepper struct {
+	cfg         *runSettings // Settings of the runs, as last reloaded
+	stateReader *withdrawalStateReader
+	ref         *withdrawalRef // Withdrawal as given on the command line, for the daemon, so its journal key is stable
+	metricsPath string
+	metrics     *metricsRecorder // Of the run of the step being taken, if any
+}
+
+// step takes the withdrawal its next step, unless it's already finalized, by this process or anyone else.
+func (s *withdrawalStepper) step(ctx context.Context, hash common.Hash, e relayEntry) (withdraw.State, error) {
+	s.metrics = nil
+	state, err := s.stateReader.get(hash)
+	if err != nil {
+		return e.State, fmt.Errorf("error querying withdrawal state: %w", err)
+	}
+	if state == withdraw.StateFinalized {
+		return state, nil
+	}
+
+	logIndex := e.LogIndex
+	ref := withdrawalRef{l2TxHash: e.L2TxHash, logIndex: &logIndex}
+	if s.ref != nil {
+		ref = *s.ref
+	}
+	cfg := *s.cfg
+	s.metrics = newMetricsRecorder(s.metricsPath, cfg.timing.Now(), cfg.networkName, e.L2TxHash, cfg.dryRun)
+	s.metrics.rpcBase = rpcRequests.Load()
+	res, err := runWithdrawal(ctx, cfg, ref, s.metrics)
+	switch {
+	case err != nil || cfg.dryRun:
+		return state, err
+	case res.Action == string(withdraw.ActionProve):
+		state = withdraw.StateProven
+	default:
+		state = withdraw.StateFinalized
+	}
+	s.stateReader.observe(hash, state)
+	return state, nil
+}
+
+// finish records the run of the step, if one was taken, with the step's outcome: waits for the withdrawal to become
+// provable or finalizable, or for the portal to be unpaused, are told apart from failures.
+func (s *withdrawalStepper) finish(_ common.Hash, _ relayEntry, outcome withdraw.StepOutcome, err error) {
+	if s.metrics == nil {
+		return
+	}
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	// the step outcomes are the metrics file's outcomes
+	s.metrics.finish(string(outcome), reason)
+	s.metrics = nil
+}
+
+// archiveWithdrawals archives the withdrawals the store records as finalized more than retention before now, unless
+// retention is zero. Failing to is only logged, as it's retried on the next poll.
+func archiveWithdrawals(store *stateStore, now time.Time, retention time.Duration) {
+	if retention == 0 {
+		return
+	}
+	archived, err := store.archiveFinalized(now, retention)
+	if err != nil {
+		log.Warn("Error archiving finalized withdrawals, retrying next poll", "error", err)
+		return
+	}
+	if archived > 0 {
+		log.Info("Archived finalized withdrawals", "count", archived, "finalizedBefore", now.Add(-retention).UTC().Format(time.RFC3339), "state", store.path)
+	}
+}
+
+// openIndex returns the index of the network's portal and dispute game factory events checkpointed to path, or nil if
+// path is empty.
+func openIndex(ctx context.Context, l1Client *ethclient.Client, n network, path string) (*withdraw.Indexer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	var factory *common.Address
+	if n.faultProofs && n.disputeGameFactory != "" {
+		f := common.HexToAddress(n.disputeGameFactory)
+		factory = &f
+	}
+	return withdraw.NewIndexer(ctx, l1Client, common.HexToAddress(n.portalAddress), factory, path)
+}
+
+// syncIndex indexes the events of the new L1 blocks, if there's an index, returning the number of dispute games
+// created in them. Failing to is only logged, as the index catches up on the next sync.
+func syncIndex(ctx context.Context, index *withdraw.Indexer) int {
+	if index == nil {
+		return 0
+	}
+	games, err := index.Sync(ctx)
+	if err != nil && ctx.Err() == nil {
+		log.Warn("Error indexing L1 events, retrying next poll", "error", err)
+	}
+	if games > 0 {
+		log.Info("New dispute games created, retrying withdrawals waiting to become provable", "games", games)
+	}
+	return games
+}
+
+// relayHead returns the L2 safe head, up to which withdrawals are watched as they won't be reorged out, or the latest
+// block if the L2 RPC doesn't track the safe head.
+func relayHead(ctx context.Context, l2 *ethclient.Client) (uint64, error) {
+	header, err := l2.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+	if err != nil {
+		log.Debug("Unable to query the L2 safe head, using the latest block", "error", err)
+		header, err = l2.HeaderByNumber(ctx, nil)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error querying L2 head: %w", err)
+	}
+	return header.Number.Uint64(), nil
+}
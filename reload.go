@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// reloadableFlags are the settings the relay and daemon commands apply while they run when the config file is
+// reloaded: the withdrawals to relay, the poll and archive intervals, the gas policy and the notification endpoints.
+type reloadableFlags struct {
+	relaySenders     addressList
+	relayTargets     addressList
+	pollInterval     time.Duration
+	archiveAfter     time.Duration
+	gasLimit         uint64
+	gasPrice         string
+	maxFeePerGas     string
+	maxPriorityFee   string
+	gasMultiplier    float64
+	maxGasPrice      string
+	baseFeeThreshold string
+	minBalance       string
+	notifyWebhook    string
+	notifySlack      string
+}
+
+// register defines the flags on fs, set to their defaults.
+func (f *reloadableFlags) register(fs *flag.FlagSet) {
+	// Gas configuration flags
+	fs.Uint64Var(&f.gasLimit, "gas-limit", 0, "Gas limit for transactions (overrides automatic estimation)")
+	fs.StringVar(&f.gasPrice, "gas-price", "", "Gas price in wei for legacy transactions")
+	fs.StringVar(&f.maxFeePerGas, "max-fee-per-gas", "", "Maximum fee per gas in wei for EIP-1559 transactions")
+	fs.StringVar(&f.maxPriorityFee, "max-priority-fee", "", "Maximum priority fee per gas in wei for EIP-1559 transactions")
+	fs.Float64Var(&f.gasMultiplier, "gas-multiplier", 1.0, "Multiplier for estimated gas limit (default 1.0)")
+	fs.StringVar(&f.maxGasPrice, "max-gas-price", "", "Maximum gas price cap in wei (safety limit)")
+	fs.StringVar(&f.baseFeeThreshold, "basefee-threshold", "", "Wait to submit transactions while the L1 base fee is above this many wei, resuming once it drops")
+	fs.StringVar(&f.minBalance, "min-balance", "", "Pause submissions while the signer's L1 balance is below this many ETH (e.g. 0.2eth), alerting the notifiers and resuming once topped up")
+
+	fs.StringVar(&f.notifyWebhook, "notify-webhook", "", "URL to POST a JSON notification to when the withdrawal is proven, finalizable or finalized, or fails")
+	fs.StringVar(&f.notifySlack, "notify-slack", "", "Slack incoming webhook URL to post a message to when the withdrawal is proven, finalizable or finalized, or fails")
+
+	fs.Var(&f.relaySenders, "relay-sender", "L2 address whose withdrawals to relay, for the relay command, may be repeated (defaults to any, bridge withdrawals are sent by the L2CrossDomainMessenger)")
+	fs.Var(&f.relayTargets, "relay-target", "L1 address the withdrawals to relay are sent to, for the relay command, may be repeated (defaults to any)")
+	fs.DurationVar(&f.pollInterval, "poll-interval", time.Minute, "Time between checks for new withdrawals and withdrawals ready for their next step, for the relay command, or on the withdrawal, for the daemon command")
+	fs.DurationVar(&f.archiveAfter, "archive-after", defaultArchiveAfter, "Time after which the relay and daemon commands archive finalized withdrawals in --state-file, which the history command lists with --archived (0 keeps them active)")
+}
+
+// gasConfig parses and validates the gas flags.
+func (f *reloadableFlags) gasConfig() (GasConfig, error) {
+	gasConfig := GasConfig{
+		GasLimit:      f.gasLimit,
+		GasMultiplier: f.gasMultiplier,
+	}
+
+	wei := []struct {
+		name  string
+		value string
+		field **big.Int
+	}{
+		{"gas-price", f.gasPrice, &gasConfig.GasPrice},               // legacy transactions
+		{"max-fee-per-gas", f.maxFeePerGas, &gasConfig.MaxFeePerGas}, // EIP-1559
+		{"max-priority-fee", f.maxPriorityFee, &gasConfig.MaxPriorityFee},
+		{"max-gas-price", f.maxGasPrice, &gasConfig.MaxGasPrice},         // safety cap
+		{"basefee-threshold", f.baseFeeThreshold, &gasConfig.BaseFeeMax}, // deferred submission
+	}
+	for _, w := range wei {
+		if w.value == "" {
+			continue
+		}
+		v, ok := new(big.Int).SetString(w.value, 10)
+		if !ok {
+			return GasConfig{}, fmt.Errorf("invalid --%s value %q", w.name, w.value)
+		}
+		*w.field = v
+	}
+
+	// Parse minimum signer balance (paused submission)
+	if f.minBalance != "" {
+		minBalanceWei, err := withdraw.ParseEther(strings.TrimSuffix(strings.ToLower(f.minBalance), "eth"))
+		if err != nil {
+			return GasConfig{}, fmt.Errorf("invalid --min-balance value %q: %w", f.minBalance, err)
+		}
+		gasConfig.MinBalance = minBalanceWei
+	}
+
+	if gasConfig.GasPrice != nil && (gasConfig.MaxFeePerGas != nil || gasConfig.MaxPriorityFee != nil) {
+		return GasConfig{}, errors.New("cannot use --gas-price with EIP-1559 flags (--max-fee-per-gas, --max-priority-fee)")
+	}
+	// If one EIP-1559 flag is set, both should be set for clarity
+	if (gasConfig.MaxFeePerGas != nil) != (gasConfig.MaxPriorityFee != nil) {
+		return GasConfig{}, errors.New("both --max-fee-per-gas and --max-priority-fee must be set for EIP-1559 transactions")
+	}
+	if gasConfig.GasMultiplier < 1.0 {
+		return GasConfig{}, fmt.Errorf("--gas-multiplier must be >= 1.0, got %v", gasConfig.GasMultiplier)
+	}
+	// Validate max gas price cap against configured gas prices
+	if gasConfig.MaxGasPrice != nil {
+		if gasConfig.GasPrice != nil && gasConfig.GasPrice.Cmp(gasConfig.MaxGasPrice) > 0 {
+			return GasConfig{}, fmt.Errorf("--gas-price %s exceeds --max-gas-price safety cap %s", gasConfig.GasPrice, gasConfig.MaxGasPrice)
+		}
+		if gasConfig.MaxFeePerGas != nil && gasConfig.MaxFeePerGas.Cmp(gasConfig.MaxGasPrice) > 0 {
+			return GasConfig{}, fmt.Errorf("--max-fee-per-gas %s exceeds --max-gas-price safety cap %s", gasConfig.MaxFeePerGas, gasConfig.MaxGasPrice)
+		}
+	}
+
+	// Warn if gas multiplier is set but explicit gas limit is also provided
+	if gasConfig.GasMultiplier > 1.0 && gasConfig.GasLimit > 0 {
+		log.Warn("--gas-multiplier is ignored when --gas-limit is explicitly set", "gas-multiplier", gasConfig.GasMultiplier, "gas-limit", gasConfig.GasLimit)
+	}
+	return gasConfig, nil
+}
+
+// notifier returns the notifier of the notification flags, or nil if none is set.
+func (f *reloadableFlags) notifier() withdraw.Notifier {
+	var notifiers withdraw.Notifiers
+	if f.notifyWebhook != "" {
+		notifiers = append(notifiers, &withdraw.WebhookNotifier{URL: f.notifyWebhook})
+	}
+	if f.notifySlack != "" {
+		notifiers = append(notifiers, &withdraw.SlackNotifier{WebhookURL: f.notifySlack})
+	}
+	if len(notifiers) == 0 {
+		return nil
+	}
+	return notifiers
+}
+
+// signerKeys are the flags the signer is created from, which the relay and daemon commands rotate to the key a
+// reloaded config file gives.
+type signerKeys struct {
+	privateKey string
+	mnemonic   string
+	hdPath     string
+	ledger     bool
+}
+
+// register defines the flags on fs, with the defaults of the flags of the running command, in running.
+func (k *signerKeys) register(fs *flag.FlagSet, running *flag.FlagSet) {
+	fs.StringVar(&k.privateKey, "private-key", "", "")
+	fs.StringVar(&k.mnemonic, "mnemonic", "", "")
+	fs.StringVar(&k.hdPath, "hd-path", running.Lookup("hd-path").DefValue, "")
+	fs.BoolVar(&k.ledger, "ledger", false, "")
+}
+
+// signer creates the signer of the keys.
+func (k *signerKeys) signer() (signer.Signer, error) {
+	set := 0
+	for _, ok := range []bool{k.privateKey != "", k.mnemonic != "", k.ledger} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("one (and only one) of private-key, ledger, mnemonic must be set")
+	}
+	return signer.CreateSigner(k.privateKey, k.mnemonic, k.hdPath)
+}
+
+// configReloader reloads the config file while the relay or daemon command runs, on SIGHUP or once the file is
+// modified, and applies the changes to the reloadable flags and the signer keys. Any other setting is only read at
+// startup, so changing it in the file is logged and ignored until a restart, as are changes to settings given on the
+// command line or in the environment, which take precedence over the file.
+//
+// A new signer only takes over once the transactions the old one sent are settled, so rotating keys leaves none of
+// them in flight.
+type configReloader struct {
+	fs          *flag.FlagSet // The flags of the running command
+	path        string
+	explicit    bool
+	profile     string
+	pinned      map[string]bool                    // Flags set on the command line or in the environment, by canonical name
+	loaded      map[string]interface{}             // The config file's settings at startup, by canonical name
+	applied     map[string]string                  // The reloadable flags' and signer keys' values as last applied, by name
+	checkSigner func(address common.Address) error // Checks a new signer before rotating to it (nil means no check)
+	rotation    signer.Signer                      // Signer to rotate to once the current one's transactions are settled
+	modTime     time.Time
+	hup         chan os.Signal
+}
+
+// newConfigReloader returns a reloader of the config file applied to fs at startup. pinned names the flags that were
+// set before the file was applied, on the command line or in the environment.
+func newConfigReloader(fs *flag.FlagSet, path string, explicit bool, profile string, pinned map[string]bool) (*configReloader, error) {
+	values, err := readConfigFile(path, explicit, profile)
+	if err != nil {
+		return nil, err
+	}
+	c := &configReloader{
+		fs:       fs,
+		path:     path,
+		explicit: explicit,
+		profile:  profile,
+		pinned:   pinned,
+		loaded:   canonicalSettings(values),
+		applied:  make(map[string]string),
+		modTime:  configModTime(path),
+		hup:      make(chan os.Signal, 1),
+	}
+	var f reloadableFlags
+	var keys signerKeys
+	reloadable := flag.NewFlagSet("reload", flag.ContinueOnError)
+	f.register(reloadable)
+	keys.register(reloadable, fs)
+	reloadable.VisitAll(func(fl *flag.Flag) {
+		c.applied[fl.Name] = fs.Lookup(fl.Name).Value.String()
+	})
+	return c, nil
+}
+
+// notifyHangup has the reloader reload the config file when the process receives SIGHUP.
+func (c *configReloader) notifyHangup() {
+	signal.Notify(c.hup, syscall.SIGHUP)
+}
+
+// hangups returns the channel SIGHUP is delivered on, or nil if there's no reloader, which never delivers.
+func (c *configReloader) hangups() <-chan os.Signal {
+	if c == nil {
+		return nil
+	}
+	return c.hup
+}
+
+// reload reloads the config file if hup is set or the file was modified since it was last loaded. Changes to the gas
+// policy and notification endpoints are applied to cfg, and the reloaded flags returned for the caller to apply the
+// rest, or nil if no reloadable flag changed. A file that doesn't load or holds invalid values is logged and leaves
+// every setting as it was. A new signer replaces cfg's once the transactions of cfg's are settled, which is retried on
+// each reload until they are.
+func (c *configReloader) reload(ctx context.Context, cfg *runSettings, hup bool) *reloadableFlags {
+	if c == nil {
+		return nil
+	}
+	var f *reloadableFlags
+	if modTime := configModTime(c.path); hup || !modTime.Equal(c.modTime) {
+		c.modTime = modTime
+		f = c.apply(cfg)
+	}
+	c.rotate(ctx, cfg)
+	return f
+}
+
+// apply loads the config file and applies its changes to cfg, returning the reloaded flags, or nil if none changed.
+func (c *configReloader) apply(cfg *runSettings) *reloadableFlags {
+	f, keys, values, err := c.load()
+	if err != nil {
+		log.Error("Error reloading config file, keeping the current settings", "path", c.path, "error", err)
+		return nil
+	}
+	var changed []string
+	rekeyed := false
+	for name, value := range values {
+		if value != c.applied[name] {
+			changed = append(changed, name)
+			rekeyed = rekeyed || name == "private-key" || name == "mnemonic" || name == "hd-path" || name == "ledger"
+		}
+	}
+	if len(changed) == 0 {
+		log.Debug("Reloaded config file, no settings to apply", "path", c.path)
+		return nil
+	}
+	sort.Strings(changed)
+	gasConfig, err := f.gasConfig()
+	if err != nil {
+		log.Error("Invalid gas settings in the reloaded config file, keeping the current settings", "path", c.path, "error", err)
+		return nil
+	}
+	var s signer.Signer
+	if rekeyed {
+		if s, err = c.newSigner(cfg, keys); err != nil {
+			log.Error("Invalid signer in the reloaded config file, keeping the current settings", "path", c.path, "error", err)
+			return nil
+		}
+	}
+	c.applied = values
+	cfg.gasConfig = gasConfig
+	cfg.notifier = f.notifier()
+	log.Info("Reloaded config file", "path", c.path, "changed", strings.Join(changed, ","))
+	if s != nil {
+		if s.Address() == cfg.signer.Address() {
+			cfg.signer, c.rotation = s, nil
+		} else {
+			c.rotation = s
+			log.Info("Rotating signer once the transactions of the current one are settled", "from", cfg.signer.Address(), "to", s.Address())
+		}
+	}
+	return f
+}
+
+// newSigner creates the signer of the reloaded keys, checking it can replace cfg's.
+func (c *configReloader) newSigner(cfg *runSettings, keys *signerKeys) (signer.Signer, error) {
+	if cfg.safe != nil || cfg.userOps != nil {
+		return nil, errors.New("the owner of a Safe or smart account can't be rotated without a restart")
+	}
+	s, err := keys.signer()
+	if err != nil {
+		return nil, err
+	}
+	if c.checkSigner != nil {
+		if err := c.checkSigner(s.Address()); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// rotate replaces cfg's signer with the one rotated to, if any, once the transactions cfg's signer sent are settled.
+func (c *configReloader) rotate(ctx context.Context, cfg *runSettings) {
+	if c.rotation == nil {
+		return
+	}
+	from := cfg.signer.Address()
+	if err := drainSigner(ctx, *cfg, from); err != nil {
+		log.Warn("Transactions of the current signer still in flight, rotating once they're settled", "from", from, "to", c.rotation.Address(), "error", err)
+		return
+	}
+	cfg.signer, c.rotation = c.rotation, nil
+	log.Info("Rotated signer", "from", from, "to", cfg.signer.Address())
+}
+
+// drainSigner checks that each transaction from the address the journal holds was mined, or replaced by another with
+// its nonce, returning an error if one is still in flight. It doesn't wait for them, leaving that and clearing them
+// from the journal to their withdrawals' runs, so the relay or daemon isn't held up.
+func drainSigner(ctx context.Context, cfg runSettings, from common.Address) error {
+	if cfg.pendingPath == "" {
+		return nil
+	}
+	pending, err := (&withdraw.Journal{Path: cfg.pendingPath}).Entries()
+	if err != nil {
+		return err
+	}
+	var nonce *uint64
+	for l2TxHash, tx := range pending {
+		sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		if err != nil || sender != from {
+			continue
+		}
+		if nonce == nil {
+			l1Client, err := dialEth(ctx, cfg.l1Rpc)
+			if err != nil {
+				return err
+			}
+			n, err := l1Client.NonceAt(ctx, from, nil)
+			l1Client.Close()
+			if err != nil {
+				return fmt.Errorf("error querying the nonce of %s: %w", from, err)
+			}
+			nonce = &n
+		}
+		if tx.Nonce() >= *nonce {
+			return fmt.Errorf("tx %s for withdrawal %s is still in flight", tx.Hash(), l2TxHash)
+		}
+	}
+	return nil
+}
+
+// load reads the config file and returns the reloadable flags and signer keys it gives, along with their values as
+// strings, by name. Changes to the other settings are logged and ignored.
+func (c *configReloader) load() (*reloadableFlags, *signerKeys, map[string]string, error) {
+	values, err := readConfigFile(c.path, c.explicit, c.profile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	settings := canonicalSettings(values)
+
+	f, keys := new(reloadableFlags), new(signerKeys)
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	f.register(fs)
+	keys.register(fs, c.fs)
+	// settings given on the command line or in the environment keep their values
+	for name := range c.pinned {
+		if fs.Lookup(name) != nil {
+			if err := fs.Set(name, c.fs.Lookup(name).Value.String()); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+
+	names := make([]string, 0, len(settings)+len(c.loaded))
+	for name := range settings {
+		names = append(names, name)
+	}
+	for name := range c.loaded {
+		if _, ok := settings[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	reloadable := make(map[string]interface{})
+	for _, name := range names {
+		value, ok := settings[name]
+		switch {
+		case c.pinned[name]:
+			if !reflect.DeepEqual(value, c.loaded[name]) {
+				log.Warn("Ignoring config file change to a setting given on the command line or in the environment", "setting", name)
+			}
+		case fs.Lookup(name) != nil:
+			if ok {
+				reloadable[name] = value
+			}
+		case !reflect.DeepEqual(value, c.loaded[name]):
+			log.Error("Ignoring config file change to a setting that needs a restart", "setting", name)
+		}
+	}
+	if err := applyFlagValues(fs, reloadable, "config file"); err != nil {
+		return nil, nil, nil, err
+	}
+
+	flagValues := make(map[string]string)
+	fs.VisitAll(func(fl *flag.Flag) {
+		flagValues[fl.Name] = fl.Value.String()
+	})
+	return f, keys, flagValues, nil
+}
+
+// canonicalSettings returns the config file settings keyed by the current flag names, with a deprecated name's setting
+// only kept if the file doesn't also give the current name.
+func canonicalSettings(values map[string]interface{}) map[string]interface{} {
+	settings := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		name := canonicalFlagName(k)
+		if _, ok := values[name]; ok && name != k {
+			continue
+		}
+		settings[name] = v
+	}
+	return settings
+}
+
+// configModTime returns the time the config file at path was last modified, or the zero time if it can't be read.
+func configModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
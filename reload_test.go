@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// reloadTestFlags returns the flags a config reload reads, set from the command line args, and the names of those set.
+func reloadTestFlags(t *testing.T, args ...string) (*reloadableFlags, *flag.FlagSet, map[string]bool) {
+	t.Helper()
+	rf := new(reloadableFlags)
+	fs := flag.NewFlagSet("withdrawer", flag.ContinueOnError)
+	rf.register(fs)
+	fs.String("network", "base-mainnet", "")
+	fs.String("private-key", "", "")
+	fs.String("mnemonic", "", "")
+	fs.String("hd-path", "m/44'/60'/0'/0/0", "")
+	fs.Bool("ledger", false, "")
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	pinned := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		pinned[f.Name] = true
+	})
+	return rf, fs, pinned
+}
+
+func TestConfigReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "withdrawer.toml")
+	writeConfig := func(config string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`
+network = "base-mainnet"
+relay-sender = "0x0000000000000000000000000000000000000001"
+poll-interval = "2m"
+gas-limit = 50000
+`)
+
+	rf, fs, pinned := reloadTestFlags(t, "--gas-limit=100000")
+	if err := applyConfigFile(fs, path, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := newConfigReloader(fs, path, true, "", pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gasConfig, err := rf.gasConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := runSettings{gasConfig: gasConfig}
+
+	if f := reloader.reload(context.Background(), &cfg, true); f != nil {
+		t.Fatalf("reloading the unchanged file applied %+v", f)
+	}
+
+	writeConfig(`
+network = "base-sepolia"
+relay-sender = ["0x0000000000000000000000000000000000000002", "0x0000000000000000000000000000000000000003"]
+poll-interval = "5m"
+gas-limit = 60000
+notify-webhook = "https://example.com/hook"
+`)
+	f := reloader.reload(context.Background(), &cfg, true)
+	if f == nil {
+		t.Fatal("reloading the changed file applied nothing")
+	}
+	wantSenders := addressList{common.HexToAddress("0x02"), common.HexToAddress("0x03")}
+	if !reflect.DeepEqual(f.relaySenders, wantSenders) || f.pollInterval != 5*time.Minute {
+		t.Errorf("reloaded senders %v and poll interval %s, want %v and 5m", f.relaySenders, f.pollInterval, wantSenders)
+	}
+	if f.gasLimit != 100000 || cfg.gasConfig.GasLimit != 100000 {
+		t.Errorf("gas limit given on the command line reloaded as %d, applied %d", f.gasLimit, cfg.gasConfig.GasLimit)
+	}
+	if cfg.notifier == nil {
+		t.Error("reloaded notification endpoint not applied")
+	}
+
+	// an invalid gas policy keeps the current settings
+	writeConfig(`
+network = "base-sepolia"
+gas-price = "1000000000"
+max-fee-per-gas = "2000000000"
+max-priority-fee = "1000000000"
+`)
+	if f := reloader.reload(context.Background(), &cfg, true); f != nil {
+		t.Fatalf("reloading an invalid gas policy applied %+v", f)
+	}
+	if cfg.notifier == nil || cfg.gasConfig.GasPrice != nil {
+		t.Errorf("invalid reload changed the settings: %+v", cfg.gasConfig)
+	}
+
+	// settings removed from the file go back to their defaults
+	writeConfig(`
+network = "base-sepolia"
+`)
+	if f = reloader.reload(context.Background(), &cfg, true); f == nil {
+		t.Fatal("reloading the emptied file applied nothing")
+	}
+	if len(f.relaySenders) != 0 || f.pollInterval != time.Minute || cfg.notifier != nil {
+		t.Errorf("removed settings reloaded as senders %v, poll interval %s, notifier %v", f.relaySenders, f.pollInterval, cfg.notifier)
+	}
+}
+
+// TestSignerRotation rotates the signer to the key of a reloaded config file, once the transaction the old key sent is
+// settled.
+func TestSignerRotation(t *testing.T) {
+	const (
+		oldKey   = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+		newKey   = "8f2a55949038a9610f50fb23b5883af3b4ecb3c3bb792cbcefbd1542c692be63"
+		otherKey = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+	)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "withdrawer.toml")
+	writeConfig := func(key string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("private-key = \""+key+"\"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	newSigner := func(key string) signer.Signer {
+		t.Helper()
+		s, err := signer.CreateSigner(key, "", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	// records a transaction from the key in the journal, as if it was sent for the withdrawal but isn't confirmed yet
+	pendingPath := filepath.Join(dir, "pending.json")
+	recordPending := func(key string, l2TxHash common.Hash) *withdraw.Journal {
+		t.Helper()
+		s, chainID := newSigner(key), big.NewInt(1)
+		tx, err := s.SignerFn(chainID)(s.Address(), types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Gas: 21_000}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		journal := &withdraw.Journal{Path: pendingPath, L2TxHash: l2TxHash}
+		if err := journal.Record(tx); err != nil {
+			t.Fatal(err)
+		}
+		return journal
+	}
+
+	writeConfig(oldKey)
+	_, fs, pinned := reloadTestFlags(t)
+	if err := applyConfigFile(fs, path, true, ""); err != nil {
+		t.Fatal(err)
+	}
+	reloader, err := newConfigReloader(fs, path, true, "", pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldSigner, rotated := newSigner(oldKey), newSigner(newKey)
+	// the L1 RPC is unreachable, so the old key's transaction can't be settled until it leaves the journal
+	cfg := runSettings{signer: oldSigner, pendingPath: pendingPath, l1Rpc: "http://127.0.0.1:1"}
+	journal := recordPending(oldKey, common.HexToHash("0x01"))
+	recordPending(otherKey, common.HexToHash("0x02"))
+
+	writeConfig(newKey)
+	reloader.reload(context.Background(), &cfg, true)
+	if cfg.signer.Address() != oldSigner.Address() {
+		t.Fatalf("rotated to %s with a transaction of the old signer in flight", cfg.signer.Address())
+	}
+	reloader.reload(context.Background(), &cfg, false)
+	if cfg.signer.Address() != oldSigner.Address() {
+		t.Fatalf("rotated to %s with a transaction of the old signer in flight", cfg.signer.Address())
+	}
+
+	if err := journal.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	reloader.reload(context.Background(), &cfg, false)
+	if cfg.signer.Address() != rotated.Address() {
+		t.Fatalf("signer %s once the old signer's transactions are settled, want %s", cfg.signer.Address(), rotated.Address())
+	}
+
+	// a Safe's owner isn't rotated
+	cfg.safe = &safeProposer{}
+	writeConfig(oldKey)
+	reloader.reload(context.Background(), &cfg, true)
+	if cfg.signer.Address() != rotated.Address() {
+		t.Errorf("rotated the owner of a Safe to %s", cfg.signer.Address())
+	}
+}
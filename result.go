@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// result is the machine-readable outcome of a successful run. It is printed to stdout as a single JSON object,
+// while human-readable logs go to stderr, so wrappers can capture it reliably.
+type result struct {
+	Action      string                  `json:"action"` // "prove", "finalize" or "none", see withdraw.Action
+	Withdrawal  common.Hash             `json:"withdrawal"`
+	LogIndex    *uint                   `json:"logIndex,omitempty"` // MessagePassed event of the withdrawal, for L2 txs initiating several
+	DryRun      bool                    `json:"dryRun,omitempty"`
+	Message     string                  `json:"message,omitempty"`
+	L1TxHash    *common.Hash            `json:"l1TxHash,omitempty"`
+	BlockNumber uint64                  `json:"blockNumber,omitempty"`
+	GasUsed     uint64                  `json:"gasUsed,omitempty"`
+	CostWei     string                  `json:"costWei,omitempty"`
+	Proof       *withdraw.ProofMetadata `json:"proof,omitempty"`
+	Delays      *withdraw.Delays        `json:"delays,omitempty"`   // Waits before the proven withdrawal can be finalized
+	Calldata    *withdraw.Calldata      `json:"calldata,omitempty"` // Portal call a dry run built instead of sending it
+}
+
+// newResult builds the result of an action from the last transaction the withdrawer confirmed.
+func newResult(action withdraw.Action, withdrawal withdrawalRef, dryRun bool, withdrawer withdraw.WithdrawHelper) result {
+	r := result{
+		Action:     string(action),
+		Withdrawal: withdrawal.l2TxHash,
+		LogIndex:   withdrawal.logIndex,
+		DryRun:     dryRun,
+	}
+	if costs := withdrawer.TxCosts(); len(costs) > 0 {
+		r.setCost(costs[len(costs)-1])
+	}
+	if reader, ok := withdrawer.(dryRunCallReader); ok && dryRun {
+		r.Calldata = reader.DryRunCall()
+	}
+	if action == withdraw.ActionProve {
+		r.Proof = withdrawer.ProvenAgainst()
+		r.Delays = readDelays(withdrawer)
+	}
+	return r
+}
+
+// setCost sets the L1 transaction of the result and what it cost.
+func (r *result) setCost(cost withdraw.TxCost) {
+	r.L1TxHash = &cost.TxHash
+	r.BlockNumber = cost.BlockNumber.Uint64()
+	r.GasUsed = cost.GasUsed
+	r.CostWei = cost.Cost.String()
+}
+
+// dryRunCallReader is implemented by the withdrawers that keep the portal call their dry runs build.
+type dryRunCallReader interface {
+	DryRunCall() *withdraw.Calldata
+}
+
+// delayReader is implemented by the withdrawers that can read the portal's delays.
+type delayReader interface {
+	Delays() (*withdraw.Delays, error)
+}
+
+// readDelays returns the delays a proof must wait for before the withdrawal can be finalized, or nil if they are
+// unknown.
+func readDelays(withdrawer withdraw.WithdrawHelper) *withdraw.Delays {
+	reader, ok := withdrawer.(delayReader)
+	if !ok {
+		return nil
+	}
+	delays, err := reader.Delays()
+	if err != nil {
+		log.Warn("Unable to read the portal's finalization delays", "error", err)
+		return nil
+	}
+	return delays
+}
+
+// printResult writes the result to stdout as a single line of JSON.
+func printResult(r result) {
+	if err := json.NewEncoder(os.Stdout).Encode(r); err != nil {
+		log.Error("Error writing result", "error", err)
+	}
+}
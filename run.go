@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// runSettings are the settings shared by the runs proving or finalizing each withdrawal, of which there are several
+// in a batch.
+type runSettings struct {
+	l1Rpc        string
+	verifyL2Rpc  string
+	network      network
+	networkName  string
+	signer       signer.Signer
+	gasConfig    GasConfig
+	txConfig     TxConfig
+	proverConfig ProverConfig
+	pendingPath  string // Journal of sent transactions (empty means no journal)
+	dryRun       bool
+	auto         bool // Prove, wait and finalize in one run, confirming each transaction unless yes is set
+	yes          bool
+	maxWait      time.Duration
+	timing       *withdraw.Timing // Clock and poll intervals of the withdrawers' and auto command's waits and of the relay and daemon schedules (nil means the system clock)
+	faults       withdraw.Faults
+	ethUSD       float64
+	quorum       *withdraw.Quorum
+	notifier     withdraw.Notifier
+	store        *stateStore   // Records each withdrawal's progress across runs (nil means no store)
+	unsignedPath string        // File to write the transaction a dry run built to, unsigned (empty means none)
+	safe         *safeProposer // Proposes the portal call a dry run built from a Safe (nil means no Safe)
+	userOps      *userOpSender // Sends the portal call a dry run built from a smart account (nil means no smart account)
+}
+
+// runError is an error a run stops on, with the message and fields it is logged with as critical when it's the only
+// run of the process. It wraps the error among its fields, if any.
+type runError struct {
+	msg    string
+	fields []interface{}
+	err    error
+}
+
+func newRunError(msg string, fields ...interface{}) *runError {
+	e := &runError{msg: msg, fields: fields}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if err, ok := fields[i+1].(error); ok && fields[i] == "error" {
+			e.err = err
+		}
+	}
+	return e
+}
+
+func (e *runError) Unwrap() error {
+	return e.err
+}
+
+func (e *runError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.msg)
+	var details []string
+	for i := 0; i+1 < len(e.fields); i += 2 {
+		if e.fields[i] == "error" {
+			fmt.Fprintf(&b, ": %v", e.fields[i+1])
+			continue
+		}
+		details = append(details, fmt.Sprintf("%v=%v", e.fields[i], e.fields[i+1]))
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	}
+	return b.String()
+}
+
+// critRunError logs the error a single run stopped on as critical, which exits the process.
+func critRunError(err error) {
+	var re *runError
+	if errors.As(err, &re) {
+		log.Crit(re.msg, re.fields...)
+	}
+	log.Crit("Error processing withdrawal", "error", err)
+}
+
+// handOff hands the transaction a dry run built over to be sent some other way, as configured: written unsigned to a
+// file, proposed to a Safe, or sent as a UserOperation, in which case the result is updated with the transaction that
+// included it.
+func handOff(ctx context.Context, cfg runSettings, withdrawer withdraw.WithdrawHelper, r *result) error {
+	if err := writeUnsignedTx(cfg.unsignedPath, cfg.signer.Address(), withdrawer); err != nil {
+		return err
+	}
+	if err := cfg.safe.propose(ctx, cfg.l1Rpc, withdrawer); err != nil {
+		return err
+	}
+	cost, err := cfg.userOps.send(ctx, cfg, withdraw.Action(r.Action), withdrawer)
+	if err != nil {
+		return err
+	}
+	if cost != nil {
+		r.DryRun = false
+		r.setCost(*cost)
+		printCostSummary([]withdraw.TxCost{*cost}, cfg.ethUSD)
+	}
+	return nil
+}
+
+// runWithdrawal takes the withdrawal its next step through its lifecycle, or, with auto, proves it and finalizes it
+// once finalizable within the max wait. The result of each step is printed to stdout, and the last one returned.
+func runWithdrawal(ctx context.Context, cfg runSettings, ref withdrawalRef, metrics *metricsRecorder) (_ *result, err error) {
+	n, s := cfg.network, cfg.signer
+	defer func() {
+		if err != nil {
+			cfg.store.recordError(ref, cfg.networkName, metrics.metrics.Action, cfg.dryRun, err)
+		}
+	}()
+	withdrawal := ref.l2TxHash
+	if cfg.pendingPath != "" && !cfg.dryRun {
+		cfg.txConfig.Journal = &withdraw.Journal{Path: cfg.pendingPath, L2TxHash: ref.journalKey()}
+	}
+	txConfig := cfg.txConfig
+
+	if err := resumePending(ctx, cfg.l1Rpc, txConfig); err != nil {
+		return nil, newRunError("Error resuming transaction from a previous run", "error", err)
+	}
+
+	withdrawer, closeClients, err := CreateWithdrawHelper(ctx, cfg, ref)
+	if err != nil {
+		return nil, newRunError("Error creating withdrawer", "error", err)
+	}
+	defer closeClients()
+	metrics.withdrawer = withdrawer
+
+	// handle withdrawals with or without the fault proofs withdrawer
+	state, err := withdraw.CurrentState(withdrawer)
+	if err != nil {
+		return nil, newRunError("Error querying withdrawal state", "error", err)
+	}
+	log.Info("Withdrawal state", "withdrawal", ref, "state", state, "next", state.NextAction())
+	cfg.store.checkStage(ref, state)
+	cfg.store.recordStage(ref, cfg.networkName, state)
+
+	if state.NextAction() == withdraw.ActionNone {
+		log.Info("Withdrawal already finalized")
+		metrics.setAction(withdraw.ActionNone)
+		r := result{Action: string(withdraw.ActionNone), Withdrawal: withdrawal, LogIndex: ref.logIndex, Message: "withdrawal already finalized"}
+		printResult(r)
+		cfg.store.recordResult(ref, cfg.networkName, r)
+		return &r, nil
+	}
+
+	if err := checkNotPaused(ctx, cfg.l1Rpc, n); err != nil {
+		return nil, newRunError("Withdrawals are paused, not submitting", "error", err)
+	}
+
+	action, err := nextAction(withdrawer, state, cfg.proverConfig, s.Address())
+	if err != nil {
+		return nil, err
+	}
+	metrics.setAction(action)
+
+	// TODO: Add functionality to generate output root proposal and prove to that proposal for FPs
+	if cfg.auto && action == withdraw.ActionProve {
+		err = waitUntilProvable(ctx, withdrawer, cfg.maxWait, cfg.timing)
+	} else {
+		err = withdrawer.CheckIfProvable()
+	}
+	if err != nil {
+		re := newRunError("Withdrawal is not provable", "error", err)
+		re.err = fmt.Errorf("%w: %w", withdraw.ErrNotProvable, err)
+		return nil, re
+	}
+
+	switch action {
+	case withdraw.ActionProve:
+		if cfg.auto && !cfg.dryRun {
+			if err := confirmTransaction(withdraw.ActionProve, ref, cfg.networkName, s.Address(), cfg.yes); err != nil {
+				return nil, newRunError("Not proving withdrawal", "error", err)
+			}
+		}
+		err = withdrawer.ProveWithdrawal()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, newRunError("Interrupted while proving withdrawal", "error", err)
+			}
+			return nil, newRunError("Error proving withdrawal", "error", err)
+		}
+
+		var delayFields []interface{}
+		proved := newResult(withdraw.ActionProve, ref, cfg.dryRun, withdrawer)
+		if proved.Delays != nil {
+			delayFields = proved.Delays.LogFields()
+		}
+		if n.faultProofs {
+			log.Info("Withdrawal successfully proven, finalize once dispute game finishes and finalization period elapses", delayFields...)
+		} else {
+			log.Info("Withdrawal successfully proven, finalize once finalization period elapses", delayFields...)
+		}
+		clearPending(txConfig)
+		if !cfg.dryRun {
+			printCostSummary(withdrawer.TxCosts(), cfg.ethUSD)
+			logFinalizationCountdown(withdrawer, !n.devnet)
+		}
+		if err := handOff(ctx, cfg, withdrawer, &proved); err != nil {
+			return nil, newRunError("Error handing off the prove transaction", "error", err)
+		}
+		printResult(proved)
+		cfg.store.recordResult(ref, cfg.networkName, proved)
+
+		// the auto command goes on to finalize if the withdrawal becomes finalizable within --max-wait
+		if !cfg.auto || cfg.dryRun {
+			return &proved, nil
+		}
+		if err := waitUntilFinalizable(ctx, withdrawer, cfg.maxWait, cfg.timing); err != nil {
+			log.Info("Not waiting to finalize, run auto again once the withdrawal is finalizable", "reason", err)
+			return &proved, nil
+		}
+		fallthrough
+
+	case withdraw.ActionFinalize:
+		metrics.setAction(withdraw.ActionFinalize)
+		if cfg.auto {
+			if err := waitUntilFinalizable(ctx, withdrawer, cfg.maxWait, cfg.timing); err != nil {
+				return nil, newRunError("Withdrawal is not finalizable yet, run auto again later", "error", err)
+			}
+			if !cfg.dryRun {
+				if err := confirmTransaction(withdraw.ActionFinalize, ref, cfg.networkName, s.Address(), cfg.yes); err != nil {
+					return nil, newRunError("Not finalizing withdrawal", "error", err)
+				}
+			}
+		}
+		err = withdrawer.FinalizeWithdrawal()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, newRunError("Interrupted while completing withdrawal", "error", err)
+			}
+			var notFinalizable *withdraw.NotFinalizableError
+			if errors.As(err, &notFinalizable) {
+				re := newRunError("Withdrawal is not finalizable yet", "reasons", strings.Join(notFinalizable.Reasons, "; "),
+					"finalizableAt", notFinalizable.FinalizableAt.UTC(), "remaining", notFinalizable.Remaining.Round(time.Second))
+				re.err = err
+				return nil, re
+			}
+			return nil, newRunError("Error completing withdrawal", "error", err)
+		}
+		clearPending(txConfig)
+		printCostSummary(withdrawer.TxCosts(), cfg.ethUSD)
+		finalized := newResult(withdraw.ActionFinalize, ref, cfg.dryRun, withdrawer)
+		if err := handOff(ctx, cfg, withdrawer, &finalized); err != nil {
+			return nil, newRunError("Error handing off the finalize transaction", "error", err)
+		}
+		printResult(finalized)
+		cfg.store.recordResult(ref, cfg.networkName, finalized)
+		return &finalized, nil
+	}
+	return nil, fmt.Errorf("unexpected action %q", action)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// safeProposer proposes the portal calls built by dry runs from a Safe to its Transaction Service, signed by one of its
+// owners, instead of sending them from an EOA.
+type safeProposer struct {
+	safe       common.Address
+	owner      signer.Signer
+	serviceURL string // Safe Transaction Service (empty means the one of the L1 chain)
+}
+
+// propose proposes the portal call of the withdrawer's last dry run from the Safe, signing it with the owner. A nil
+// proposer proposes nothing.
+func (p *safeProposer) propose(ctx context.Context, l1Rpc string, withdrawer withdraw.WithdrawHelper) error {
+	if p == nil {
+		return nil
+	}
+	reader, ok := withdrawer.(dryRunCallReader)
+	if !ok || reader.DryRunCall() == nil {
+		return errors.New("no portal call was built to propose")
+	}
+	call := reader.DryRunCall()
+
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	service := &withdraw.SafeService{URL: p.serviceURL}
+	if service.URL == "" {
+		chainID, err := l1Client.ChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("error querying L1 chain ID: %w", err)
+		}
+		if service.URL = withdraw.SafeServiceURLs[chainID.Uint64()]; service.URL == "" {
+			return fmt.Errorf("no known Safe Transaction Service for L1 chain %s, pass --safe-tx-service", chainID)
+		}
+	}
+
+	nonce, err := service.NextNonce(ctx, l1Client, p.safe)
+	if err != nil {
+		return err
+	}
+	tx, err := withdraw.NewSafeTx(l1Client, p.safe, call, nonce, p.owner.Address())
+	if err != nil {
+		return err
+	}
+	log.Info("Signing Safe transaction", "safe", p.safe, "method", call.Method, "to", call.To, "nonce", nonce, "safeTxHash", tx.SafeTxHash)
+	signature, err := p.owner.SignTypedData(tx.TypedData)
+	if err != nil {
+		return fmt.Errorf("error signing Safe transaction: %w", err)
+	}
+	if err := service.Propose(ctx, tx, p.owner.Address(), signature); err != nil {
+		return err
+	}
+
+	fields := []interface{}{"safe", p.safe, "nonce", nonce, "safeTxHash", tx.SafeTxHash, "proposer", p.owner.Address()}
+	if threshold, err := withdraw.SafeThreshold(l1Client, p.safe); err == nil {
+		fields = append(fields, "confirmations", fmt.Sprintf("1/%d", threshold))
+	}
+	log.Info("Proposed transaction to the Safe, have its owners confirm and execute it", fields...)
+	return nil
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// scanEntry is a line of the scan output, a withdrawal the address initiated on L2 with its stage on L1.
+type scanEntry struct {
+	L2TxHash       common.Hash              `json:"l2TxHash"`
+	LogIndex       uint                     `json:"logIndex"` // MessagePassed event of the withdrawal, to select it with --log-index
+	WithdrawalHash common.Hash              `json:"withdrawalHash"`
+	L2BlockNumber  uint64                   `json:"l2BlockNumber"`
+	State          withdraw.State           `json:"state"`
+	Next           withdraw.Action          `json:"next"`  // What moves it to its next state, "none" once finalized
+	Value          string                   `json:"value"` // In wei, or the gas paying token's base units
+	Recipient      common.Address           `json:"recipient"`
+	BridgeTransfer *withdraw.BridgeTransfer `json:"bridgeTransfer,omitempty"`
+	L1Call         *withdraw.L1Call         `json:"l1Call"` // Call it makes on L1 once finalized
+}
+
+// runScan finds the withdrawals the address initiated on the network's L2 since fromBlock and reads how far along
+// each is on L1. Each is printed to stdout as a JSON line and to stderr as a table row, followed by a count per state.
+func runScan(ctx context.Context, l1Rpc string, n network, from common.Address, fromBlock uint64) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	l2Client, err := dialEth(ctx, n.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	defer l2Client.Close()
+	head, err := l2Client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L2 head: %w", err)
+	}
+	if fromBlock > head {
+		return fmt.Errorf("--from-block %d is past the L2 head %d", fromBlock, head)
+	}
+	state, err := newWithdrawalStateReader(l1Client, n)
+	if err != nil {
+		return err
+	}
+
+	txs, err := withdraw.FindInitiatedWithdrawals(ctx, l2Client, from, fromBlock, head)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	counts := make(map[withdraw.State]int)
+	total := 0
+	for _, tx := range txs {
+		receipt, err := l2Client.TransactionReceipt(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("error querying withdrawal %s: %w", tx, err)
+		}
+		// each withdrawal of txs that initiated several is listed on its own
+		for _, logIndex := range withdraw.MessagePassedLogIndexes(receipt) {
+			selected, err := withdraw.SelectWithdrawal(receipt, &logIndex)
+			if err != nil {
+				return err
+			}
+			details, err := withdraw.DecodeWithdrawal(selected)
+			if err != nil {
+				return fmt.Errorf("error decoding withdrawal %s: %w", tx, err)
+			}
+			s, err := state.get(details.Hash)
+			if err != nil {
+				return fmt.Errorf("error querying state of withdrawal %s: %w", tx, err)
+			}
+			counts[s]++
+			total++
+
+			e := scanEntry{
+				L2TxHash:       tx,
+				LogIndex:       logIndex,
+				WithdrawalHash: details.Hash,
+				L2BlockNumber:  details.L2BlockNumber.Uint64(),
+				State:          s,
+				Next:           s.NextAction(),
+				Value:          details.Event.Value.String(),
+				Recipient:      details.Recipient(),
+				BridgeTransfer: details.BridgeTransfer(),
+				L1Call:         details.L1Call(),
+			}
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+			what := fmt.Sprintf("%s to %s", n.gasToken.FormatValue(details.Event.Value), e.Recipient)
+			if e.BridgeTransfer != nil && e.BridgeTransfer.Kind != withdraw.BridgeETH {
+				what = e.BridgeTransfer.String()
+			}
+			fmt.Fprintf(os.Stderr, "  %s  log %-4d L2 block %-10d %-9s next %-8s %s\n", tx, logIndex, e.L2BlockNumber, s, e.Next, what)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%s initiated %d withdrawals in L2 blocks %d-%d: %d initiated, %d proven, %d finalized\n",
+		from, total, fromBlock, head, counts[withdraw.StateInitiated], counts[withdraw.StateProven], counts[withdraw.StateFinalized])
+	return nil
+}
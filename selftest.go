@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+)
+
+// runSelfTest is a cheap smoke test of the configured setup: it checks that both RPCs are reachable,
+// signs a throwaway transaction with the signer and verifies the recovered sender. Nothing is broadcast.
+func runSelfTest(ctx context.Context, l1Rpc string, n network, s signer.Signer) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	l1ChainID, err := l1Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	log.Info("L1 RPC reachable", "chainId", l1ChainID)
+
+	l2Client, err := dialEth(ctx, n.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	l2ChainID, err := l2Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L2 chain ID: %w", err)
+	}
+	log.Info("L2 RPC reachable", "chainId", l2ChainID)
+
+	if n.chainID != 0 && l2ChainID.Uint64() != n.chainID {
+		return fmt.Errorf("L2 RPC reports chain ID %s, but the network's chain ID is %d", l2ChainID, n.chainID)
+	}
+	if l1ChainID.Cmp(l2ChainID) == 0 {
+		return fmt.Errorf("L1 and L2 RPCs report the same chain ID %s, is --rpc pointing at the L2?", l1ChainID)
+	}
+
+	// Sign a zero-value transaction to the zero address that is never broadcast
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   l1ChainID,
+		Nonce:     0,
+		GasTipCap: big.NewInt(0),
+		GasFeeCap: big.NewInt(0),
+		Gas:       21000,
+		To:        &common.Address{},
+		Value:     big.NewInt(0),
+	})
+	log.Info("Signing throwaway transaction", "address", s.Address())
+	signed, err := s.SignerFn(l1ChainID)(s.Address(), tx)
+	if err != nil {
+		return fmt.Errorf("error signing transaction: %w", err)
+	}
+
+	sender, err := types.Sender(types.LatestSignerForChainID(l1ChainID), signed)
+	if err != nil {
+		return fmt.Errorf("error recovering signer address: %w", err)
+	}
+	if sender != s.Address() {
+		return fmt.Errorf("recovered signer address %s does not match configured address %s", sender, s.Address())
+	}
+
+	log.Info("Self-test passed", "address", sender, "l1ChainId", l1ChainID, "l2ChainId", l2ChainID)
+	return nil
+}
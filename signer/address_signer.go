@@ -0,0 +1,46 @@
+package signer
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrNoKey is returned when an address signer is asked to sign a transaction or message.
+var ErrNoKey = errors.New("signer has no key, transactions can't be signed")
+
+// addressSigner represents an address without its key, for modes that build transactions without signing them.
+type addressSigner struct {
+	address common.Address
+}
+
+// NewAddressSigner returns a signer for the address that refuses to sign, for modes that only need to know who
+// transactions would be sent from.
+func NewAddressSigner(address common.Address) Signer {
+	return &addressSigner{address: address}
+}
+
+// Address returns the address the signer stands for.
+func (s *addressSigner) Address() common.Address {
+	return s.address
+}
+
+// SignerFn returns a signer function that always fails with ErrNoKey.
+func (s *addressSigner) SignerFn(chainID *big.Int) bind.SignerFn {
+	return func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return nil, ErrNoKey
+	}
+}
+
+// SignTypedData always fails with ErrNoKey.
+func (s *addressSigner) SignTypedData(data []byte) ([]byte, error) {
+	return nil, ErrNoKey
+}
+
+// SignText always fails with ErrNoKey.
+func (s *addressSigner) SignText(text []byte) ([]byte, error) {
+	return nil, ErrNoKey
+}
@@ -5,6 +5,7 @@ import (
 	"math/big"
 
 	opcrypto "github.com/ethereum-optimism/optimism/op-service/crypto"
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -25,4 +26,22 @@ func (s *ecdsaSigner) SignerFn(chainID *big.Int) bind.SignerFn {
 	return opcrypto.PrivateKeySignerFn(s.PrivateKey, chainID)
 }
 
+// SignTypedData signs the EIP-712 message with the ECDSA private key.
+func (s *ecdsaSigner) SignTypedData(data []byte) ([]byte, error) {
+	sig, err := crypto.Sign(crypto.Keccak256(data), s.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
 
+// SignText signs the EIP-191 personal message with the ECDSA private key.
+func (s *ecdsaSigner) SignText(text []byte) ([]byte, error) {
+	sig, err := crypto.Sign(accounts.TextHash(text), s.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig, nil
+}
@@ -15,6 +15,8 @@ import (
 type Signer interface {
 	Address() common.Address        // Address returns the Ethereum address associated with the signer.
 	SignerFn(chainID *big.Int) bind.SignerFn // SignerFn returns a signer function used for transaction signing.
+	SignTypedData(data []byte) ([]byte, error) // SignTypedData signs an EIP-712 message (0x1901, domain separator, struct hash), with a v of 27 or 28.
+	SignText(text []byte) ([]byte, error)      // SignText signs an EIP-191 personal message, with a v of 27 or 28.
 }
 
 // CreateSigner creates a signer based on the provided private key, mnemonic, or hardware wallet.
@@ -31,6 +31,17 @@ func (s *walletSigner) SignerFn(chainID *big.Int) bind.SignerFn {
 	}
 }
 
+// SignTypedData signs the EIP-712 message on the wallet, which shows its domain and struct hashes for confirmation.
+func (s *walletSigner) SignTypedData(data []byte) ([]byte, error) {
+	return s.wallet.SignData(s.account, accounts.MimetypeTypedData, data)
+}
+
+// SignText signs the EIP-191 personal message on the wallet. Ledger devices don't support signing personal messages
+// of arbitrary hashes this way, so they return accounts.ErrNotSupported.
+func (s *walletSigner) SignText(text []byte) ([]byte, error) {
+	return s.wallet.SignText(s.account, text)
+}
+
 // derivePrivateKeyFromMnemonic derives an ECDSA private key from a mnemonic phrase and derivation path.
 func derivePrivateKeyFromMnemonic(mnemonic string, path accounts.DerivationPath) (*ecdsa.PrivateKey, error) {
 	// Parse the seed string into the master BIP32 key.
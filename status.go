@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// runStatus prints the withdrawal's timeline, from its initiation on L2 to its finalization on L1, with the blocks and
+// transactions of each milestone. The timeline is printed to stdout as a single JSON object, and as a table on stderr
+// with the time each milestone took. On fault proof chains, the proof traced is the address's, if it proved the
+// withdrawal. A transaction journaled in the pending file by a previous run is included.
+func runStatus(ctx context.Context, l1Rpc string, n network, ref withdrawalRef, address common.Address, pendingPath string) error {
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	l2Client, err := dialEth(ctx, n.l2RPC)
+	if err != nil {
+		return fmt.Errorf("error dialing L2 client: %w", err)
+	}
+	defer l2Client.Close()
+
+	var trace *withdraw.Trace
+	if n.faultProofs {
+		trace, err = withdraw.TraceFaultProofs(ctx, l1Client, l2Client, common.HexToAddress(n.portalAddress), common.HexToAddress(n.disputeGameFactory), ref.l2TxHash, ref.logIndex, address, n.gasToken)
+	} else {
+		trace, err = withdraw.TraceLegacy(ctx, l1Client, l2Client, common.HexToAddress(n.portalAddress), common.HexToAddress(n.l2OOAddress), ref.l2TxHash, ref.logIndex, n.gasToken)
+	}
+	if err != nil {
+		return err
+	}
+	if pendingPath != "" && trace.State != withdraw.StateFinalized {
+		journal := &withdraw.Journal{Path: pendingPath, L2TxHash: ref.journalKey()}
+		pending, err := journal.PendingEvent()
+		if err != nil {
+			return err
+		}
+		if pending != nil {
+			trace.Events = append(trace.Events, *pending)
+		}
+	}
+
+	printTimeline(trace)
+	return json.NewEncoder(os.Stdout).Encode(trace)
+}
+
+// printTimeline writes the trace to stderr as a table, one milestone per line, with the time since the previous one.
+func printTimeline(trace *withdraw.Trace) {
+	fmt.Fprintf(os.Stderr, "Withdrawal %s is %s\n", trace.WithdrawalHash, trace.State)
+	if d := trace.Delays; d != nil {
+		fmt.Fprintf(os.Stderr, "Proof maturity delay %s", d.ProofMaturityDelay())
+		if d.DisputeGameFinalityDelaySeconds > 0 {
+			fmt.Fprintf(os.Stderr, ", dispute game finality delay %s", d.DisputeGameFinalityDelay())
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+	if transfer := trace.BridgeTransfer; transfer != nil {
+		fmt.Fprintf(os.Stderr, "Bridge transfer: %s\n", transfer)
+	}
+	if sim := trace.FinalizeSimulation; sim != nil {
+		switch {
+		case sim.Reverted() && sim.Replayable:
+			fmt.Fprintf(os.Stderr, "Finalize simulation: the message may fail and need replaying on L1: %s\n", sim.Error)
+		case sim.Reverted():
+			fmt.Fprintf(os.Stderr, "Finalize simulation: finalizing may not deliver the value: %s\n", sim.Error)
+		default:
+			fmt.Fprintf(os.Stderr, "Finalize simulation: %s call to %s succeeds\n", sim.Call, sim.Target)
+		}
+	}
+	var previous uint64
+	for _, e := range trace.Events {
+		at, took := "unknown", ""
+		if e.Time > 0 {
+			at = time.Unix(int64(e.Time), 0).UTC().Format(time.RFC3339)
+			if previous > 0 && e.Time >= previous {
+				took = "+" + (time.Duration(e.Time-previous) * time.Second).String()
+			}
+			previous = e.Time
+		}
+		if e.Estimated {
+			at += " (expected)"
+		}
+		block := ""
+		if e.BlockNumber > 0 {
+			block = fmt.Sprintf("%s block %d", e.Chain, e.BlockNumber)
+		}
+		tx := ""
+		if e.TxHash != nil {
+			tx = "tx " + e.TxHash.Hex()
+		}
+		fmt.Fprintf(os.Stderr, "  %-10s %-32s %-12s %-18s %-69s %s\n", e.Milestone, at, took, block, tx, e.Detail)
+	}
+}
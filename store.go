@@ -0,0 +1,532 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+	_ "modernc.org/sqlite"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+// defaultStateFile is the state store the relay and daemon commands keep their progress in without --state-file, in
+// the user's home directory.
+const defaultStateFile = ".withdrawer-state.db"
+
+// defaultArchiveAfter is how long after being finalized the relay and daemon commands archive a withdrawal without
+// --archive-after.
+const defaultArchiveAfter = 30 * 24 * time.Hour
+
+// storeMaxRuns caps the runs kept per withdrawal, dropping the oldest, so a withdrawal retried for weeks by a relayer
+// doesn't grow the store without bound.
+const storeMaxRuns = 100
+
+// storeSchema creates the state store's tables. Times are stored in UTC as SQLite datetime text, which SQLite's date
+// functions read, hashes as hex.
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS withdrawals (
+	key              TEXT PRIMARY KEY,
+	l2_tx_hash       TEXT NOT NULL,
+	log_index        INTEGER,
+	network          TEXT NOT NULL,
+	stage            TEXT NOT NULL DEFAULT '',
+	proof            TEXT,
+	prove_tx_hash    TEXT,
+	proven_at        DATETIME,
+	finalize_tx_hash TEXT,
+	finalized_at     DATETIME,
+	last_error       TEXT NOT NULL DEFAULT '',
+	updated_at       DATETIME NOT NULL,
+	archived_at      DATETIME
+);
+CREATE INDEX IF NOT EXISTS withdrawals_network_stage ON withdrawals (network, stage);
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	key        TEXT NOT NULL REFERENCES withdrawals (key),
+	time       DATETIME NOT NULL,
+	action     TEXT NOT NULL DEFAULT '',
+	outcome    TEXT NOT NULL,
+	dry_run    BOOLEAN NOT NULL DEFAULT FALSE,
+	l1_tx_hash TEXT,
+	error      TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS runs_key ON runs (key, id);
+CREATE TABLE IF NOT EXISTS relay_cursors (
+	l2_chain_id INTEGER PRIMARY KEY,
+	next_block  INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS relay_withdrawals (
+	l2_chain_id     INTEGER NOT NULL,
+	withdrawal_hash TEXT NOT NULL,
+	l2_tx_hash      TEXT NOT NULL,
+	log_index       INTEGER NOT NULL,
+	l2_block        INTEGER NOT NULL,
+	state           TEXT NOT NULL DEFAULT '',
+	next_attempt    DATETIME NOT NULL,
+	failures        INTEGER NOT NULL DEFAULT 0,
+	last_error      TEXT NOT NULL DEFAULT '',
+	PRIMARY KEY (l2_chain_id, withdrawal_hash)
+);
+CREATE TABLE IF NOT EXISTS daemon_withdrawals (
+	key             TEXT PRIMARY KEY,
+	network         TEXT NOT NULL,
+	withdrawal_hash TEXT NOT NULL,
+	l2_tx_hash      TEXT NOT NULL,
+	log_index       INTEGER NOT NULL,
+	l2_block        INTEGER NOT NULL,
+	state           TEXT NOT NULL DEFAULT '',
+	next_attempt    DATETIME NOT NULL,
+	failures        INTEGER NOT NULL DEFAULT 0,
+	last_error      TEXT NOT NULL DEFAULT '',
+	last_check      DATETIME NOT NULL
+);
+`
+
+// stateStore records the progress of each withdrawal proven or finalized across runs in a SQLite database: its stage,
+// the game or output it was proven against, its prove and finalize transactions, when each happened, and the outcome
+// of its latest runs, so batches and daemons can be audited and tell what was already done. The relay and daemon
+// commands keep their progress in it too. Changes are made in transactions, so processes sharing the store don't
+// overwrite each other's. A nil store records nothing.
+type stateStore struct {
+	path string
+	db   *sql.DB
+}
+
+// storeRecord is a withdrawal's entry in the state store.
+type storeRecord struct {
+	L2TxHash       common.Hash             `json:"l2TxHash"`
+	LogIndex       *uint                   `json:"logIndex,omitempty"`
+	Network        string                  `json:"network"`
+	Stage          withdraw.State          `json:"stage"`           // As last seen on L1
+	Proof          *withdraw.ProofMetadata `json:"proof,omitempty"` // Game or output the withdrawal was last proven against
+	ProveTxHash    *common.Hash            `json:"proveTxHash,omitempty"`
+	ProvenAt       *time.Time              `json:"provenAt,omitempty"`
+	FinalizeTxHash *common.Hash            `json:"finalizeTxHash,omitempty"`
+	FinalizedAt    *time.Time              `json:"finalizedAt,omitempty"`
+	LastError      string                  `json:"lastError,omitempty"`
+	UpdatedAt      time.Time               `json:"updatedAt"`
+	ArchivedAt     *time.Time              `json:"archivedAt,omitempty"` // When it left the withdrawals runs go through, once finalized
+}
+
+// storeRun is the outcome of a step a run took the withdrawal, or tried to.
+type storeRun struct {
+	Time     time.Time
+	Action   string
+	Outcome  string // success or failure
+	DryRun   bool
+	L1TxHash *common.Hash
+	Error    string
+}
+
+// defaultStatePath returns the path of the default state store in the user's home directory, or an empty path if it
+// can't be determined.
+func defaultStatePath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, defaultStateFile)
+}
+
+// openStateStore opens the state store at path, creating it if needed, or returns nil if path is empty.
+func openStateStore(path string) (*stateStore, error) {
+	if path == "" {
+		return nil, nil
+	}
+	// concurrent writers wait for each other's transactions rather than failing, and take the write lock up front so
+	// a read-modify-write can't interleave with another. Times are written in a format SQLite's date functions parse,
+	// rather than the driver's default of time.Time's String.
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_txlock=immediate&_time_format=sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("error opening state store %s: %w", path, err)
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening state store %s: %w", path, err)
+	}
+	if err := migrateStore(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating state store %s: %w", path, err)
+	}
+	return &stateStore{path: path, db: db}, nil
+}
+
+// migrateStore adds the columns stores created by earlier versions lack.
+func migrateStore(db *sql.DB) error {
+	var archived int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('withdrawals') WHERE name = 'archived_at'`).Scan(&archived); err != nil {
+		return err
+	}
+	if archived == 0 {
+		if _, err := db.Exec(`ALTER TABLE withdrawals ADD COLUMN archived_at DATETIME`); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the store's database.
+func (s *stateStore) Close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// get returns the withdrawal's record, or nil if it has none.
+func (s *stateStore) get(ref withdrawalRef) (*storeRecord, error) {
+	if s == nil {
+		return nil, nil
+	}
+	r, err := scanRecord(s.db.QueryRow(`SELECT `+recordColumns+` FROM withdrawals WHERE key = ?`, ref.journalKey().Hex()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+	}
+	return r, nil
+}
+
+// unfinished returns the withdrawals of the network the store records as not finalized yet, oldest update first, for
+// --resume to continue them. Archived withdrawals are finalized, so never among them.
+func (s *stateStore) unfinished(networkName string) ([]withdrawalRef, error) {
+	if s == nil {
+		return nil, nil
+	}
+	rows, err := s.db.Query(`SELECT l2_tx_hash, log_index FROM withdrawals WHERE network = ? AND stage != ? AND archived_at IS NULL ORDER BY julianday(updated_at)`,
+		networkName, string(withdraw.StateFinalized))
+	if err != nil {
+		return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+	}
+	defer rows.Close()
+	var refs []withdrawalRef
+	for rows.Next() {
+		var l2TxHash string
+		var logIndex sql.NullInt64
+		if err := rows.Scan(&l2TxHash, &logIndex); err != nil {
+			return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+		}
+		refs = append(refs, withdrawalRef{l2TxHash: common.HexToHash(l2TxHash), logIndex: nullUint(logIndex)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+	}
+	return refs, nil
+}
+
+// checkStage warns if the withdrawal's stage read from L1 is behind the one the store recorded, e.g. after an L1 reorg
+// dropped its prove tx, in which case the run goes on from the chain's stage.
+func (s *stateStore) checkStage(ref withdrawalRef, stage withdraw.State) {
+	r, err := s.get(ref)
+	if err != nil {
+		log.Warn("Unable to read the withdrawal from the state file", "withdrawal", ref, "error", err)
+		return
+	}
+	if r == nil || r.Stage == "" || slices.Index(withdraw.States, stage) >= slices.Index(withdraw.States, r.Stage) {
+		return
+	}
+	log.Warn("Withdrawal is behind the stage recorded in the state file, continuing from its stage on L1", "withdrawal", ref,
+		"recorded", r.Stage, "onChain", stage, "proveTx", r.ProveTxHash, "finalizeTx", r.FinalizeTxHash)
+}
+
+// recordStage records the withdrawal's stage as read from L1 at the start of a run.
+func (s *stateStore) recordStage(ref withdrawalRef, networkName string, stage withdraw.State) {
+	s.update(ref, networkName, nil, func(r *storeRecord) {
+		r.Stage = stage
+	})
+}
+
+// recordResult records the step a run took the withdrawal. Dry runs only add to the withdrawal's runs.
+func (s *stateStore) recordResult(ref withdrawalRef, networkName string, res result) {
+	now := time.Now().UTC()
+	run := &storeRun{Time: now, Action: res.Action, Outcome: outcomeSuccess, DryRun: res.DryRun, L1TxHash: res.L1TxHash}
+	s.update(ref, networkName, run, func(r *storeRecord) {
+		r.LastError = ""
+		if res.DryRun {
+			return
+		}
+		switch withdraw.Action(res.Action) {
+		case withdraw.ActionProve:
+			r.Stage = withdraw.StateProven
+			r.Proof, r.ProveTxHash, r.ProvenAt = res.Proof, res.L1TxHash, &now
+		case withdraw.ActionFinalize:
+			r.Stage = withdraw.StateFinalized
+			r.FinalizeTxHash, r.FinalizedAt = res.L1TxHash, &now
+		case withdraw.ActionNone:
+			r.Stage = withdraw.StateFinalized
+		}
+	})
+}
+
+// recordError records the step a run failed to take the withdrawal.
+func (s *stateStore) recordError(ref withdrawalRef, networkName string, action string, dryRun bool, err error) {
+	run := &storeRun{Time: time.Now().UTC(), Action: action, Outcome: outcomeFailure, DryRun: dryRun, Error: err.Error()}
+	s.update(ref, networkName, run, func(r *storeRecord) {
+		r.LastError = err.Error()
+	})
+}
+
+// update applies change to the withdrawal's record, creating it if needed, and adds the run to its runs, if any, in
+// one transaction. Failing to write the store is only logged, as it must not fail a run that already sent its
+// transaction.
+func (s *stateStore) update(ref withdrawalRef, networkName string, run *storeRun, change func(r *storeRecord)) {
+	if s == nil {
+		return
+	}
+	if err := s.updateTx(ref, networkName, run, change); err != nil {
+		log.Error("Error updating state store", "path", s.path, "error", err)
+	}
+}
+
+func (s *stateStore) updateTx(ref withdrawalRef, networkName string, run *storeRun, change func(r *storeRecord)) error {
+	key := ref.journalKey().Hex()
+	return s.inTx(func(tx *sql.Tx) error {
+		r, err := scanRecord(tx.QueryRow(`SELECT `+recordColumns+` FROM withdrawals WHERE key = ?`, key))
+		if errors.Is(err, sql.ErrNoRows) {
+			r = &storeRecord{L2TxHash: ref.l2TxHash, LogIndex: ref.logIndex, Network: networkName}
+		} else if err != nil {
+			return err
+		}
+		change(r)
+		r.UpdatedAt = time.Now().UTC()
+		var proof []byte
+		if r.Proof != nil {
+			if proof, err = json.Marshal(r.Proof); err != nil {
+				return err
+			}
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO withdrawals (key, `+recordColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			key, r.L2TxHash.Hex(), nullableUint(r.LogIndex), r.Network, string(r.Stage), nullableBytes(proof),
+			nullableHash(r.ProveTxHash), r.ProvenAt, nullableHash(r.FinalizeTxHash), r.FinalizedAt, r.LastError, r.UpdatedAt, r.ArchivedAt)
+		if err != nil || run == nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT INTO runs (key, time, action, outcome, dry_run, l1_tx_hash, error) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			key, run.Time, run.Action, run.Outcome, run.DryRun, nullableHash(run.L1TxHash), run.Error)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(`DELETE FROM runs WHERE key = ? AND id NOT IN (SELECT id FROM runs WHERE key = ? ORDER BY id DESC LIMIT ?)`,
+			key, key, storeMaxRuns)
+		return err
+	})
+}
+
+// recordColumns are the columns of the withdrawals table after its key, in the order scanRecord reads them.
+const recordColumns = `l2_tx_hash, log_index, network, stage, proof, prove_tx_hash, proven_at, finalize_tx_hash, finalized_at, last_error, updated_at, archived_at`
+
+// scanRecord reads the recordColumns of a withdrawal.
+func scanRecord(row interface{ Scan(dest ...any) error }) (*storeRecord, error) {
+	var (
+		r                       storeRecord
+		l2TxHash, stage         string
+		logIndex                sql.NullInt64
+		proof                   []byte
+		proveTxHash, finalizeTx sql.NullString
+		provenAt, finalizedAt   sql.NullTime
+		archivedAt              sql.NullTime
+	)
+	if err := row.Scan(&l2TxHash, &logIndex, &r.Network, &stage, &proof, &proveTxHash, &provenAt, &finalizeTx, &finalizedAt, &r.LastError, &r.UpdatedAt, &archivedAt); err != nil {
+		return nil, err
+	}
+	r.L2TxHash, r.LogIndex, r.Stage = common.HexToHash(l2TxHash), nullUint(logIndex), withdraw.State(stage)
+	r.ProveTxHash, r.FinalizeTxHash = nullHash(proveTxHash), nullHash(finalizeTx)
+	r.ProvenAt, r.FinalizedAt, r.ArchivedAt = nullTime(provenAt), nullTime(finalizedAt), nullTime(archivedAt)
+	if len(proof) > 0 {
+		r.Proof = new(withdraw.ProofMetadata)
+		if err := json.Unmarshal(proof, r.Proof); err != nil {
+			return nil, fmt.Errorf("error decoding proof: %w", err)
+		}
+	}
+	return &r, nil
+}
+
+// archiveFinalized soft-deletes the withdrawals finalized more than retention ago, returning how many: their records
+// and runs are kept, and listed by history --archived, but they leave the active set --resume looks through.
+// Withdrawals finalized by someone else have no finalize time, so their last update is used instead.
+func (s *stateStore) archiveFinalized(now time.Time, retention time.Duration) (int64, error) {
+	res, err := s.db.Exec(`UPDATE withdrawals SET archived_at = ? WHERE stage = ? AND archived_at IS NULL
+		AND julianday(COALESCE(finalized_at, updated_at)) <= julianday(?)`,
+		now.UTC(), string(withdraw.StateFinalized), now.Add(-retention).UTC())
+	if err != nil {
+		return 0, fmt.Errorf("error archiving finalized withdrawals in %s: %w", s.path, err)
+	}
+	return res.RowsAffected()
+}
+
+// archived returns the archived withdrawals of the network, latest archived first.
+func (s *stateStore) archived(networkName string) ([]*storeRecord, error) {
+	rows, err := s.db.Query(`SELECT `+recordColumns+` FROM withdrawals WHERE network = ? AND archived_at IS NOT NULL
+		ORDER BY julianday(archived_at) DESC, julianday(updated_at) DESC`, networkName)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+	}
+	defer rows.Close()
+	var records []*storeRecord
+	for rows.Next() {
+		r, err := scanRecord(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+		}
+		records = append(records, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading state store %s: %w", s.path, err)
+	}
+	return records, nil
+}
+
+// loadRelay returns the relayer's progress on the L2 chain, or nil if it has none yet.
+func (s *stateStore) loadRelay(l2ChainID uint64) (*relayState, error) {
+	state := &relayState{L2ChainID: l2ChainID, Withdrawals: make(map[common.Hash]*relayEntry)}
+	err := s.db.QueryRow(`SELECT next_block FROM relay_cursors WHERE l2_chain_id = ?`, l2ChainID).Scan(&state.NextBlock)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading relay state from %s: %w", s.path, err)
+	}
+	rows, err := s.db.Query(`SELECT withdrawal_hash, l2_tx_hash, log_index, l2_block, state, next_attempt, failures, last_error
+		FROM relay_withdrawals WHERE l2_chain_id = ?`, l2ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("error reading relay state from %s: %w", s.path, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var hash, l2TxHash, st string
+		var e relayEntry
+		if err := rows.Scan(&hash, &l2TxHash, &e.LogIndex, &e.L2Block, &st, &e.NextAttempt, &e.Failures, &e.LastError); err != nil {
+			return nil, fmt.Errorf("error reading relay state from %s: %w", s.path, err)
+		}
+		e.L2TxHash, e.State = common.HexToHash(l2TxHash), withdraw.State(st)
+		state.Withdrawals[common.HexToHash(hash)] = &e
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading relay state from %s: %w", s.path, err)
+	}
+	return state, nil
+}
+
+// saveRelay replaces the relayer's progress on its L2 chain with state, in one transaction.
+func (s *stateStore) saveRelay(state *relayState) error {
+	err := s.inTx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO relay_cursors (l2_chain_id, next_block) VALUES (?, ?)`, state.L2ChainID, state.NextBlock); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM relay_withdrawals WHERE l2_chain_id = ?`, state.L2ChainID); err != nil {
+			return err
+		}
+		for hash, e := range state.Withdrawals {
+			_, err := tx.Exec(`INSERT INTO relay_withdrawals (l2_chain_id, withdrawal_hash, l2_tx_hash, log_index, l2_block, state, next_attempt, failures, last_error)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				state.L2ChainID, hash.Hex(), e.L2TxHash.Hex(), e.LogIndex, e.L2Block, string(e.State), e.NextAttempt.UTC(), e.Failures, e.LastError)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error writing relay state to %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// loadDaemon returns the progress of the daemon of the withdrawal with the key, or nil if it has none yet.
+func (s *stateStore) loadDaemon(key common.Hash) (*daemonEntry, error) {
+	var e daemonEntry
+	var hash, l2TxHash, st string
+	err := s.db.QueryRow(`SELECT network, withdrawal_hash, l2_tx_hash, log_index, l2_block, state, next_attempt, failures, last_error, last_check
+		FROM daemon_withdrawals WHERE key = ?`, key.Hex()).
+		Scan(&e.Network, &hash, &l2TxHash, &e.LogIndex, &e.L2Block, &st, &e.NextAttempt, &e.Failures, &e.LastError, &e.LastCheck)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading daemon state from %s: %w", s.path, err)
+	}
+	e.WithdrawalHash, e.L2TxHash, e.State = common.HexToHash(hash), common.HexToHash(l2TxHash), withdraw.State(st)
+	return &e, nil
+}
+
+// saveDaemon replaces the progress of the daemon of the withdrawal with the key.
+func (s *stateStore) saveDaemon(key common.Hash, e *daemonEntry) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO daemon_withdrawals (key, network, withdrawal_hash, l2_tx_hash, log_index, l2_block, state, next_attempt, failures, last_error, last_check)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.Hex(), e.Network, e.WithdrawalHash.Hex(), e.L2TxHash.Hex(), e.LogIndex, e.L2Block, string(e.State), e.NextAttempt.UTC(), e.Failures, e.LastError, e.LastCheck.UTC())
+	if err != nil {
+		return fmt.Errorf("error writing daemon state to %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// removeDaemon removes the progress of the daemon of the withdrawal with the key, once it's finalized.
+func (s *stateStore) removeDaemon(key common.Hash) error {
+	if _, err := s.db.Exec(`DELETE FROM daemon_withdrawals WHERE key = ?`, key.Hex()); err != nil {
+		return fmt.Errorf("error removing daemon state from %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds.
+func (s *stateStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func nullableUint(v *uint) any {
+	if v == nil {
+		return nil
+	}
+	return int64(*v)
+}
+
+func nullableHash(h *common.Hash) any {
+	if h == nil {
+		return nil
+	}
+	return h.Hex()
+}
+
+func nullableBytes(b []byte) any {
+	if b == nil {
+		return nil
+	}
+	return string(b)
+}
+
+func nullUint(v sql.NullInt64) *uint {
+	if !v.Valid {
+		return nil
+	}
+	u := uint(v.Int64)
+	return &u
+}
+
+func nullHash(s sql.NullString) *common.Hash {
+	if !s.Valid {
+		return nil
+	}
+	h := common.HexToHash(s.String)
+	return &h
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
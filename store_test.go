@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"math/big"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"github.com/base/withdrawer/withdraw"
+)
+
+func openTestStore(t *testing.T) *stateStore {
+	t.Helper()
+	store, err := openStateStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreRecord(t *testing.T) {
+	store := openTestStore(t)
+	logIndex := uint(2)
+	ref := withdrawalRef{l2TxHash: common.HexToHash("0x01"), logIndex: &logIndex}
+	proveTx, finalizeTx := common.HexToHash("0xaa"), common.HexToHash("0xbb")
+	proof := &withdraw.ProofMetadata{GameIndex: big.NewInt(42), L2Block: big.NewInt(1000)}
+
+	store.recordStage(ref, "base-mainnet", withdraw.StateInitiated)
+	store.recordError(ref, "base-mainnet", string(withdraw.ActionProve), false, errors.New("not provable yet"))
+	r, err := store.get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Stage != withdraw.StateInitiated || r.LastError != "not provable yet" || r.Network != "base-mainnet" ||
+		r.L2TxHash != ref.l2TxHash || r.LogIndex == nil || *r.LogIndex != logIndex {
+		t.Fatalf("after the failed prove, got %+v", r)
+	}
+
+	store.recordResult(ref, "base-mainnet", result{Action: string(withdraw.ActionProve), L1TxHash: &proveTx, Proof: proof, DryRun: true})
+	if r, err = store.get(ref); err != nil {
+		t.Fatal(err)
+	}
+	if r.Stage != withdraw.StateInitiated || r.ProveTxHash != nil || r.LastError != "" {
+		t.Fatalf("a dry run changed the record: %+v", r)
+	}
+
+	store.recordResult(ref, "base-mainnet", result{Action: string(withdraw.ActionProve), L1TxHash: &proveTx, Proof: proof})
+	if r, err = store.get(ref); err != nil {
+		t.Fatal(err)
+	}
+	if r.Stage != withdraw.StateProven || r.ProveTxHash == nil || *r.ProveTxHash != proveTx || r.ProvenAt == nil ||
+		!reflect.DeepEqual(r.Proof, proof) {
+		t.Fatalf("after the prove, got %+v", r)
+	}
+
+	store.recordResult(ref, "base-mainnet", result{Action: string(withdraw.ActionFinalize), L1TxHash: &finalizeTx})
+	if r, err = store.get(ref); err != nil {
+		t.Fatal(err)
+	}
+	if r.Stage != withdraw.StateFinalized || r.FinalizeTxHash == nil || *r.FinalizeTxHash != finalizeTx || r.FinalizedAt == nil {
+		t.Fatalf("after the finalize, got %+v", r)
+	}
+	if time.Since(r.UpdatedAt) > time.Minute || r.FinalizedAt.Before(*r.ProvenAt) {
+		t.Errorf("times read back wrong: proven at %s, finalized at %s, updated at %s", r.ProvenAt, r.FinalizedAt, r.UpdatedAt)
+	}
+
+	rows, err := store.db.Query(`SELECT action, outcome, dry_run, l1_tx_hash, error FROM runs WHERE key = ? ORDER BY id`, ref.journalKey().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	type run struct {
+		action, outcome string
+		dryRun          bool
+		l1TxHash        *string
+		err             string
+	}
+	var runs []run
+	for rows.Next() {
+		var r run
+		if err := rows.Scan(&r.action, &r.outcome, &r.dryRun, &r.l1TxHash, &r.err); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, r)
+	}
+	proveHex, finalizeHex := proveTx.Hex(), finalizeTx.Hex()
+	want := []run{
+		{action: "prove", outcome: outcomeFailure, err: "not provable yet"},
+		{action: "prove", outcome: outcomeSuccess, dryRun: true, l1TxHash: &proveHex},
+		{action: "prove", outcome: outcomeSuccess, l1TxHash: &proveHex},
+		{action: "finalize", outcome: outcomeSuccess, l1TxHash: &finalizeHex},
+	}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("runs %+v, want %+v", runs, want)
+	}
+}
+
+func TestStoreUnfinished(t *testing.T) {
+	store := openTestStore(t)
+	refs := []withdrawalRef{
+		{l2TxHash: common.HexToHash("0x03")},
+		{l2TxHash: common.HexToHash("0x01")},
+		{l2TxHash: common.HexToHash("0x04")},
+		{l2TxHash: common.HexToHash("0x02")},
+	}
+	for _, ref := range refs {
+		store.recordStage(ref, "base-mainnet", withdraw.StateInitiated)
+	}
+	store.recordStage(withdrawalRef{l2TxHash: common.HexToHash("0x05")}, "base-sepolia", withdraw.StateInitiated)
+	store.recordResult(refs[2], "base-mainnet", result{Action: string(withdraw.ActionNone)})
+	// updated last, so resumed last
+	store.recordStage(refs[0], "base-mainnet", withdraw.StateProven)
+	// last updated by an older run, e.g. before the others were added, so resumed first
+	if _, err := store.db.Exec(`UPDATE withdrawals SET updated_at = ? WHERE key = ?`, time.Now().UTC().Add(-time.Hour), refs[3].journalKey().Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	var unparsed int
+	if err := store.db.QueryRow(`SELECT COUNT(*) FROM withdrawals WHERE julianday(updated_at) IS NULL`).Scan(&unparsed); err != nil {
+		t.Fatal(err)
+	}
+	if unparsed > 0 {
+		t.Fatalf("SQLite can't parse the update times of %d withdrawals", unparsed)
+	}
+	got, err := store.unfinished("base-mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []withdrawalRef{refs[3], refs[1], refs[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unfinished %v, want %v", got, want)
+	}
+}
+
+func TestStoreArchive(t *testing.T) {
+	store := openTestStore(t)
+	old, recent, unfinished := withdrawalRef{l2TxHash: common.HexToHash("0x01")}, withdrawalRef{l2TxHash: common.HexToHash("0x02")}, withdrawalRef{l2TxHash: common.HexToHash("0x03")}
+	store.recordResult(old, "base-mainnet", result{Action: string(withdraw.ActionNone)})
+	store.recordResult(recent, "base-mainnet", result{Action: string(withdraw.ActionNone)})
+	store.recordStage(unfinished, "base-mainnet", withdraw.StateProven)
+	if _, err := store.db.Exec(`UPDATE withdrawals SET updated_at = ? WHERE key = ?`, time.Now().UTC().Add(-48*time.Hour), old.journalKey().Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	archived, err := store.archiveFinalized(time.Now(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if archived != 1 {
+		t.Fatalf("archived %d withdrawals, want 1", archived)
+	}
+	records, err := store.archived("base-mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].L2TxHash != old.l2TxHash || records[0].ArchivedAt == nil {
+		t.Fatalf("archived records %+v, want only %s", records, old)
+	}
+	if r, err := store.get(old); err != nil || r == nil || r.Stage != withdraw.StateFinalized {
+		t.Errorf("archived withdrawal no longer readable: %+v, %v", r, err)
+	}
+	if archived, err = store.archiveFinalized(time.Now(), 24*time.Hour); err != nil || archived != 0 {
+		t.Errorf("archived %d withdrawals again, error %v", archived, err)
+	}
+	refs, err := store.unfinished("base-mainnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(refs, []withdrawalRef{unfinished}) {
+		t.Errorf("unfinished %v, want %v", refs, []withdrawalRef{unfinished})
+	}
+}
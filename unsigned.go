@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// dryRunTxReader is implemented by the withdrawers that keep the transaction their dry runs build.
+type dryRunTxReader interface {
+	DryRunTx() *types.Transaction
+}
+
+// writeUnsignedTx writes the transaction the withdrawer's dry run built, unsigned, as JSON to the file at path, for
+// the from address to sign offline. Nothing is written if path is empty.
+func writeUnsignedTx(path string, from common.Address, withdrawer withdraw.WithdrawHelper) error {
+	if path == "" {
+		return nil
+	}
+	reader, ok := withdrawer.(dryRunTxReader)
+	if !ok || reader.DryRunTx() == nil {
+		return errors.New("no transaction was built to export")
+	}
+	tx, err := withdraw.NewUnsignedTx(from, reader.DryRunTx())
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(tx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing unsigned transaction %s: %w", path, err)
+	}
+	log.Info("Exported unsigned transaction, sign it offline", "method", tx.Method, "from", tx.From, "nonce", uint64(tx.Nonce),
+		"gas", uint64(tx.Gas), "maxCostETH", withdraw.FormatEther(tx.MaxCost()), "signingHash", tx.SigningHash, "file", path)
+	return nil
+}
+
+// runSign signs the unsigned transaction in the file at unsignedPath with the signer, which must be the address it was
+// built for, and writes it as raw hex to the file at rawPath, or stdout if rawPath is empty. No RPC is used, so this
+// can run on an air-gapped machine.
+func runSign(s signer.Signer, unsignedPath, rawPath string) error {
+	data, err := os.ReadFile(unsignedPath)
+	if err != nil {
+		return fmt.Errorf("error reading unsigned transaction %s: %w", unsignedPath, err)
+	}
+	var unsigned withdraw.UnsignedTx
+	if err := json.Unmarshal(data, &unsigned); err != nil {
+		return fmt.Errorf("error decoding unsigned transaction %s: %w", unsignedPath, err)
+	}
+	tx, err := unsigned.Transaction()
+	if err != nil {
+		return err
+	}
+	if s.Address() != unsigned.From {
+		return fmt.Errorf("transaction was built for %s, with its nonce, not the signer %s", unsigned.From, s.Address())
+	}
+	log.Info("Signing transaction", "method", unsigned.Method, "to", unsigned.To, "value", withdraw.FormatEther(tx.Value()), "chainID", tx.ChainId(),
+		"nonce", tx.Nonce(), "gas", tx.Gas(), "maxCostETH", withdraw.FormatEther(unsigned.MaxCost()), "signingHash", unsigned.SigningHash)
+	signed, err := s.SignerFn(tx.ChainId())(s.Address(), tx)
+	if err != nil {
+		return fmt.Errorf("error signing transaction: %w", err)
+	}
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	out := []byte(hexutil.Encode(raw) + "\n")
+	if rawPath == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	if err := os.WriteFile(rawPath, out, 0o644); err != nil {
+		return fmt.Errorf("error writing signed transaction %s: %w", rawPath, err)
+	}
+	log.Info("Signed transaction, broadcast it from an online machine", "l1TxHash", signed.Hash(), "file", rawPath)
+	return nil
+}
+
+// broadcastResult is the machine-readable outcome of the broadcast command, printed to stdout as a single JSON object.
+type broadcastResult struct {
+	Action      string         `json:"action"` // "prove" or "finalize" for portal calls, "none" otherwise
+	From        common.Address `json:"from"`
+	L1TxHash    common.Hash    `json:"l1TxHash"`
+	BlockNumber uint64         `json:"blockNumber"`
+	GasUsed     uint64         `json:"gasUsed"`
+	CostWei     string         `json:"costWei"`
+}
+
+// runBroadcast sends the signed raw transaction in the file at rawPath through the L1 RPC, or the send RPC if set,
+// and waits for it to be confirmed.
+func runBroadcast(ctx context.Context, l1Rpc, sendRpc, rawPath string, timeout time.Duration, confirmations uint64, waitFinalized bool) error {
+	tx, from, err := readSignedTx(rawPath)
+	if err != nil {
+		return err
+	}
+
+	l1Client, err := dialEth(ctx, l1Rpc)
+	if err != nil {
+		return fmt.Errorf("error dialing L1 client: %w", err)
+	}
+	defer l1Client.Close()
+	chainID, err := l1Client.ChainID(ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	if err := checkTxChain(tx, chainID); err != nil {
+		return err
+	}
+	var sender bind.ContractTransactor = l1Client
+	if sendRpc != "" {
+		sendClient, err := dialEth(ctx, sendRpc)
+		if err != nil {
+			return fmt.Errorf("error dialing send L1 client: %w", err)
+		}
+		defer sendClient.Close()
+		sender = sendClient
+	}
+
+	cost, err := withdraw.Broadcast(ctx, l1Client, sender, tx, timeout, confirmations, waitFinalized)
+	if err != nil {
+		return err
+	}
+	log.Info("Transaction confirmed", "action", cost.Action, "from", from, "l1TxHash", cost.TxHash, "block", cost.BlockNumber)
+	r := broadcastResult{
+		Action:      cost.Action,
+		From:        from,
+		L1TxHash:    cost.TxHash,
+		BlockNumber: cost.BlockNumber.Uint64(),
+		GasUsed:     cost.GasUsed,
+		CostWei:     cost.Cost.String(),
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(r); err != nil {
+		log.Error("Error writing result", "error", err)
+	}
+	return nil
+}
+
+// readSignedTx reads the signed raw transaction in the file at rawPath, returning it with the address that signed it.
+func readSignedTx(rawPath string) (*types.Transaction, common.Address, error) {
+	data, err := os.ReadFile(rawPath)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("error reading signed transaction %s: %w", rawPath, err)
+	}
+	raw, err := hexutil.Decode(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("error decoding signed transaction %s: %w", rawPath, err)
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(raw); err != nil {
+		return nil, common.Address{}, fmt.Errorf("error decoding signed transaction %s: %w", rawPath, err)
+	}
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("error recovering the signer of the transaction: %w", err)
+	}
+	return tx, from, nil
+}
+
+// checkTxChain checks that the signed transaction is for the chain the RPC it is about to be sent through serves.
+func checkTxChain(tx *types.Transaction, chainID *big.Int) error {
+	if tx.ChainId().Cmp(chainID) != 0 {
+		return fmt.Errorf("transaction is for chain %s, but the L1 RPC is for chain %s", tx.ChainId(), chainID)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+
+	"github.com/base/withdrawer/signer"
+	"github.com/base/withdrawer/withdraw"
+)
+
+// dryRunWithdrawer is a withdrawer whose last dry run built tx.
+type dryRunWithdrawer struct {
+	fakeWithdrawer
+	tx *types.Transaction
+}
+
+func (w *dryRunWithdrawer) DryRunTx() *types.Transaction {
+	return w.tx
+}
+
+// TestOfflineSigning exports a dry run's transaction, signs it as the sign command does and reads it back as the
+// broadcast command does, up to sending it.
+func TestOfflineSigning(t *testing.T) {
+	s, err := signer.CreateSigner("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	portal := common.HexToAddress("0x49048044D57e1C92A77f79988d21Fa8fAF74E97e")
+	dryRunTx := func(chainID int64) *types.Transaction {
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   big.NewInt(chainID),
+			Nonce:     7,
+			GasTipCap: big.NewInt(1e9),
+			GasFeeCap: big.NewInt(30e9),
+			Gas:       450_000,
+			To:        &portal,
+			Data:      common.FromHex("0x8c3152e9"),
+		})
+	}
+
+	dir := t.TempDir()
+	unsignedPath := filepath.Join(dir, "unsigned.json")
+	rawPath := filepath.Join(dir, "signed.hex")
+
+	if err := writeUnsignedTx(unsignedPath, s.Address(), &dryRunWithdrawer{tx: dryRunTx(0)}); err == nil {
+		t.Fatal("exported a transaction without a chain ID")
+	}
+
+	if err := writeUnsignedTx(unsignedPath, s.Address(), &dryRunWithdrawer{tx: dryRunTx(1)}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(unsignedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var unsigned withdraw.UnsignedTx
+	if err := json.Unmarshal(data, &unsigned); err != nil {
+		t.Fatal(err)
+	}
+	if unsigned.ChainID.ToInt().Cmp(big.NewInt(1)) != 0 {
+		t.Fatalf("exported chain ID %s, want 1", unsigned.ChainID)
+	}
+	wantHash := types.LatestSignerForChainID(big.NewInt(1)).Hash(dryRunTx(1))
+	if unsigned.SigningHash != wantHash {
+		t.Fatalf("exported signing hash %s, want %s", unsigned.SigningHash, wantHash)
+	}
+
+	other, err := signer.CreateSigner("8da4ef21b864d2cc526dbdb2a120bd2874c36c9d0a1fb7f8c63d7f7a8b41de8f", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runSign(other, unsignedPath, rawPath); err == nil {
+		t.Fatal("signed with a key other than the one the transaction was built for")
+	}
+	if err := runSign(s, unsignedPath, rawPath); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, from, err := readSignedTx(rawPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != s.Address() {
+		t.Errorf("signed by %s, want %s", from, s.Address())
+	}
+	if tx.ChainId().Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("signed for chain %s, want 1", tx.ChainId())
+	}
+	if hash := types.LatestSignerForChainID(tx.ChainId()).Hash(tx); hash != wantHash {
+		t.Errorf("signed transaction has signing hash %s, want %s", hash, wantHash)
+	}
+	if err := checkTxChain(tx, big.NewInt(1)); err != nil {
+		t.Errorf("rejected for its own chain: %v", err)
+	}
+	if err := checkTxChain(tx, big.NewInt(8453)); err == nil {
+		t.Error("accepted for another chain")
+	}
+}
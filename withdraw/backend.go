@@ -0,0 +1,80 @@
+package withdraw
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SendBackend is a contract backend that sends transactions through a dedicated L1 client, such as a private relay
+// or a trusted provider, while reads, gas estimation and fee suggestions go to another.
+type SendBackend struct {
+	*ethclient.Client                   // Client for everything but sending
+	Sender            *ethclient.Client // Client for eth_sendRawTransaction
+}
+
+// SendTransaction sends the signed transaction through the Sender client.
+func (b *SendBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	return b.Sender.SendTransaction(ctx, tx)
+}
+
+// NonceTracker hands out the signer's L1 nonces across the withdrawers of a run, so that consecutive transactions,
+// e.g. proving then finalizing, or those of a batch of withdrawals, are sequenced even while the L1 RPC's pending
+// nonce lags behind the transactions it was sent.
+type NonceTracker struct {
+	mu   sync.Mutex
+	next map[common.Address]uint64
+}
+
+// Next returns the nonce of the account's next transaction: its pending nonce, unless the tracker saw transactions
+// sent past it.
+func (t *NonceTracker) Next(pending uint64, account common.Address) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return max(pending, t.next[account])
+}
+
+// Sent records that the account sent a transaction with the nonce.
+func (t *NonceTracker) Sent(account common.Address, nonce uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.next == nil {
+		t.next = make(map[common.Address]uint64)
+	}
+	t.next[account] = max(t.next[account], nonce+1)
+}
+
+// NonceBackend is a contract backend that picks nonces with the tracker and records the transactions it sends.
+type NonceBackend struct {
+	bind.ContractBackend
+	Nonces *NonceTracker
+}
+
+// PendingNonceAt returns the next nonce of the account according to both the wrapped backend and the tracker.
+func (b *NonceBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	pending, err := b.ContractBackend.PendingNonceAt(ctx, account)
+	if err != nil {
+		return 0, err
+	}
+	return b.Nonces.Next(pending, account), nil
+}
+
+// SendTransaction sends the signed transaction through the wrapped backend, and records its nonce once sent.
+func (b *NonceBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.ContractBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	// the transaction was sent, so failing to track it must not fail the send
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		log.Warn("Unable to recover the sender of a sent transaction, not tracking its nonce", "txHash", tx.Hash(), "error", err)
+		return nil
+	}
+	b.Nonces.Sent(from, tx.Nonce())
+	return nil
+}
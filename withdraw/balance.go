@@ -0,0 +1,50 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// balancePollInterval is how often the signer's balance is checked while a submission is paused for a top-up.
+const balancePollInterval = time.Minute
+
+// waitForBalance blocks until the signer's L1 balance is at least minBalance, alerting the notifier once when it is
+// below, so that running out of gas money pauses submissions instead of failing them. A nil minBalance never waits.
+func waitForBalance(ctx context.Context, client *ethclient.Client, signer common.Address, minBalance *big.Int, notifier Notifier, n Notification, timing *Timing) error {
+	if minBalance == nil {
+		return nil
+	}
+
+	start := timing.Now()
+	alerted := false
+	for {
+		balance, err := client.BalanceAt(ctx, signer, nil)
+		if err != nil {
+			return fmt.Errorf("error querying signer balance: %w", err)
+		}
+		if balance.Cmp(minBalance) >= 0 {
+			if alerted {
+				log.Info("Signer balance topped up, resuming submission", "signer", signer, "balance", FormatEther(balance), "minBalance", FormatEther(minBalance), "paused", timing.since(start).Round(time.Second))
+			}
+			return nil
+		}
+
+		log.Warn("Signer balance below minimum, pausing submission until it is topped up", "signer", signer, "balance", FormatEther(balance), "minBalance", FormatEther(minBalance), "paused", timing.since(start).Round(time.Second))
+		if !alerted {
+			notifyLowBalance(ctx, notifier, n, signer, balance, minBalance)
+			alerted = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("paused for %s waiting for signer %s to be topped up: %w", timing.since(start).Round(time.Second), signer, ctx.Err())
+		case <-timing.After(timing.balancePoll()):
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// baseFeePollInterval is how often the L1 base fee is checked while a submission is deferred, about once per block.
+const baseFeePollInterval = 12 * time.Second
+
+// waitForBaseFee blocks until the latest L1 block's base fee is at or below threshold, logging the base fee trend
+// while waiting, along with how long the submission has been deferred. A nil threshold never defers.
+func waitForBaseFee(ctx context.Context, client *ethclient.Client, threshold *big.Int, timing *Timing) error {
+	if threshold == nil {
+		return nil
+	}
+
+	start := timing.Now()
+	var previous *big.Int
+	for {
+		head, err := client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("error querying L1 head: %w", err)
+		}
+		if head.BaseFee == nil || head.BaseFee.Cmp(threshold) <= 0 {
+			if previous != nil {
+				log.Info("L1 base fee dropped below threshold, resuming submission", "baseFee", head.BaseFee, "threshold", threshold, "deferred", timing.since(start).Round(time.Second))
+			}
+			return nil
+		}
+
+		trend := "steady"
+		if previous != nil && head.BaseFee.Cmp(previous) > 0 {
+			trend = "rising"
+		} else if previous != nil && head.BaseFee.Cmp(previous) < 0 {
+			trend = "falling"
+		}
+		log.Info("L1 base fee above threshold, deferring submission", "block", head.Number, "baseFee", head.BaseFee, "threshold", threshold, "trend", trend, "deferred", timing.since(start).Round(time.Second))
+		previous = head.BaseFee
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("deferred for %s waiting for the L1 base fee to drop: %w", timing.since(start).Round(time.Second), ctx.Err())
+		case <-timing.After(timing.baseFeePoll()):
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Broadcast sends a transaction signed elsewhere through sender and waits for it to be confirmed on client, as for
+// the transactions the withdrawers send. A transaction the node already knows, e.g. from an earlier broadcast, is
+// waited for all the same. The action of the returned cost is that of the portal method called, if any.
+func Broadcast(ctx context.Context, client *ethclient.Client, sender bind.ContractTransactor, tx *types.Transaction, timeout time.Duration, confirmations uint64, waitFinalized bool) (*TxCost, error) {
+	if err := sender.SendTransaction(ctx, tx); err != nil && !strings.Contains(err.Error(), "already known") {
+		return nil, fmt.Errorf("error sending tx %s: %w", tx.Hash(), err)
+	}
+	log.Info("Broadcast transaction", "l1TxHash", tx.Hash(), "nonce", tx.Nonce())
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, txTimeout(timeout))
+	defer cancel()
+	receipt, err := waitForConfirmation(ctxWithTimeout, client, tx.Hash(), confirmations, waitFinalized, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("tx %s was broadcast but not confirmed: %w", tx.Hash(), err)
+	}
+	action := ActionNone
+	if call := NewCalldata(tx); call != nil {
+		action = call.Action()
+	}
+	cost := newTxCost(string(action), receipt)
+	return &cost, nil
+}
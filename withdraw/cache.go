@@ -0,0 +1,56 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// withdrawalCache caches withdrawal receipts and their decoded MessagePassed events by L2 tx hash, so each
+// withdrawal is only fetched and parsed once per run. The zero value is ready to use.
+type withdrawalCache struct {
+	mu      sync.Mutex
+	entries map[common.Hash]*cachedWithdrawal
+}
+
+type cachedWithdrawal struct {
+	receipt *types.Receipt
+	details *WithdrawalDetails
+}
+
+// get returns the receipt and decoded withdrawal for the L2 tx, fetching them on first use. The receipt only has the
+// MessagePassed event at logIndex, see SelectWithdrawal. Reverted withdrawal transactions are rejected.
+func (c *withdrawalCache) get(ctx context.Context, client *rpc.Client, l2TxHash common.Hash, logIndex *uint) (*types.Receipt, *WithdrawalDetails, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if entry, ok := c.entries[l2TxHash]; ok {
+		return entry.receipt, entry.details, nil
+	}
+
+	receipt, err := ethclient.NewClient(client).TransactionReceipt(ctx, l2TxHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, nil, errors.New("unsuccessful withdrawal receipt status")
+	}
+	receipt, err = SelectWithdrawal(receipt, logIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	details, err := DecodeWithdrawal(receipt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[common.Hash]*cachedWithdrawal)
+	}
+	c.entries[l2TxHash] = &cachedWithdrawal{receipt: receipt, details: details}
+	return receipt, details, nil
+}
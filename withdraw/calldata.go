@@ -0,0 +1,61 @@
+package withdraw
+
+import (
+	"strings"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Calldata is a portal call as built by a dry run, to be made some other way than by the withdrawer, e.g. proposed
+// to a Safe or sent with cast send, so the withdrawer needn't hold the key of the address making it.
+type Calldata struct {
+	Method string         `json:"method,omitempty"` // Portal method called, e.g. proveWithdrawalTransaction
+	To     common.Address `json:"to"`
+	Value  *hexutil.Big   `json:"value"`
+	Data   hexutil.Bytes  `json:"data"` // ABI-encoded call, including the method selector
+}
+
+// portalABIs are the ABIs of the portal generations, to name the method a call's selector is of.
+var portalABIs = func() []*abi.ABI {
+	var abis []*abi.ABI
+	for _, meta := range []*bind.MetaData{bindingspreview.OptimismPortal2MetaData, bindings.OptimismPortalMetaData} {
+		if parsed, err := meta.GetAbi(); err == nil {
+			abis = append(abis, parsed)
+		}
+	}
+	return append(abis, &superRootPortalParsedABI)
+}()
+
+// NewCalldata returns the call the transaction makes, or nil if there is no transaction.
+func NewCalldata(tx *types.Transaction) *Calldata {
+	if tx == nil || tx.To() == nil {
+		return nil
+	}
+	c := &Calldata{To: *tx.To(), Value: (*hexutil.Big)(tx.Value()), Data: tx.Data()}
+	if len(c.Data) >= 4 {
+		for _, parsed := range portalABIs {
+			if method, err := parsed.MethodById(c.Data[:4]); err == nil {
+				c.Method = method.RawName
+				break
+			}
+		}
+	}
+	return c
+}
+
+// Action returns the withdrawal step the call performs, or ActionNone if it calls another method.
+func (c *Calldata) Action() Action {
+	switch {
+	case strings.HasPrefix(c.Method, "prove"):
+		return ActionProve
+	case strings.HasPrefix(c.Method, "finalize"):
+		return ActionFinalize
+	}
+	return ActionNone
+}
@@ -0,0 +1,84 @@
+package withdraw
+
+import (
+	"context"
+	"time"
+)
+
+// confirmationPollInterval is how often the receipt of a sent transaction is checked while waiting for it.
+const confirmationPollInterval = 5 * time.Second
+
+// Clock is the source of time for the waits of the withdrawers: polling for confirmations, deferring for the base
+// fee or a top-up, transaction timeouts and maturity countdowns. It can be replaced with a fake clock to drive the
+// waits deterministically.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc)
+}
+
+// SystemClock is the Clock of the time package.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+func (SystemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (SystemClock) WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d)
+}
+
+// Timing configures the clock and poll intervals of the waits. A nil Timing, nil Clock or zero interval uses the
+// system clock and the default intervals.
+type Timing struct {
+	Clock            Clock
+	ConfirmationPoll time.Duration // How often a sent transaction's receipt is checked
+	BaseFeePoll      time.Duration // How often the L1 base fee is checked while a submission is deferred
+	BalancePoll      time.Duration // How often the signer's balance is checked while a submission is paused
+}
+
+func (t *Timing) clock() Clock {
+	if t == nil || t.Clock == nil {
+		return SystemClock{}
+	}
+	return t.Clock
+}
+
+// Now returns the current time of the Timing's clock.
+func (t *Timing) Now() time.Time {
+	return t.clock().Now()
+}
+
+func (t *Timing) since(start time.Time) time.Duration {
+	return t.Now().Sub(start)
+}
+
+// After returns a channel that receives the time of the Timing's clock once d has elapsed on it.
+func (t *Timing) After(d time.Duration) <-chan time.Time {
+	return t.clock().After(d)
+}
+
+func (t *Timing) withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return t.clock().WithTimeout(ctx, d)
+}
+
+func (t *Timing) confirmationPoll() time.Duration {
+	if t == nil || t.ConfirmationPoll == 0 {
+		return confirmationPollInterval
+	}
+	return t.ConfirmationPoll
+}
+
+func (t *Timing) baseFeePoll() time.Duration {
+	if t == nil || t.BaseFeePoll == 0 {
+		return baseFeePollInterval
+	}
+	return t.BaseFeePoll
+}
+
+func (t *Timing) balancePoll() time.Duration {
+	if t == nil || t.BalancePoll == 0 {
+		return balancePollInterval
+	}
+	return t.BalancePoll
+}
@@ -0,0 +1,48 @@
+package withdraw
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxCost is the gas spent by a confirmed transaction.
+type TxCost struct {
+	Action            string      // Withdrawal step the tx performed, e.g. "prove" or "finalize"
+	TxHash            common.Hash // L1 tx hash
+	BlockNumber       *big.Int    // L1 block the tx was included in
+	GasUsed           uint64      // Gas used by the tx
+	EffectiveGasPrice *big.Int    // Price paid per unit of gas, including the priority fee
+	Cost              *big.Int    // Total wei spent on gas
+}
+
+func newTxCost(action string, receipt *types.Receipt) TxCost {
+	price := receipt.EffectiveGasPrice
+	if price == nil {
+		price = new(big.Int)
+	}
+	return TxCost{
+		Action:            action,
+		TxHash:            receipt.TxHash,
+		BlockNumber:       receipt.BlockNumber,
+		GasUsed:           receipt.GasUsed,
+		EffectiveGasPrice: price,
+		Cost:              new(big.Int).Mul(price, new(big.Int).SetUint64(receipt.GasUsed)),
+	}
+}
+
+// TotalCost returns the wei spent across all the given transactions.
+func TotalCost(costs []TxCost) *big.Int {
+	total := new(big.Int)
+	for _, c := range costs {
+		total.Add(total, c.Cost)
+	}
+	return total
+}
+
+// FormatGwei formats a wei amount as a decimal gwei string.
+func FormatGwei(wei *big.Int) string {
+	gwei := new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetFloat64(1e9))
+	return gwei.Text('f', 9)
+}
@@ -0,0 +1,329 @@
+package withdraw
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+const crossDomainMessengerABI = `[{"type":"function","name":"relayMessage","stateMutability":"payable","outputs":[],"inputs":[
+	{"name":"_nonce","type":"uint256"},
+	{"name":"_sender","type":"address"},
+	{"name":"_target","type":"address"},
+	{"name":"_value","type":"uint256"},
+	{"name":"_minGasLimit","type":"uint256"},
+	{"name":"_message","type":"bytes"}]}]`
+
+var crossDomainMessenger = mustParseABI(crossDomainMessengerABI)
+
+const standardBridgeABI = `[{"type":"function","name":"finalizeBridgeETH","stateMutability":"payable","outputs":[],"inputs":[
+	{"name":"_from","type":"address"},
+	{"name":"_to","type":"address"},
+	{"name":"_amount","type":"uint256"},
+	{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"finalizeBridgeERC20","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"_localToken","type":"address"},
+	{"name":"_remoteToken","type":"address"},
+	{"name":"_from","type":"address"},
+	{"name":"_to","type":"address"},
+	{"name":"_amount","type":"uint256"},
+	{"name":"_extraData","type":"bytes"}]}]`
+
+var standardBridge = mustParseABI(standardBridgeABI)
+
+const erc721BridgeABI = `[{"type":"function","name":"finalizeBridgeERC721","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"_localToken","type":"address"},
+	{"name":"_remoteToken","type":"address"},
+	{"name":"_from","type":"address"},
+	{"name":"_to","type":"address"},
+	{"name":"_tokenId","type":"uint256"},
+	{"name":"_extraData","type":"bytes"}]}]`
+
+var erc721Bridge = mustParseABI(erc721BridgeABI)
+
+// l1Methods are the L1 contracts' methods withdrawals are known to call, by which their L1 calls are named.
+var l1Methods = []abi.ABI{standardBridge, erc721Bridge, crossDomainMessenger}
+
+func mustParseABI(def string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(def))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// CrossDomainMessage is a relayMessage call to the L1CrossDomainMessenger carried by a withdrawal.
+type CrossDomainMessage struct {
+	Nonce       *big.Int       // Message nonce, without the version
+	Version     uint16         // Message version, encoded in the top two bytes of the nonce
+	Sender      common.Address // L2 sender of the message
+	Target      common.Address // L1 target that will be called
+	Value       *big.Int       // ETH value forwarded to the target
+	MinGasLimit *big.Int       // Minimum gas limit for the call to the target
+	Message     []byte         // Calldata for the call to the target
+}
+
+// DecodeCrossDomainMessage decodes withdrawal calldata as an L1CrossDomainMessenger relayMessage call.
+// It returns nil if the data is not a relayMessage call.
+func DecodeCrossDomainMessage(data []byte) (*CrossDomainMessage, error) {
+	method := crossDomainMessenger.Methods["relayMessage"]
+	if len(data) < 4 || !bytes.Equal(data[:4], method.ID) {
+		return nil, nil
+	}
+
+	args, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return nil, fmt.Errorf("error decoding relayMessage call: %w", err)
+	}
+
+	versionedNonce := args[0].(*big.Int)
+	nonceMask := new(big.Int).Sub(new(big.Int).Lsh(common.Big1, 240), common.Big1)
+	return &CrossDomainMessage{
+		Nonce:       new(big.Int).And(versionedNonce, nonceMask),
+		Version:     uint16(new(big.Int).Rsh(versionedNonce, 240).Uint64()),
+		Sender:      args[1].(common.Address),
+		Target:      args[2].(common.Address),
+		Value:       args[3].(*big.Int),
+		MinGasLimit: args[4].(*big.Int),
+		Message:     args[5].([]byte),
+	}, nil
+}
+
+// WithdrawalDetails is the decoded content of a withdrawal initiated on L2.
+type WithdrawalDetails struct {
+	Event          *bindings.L2ToL1MessagePasserMessagePassed // Raw MessagePassed event
+	Hash           common.Hash                                // Withdrawal hash computed from the event fields
+	LogIndex       uint                                       // Index of the MessagePassed event in the block's logs
+	MessengerCall  *CrossDomainMessage                        // Decoded CrossDomainMessenger payload, if any
+	Direct         bool                                       // Initiated directly on the L2ToL1MessagePasser, bypassing the CrossDomainMessenger
+	L2BlockNumber  *big.Int                                   // L2 block that includes the withdrawal
+	ReceiptSuccess bool                                       // Whether the L2 transaction succeeded
+}
+
+// MessagePassedLogIndexes returns the log indexes of the MessagePassed events in the receipt, one for each withdrawal
+// the transaction initiated, in order.
+func MessagePassedLogIndexes(receipt *types.Receipt) []uint {
+	var indexes []uint
+	for _, l := range receipt.Logs {
+		if len(l.Topics) > 0 && l.Topics[0] == withdrawals.MessagePassedTopic {
+			indexes = append(indexes, l.Index)
+		}
+	}
+	return indexes
+}
+
+// SelectWithdrawal returns a copy of the receipt without the MessagePassed events other than the one at logIndex, so
+// that the withdrawal it emitted is the one decoded and proven from the receipt. A nil logIndex selects the first
+// withdrawal, and returns the receipt as-is.
+func SelectWithdrawal(receipt *types.Receipt, logIndex *uint) (*types.Receipt, error) {
+	if logIndex == nil {
+		return receipt, nil
+	}
+	indexes := MessagePassedLogIndexes(receipt)
+	found := false
+	for _, i := range indexes {
+		found = found || i == *logIndex
+	}
+	if !found {
+		return nil, fmt.Errorf("log %d of L2 tx %s is not a withdrawal, its withdrawals are at log indexes %v", *logIndex, receipt.TxHash, indexes)
+	}
+
+	selected := *receipt
+	selected.Logs = nil
+	for _, l := range receipt.Logs {
+		if len(l.Topics) > 0 && l.Topics[0] == withdrawals.MessagePassedTopic && l.Index != *logIndex {
+			continue
+		}
+		selected.Logs = append(selected.Logs, l)
+	}
+	return &selected, nil
+}
+
+// SelectOnlyWithdrawal is SelectWithdrawal, except that a nil logIndex is rejected for transactions that initiated
+// several withdrawals, rather than selecting the first, which would leave the others behind unnoticed.
+func SelectOnlyWithdrawal(receipt *types.Receipt, logIndex *uint) (*types.Receipt, error) {
+	if indexes := MessagePassedLogIndexes(receipt); logIndex == nil && len(indexes) > 1 {
+		return nil, fmt.Errorf("L2 tx %s initiated %d withdrawals, at log indexes %v: select one by its log index", receipt.TxHash, len(indexes), indexes)
+	}
+	return SelectWithdrawal(receipt, logIndex)
+}
+
+// DecodeWithdrawal parses the MessagePassed event from a withdrawal receipt and decodes its contents. Of the
+// withdrawals of a transaction that initiated several, the first is decoded, see SelectWithdrawal.
+func DecodeWithdrawal(receipt *types.Receipt) (*WithdrawalDetails, error) {
+	ev, err := withdrawals.ParseMessagePassed(receipt)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := withdrawals.WithdrawalHash(ev)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the L2CrossDomainMessenger's withdrawals are relayed by the L1CrossDomainMessenger, direct
+	// withdrawals call their target with their value as-is even if the data looks like a relayMessage call
+	direct := ev.Sender != predeploys.L2CrossDomainMessengerAddr
+	var msg *CrossDomainMessage
+	if !direct {
+		msg, err = DecodeCrossDomainMessage(ev.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &WithdrawalDetails{
+		Event:          ev,
+		Hash:           hash,
+		LogIndex:       ev.Raw.Index,
+		MessengerCall:  msg,
+		Direct:         direct,
+		L2BlockNumber:  receipt.BlockNumber,
+		ReceiptSuccess: receipt.Status == types.ReceiptStatusSuccessful,
+	}, nil
+}
+
+// Recipient returns the L1 address that receives the withdrawn ETH: the recipient of an L2StandardBridge ETH
+// withdrawal, the target of any other CrossDomainMessenger message, or the target of a direct withdrawal.
+func (d *WithdrawalDetails) Recipient() common.Address {
+	msg := d.MessengerCall
+	if msg == nil {
+		return d.Event.Target
+	}
+	method := standardBridge.Methods["finalizeBridgeETH"]
+	if msg.Sender == predeploys.L2StandardBridgeAddr && len(msg.Message) >= 4 && bytes.Equal(msg.Message[:4], method.ID) {
+		if args, err := method.Inputs.Unpack(msg.Message[4:]); err == nil {
+			return args[1].(common.Address)
+		}
+	}
+	return msg.Target
+}
+
+// Callers of an L1Call.
+const (
+	L1CallerPortal    = "portal"    // OptimismPortal, calling the target of a direct withdrawal
+	L1CallerMessenger = "messenger" // L1CrossDomainMessenger, relaying a message to its target
+)
+
+// L1Call is the call a withdrawal makes on L1 once finalized: the L1CrossDomainMessenger's call relaying its message,
+// or the portal's own call for direct withdrawals and messages that can't be decoded, so the downstream target, value
+// and method can be seen instead of the opaque withdrawal calldata.
+type L1Call struct {
+	Caller   string         `json:"caller"` // L1CallerPortal or L1CallerMessenger
+	Target   common.Address `json:"target"`
+	Value    *big.Int       `json:"value"`            // In wei, or the gas paying token's base units
+	Method   string         `json:"method,omitempty"` // e.g. finalizeBridgeETH, or the selector if unknown, empty for a plain transfer
+	Data     hexutil.Bytes  `json:"data,omitempty"`
+	GasLimit *big.Int       `json:"gasLimit"` // Gas limit of the withdrawal, or minimum of the message's call
+}
+
+// L1Call returns the call the withdrawal makes on L1 once finalized.
+func (d *WithdrawalDetails) L1Call() *L1Call {
+	if msg := d.MessengerCall; msg != nil {
+		return &L1Call{Caller: L1CallerMessenger, Target: msg.Target, Value: msg.Value, Method: methodName(msg.Message), Data: msg.Message, GasLimit: msg.MinGasLimit}
+	}
+	ev := d.Event
+	return &L1Call{Caller: L1CallerPortal, Target: ev.Target, Value: ev.Value, Method: methodName(ev.Data), Data: ev.Data, GasLimit: ev.GasLimit}
+}
+
+// LogFields returns the call as log key/value pairs.
+func (c *L1Call) LogFields() []interface{} {
+	fields := []interface{}{"caller", c.Caller, "target", c.Target, "value", c.Value}
+	if c.Method != "" {
+		fields = append(fields, "method", c.Method)
+	}
+	return fields
+}
+
+// methodName names the method the calldata calls, from the known L1 methods, or returns its selector if it is unknown.
+// Calldata too short to call a method, e.g. none for a plain transfer, has no name.
+func methodName(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	for _, contract := range l1Methods {
+		if method, err := contract.MethodById(data[:4]); err == nil {
+			return method.Name
+		}
+	}
+	return hexutil.Encode(data[:4])
+}
+
+// Kinds of BridgeTransfer.
+const (
+	BridgeETH    = "ETH"
+	BridgeERC20  = "ERC-20"
+	BridgeERC721 = "ERC-721"
+)
+
+// BridgeTransfer is the transfer an L1 bridge makes when it finalizes a withdrawal sent through the L2StandardBridge
+// or L2ERC721Bridge: the decoded finalizeBridgeETH, finalizeBridgeERC20 or finalizeBridgeERC721 call the
+// CrossDomainMessenger relays to it.
+type BridgeTransfer struct {
+	Kind      string         `json:"kind"`              // BridgeETH, BridgeERC20 or BridgeERC721
+	L1Token   common.Address `json:"l1Token,omitempty"` // Token released on L1 (tokens only)
+	L2Token   common.Address `json:"l2Token,omitempty"` // Token withdrawn on L2 (tokens only)
+	From      common.Address `json:"from"`
+	To        common.Address `json:"to"`
+	Amount    *big.Int       `json:"amount,omitempty"`  // ETH or ERC-20 amount, in wei or the token's base units
+	TokenID   *big.Int       `json:"tokenId,omitempty"` // ERC-721 token ID
+	ExtraData hexutil.Bytes  `json:"extraData,omitempty"`
+}
+
+// BridgeTransfer decodes the transfer the withdrawal's message makes the L1 bridge do, or returns nil if it is not a
+// withdrawal through the L2StandardBridge or L2ERC721Bridge.
+func (d *WithdrawalDetails) BridgeTransfer() *BridgeTransfer {
+	msg := d.MessengerCall
+	if msg == nil || len(msg.Message) < 4 {
+		return nil
+	}
+	var bridge abi.ABI
+	switch msg.Sender {
+	case predeploys.L2StandardBridgeAddr:
+		bridge = standardBridge
+	case predeploys.L2ERC721BridgeAddr:
+		bridge = erc721Bridge
+	default:
+		return nil
+	}
+	method, err := bridge.MethodById(msg.Message[:4])
+	if err != nil {
+		return nil
+	}
+	args, err := method.Inputs.Unpack(msg.Message[4:])
+	if err != nil {
+		return nil
+	}
+	switch method.Name {
+	case "finalizeBridgeETH":
+		return &BridgeTransfer{Kind: BridgeETH, From: args[0].(common.Address), To: args[1].(common.Address), Amount: args[2].(*big.Int), ExtraData: args[3].([]byte)}
+	case "finalizeBridgeERC20":
+		return &BridgeTransfer{Kind: BridgeERC20, L1Token: args[0].(common.Address), L2Token: args[1].(common.Address), From: args[2].(common.Address),
+			To: args[3].(common.Address), Amount: args[4].(*big.Int), ExtraData: args[5].([]byte)}
+	case "finalizeBridgeERC721":
+		return &BridgeTransfer{Kind: BridgeERC721, L1Token: args[0].(common.Address), L2Token: args[1].(common.Address), From: args[2].(common.Address),
+			To: args[3].(common.Address), TokenID: args[4].(*big.Int), ExtraData: args[5].([]byte)}
+	}
+	return nil
+}
+
+// String describes the transfer, e.g. "ERC-721 token 42 of 0x... from 0x... to 0x...".
+func (t *BridgeTransfer) String() string {
+	switch t.Kind {
+	case BridgeETH:
+		return fmt.Sprintf("%s ETH from %s to %s", FormatEther(t.Amount), t.From, t.To)
+	case BridgeERC721:
+		return fmt.Sprintf("ERC-721 token %s of %s (L2 %s) from %s to %s", t.TokenID, t.L1Token, t.L2Token, t.From, t.To)
+	default:
+		return fmt.Sprintf("%s base units of ERC-20 %s (L2 %s) from %s to %s", t.Amount, t.L1Token, t.L2Token, t.From, t.To)
+	}
+}
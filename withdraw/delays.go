@@ -0,0 +1,62 @@
+package withdraw
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+)
+
+// Delays are the waits the portal enforces before a proven withdrawal can be finalized. They are configured per chain,
+// so they are read from the contracts instead of assuming the usual seven days.
+type Delays struct {
+	ProofMaturityDelaySeconds       uint64 `json:"proofMaturityDelaySeconds"`                 // Minimum age of a proof, the finalization period on legacy portals
+	DisputeGameFinalityDelaySeconds uint64 `json:"disputeGameFinalityDelaySeconds,omitempty"` // Minimum time since the game resolved (fault proofs only)
+}
+
+// ProofMaturityDelay returns the proof maturity delay as a duration.
+func (d *Delays) ProofMaturityDelay() time.Duration {
+	return time.Duration(d.ProofMaturityDelaySeconds) * time.Second
+}
+
+// DisputeGameFinalityDelay returns the dispute game finality delay as a duration.
+func (d *Delays) DisputeGameFinalityDelay() time.Duration {
+	return time.Duration(d.DisputeGameFinalityDelaySeconds) * time.Second
+}
+
+// LogFields returns the delays as key/value pairs for logging.
+func (d *Delays) LogFields() []interface{} {
+	fields := []interface{}{"proofMaturityDelay", d.ProofMaturityDelay()}
+	if d.DisputeGameFinalityDelaySeconds > 0 {
+		fields = append(fields, "disputeGameFinalityDelay", d.DisputeGameFinalityDelay())
+	}
+	return fields
+}
+
+// ReadDelays reads the proof maturity and dispute game finality delays of a fault proof portal.
+func ReadDelays(portal *bindingspreview.OptimismPortal2Caller) (*Delays, error) {
+	maturityDelay, err := portal.ProofMaturityDelaySeconds(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying proof maturity delay: %w", err)
+	}
+	finalityDelay, err := portal.DisputeGameFinalityDelaySeconds(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying dispute game finality delay: %w", err)
+	}
+	return &Delays{
+		ProofMaturityDelaySeconds:       maturityDelay.Uint64(),
+		DisputeGameFinalityDelaySeconds: finalityDelay.Uint64(),
+	}, nil
+}
+
+// ReadLegacyDelays reads the finalization period of the L2OutputOracle, which legacy portals require both proofs and
+// L2 outputs to have aged by.
+func ReadLegacyDelays(oracle *bindings.L2OutputOracleCaller) (*Delays, error) {
+	period, err := oracle.FINALIZATIONPERIODSECONDS(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("error querying finalization period: %w", err)
+	}
+	return &Delays{ProofMaturityDelaySeconds: period.Uint64()}, nil
+}
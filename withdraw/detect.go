@@ -0,0 +1,32 @@
+package withdraw
+
+import (
+	"fmt"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DetectFaultProofs probes the OptimismPortal to determine whether it uses fault proofs: the fault proof
+// portal has a respected game type, while the legacy portal has an L2OutputOracle.
+func DetectFaultProofs(portal common.Address, caller bind.ContractCaller) (bool, error) {
+	portal2, err := bindingspreview.NewOptimismPortal2Caller(portal, caller)
+	if err != nil {
+		return false, err
+	}
+	_, fpErr := portal2.RespectedGameType(&bind.CallOpts{})
+	if fpErr == nil {
+		return true, nil
+	}
+
+	legacy, err := bindings.NewOptimismPortalCaller(portal, caller)
+	if err != nil {
+		return false, err
+	}
+	if _, err := legacy.L2Oracle(&bind.CallOpts{}); err == nil {
+		return false, nil
+	}
+	return false, fmt.Errorf("OptimismPortal %s has neither a respected game type nor an L2OutputOracle: %w", portal, fpErr)
+}
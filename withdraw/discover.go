@@ -0,0 +1,75 @@
+package withdraw
+
+import (
+	"fmt"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// crossDomainMessengerGettersABI has the getters of the CrossDomainMessengers, including the legacy upper case ones
+// of older releases.
+const crossDomainMessengerGettersABI = `[
+	{"type":"function","name":"otherMessenger","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"OTHER_MESSENGER","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"portal","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"PORTAL","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
+
+var crossDomainMessengerGettersParsedABI = mustParseABI(crossDomainMessengerGettersABI)
+
+// callAddressGetter calls the first of the given getters that succeeds on the contract at address.
+func callAddressGetter(address common.Address, caller bind.ContractCaller, methods ...string) (common.Address, error) {
+	contract := bind.NewBoundContract(address, crossDomainMessengerGettersParsedABI, caller, nil, nil)
+	var err error
+	for _, method := range methods {
+		var out []interface{}
+		if err = contract.Call(&bind.CallOpts{}, &out, method); err == nil {
+			return out[0].(common.Address), nil
+		}
+	}
+	return common.Address{}, fmt.Errorf("error calling %s on %s: %w", methods[0], address, err)
+}
+
+// DiscoverPortal finds the chain's OptimismPortal on L1 from the L2 alone: the L2CrossDomainMessenger predeploy
+// knows its L1 counterpart, which in turn knows the portal.
+func DiscoverPortal(l1 bind.ContractCaller, l2 bind.ContractCaller) (common.Address, error) {
+	l1Messenger, err := callAddressGetter(predeploys.L2CrossDomainMessengerAddr, l2, "otherMessenger", "OTHER_MESSENGER")
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error querying L1CrossDomainMessenger from L2: %w", err)
+	}
+	portal, err := callAddressGetter(l1Messenger, l1, "portal", "PORTAL")
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error querying OptimismPortal from L1CrossDomainMessenger: %w", err)
+	}
+	return portal, nil
+}
+
+// DiscoverDisputeGameFactory returns the DisputeGameFactory used by a fault proof OptimismPortal.
+func DiscoverDisputeGameFactory(portal common.Address, l1 bind.ContractCaller) (common.Address, error) {
+	caller, err := bindingspreview.NewOptimismPortal2Caller(portal, l1)
+	if err != nil {
+		return common.Address{}, err
+	}
+	factory, err := caller.DisputeGameFactory(&bind.CallOpts{})
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error querying DisputeGameFactory from OptimismPortal %s: %w", portal, err)
+	}
+	return factory, nil
+}
+
+// DiscoverL2OutputOracle returns the L2OutputOracle used by a legacy OptimismPortal.
+func DiscoverL2OutputOracle(portal common.Address, l1 bind.ContractCaller) (common.Address, error) {
+	caller, err := bindings.NewOptimismPortalCaller(portal, l1)
+	if err != nil {
+		return common.Address{}, err
+	}
+	oracle, err := caller.L2Oracle(&bind.CallOpts{})
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error querying L2OutputOracle from OptimismPortal %s: %w", portal, err)
+	}
+	return oracle, nil
+}
@@ -0,0 +1,152 @@
+package withdraw
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GameStatus mirrors the GameStatus enum of IDisputeGame.
+type GameStatus uint8
+
+const (
+	GameStatusInProgress     GameStatus = 0
+	GameStatusChallengerWins GameStatus = 1
+	GameStatusDefenderWins   GameStatus = 2
+)
+
+func (s GameStatus) String() string {
+	switch s {
+	case GameStatusInProgress:
+		return "IN_PROGRESS"
+	case GameStatusChallengerWins:
+		return "CHALLENGER_WINS"
+	case GameStatusDefenderWins:
+		return "DEFENDER_WINS"
+	default:
+		return fmt.Sprintf("UNKNOWN(%d)", uint8(s))
+	}
+}
+
+const disputeGameABI = `[
+	{"type":"function","name":"status","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"createdAt","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint64"}]},
+	{"type":"function","name":"resolvedAt","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint64"}]},
+	{"type":"function","name":"rootClaim","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bytes32"}]},
+	{"type":"function","name":"l2BlockNumber","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"gameType","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint32"}]},
+	{"type":"function","name":"maxClockDuration","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint64"}]},
+	{"type":"function","name":"claimDataLen","stateMutability":"view","inputs":[],"outputs":[{"name":"len_","type":"uint256"}]},
+	{"type":"function","name":"wasRespectedGameTypeWhenCreated","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+var disputeGameParsedABI = mustParseABI(disputeGameABI)
+
+// DisputeGame is a minimal read-only binding for the IDisputeGame methods (and FaultDisputeGame's
+// maxClockDuration, claimDataLen and wasRespectedGameTypeWhenCreated) used by the withdrawer.
+type DisputeGame struct {
+	Address  common.Address
+	contract *bind.BoundContract
+}
+
+// NewDisputeGame binds the dispute game at the given address.
+func NewDisputeGame(address common.Address, caller bind.ContractCaller) *DisputeGame {
+	return &DisputeGame{
+		Address:  address,
+		contract: bind.NewBoundContract(address, disputeGameParsedABI, caller, nil, nil),
+	}
+}
+
+func (g *DisputeGame) call(method string) (interface{}, error) {
+	var out []interface{}
+	if err := g.contract.Call(&bind.CallOpts{}, &out, method); err != nil {
+		return nil, fmt.Errorf("error calling %s on dispute game %s: %w", method, g.Address, err)
+	}
+	return out[0], nil
+}
+
+// Status returns the game's resolution status.
+func (g *DisputeGame) Status() (GameStatus, error) {
+	out, err := g.call("status")
+	if err != nil {
+		return 0, err
+	}
+	return GameStatus(out.(uint8)), nil
+}
+
+// CreatedAt returns the timestamp the game was created at.
+func (g *DisputeGame) CreatedAt() (uint64, error) {
+	out, err := g.call("createdAt")
+	if err != nil {
+		return 0, err
+	}
+	return out.(uint64), nil
+}
+
+// ResolvedAt returns the timestamp the game was resolved at, or 0 if it is unresolved.
+func (g *DisputeGame) ResolvedAt() (uint64, error) {
+	out, err := g.call("resolvedAt")
+	if err != nil {
+		return 0, err
+	}
+	return out.(uint64), nil
+}
+
+// RootClaim returns the output root proposed by the game.
+func (g *DisputeGame) RootClaim() (common.Hash, error) {
+	out, err := g.call("rootClaim")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.Hash(out.([32]byte)), nil
+}
+
+// L2BlockNumber returns the L2 block the game's root claim is for.
+func (g *DisputeGame) L2BlockNumber() (*big.Int, error) {
+	out, err := g.call("l2BlockNumber")
+	if err != nil {
+		return nil, err
+	}
+	return out.(*big.Int), nil
+}
+
+// GameType returns the game's type.
+func (g *DisputeGame) GameType() (uint32, error) {
+	out, err := g.call("gameType")
+	if err != nil {
+		return 0, err
+	}
+	return out.(uint32), nil
+}
+
+// MaxClockDuration returns the maximum time a side's chess clock can run for, which bounds how soon an
+// unchallenged game can be resolved.
+func (g *DisputeGame) MaxClockDuration() (uint64, error) {
+	out, err := g.call("maxClockDuration")
+	if err != nil {
+		return 0, err
+	}
+	return out.(uint64), nil
+}
+
+// ClaimDataLen returns the number of claims in the game, including the root claim, so a game whose root claim was
+// never countered has one.
+func (g *DisputeGame) ClaimDataLen() (uint64, error) {
+	out, err := g.call("claimDataLen")
+	if err != nil {
+		return 0, err
+	}
+	return out.(*big.Int).Uint64(), nil
+}
+
+// WasRespectedGameTypeWhenCreated returns whether the game was of the portal's respected game type when it was
+// created. Games deployed before the portal accepted other game types don't have it.
+func (g *DisputeGame) WasRespectedGameTypeWhenCreated() (bool, error) {
+	out, err := g.call("wasRespectedGameTypeWhenCreated")
+	if err != nil {
+		return false, err
+	}
+	return out.(bool), nil
+}
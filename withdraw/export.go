@@ -0,0 +1,189 @@
+package withdraw
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ErrExportedProofMismatch is returned when an imported proof isn't of the withdrawal, or its dispute game no longer
+// has the root claim it was exported against.
+var ErrExportedProofMismatch = errors.New("exported proof does not match the withdrawal")
+
+// ExportedProof is everything proveWithdrawalTransaction needs to prove a withdrawal against a dispute game, as
+// computed by ExportProof, so the proof can be reviewed, archived or submitted from elsewhere.
+type ExportedProof struct {
+	L1ChainID       *hexutil.Big            `json:"l1ChainId"`
+	Portal          common.Address          `json:"portal"`
+	L2TxHash        common.Hash             `json:"l2TxHash"`
+	LogIndex        *uint                   `json:"logIndex,omitempty"`
+	WithdrawalHash  common.Hash             `json:"withdrawalHash"`
+	Withdrawal      ExportedWithdrawalTx    `json:"withdrawal"`
+	Game            ProofMetadata           `json:"game"` // Dispute game proven against, whose index is the L2 output index argument
+	OutputRootProof ExportedOutputRootProof `json:"outputRootProof"`
+	WithdrawalProof []hexutil.Bytes         `json:"withdrawalProof"` // Storage proof of the withdrawal in the L2ToL1MessagePasser
+	ExportedAt      time.Time               `json:"exportedAt"`
+}
+
+// ExportedWithdrawalTx is the withdrawal transaction argument of proveWithdrawalTransaction.
+type ExportedWithdrawalTx struct {
+	Nonce    *hexutil.Big   `json:"nonce"`
+	Sender   common.Address `json:"sender"`
+	Target   common.Address `json:"target"`
+	Value    *hexutil.Big   `json:"value"`
+	GasLimit *hexutil.Big   `json:"gasLimit"`
+	Data     hexutil.Bytes  `json:"data"`
+}
+
+// ExportedOutputRootProof is the preimage of the game's root claim, the output root proof argument of
+// proveWithdrawalTransaction.
+type ExportedOutputRootProof struct {
+	Version                  common.Hash `json:"version"`
+	StateRoot                common.Hash `json:"stateRoot"`
+	MessagePasserStorageRoot common.Hash `json:"messagePasserStorageRoot"`
+	LatestBlockhash          common.Hash `json:"latestBlockhash"`
+}
+
+// ExportProof computes the withdrawal's proof against the dispute game it would be proven against, checked like
+// before proving, without sending anything. Portals proving against super roots aren't supported.
+func (w *FPWithdrawer) ExportProof() (*ExportedProof, error) {
+	if SuperRootsActive(w.L1Client, w.PortalAddress) {
+		return nil, errors.New("exporting proofs against super roots is not supported")
+	}
+	hash, err := w.getWithdrawalHash()
+	if err != nil {
+		return nil, err
+	}
+	chainID, err := w.L1Client.ChainID(w.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := w.buildProof()
+	if err != nil {
+		return nil, err
+	}
+	e := &ExportedProof{
+		L1ChainID:      (*hexutil.Big)(chainID),
+		Portal:         w.PortalAddress,
+		L2TxHash:       w.L2TxHash,
+		LogIndex:       w.LogIndex,
+		WithdrawalHash: hash,
+		Withdrawal: ExportedWithdrawalTx{
+			Nonce:    (*hexutil.Big)(proof.WithdrawalTx.Nonce),
+			Sender:   proof.WithdrawalTx.Sender,
+			Target:   proof.WithdrawalTx.Target,
+			Value:    (*hexutil.Big)(proof.WithdrawalTx.Value),
+			GasLimit: (*hexutil.Big)(proof.WithdrawalTx.GasLimit),
+			Data:     proof.WithdrawalTx.Data,
+		},
+		Game: *w.Proof,
+		OutputRootProof: ExportedOutputRootProof{
+			Version:                  proof.OutputRootProof.Version,
+			StateRoot:                proof.OutputRootProof.StateRoot,
+			MessagePasserStorageRoot: proof.OutputRootProof.MessagePasserStorageRoot,
+			LatestBlockhash:          proof.OutputRootProof.LatestBlockhash,
+		},
+		ExportedAt: w.Timing.Now().UTC(),
+	}
+	for _, node := range proof.WithdrawalProof {
+		e.WithdrawalProof = append(e.WithdrawalProof, node)
+	}
+	return e, nil
+}
+
+// importProof checks the ImportedProof against the current chain state and returns it as the arguments of
+// proveWithdrawalTransaction: it must be for this L1 chain, portal and withdrawal, and its dispute game must still be
+// one the portal accepts proofs against, with the root claim its output root proof hashes to. The storage proof itself
+// is checked by the portal, when the prove transaction is estimated. The game is recorded as the Proof.
+func (w *FPWithdrawer) importProof() (*fpProof, error) {
+	p := w.ImportedProof
+	if SuperRootsActive(w.L1Client, w.PortalAddress) {
+		return nil, errors.New("proving against super roots with an exported proof is not supported")
+	}
+	chainID, err := w.L1Client.ChainID(w.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	if p.L1ChainID == nil || p.L1ChainID.ToInt().Cmp(chainID) != 0 {
+		return nil, fmt.Errorf("%w: proof is for L1 chain %v, not %s", ErrExportedProofMismatch, p.L1ChainID, chainID)
+	}
+	if p.Portal != w.PortalAddress {
+		return nil, fmt.Errorf("%w: proof is for portal %s, not %s", ErrExportedProofMismatch, p.Portal, w.PortalAddress)
+	}
+	if p.Withdrawal.Nonce == nil || p.Withdrawal.Value == nil || p.Withdrawal.GasLimit == nil || p.Game.GameIndex == nil || p.Game.L2Block == nil {
+		return nil, fmt.Errorf("%w: proof is missing withdrawal or game fields", ErrExportedProofMismatch)
+	}
+	withdrawalTx := bindingspreview.TypesWithdrawalTransaction{
+		Nonce:    p.Withdrawal.Nonce.ToInt(),
+		Sender:   p.Withdrawal.Sender,
+		Target:   p.Withdrawal.Target,
+		Value:    p.Withdrawal.Value.ToInt(),
+		GasLimit: p.Withdrawal.GasLimit.ToInt(),
+		Data:     p.Withdrawal.Data,
+	}
+	computed, err := withdrawals.WithdrawalHash(&bindings.L2ToL1MessagePasserMessagePassed{
+		Nonce:    withdrawalTx.Nonce,
+		Sender:   withdrawalTx.Sender,
+		Target:   withdrawalTx.Target,
+		Value:    withdrawalTx.Value,
+		GasLimit: withdrawalTx.GasLimit,
+		Data:     withdrawalTx.Data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hash, err := w.getWithdrawalHash()
+	if err != nil {
+		return nil, err
+	}
+	if computed != hash || p.WithdrawalHash != hash {
+		return nil, fmt.Errorf("%w: proof is of withdrawal %s (computed %s), not %s", ErrExportedProofMismatch, p.WithdrawalHash, computed, hash)
+	}
+
+	// the game must still be usable: of the respected type, created after it was last updated, and neither
+	// blacklisted nor resolved against its root claim
+	game, err := GameAtIndex(&w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, w.L1Client, p.Game.GameIndex, p.Game.L2Block)
+	if err != nil {
+		return nil, err
+	}
+	if gameProxy(*game) != p.Game.GameAddress || common.Hash(game.RootClaim) != p.Game.OutputRoot {
+		return nil, fmt.Errorf("%w: game %s is %s with root claim %s, but the proof expects %s with %s", ErrExportedProofMismatch, p.Game.GameIndex,
+			gameProxy(*game), common.Hash(game.RootClaim), p.Game.GameAddress, p.Game.OutputRoot)
+	}
+	outputRootProof := bindings.TypesOutputRootProof{
+		Version:                  p.OutputRootProof.Version,
+		StateRoot:                p.OutputRootProof.StateRoot,
+		MessagePasserStorageRoot: p.OutputRootProof.MessagePasserStorageRoot,
+		LatestBlockhash:          p.OutputRootProof.LatestBlockhash,
+	}
+	if err := verifyOutputRoot(outputRootProof, game.RootClaim); err != nil {
+		return nil, err
+	}
+
+	w.Proof = &ProofMetadata{
+		GameIndex:   game.Index,
+		GameAddress: gameProxy(*game),
+		GameType:    gameType(*game),
+		L2Block:     gameL2BlockNumber(*game),
+		OutputRoot:  game.RootClaim,
+	}
+	log.Info("Proving with the exported proof against dispute game", append(w.Proof.logFields(), "exportedAt", p.ExportedAt)...)
+
+	withdrawalProof := make([][]byte, len(p.WithdrawalProof))
+	for i, node := range p.WithdrawalProof {
+		withdrawalProof[i] = node
+	}
+	return &fpProof{
+		WithdrawalTx:    withdrawalTx,
+		GameIndex:       game.Index,
+		OutputRootProof: bindingspreview.TypesOutputRootProof(outputRootProof),
+		WithdrawalProof: withdrawalProof,
+	}, nil
+}
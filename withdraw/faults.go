@@ -0,0 +1,97 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Fault names a failure that can be injected so operators can rehearse their runbooks and verify alerting and
+// retry behavior in test environments.
+type Fault string
+
+const (
+	FaultRPCTimeout      Fault = "rpc-timeout"      // L1 RPC times out while waiting for a submitted tx to confirm
+	FaultRevert          Fault = "revert"           // prove and finalize txs revert instead of being submitted
+	FaultReorg           Fault = "reorg"            // submitted txs are reorged out once after inclusion
+	FaultGameBlacklisted Fault = "game-blacklisted" // the dispute game the withdrawal was proven against is blacklisted
+)
+
+var knownFaults = []Fault{FaultRPCTimeout, FaultRevert, FaultReorg, FaultGameBlacklisted}
+
+// ErrInjectedFault is wrapped by every error caused by an injected fault.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Faults is the set of faults to inject. It implements flag.Value, accepting comma-separated fault names and
+// accumulating across repeated flags. A nil Faults injects nothing.
+type Faults map[Fault]bool
+
+func (f *Faults) String() string {
+	if f == nil || len(*f) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(*f))
+	for fault := range *f {
+		names = append(names, string(fault))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func (f *Faults) Set(value string) error {
+	if *f == nil {
+		*f = Faults{}
+	}
+	for _, name := range strings.Split(value, ",") {
+		fault := Fault(strings.TrimSpace(name))
+		if !isKnownFault(fault) {
+			return fmt.Errorf("unknown fault %q (one of: %s)", fault, FaultNames())
+		}
+		(*f)[fault] = true
+	}
+	return nil
+}
+
+// Has reports whether the fault should be injected.
+func (f Faults) Has(fault Fault) bool {
+	return f[fault]
+}
+
+// FaultNames returns the comma-separated names of all injectable faults.
+func FaultNames() string {
+	names := make([]string, len(knownFaults))
+	for i, fault := range knownFaults {
+		names[i] = string(fault)
+	}
+	return strings.Join(names, ", ")
+}
+
+func isKnownFault(fault Fault) bool {
+	for _, known := range knownFaults {
+		if fault == known {
+			return true
+		}
+	}
+	return false
+}
+
+// injectFault logs and returns an error for the fault if it should be injected, or nil otherwise.
+func (f Faults) injectFault(fault Fault, format string, args ...interface{}) error {
+	if !f.Has(fault) {
+		return nil
+	}
+	log.Warn("Injecting fault", "fault", fault)
+	return fmt.Errorf("%w %s: %s", ErrInjectedFault, fault, fmt.Sprintf(format, args...))
+}
+
+// injectTimeout returns a deadline exceeded error if the rpc-timeout fault should be injected, or nil otherwise.
+func (f Faults) injectTimeout() error {
+	if err := f.injectFault(FaultRPCTimeout, "L1 RPC request timed out"); err != nil {
+		return fmt.Errorf("%w: %w", err, context.DeadlineExceeded)
+	}
+	return nil
+}
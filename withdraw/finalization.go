@@ -0,0 +1,200 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// FinalizationEstimate describes when a proven withdrawal will pass the portal's checkWithdrawal.
+type FinalizationEstimate struct {
+	ProvenAt                uint64         // Timestamp the withdrawal was proven at
+	ProofMaturesAt          uint64         // ProvenAt plus the portal's proof maturity delay
+	Game                    common.Address // Dispute game the withdrawal was proven against
+	GameStatus              GameStatus     // Current status of the dispute game
+	GameResolvedAt          uint64         // Timestamp the game resolved at, or the earliest it can resolve if unresolved
+	GameResolutionEstimated bool           // Whether GameResolvedAt is an estimate because the game is unresolved
+	GameContested           bool           // Whether the unresolved game's root claim was countered, so it may resolve against it
+	GameFinalAt             uint64         // GameResolvedAt plus the portal's dispute game finality delay
+	OutputProposedAt        uint64         // Legacy only: timestamp the L2 output proven against was proposed at
+	OutputFinalAt           uint64         // Legacy only: OutputProposedAt plus the finalization period
+	FinalizableAt           uint64         // The later of ProofMaturesAt and GameFinalAt (or OutputFinalAt)
+	ProofFinalized          bool           // Whether the L1 block the proof was included in is finalized, if known
+	Delays                  *Delays        // Portal delays the estimate is based on
+}
+
+// Legacy reports whether the estimate is for a withdrawal proven against an L2OutputOracle output rather than a
+// dispute game.
+func (e *FinalizationEstimate) Legacy() bool {
+	return e.Game == (common.Address{})
+}
+
+// Remaining returns how long until the withdrawal is finalizable, or 0 if it already is.
+func (e *FinalizationEstimate) Remaining(now time.Time) time.Duration {
+	at := time.Unix(int64(e.FinalizableAt), 0)
+	if !at.After(now) {
+		return 0
+	}
+	return at.Sub(now)
+}
+
+// EstimateFinalization computes when the withdrawal proven by submitter can be finalized, based on the portal's
+// proof maturity and dispute game finality delays and the resolution of the game it was proven against. If the game
+// is still in progress, its resolution is estimated as the earliest an unchallenged game can resolve, or the latest a
+// challenged one can.
+func EstimateFinalization(portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, hash common.Hash, submitter common.Address) (*FinalizationEstimate, error) {
+	proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash, submitter)
+	if err != nil {
+		return nil, fmt.Errorf("error querying proven withdrawal: %w", err)
+	}
+	if proven.Timestamp == 0 {
+		return nil, fmt.Errorf("withdrawal has not been proven by %s", submitter)
+	}
+
+	delays, err := ReadDelays(portal)
+	if err != nil {
+		return nil, err
+	}
+
+	game := NewDisputeGame(proven.DisputeGameProxy, caller)
+	status, err := game.Status()
+	if err != nil {
+		return nil, err
+	}
+	if status == GameStatusChallengerWins {
+		return nil, errors.New("the dispute game resolved against the root claim, the withdrawal must be re-proven")
+	}
+
+	e := &FinalizationEstimate{
+		ProvenAt:       proven.Timestamp,
+		ProofMaturesAt: proven.Timestamp + delays.ProofMaturityDelaySeconds,
+		Game:           proven.DisputeGameProxy,
+		GameStatus:     status,
+		Delays:         delays,
+	}
+
+	if status == GameStatusDefenderWins {
+		e.GameResolvedAt, err = game.ResolvedAt()
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		createdAt, err := game.CreatedAt()
+		if err != nil {
+			return nil, err
+		}
+		maxClock, err := game.MaxClockDuration()
+		if err != nil {
+			return nil, err
+		}
+		e.GameResolvedAt = createdAt + maxClock
+		e.GameResolutionEstimated = true
+		// a countered root claim can keep the game going until both sides' clocks run out
+		if claims, err := game.ClaimDataLen(); err == nil && claims > 1 {
+			e.GameResolvedAt = createdAt + 2*maxClock
+			e.GameContested = true
+		}
+	}
+	e.GameFinalAt = e.GameResolvedAt + delays.DisputeGameFinalityDelaySeconds
+
+	e.FinalizableAt = e.ProofMaturesAt
+	if e.GameFinalAt > e.FinalizableAt {
+		e.FinalizableAt = e.GameFinalAt
+	}
+	return e, nil
+}
+
+// EstimateLegacyFinalization computes when a withdrawal proven on a legacy portal can be finalized. The portal requires
+// the L2OutputOracle's FINALIZATION_PERIOD_SECONDS to have elapsed both since the withdrawal was proven and since the
+// output it was proven against was proposed.
+func EstimateLegacyFinalization(portal *bindings.OptimismPortalCaller, oracle *bindings.L2OutputOracleCaller, hash common.Hash) (*FinalizationEstimate, error) {
+	proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() == 0 {
+		return nil, errors.New("withdrawal has not been proven")
+	}
+	delays, err := ReadLegacyDelays(oracle)
+	if err != nil {
+		return nil, err
+	}
+	period := delays.ProofMaturityDelaySeconds
+	output, err := oracle.GetL2Output(&bind.CallOpts{}, proven.L2OutputIndex)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L2 output %s: %w", proven.L2OutputIndex, err)
+	}
+
+	e := &FinalizationEstimate{
+		ProvenAt:         proven.Timestamp.Uint64(),
+		ProofMaturesAt:   proven.Timestamp.Uint64() + period,
+		OutputProposedAt: output.Timestamp.Uint64(),
+		OutputFinalAt:    output.Timestamp.Uint64() + period,
+		Delays:           delays,
+	}
+	e.FinalizableAt = max(e.ProofMaturesAt, e.OutputFinalAt)
+	return e, nil
+}
+
+// NotFinalizableError explains why the portal's checkWithdrawal rejected a proven withdrawal, and when it will pass.
+type NotFinalizableError struct {
+	Reasons       []string  // Conditions that are not met yet
+	FinalizableAt time.Time // When the withdrawal is expected to become finalizable
+	Remaining     time.Duration
+}
+
+func (e *NotFinalizableError) Error() string {
+	return fmt.Sprintf("withdrawal is not finalizable yet: %s - finalizable at %s (in %d seconds)",
+		strings.Join(e.Reasons, ", "), e.FinalizableAt.UTC().Format(time.RFC3339), int64(e.Remaining.Seconds()))
+}
+
+// notFinalizableError returns a NotFinalizableError listing the waits that haven't elapsed at now, or nil if they
+// all have, in which case checkWithdrawal failed for another reason.
+func (e *FinalizationEstimate) notFinalizableError(now time.Time) error {
+	remaining := e.Remaining(now)
+	if remaining == 0 {
+		return nil
+	}
+
+	var reasons []string
+	if at := time.Unix(int64(e.ProofMaturesAt), 0); at.After(now) {
+		reasons = append(reasons, fmt.Sprintf("the proof matures in %d seconds", int64(at.Sub(now).Seconds())))
+	}
+	if e.Legacy() {
+		if at := time.Unix(int64(e.OutputFinalAt), 0); at.After(now) {
+			reasons = append(reasons, fmt.Sprintf("the L2 output's finalization period ends in %d seconds", int64(at.Sub(now).Seconds())))
+		}
+	} else if e.GameStatus == GameStatusInProgress && e.GameContested {
+		reasons = append(reasons, fmt.Sprintf("dispute game %s has not resolved, and its root claim was countered so it may still resolve in favor of the challenger", e.Game))
+	} else if e.GameStatus == GameStatusInProgress {
+		reasons = append(reasons, fmt.Sprintf("dispute game %s has not resolved", e.Game))
+	} else if at := time.Unix(int64(e.GameFinalAt), 0); at.After(now) {
+		reasons = append(reasons, fmt.Sprintf("the dispute game finality delay ends in %d seconds", int64(at.Sub(now).Seconds())))
+	}
+	return &NotFinalizableError{
+		Reasons:       reasons,
+		FinalizableAt: time.Unix(int64(e.FinalizableAt), 0),
+		Remaining:     remaining,
+	}
+}
+
+// ProofFinalizedOnL1 reports whether the L1 block a withdrawal was proven in, identified by its proven timestamp,
+// has been finalized. The maturity countdown starts at that block's timestamp, but until the block is finalized an
+// L1 reorg can drop the proof and restart the countdown from a later block.
+func ProofFinalizedOnL1(ctx context.Context, l1 *ethclient.Client, provenAt uint64) (bool, error) {
+	finalized, err := l1.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+	if err != nil {
+		return false, fmt.Errorf("error querying finalized L1 block: %w", err)
+	}
+	return finalized.Time >= provenAt, nil
+}
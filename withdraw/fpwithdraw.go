@@ -2,72 +2,210 @@ package withdraw
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"time"
 
 	"github.com/ethereum-optimism/optimism/op-node/bindings"
 	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
-	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
 type FPWithdrawer struct {
-	Ctx           context.Context
-	L1Client      *ethclient.Client
-	L2Client      *rpc.Client
-	L2TxHash      common.Hash
-	Portal        *bindingspreview.OptimismPortal2
-	Factory       *bindings.DisputeGameFactory
-	Opts          *bind.TransactOpts
-	GasMultiplier float64 // Multiplier for estimated gas (default 1.0)
-	UserGasLimit  uint64  // Original user-specified gas limit (0 means auto-estimate)
-	DryRun        bool    // Simulate transactions without submitting
+	Ctx             context.Context
+	L1Client        *ethclient.Client
+	L2Client        *rpc.Client
+	L2TxHash        common.Hash
+	Portal          *bindingspreview.OptimismPortal2
+	Factory         *bindings.DisputeGameFactory
+	FactoryShards   []*bindings.DisputeGameFactoryCaller // Factory bound to other L1 providers, to spread large game searches across (optional)
+	Backend         bind.ContractBackend
+	Opts            *bind.TransactOpts
+	GasMultiplier   float64        // Multiplier for estimated gas (default 1.0)
+	UserGasLimit    uint64         // Original user-specified gas limit (0 means auto-estimate)
+	DryRun          bool           // Simulate transactions without submitting
+	ProveTimeout    time.Duration  // Max time to wait for the prove tx to confirm (0 means default of 5 minutes)
+	FinalizeTimeout time.Duration  // Max time to wait for the finalize tx to confirm (0 means default of 5 minutes)
+	Confirmations   uint64         // Number of L1 confirmations to wait for (0 or 1 means inclusion is enough)
+	WaitFinalized   bool           // Wait for the L1 block containing the tx to be finalized
+	Proof           *ProofMetadata // Output root used by the last ProveWithdrawal call
+	Faults          Faults         // Failures to inject, for rehearsals in test environments only
+	Timing          *Timing        // Clock and poll intervals of the waits (nil means the system clock and default intervals)
+	VerifyL2Client  *rpc.Client    // Second L2 provider to cross-check withdrawal and proof data against (optional)
+	Costs           []TxCost       // Gas spent by the transactions confirmed so far
+	ETHUSD          float64        // ETH price in USD for cost estimates (0 means unknown)
+	PortalAddress   common.Address // OptimismPortal address, which direct withdrawal calls are simulated from
+	BaseFeeMax      *big.Int       // Defer submissions while the L1 base fee is above this (nil means never defer)
+	MinBalance      *big.Int       // Pause submissions while the signer's L1 balance is below this (nil means never pause)
+	Quorum          *Quorum        // Additional L1 providers that must agree on critical state (nil means trust L1Client alone)
+	GasToken        *GasToken      // Native token of the chain, which withdrawal values are paid out in (nil means ETH)
+	Notifier        Notifier       // Told about proofs, finalizations and errors (nil means no notifications)
+	Prover          Prover         // Service to delegate the prove transaction to (nil means prove locally)
+	ProofSubmitter  common.Address // Address whose proof is checked and finalized (zero means the signer's own)
+	GameType        *uint32        // Dispute game type to prove against (nil means the portal's respected game type)
+	GameIndex       *big.Int       // Index of the dispute game to prove against (nil means the earliest usable one)
+	Supervisor      *rpc.Client    // op-supervisor to fetch super roots from, for portals proving against them (optional)
+	ProofSources    []ProofSource  // Tried in order for the withdrawal proof before the L2 RPC, e.g. light clients (optional)
+	LogIndex        *uint          // Log index of the MessagePassed event to prove and finalize, for L2 txs initiating several withdrawals (nil means the first)
+	ImportedProof   *ExportedProof // Previously exported proof to prove with, once checked against the chain (nil means build the proof)
+
+	cache          withdrawalCache    // Receipt and decoded event of the withdrawal, fetched once
+	foundSubmitter common.Address     // Address whose proof was found to be used, once there is one
+	dryRunTx       *types.Transaction // Transaction the last dry run built instead of sending it
 }
 
 func (w *FPWithdrawer) CheckIfProvable() error {
-	l2WithdrawalBlock, err := txBlock(w.Ctx, w.L2Client, w.L2TxHash)
+	_, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
 		return fmt.Errorf("error querying withdrawal tx block: %w", err)
 	}
+	l2WithdrawalBlock := details.L2BlockNumber
 
-	latestGame, err := withdrawals.FindLatestGame(w.Ctx, &w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller)
+	if w.VerifyL2Client != nil {
+		if err := crossCheckWithdrawal(w.Ctx, details, w.VerifyL2Client, w.L2TxHash, w.LogIndex); err != nil {
+			return err
+		}
+	}
+
+	target, needed := l2WithdrawalBlock, fmt.Sprintf("L2 block %d", l2WithdrawalBlock.Uint64())
+	superRoots := SuperRootsActive(w.L1Client, w.PortalAddress)
+	if superRoots {
+		// super root games propose a timestamp rather than an L2 block number
+		timestamp, _, err := withdrawalTimestamp(w.Ctx, ethclient.NewClient(w.L2Client), l2WithdrawalBlock)
+		if err != nil {
+			return err
+		}
+		target, needed = new(big.Int).SetUint64(timestamp), fmt.Sprintf("timestamp %d of L2 block %d", timestamp, l2WithdrawalBlock.Uint64())
+	}
+
+	// a pinned game must cover the withdrawal itself, which findGame checks along with everything the portal requires
+	if w.GameIndex != nil {
+		_, err := w.findGame(target)
+		return err
+	}
+
+	// check coverage by the game type the withdrawal will be proven against
+	var gameType uint32
+	if w.GameType != nil {
+		if err := ValidateGameType(&w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, *w.GameType); err != nil {
+			return err
+		}
+		gameType = *w.GameType
+	} else {
+		if gameType, err = w.Portal.RespectedGameType(&bind.CallOpts{}); err != nil {
+			return fmt.Errorf("failed to get respected game type: %w", err)
+		}
+	}
+	latestGame, err := FindLatestGameOfType(&w.Factory.DisputeGameFactoryCaller, gameType)
 	if err != nil {
 		return fmt.Errorf("failed to find latest game: %w", err)
 	}
-	l2BlockNumber := new(big.Int).SetBytes(latestGame.ExtraData[0:32])
+	proposed := gameL2BlockNumber(*latestGame)
+	if proposed.Cmp(target) >= 0 {
+		return nil
+	}
 
-	if l2BlockNumber.Uint64() < l2WithdrawalBlock.Uint64() {
-		return fmt.Errorf("the latest L2 block proposed in the DisputeGameFactory is %d and is not past L2 block %d that includes the withdrawal - the withdrawal cannot be proven yet",
-			l2BlockNumber.Uint64(), l2WithdrawalBlock.Uint64())
+	what := "L2 block"
+	if superRoots {
+		what = "super root"
 	}
-	return nil
+	err = fmt.Errorf("the latest %s proposed by games of type %d in the DisputeGameFactory is at %d and is not past %s that includes the withdrawal - the withdrawal cannot be proven yet",
+		what, gameType, proposed.Uint64(), needed)
+	if superRoots {
+		return err
+	}
+	e, estimateErr := EstimateGameCoverageOfType(&w.Factory.DisputeGameFactoryCaller, gameType, l2WithdrawalBlock)
+	if estimateErr != nil {
+		log.Debug("Unable to estimate when a covering game will be created", "error", estimateErr)
+		return err
+	}
+	return fmt.Errorf("%w - a covering game is expected around %s (in about %s, games are created every %s on average)",
+		err, e.EstimatedAt.UTC().Format(time.RFC3339), e.Remaining(w.Timing.Now()).Round(time.Minute), e.Interval.Round(time.Second))
 }
 
-func (w *FPWithdrawer) getWithdrawalHash() (common.Hash, error) {
-	l2 := ethclient.NewClient(w.L2Client)
-	receipt, err := l2.TransactionReceipt(w.Ctx, w.L2TxHash)
+// submitter returns the address whose proof of the withdrawal is used, as proofs are stored per submitter: the
+// ProofSubmitter if set, or else the signer, unless only other addresses, such as relayers, have proven it.
+func (w *FPWithdrawer) submitter() common.Address {
+	if w.ProofSubmitter != (common.Address{}) {
+		return w.ProofSubmitter
+	}
+	if w.foundSubmitter != (common.Address{}) {
+		return w.foundSubmitter
+	}
+	found, err := w.findSubmitter()
 	if err != nil {
-		return common.HexToHash(""), err
+		log.Debug("Unable to look up the proofs of the withdrawal", "error", err)
+		return w.Opts.From
+	}
+	if found == (common.Address{}) {
+		return w.Opts.From
 	}
+	w.foundSubmitter = found
+	return found
+}
 
-	ev, err := withdrawals.ParseMessagePassed(receipt)
+// findSubmitter returns the signer if it proved the withdrawal, or else, of the other addresses that proved it, the one
+// whose proof can still be finalized and matures first, so it isn't proven again. It returns the zero address if
+// there is no such proof.
+func (w *FPWithdrawer) findSubmitter() (common.Address, error) {
+	hash, err := w.getWithdrawalHash()
 	if err != nil {
-		return common.HexToHash(""), err
+		return common.Address{}, err
+	}
+	portal := &w.Portal.OptimismPortal2Caller
+	own, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash, w.Opts.From)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("error querying proven withdrawal: %w", err)
+	}
+	if own.Timestamp != 0 {
+		return w.Opts.From, nil
 	}
 
-	hash, err := withdrawals.WithdrawalHash(ev)
+	numSubmitters, err := portal.NumProofSubmitters(&bind.CallOpts{}, hash)
 	if err != nil {
-		return common.HexToHash(""), err
+		return common.Address{}, fmt.Errorf("error querying proof submitters: %w", err)
+	}
+	var best common.Address
+	var bestProvenAt uint64
+	for i := int64(0); i < numSubmitters.Int64(); i++ {
+		submitter, err := portal.ProofSubmitters(&bind.CallOpts{}, hash, big.NewInt(i))
+		if err != nil {
+			return common.Address{}, fmt.Errorf("error querying proof submitter %d: %w", i, err)
+		}
+		proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash, submitter)
+		if err != nil {
+			return common.Address{}, fmt.Errorf("error querying proven withdrawal: %w", err)
+		}
+		if proven.Timestamp == 0 {
+			continue
+		}
+		if err := CheckProofValidity(portal, w.L1Client, hash, submitter); err != nil {
+			log.Info("Ignoring proof of another address that can no longer be finalized", "proofSubmitter", submitter, "reason", err)
+			continue
+		}
+		if best == (common.Address{}) || proven.Timestamp < bestProvenAt {
+			best, bestProvenAt = submitter, proven.Timestamp
+		}
 	}
+	if best != (common.Address{}) {
+		log.Info("Withdrawal was proven by another address, using its proof instead of proving again", "proofSubmitter", best,
+			"provenAt", time.Unix(int64(bestProvenAt), 0).UTC())
+	}
+	return best, nil
+}
 
-	return hash, nil
+func (w *FPWithdrawer) getWithdrawalHash() (common.Hash, error) {
+	_, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return details.Hash, nil
 }
 
 func (w *FPWithdrawer) GetProvenWithdrawalTime() (uint64, error) {
@@ -77,46 +215,132 @@ func (w *FPWithdrawer) GetProvenWithdrawalTime() (uint64, error) {
 	}
 
 	// the proven withdrawal structure now contains an additional mapping, as withdrawal proofs are now stored per submitter address
-	provenWithdrawal, err := w.Portal.ProvenWithdrawals(&bind.CallOpts{}, hash, w.Opts.From)
+	return quorumRead(w.Quorum, "proven timestamp", w.L1Client, func(caller bind.ContractCaller) (uint64, error) {
+		portal, err := bindingspreview.NewOptimismPortal2Caller(w.PortalAddress, caller)
+		if err != nil {
+			return 0, err
+		}
+		provenWithdrawal, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash, w.submitter())
+		if err != nil {
+			return 0, err
+		}
+		return provenWithdrawal.Timestamp, nil
+	})
+}
+
+// FinalizationEstimate returns when the withdrawal proven by the submitter can be finalized, and whether the proof
+// is final on L1.
+func (w *FPWithdrawer) FinalizationEstimate() (*FinalizationEstimate, error) {
+	hash, err := w.getWithdrawalHash()
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	e, err := EstimateFinalization(&w.Portal.OptimismPortal2Caller, w.L1Client, hash, w.submitter())
+	if err != nil {
+		return nil, err
 	}
+	// some L1s, such as local devnets, don't track finality, which only makes it unknown
+	e.ProofFinalized, err = ProofFinalizedOnL1(w.Ctx, w.L1Client, e.ProvenAt)
+	if err != nil {
+		log.Debug("Unable to tell whether the proof is finalized on L1", "error", err)
+	}
+	return e, nil
+}
+
+// Delays returns the portal's proof maturity and dispute game finality delays.
+func (w *FPWithdrawer) Delays() (*Delays, error) {
+	return ReadDelays(&w.Portal.OptimismPortal2Caller)
+}
 
-	return provenWithdrawal.Timestamp, nil
+// CheckProofValidity returns a *ProofInvalidatedError if the submitter's proof can no longer be finalized, because
+// the portal's respected game type changed since.
+func (w *FPWithdrawer) CheckProofValidity() error {
+	hash, err := w.getWithdrawalHash()
+	if err != nil {
+		return err
+	}
+	return CheckProofValidity(&w.Portal.OptimismPortal2Caller, w.L1Client, hash, w.submitter())
 }
 
 func (w *FPWithdrawer) ProveWithdrawal() error {
-	l2 := ethclient.NewClient(w.L2Client)
-	l2g := gethclient.New(w.L2Client)
+	// flag a finalization that would not deliver the withdrawal before spending gas on proving it
+	if _, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex); err == nil {
+		warnIfFinalizeReverts(w.Ctx, w.L1Client, w.PortalAddress, details, w.GasToken)
+	}
+	err := w.proveWithdrawal()
+	if err == nil && !w.DryRun {
+		// the new proof is used from now on, rather than another address's found before
+		w.foundSubmitter = common.Address{}
+	}
+	notifyStep(w.Ctx, w.Notifier, w.DryRun, w.notification(), err, Notifier.OnProven)
+	return err
+}
 
-	params, err := withdrawals.ProveWithdrawalParametersFaultProofs(w.Ctx, l2g, l2, l2, w.L2TxHash, &w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller)
+// findGame returns the dispute game to prove a withdrawal in l2BlockNumber (or, for super root games, at that
+// timestamp) against: the game at GameIndex if set, otherwise the earliest usable game covering it, as it will
+// resolve soonest.
+func (w *FPWithdrawer) findGame(l2BlockNumber *big.Int) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	if w.GameIndex != nil {
+		game, err := GameAtIndex(&w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, w.L1Client, w.GameIndex, l2BlockNumber)
+		if err != nil {
+			return nil, err
+		}
+		if w.GameType != nil && gameType(*game) != *w.GameType {
+			return nil, fmt.Errorf("game %s is of type %d, not the --game-type %d", w.GameIndex, gameType(*game), *w.GameType)
+		}
+		return game, nil
+	}
+
+	var game *bindings.IDisputeGameFactoryGameSearchResult
+	var err error
+	if w.GameType != nil {
+		if err := ValidateGameType(&w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, *w.GameType); err != nil {
+			return nil, err
+		}
+		game, err = FindEarliestGameOfType(w.Ctx, &w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, *w.GameType, l2BlockNumber, w.FactoryShards...)
+	} else {
+		game, err = FindEarliestGame(w.Ctx, &w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, l2BlockNumber, w.FactoryShards...)
+	}
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to find dispute game: %w", err)
+	}
+	// a blacklisted or disproven game would strand the proof and a contested one would delay it, so prove against the
+	// next usable one instead
+	game, err = NextUsableGame(&w.Factory.DisputeGameFactoryCaller, &w.Portal.OptimismPortal2Caller, w.L1Client, game, l2BlockNumber)
+	if err != nil || w.GameType == nil {
+		return game, err
+	}
+	reason, err := gameTypeRejection(&w.Portal.OptimismPortal2Caller, w.L1Client, gameProxy(*game), *w.GameType)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, fmt.Errorf("the portal would reject proofs against game %s: %s", game.Index, reason)
 	}
+	return game, nil
+}
 
-	withdrawalTx := bindingspreview.TypesWithdrawalTransaction{
-		Nonce:    params.Nonce,
-		Sender:   params.Sender,
-		Target:   params.Target,
-		Value:    params.Value,
-		GasLimit: params.GasLimit,
-		Data:     params.Data,
+func (w *FPWithdrawer) proveWithdrawal() error {
+	if w.Prover != nil {
+		return w.proveDelegated()
 	}
-	outputRootProof := bindingspreview.TypesOutputRootProof{
-		Version:                  params.OutputRootProof.Version,
-		StateRoot:                params.OutputRootProof.StateRoot,
-		MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
-		LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+	if SuperRootsActive(w.L1Client, w.PortalAddress) {
+		return w.proveWithdrawalSuperRoot()
+	}
+
+	proof, err := w.buildProof()
+	if err != nil {
+		return err
 	}
 
 	// Prepare gas options with multiplier if configured
 	simulatedTx, err := prepareGasOpts(w.Opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, func(opts *bind.TransactOpts) (*types.Transaction, error) {
 		return w.Portal.ProveWithdrawalTransaction(
 			opts,
-			withdrawalTx,
-			params.L2OutputIndex,
-			outputRootProof,
-			params.WithdrawalProof,
+			proof.WithdrawalTx,
+			proof.GameIndex,
+			proof.OutputRootProof,
+			proof.WithdrawalProof,
 		)
 	})
 	if err != nil {
@@ -124,65 +348,183 @@ func (w *FPWithdrawer) ProveWithdrawal() error {
 	}
 
 	if w.DryRun {
-		printDryRun("ProveWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit)
-		return nil
+		printDryRun("ProveWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit, w.ETHUSD)
+		w.dryRunTx, err = dryRunTx(w.Ctx, w.L1Client, simulatedTx, w.Opts.GasLimit)
+		return err
 	}
 
 	// create the proof
-	tx, err := w.Portal.ProveWithdrawalTransaction(
-		w.Opts,
-		withdrawalTx,
-		params.L2OutputIndex, // this is overloaded and is the DisputeGame index in this context
-		outputRootProof,
-		params.WithdrawalProof,
-	)
+	cost, err := w.portalTxs().sendPortalTx(w.Ctx, ActionProve, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return w.Portal.ProveWithdrawalTransaction(
+			opts,
+			proof.WithdrawalTx,
+			proof.GameIndex, // the L2 output index argument is overloaded as the dispute game index
+			proof.OutputRootProof,
+			proof.WithdrawalProof,
+		)
+	})
 	if err != nil {
 		return err
 	}
+	w.Costs = append(w.Costs, cost)
+	return nil
+}
 
-	log.Info("Proved withdrawal", "l2TxHash", w.L2TxHash, "l1TxHash", tx.Hash())
-
-	// Wait 5 mins max for confirmation
-	ctxWithTimeout, cancel := context.WithTimeout(w.Ctx, 5*time.Minute)
-	defer cancel()
-	return waitForConfirmation(ctxWithTimeout, w.L1Client, tx.Hash())
+// fpProof is the arguments of proveWithdrawalTransaction for the withdrawal against a dispute game.
+type fpProof struct {
+	WithdrawalTx    bindingspreview.TypesWithdrawalTransaction
+	GameIndex       *big.Int
+	OutputRootProof bindingspreview.TypesOutputRootProof
+	WithdrawalProof [][]byte
 }
 
-func (w *FPWithdrawer) IsProofFinalized() (bool, error) {
-	hash, err := w.getWithdrawalHash()
+// buildProof finds the dispute game to prove the withdrawal against, fetches the withdrawal's proof at the game's L2
+// block and checks it against the game's root claim, unless an exported proof is imported. The game is recorded as
+// the Proof.
+func (w *FPWithdrawer) buildProof() (*fpProof, error) {
+	if w.ImportedProof != nil {
+		return w.importProof()
+	}
+	receipt, _, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+
+	game, err := w.findGame(receipt.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	w.Proof = &ProofMetadata{
+		GameIndex:   game.Index,
+		GameAddress: gameProxy(*game),
+		GameType:    gameType(*game),
+		L2Block:     gameL2BlockNumber(*game),
+		OutputRoot:  game.RootClaim,
 	}
-	return w.Portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	log.Info("Proving against dispute game", w.Proof.logFields()...)
+
+	rootClaim := common.Hash(game.RootClaim)
+	if w.Quorum != nil {
+		rootClaim, err = quorumRead(w.Quorum, "game root claim", w.L1Client, func(caller bind.ContractCaller) (common.Hash, error) {
+			return NewDisputeGame(w.Proof.GameAddress, caller).RootClaim()
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, params, err := fetchProofParameters(w.Ctx, w.ProofSources, w.L2Client, receipt, gameL2BlockNumber(*game), game.Index,
+		outputRootVerifier(w.Ctx, rootClaim, w.VerifyL2Client, w.L2TxHash, w.LogIndex, game.Index))
+	if err != nil {
+		return nil, err
+	}
+
+	return &fpProof{
+		WithdrawalTx: bindingspreview.TypesWithdrawalTransaction{
+			Nonce:    params.Nonce,
+			Sender:   params.Sender,
+			Target:   params.Target,
+			Value:    params.Value,
+			GasLimit: params.GasLimit,
+			Data:     params.Data,
+		},
+		GameIndex: params.L2OutputIndex,
+		OutputRootProof: bindingspreview.TypesOutputRootProof{
+			Version:                  params.OutputRootProof.Version,
+			StateRoot:                params.OutputRootProof.StateRoot,
+			MessagePasserStorageRoot: params.OutputRootProof.MessagePasserStorageRoot,
+			LatestBlockhash:          params.OutputRootProof.LatestBlockhash,
+		},
+		WithdrawalProof: params.WithdrawalProof,
+	}, nil
 }
 
-func (w *FPWithdrawer) FinalizeWithdrawal() error {
-	// get the withdrawal hash
+// proveDelegated has the prover service prove the withdrawal, then checks that the proof is recorded on-chain for
+// the withdrawal and the expected submitter.
+func (w *FPWithdrawer) proveDelegated() error {
 	hash, err := w.getWithdrawalHash()
 	if err != nil {
 		return err
 	}
+	if w.DryRun {
+		log.Info("Dry run: the prove transaction would be delegated to the prover service", "withdrawalHash", hash, "proofSubmitter", w.submitter())
+		return nil
+	}
 
-	// check if the withdrawal can be finalized using the calculated withdrawal hash
-	err = w.Portal.CheckWithdrawal(&bind.CallOpts{}, hash, w.Opts.From)
+	_, submitter, err := delegateProof(w.Ctx, w.Prover, w.L1Client, w.L2Client, w.L2TxHash, hash, w.PortalAddress, w.ProveTimeout, w.Confirmations, w.WaitFinalized, w.Faults, w.Timing)
 	if err != nil {
 		return err
 	}
+	if submitter == (common.Address{}) {
+		return fmt.Errorf("%w: prove tx emitted no WithdrawalProvenExtension1 event naming the proof submitter", ErrProofMismatch)
+	}
+	if submitter != w.submitter() {
+		return fmt.Errorf("%w: proof was submitted by %s, expected %s", ErrProofMismatch, submitter, w.submitter())
+	}
+	proven, err := w.Portal.ProvenWithdrawals(&bind.CallOpts{}, hash, submitter)
+	if err != nil {
+		return fmt.Errorf("error querying delegated proof: %w", err)
+	}
+	if proven.Timestamp == 0 {
+		return fmt.Errorf("%w: portal has no proof of withdrawal %s by %s", ErrProofMismatch, hash, submitter)
+	}
+	log.Info("Verified delegated proof", "withdrawalHash", hash, "proofSubmitter", submitter, "gameAddress", proven.DisputeGameProxy, "provenAt", proven.Timestamp)
+	return nil
+}
+
+func (w *FPWithdrawer) IsProofFinalized() (bool, error) {
+	hash, err := w.getWithdrawalHash()
+	if err != nil {
+		return false, err
+	}
+	return quorumRead(w.Quorum, "finalized status", w.L1Client, func(caller bind.ContractCaller) (bool, error) {
+		portal, err := bindingspreview.NewOptimismPortal2Caller(w.PortalAddress, caller)
+		if err != nil {
+			return false, err
+		}
+		return portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	})
+}
 
-	// get the WithdrawalTransaction info needed to finalize the withdrawal
-	l2 := ethclient.NewClient(w.L2Client)
+func (w *FPWithdrawer) FinalizeWithdrawal() error {
+	err := w.finalizeWithdrawal()
+	notifyStep(w.Ctx, w.Notifier, w.DryRun, w.notification(), err, Notifier.OnFinalized)
+	return err
+}
 
-	// Transaction receipt
-	receipt, err := l2.TransactionReceipt(w.Ctx, w.L2TxHash)
+func (w *FPWithdrawer) finalizeWithdrawal() error {
+	// the withdrawal hash and the WithdrawalTransaction info needed to finalize come from the same cached event
+	_, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
 		return err
 	}
-	// Parse the receipt
-	ev, err := withdrawals.ParseMessagePassed(receipt)
+	hash, ev := details.Hash, details.Event
+
+	if err := w.Faults.injectFault(FaultGameBlacklisted, "OptimismPortal: dispute game has been blacklisted"); err != nil {
+		return err
+	}
+
+	// check if the withdrawal can be finalized using the calculated withdrawal hash
+	err = w.Portal.CheckWithdrawal(&bind.CallOpts{}, hash, w.submitter())
 	if err != nil {
+		// proofs invalidated by a game type change can never be finalized, which the revert doesn't make clear
+		var invalidated *ProofInvalidatedError
+		if validityErr := CheckProofValidity(&w.Portal.OptimismPortal2Caller, w.L1Client, hash, w.submitter()); errors.As(validityErr, &invalidated) {
+			return validityErr
+		}
+		// explain waits that haven't elapsed yet instead of surfacing the revert
+		if e, estimateErr := EstimateFinalization(&w.Portal.OptimismPortal2Caller, w.L1Client, hash, w.submitter()); estimateErr == nil {
+			if notFinalizable := e.notFinalizableError(w.Timing.Now()); notFinalizable != nil {
+				return notFinalizable
+			}
+		}
 		return err
 	}
 
+	notifyFinalizable(w.Ctx, w.Notifier, w.notification())
+	warnIfFinalizeReverts(w.Ctx, w.L1Client, w.PortalAddress, details, w.GasToken)
+
 	withdrawalTx := bindingspreview.TypesWithdrawalTransaction{
 		Nonce:    ev.Nonce,
 		Sender:   ev.Sender,
@@ -194,27 +536,59 @@ func (w *FPWithdrawer) FinalizeWithdrawal() error {
 
 	// Prepare gas options with multiplier if configured
 	simulatedTx, err := prepareGasOpts(w.Opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, func(opts *bind.TransactOpts) (*types.Transaction, error) {
-		return w.Portal.FinalizeWithdrawalTransaction(opts, withdrawalTx)
+		return w.finalizeWithdrawalTransaction(opts, withdrawalTx)
 	})
 	if err != nil {
 		return err
 	}
 
 	if w.DryRun {
-		printDryRun("FinalizeWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit)
-		return nil
+		printDryRun("FinalizeWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit, w.ETHUSD)
+		w.dryRunTx, err = dryRunTx(w.Ctx, w.L1Client, simulatedTx, w.Opts.GasLimit)
+		return err
 	}
 
 	// finalize the withdrawal
-	tx, err := w.Portal.FinalizeWithdrawalTransaction(w.Opts, withdrawalTx)
+	cost, err := w.portalTxs().sendPortalTx(w.Ctx, ActionFinalize, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return w.finalizeWithdrawalTransaction(opts, withdrawalTx)
+	})
 	if err != nil {
 		return err
 	}
+	w.Costs = append(w.Costs, cost)
+	return nil
+}
+
+// finalizeWithdrawalTransaction finalizes the withdrawal using the submitter's proof, which may be another address's.
+func (w *FPWithdrawer) finalizeWithdrawalTransaction(opts *bind.TransactOpts, withdrawalTx bindingspreview.TypesWithdrawalTransaction) (*types.Transaction, error) {
+	if w.submitter() != opts.From {
+		return w.Portal.FinalizeWithdrawalTransactionExternalProof(opts, withdrawalTx, w.submitter())
+	}
+	return w.Portal.FinalizeWithdrawalTransaction(opts, withdrawalTx)
+}
+
+// notification describes the withdrawal for notifications.
+func (w *FPWithdrawer) notification() Notification {
+	hash, _ := w.getWithdrawalHash()
+	return newNotification(w.L2TxHash, hash, w.Costs)
+}
+
+// TxCosts returns the gas spent by the transactions confirmed so far.
+func (w *FPWithdrawer) TxCosts() []TxCost {
+	return w.Costs
+}
+
+// DryRunCall returns the portal call the last dry run built, or nil if none did.
+func (w *FPWithdrawer) DryRunCall() *Calldata {
+	return NewCalldata(w.dryRunTx)
+}
 
-	log.Info("Completed withdrawal", "l2TxHash", w.L2TxHash, "l1TxHash", tx.Hash())
+// DryRunTx returns the unsigned transaction the last dry run built, as it would have been sent, or nil if none did.
+func (w *FPWithdrawer) DryRunTx() *types.Transaction {
+	return w.dryRunTx
+}
 
-	// Wait 5 mins max for confirmation
-	ctxWithTimeout, cancel := context.WithTimeout(w.Ctx, 5*time.Minute)
-	defer cancel()
-	return waitForConfirmation(ctxWithTimeout, w.L1Client, tx.Hash())
+// ProvenAgainst returns the output root used by the last ProveWithdrawal call, or nil if none was made.
+func (w *FPWithdrawer) ProvenAgainst() *ProofMetadata {
+	return w.Proof
 }
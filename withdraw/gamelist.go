@@ -0,0 +1,81 @@
+package withdraw
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GameInfo describes a dispute game created by the DisputeGameFactory, for listing.
+type GameInfo struct {
+	Index      *big.Int       `json:"index"`
+	Address    common.Address `json:"address"`
+	GameType   uint32         `json:"gameType"`
+	L2Block    *big.Int       `json:"l2Block"` // L2 block (or, for super root games, timestamp) the root claim is for
+	RootClaim  common.Hash    `json:"rootClaim"`
+	CreatedAt  uint64         `json:"createdAt"`
+	Status     string         `json:"status"`
+	ResolvedAt uint64         `json:"resolvedAt,omitempty"`
+	Retired    bool           `json:"retired,omitempty"`   // Created before the respected game type was last set
+	Rejection  string         `json:"rejection,omitempty"` // Why the portal would reject proofs against the game
+}
+
+// ListGames returns up to limit games of the portal's respected game type, newest first, starting at factory index
+// before (nil means the latest game), with their status and whether the portal would accept proofs against them.
+func ListGames(factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, before *big.Int, limit int) ([]GameInfo, error) {
+	gameType, err := portal.RespectedGameType(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type: %w", err)
+	}
+	retiredBefore, err := portal.RespectedGameTypeUpdatedAt(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type update time: %w", err)
+	}
+	if before == nil {
+		gameCount, err := factory.GameCount(&bind.CallOpts{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get game count: %w", err)
+		}
+		if gameCount.Sign() == 0 {
+			return nil, nil
+		}
+		before = new(big.Int).Sub(gameCount, common.Big1)
+	}
+	games, err := factory.FindLatestGames(&bind.CallOpts{}, gameType, before, big.NewInt(int64(limit)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest games: %w", err)
+	}
+
+	infos := make([]GameInfo, 0, len(games))
+	for _, game := range games {
+		proxy := gameProxy(game)
+		status, err := NewDisputeGame(proxy, caller).Status()
+		if err != nil {
+			return nil, err
+		}
+		info := GameInfo{
+			Index:     game.Index,
+			Address:   proxy,
+			GameType:  gameType,
+			L2Block:   gameL2BlockNumber(game),
+			RootClaim: game.RootClaim,
+			CreatedAt: game.Timestamp,
+			Status:    status.String(),
+			Retired:   game.Timestamp < retiredBefore,
+		}
+		if status != GameStatusInProgress {
+			if info.ResolvedAt, err = NewDisputeGame(proxy, caller).ResolvedAt(); err != nil {
+				return nil, err
+			}
+		}
+		if info.Rejection, err = gameRejection(portal, caller, proxy); err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
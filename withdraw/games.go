@@ -0,0 +1,595 @@
+package withdraw
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// gameSearchPageSize is the number of games requested per FindLatestGames call during linear scans.
+	gameSearchPageSize = 100
+	// maxLinearGameScan bounds the number of games inspected when falling back to a linear scan.
+	maxLinearGameScan = 2000
+)
+
+// gameL2BlockNumber returns the L2 block number a game proposes an output root for.
+func gameL2BlockNumber(game bindings.IDisputeGameFactoryGameSearchResult) *big.Int {
+	return new(big.Int).SetBytes(game.ExtraData[0:32])
+}
+
+// gameType returns the game type packed into the game's GameId metadata.
+func gameType(game bindings.IDisputeGameFactoryGameSearchResult) uint32 {
+	return binary.BigEndian.Uint32(game.Metadata[0:4])
+}
+
+// gameProxy returns the game's proxy address packed into its GameId metadata.
+func gameProxy(game bindings.IDisputeGameFactoryGameSearchResult) common.Address {
+	return common.BytesToAddress(game.Metadata[12:32])
+}
+
+// coversBlock reports whether the game proposes an output at or past l2BlockNumber and was created at or after
+// retiredBefore. The portal rejects games created before its respectedGameTypeUpdatedAt timestamp, so proving
+// against them strands the withdrawal.
+func coversBlock(game bindings.IDisputeGameFactoryGameSearchResult, l2BlockNumber *big.Int, retiredBefore uint64) bool {
+	return gameL2BlockNumber(game).Cmp(l2BlockNumber) >= 0 && game.Timestamp >= retiredBefore
+}
+
+// latestGameAtOrBefore returns the latest game of the given type with an index at or before index, or nil if there is none.
+func latestGameAtOrBefore(factory *bindings.DisputeGameFactoryCaller, gameType uint32, index *big.Int) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	games, err := factory.FindLatestGames(&bind.CallOpts{}, gameType, index, common.Big1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest games: %w", err)
+	}
+	if len(games) == 0 {
+		return nil, nil
+	}
+	return &games[0], nil
+}
+
+// FindLatestGameOfType returns the latest game of the given type, which proposes the furthest output of its type.
+func FindLatestGameOfType(factory *bindings.DisputeGameFactoryCaller, gameType uint32) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	gameCount, err := factory.GameCount(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+	if gameCount.Sign() == 0 {
+		return nil, errors.New("no games")
+	}
+	game, err := latestGameAtOrBefore(factory, gameType, new(big.Int).Sub(gameCount, common.Big1))
+	if err != nil {
+		return nil, err
+	}
+	if game == nil {
+		return nil, fmt.Errorf("no games of type %d", gameType)
+	}
+	return game, nil
+}
+
+// FindEarliestGame finds the earliest game of the portal's respected game type whose proposed L2 block is at or
+// past l2BlockNumber. Proving against the earliest covering game means the game resolves sooner than the latest one.
+// Games created before the portal's respectedGameTypeUpdatedAt timestamp are retired and skipped.
+//
+// The binary search assumes game L2 block numbers never decrease by index. That is not guaranteed across game types
+// and retirements, so a game it finds is validated and, if the assumption doesn't hold, a bounded linear scan is used
+// instead, falling back to the latest game as a last resort. When it finds none, the latest game is checked instead,
+// which doesn't cover the withdrawal either unless the assumption doesn't hold. Games are not checked for challenges or the blacklist,
+// use NextUsableGame for that. Linear scans are spread across the factory and shards, the same factory bound to other
+// L1 providers, if any are given.
+func FindEarliestGame(ctx context.Context, factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, l2BlockNumber *big.Int, shards ...*bindings.DisputeGameFactoryCaller) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	gameType, err := portal.RespectedGameType(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type: %w", err)
+	}
+	return FindEarliestGameOfType(ctx, factory, portal, gameType, l2BlockNumber, shards...)
+}
+
+// FindEarliestGameOfType is FindEarliestGame for the given game type instead of the portal's respected one. Use
+// ValidateGameType first, and check the game found is of a type the portal accepts.
+func FindEarliestGameOfType(ctx context.Context, factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, gameType uint32, l2BlockNumber *big.Int, shards ...*bindings.DisputeGameFactoryCaller) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	retiredBefore, err := portal.RespectedGameTypeUpdatedAt(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type update time: %w", err)
+	}
+
+	gameCount, err := factory.GameCount(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+	if gameCount.Sign() == 0 {
+		return nil, errors.New("no games")
+	}
+
+	game, err := binarySearchGame(factory, gameType, gameCount, l2BlockNumber, retiredBefore)
+	if err != nil {
+		return nil, err
+	}
+	if game == nil {
+		// no covering game is the usual case of a withdrawal that isn't provable yet, which the latest game confirms
+		return latestCoveringGame(factory, gameType, gameCount, l2BlockNumber, retiredBefore)
+	}
+	ok, err := validateEarliestGame(factory, gameType, game, l2BlockNumber, retiredBefore)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return game, nil
+	}
+
+	log.Warn("Dispute game L2 block numbers are not monotonic, falling back to a linear scan", "l2BlockNumber", l2BlockNumber)
+	if len(shards) > 0 {
+		game, err = shardedScanGame(ctx, append([]*bindings.DisputeGameFactoryCaller{factory}, shards...), gameType, gameCount, l2BlockNumber, retiredBefore)
+	} else {
+		game, err = linearScanGame(factory, gameType, gameCount, l2BlockNumber, retiredBefore)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if game != nil {
+		return game, nil
+	}
+	log.Warn("Linear scan found no covering game, falling back to the latest game")
+	return latestCoveringGame(factory, gameType, gameCount, l2BlockNumber, retiredBefore)
+}
+
+// latestCoveringGame returns the latest game of the type if it covers l2BlockNumber, or an error saying why the
+// withdrawal can't be proven against it yet.
+func latestCoveringGame(factory *bindings.DisputeGameFactoryCaller, gameType uint32, gameCount *big.Int, l2BlockNumber *big.Int, retiredBefore uint64) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	game, err := latestGameAtOrBefore(factory, gameType, new(big.Int).Sub(gameCount, common.Big1))
+	if err != nil {
+		return nil, err
+	}
+	if game == nil {
+		return nil, fmt.Errorf("no games of type %d", gameType)
+	}
+	if gameL2BlockNumber(*game).Cmp(l2BlockNumber) < 0 {
+		return nil, fmt.Errorf("the latest game proposes L2 block %s, which is not past L2 block %s - the withdrawal cannot be proven yet", gameL2BlockNumber(*game), l2BlockNumber)
+	}
+	if game.Timestamp < retiredBefore {
+		return nil, fmt.Errorf("the latest game was created at %d, before the respected game type was updated at %d - wait for a new game to be proposed", game.Timestamp, retiredBefore)
+	}
+	return game, nil
+}
+
+// ValidateGameType checks that the factory can create games of the given type. Whether the portal accepts proofs
+// against them depends on the game, so that is checked once one is picked, with a warning here if the type isn't the
+// portal's respected one.
+func ValidateGameType(factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, gameType uint32) error {
+	impl, err := factory.GameImpls(&bind.CallOpts{}, gameType)
+	if err != nil {
+		return fmt.Errorf("failed to get game implementation: %w", err)
+	}
+	if impl == (common.Address{}) {
+		return fmt.Errorf("the DisputeGameFactory has no implementation for game type %d", gameType)
+	}
+	respected, err := portal.RespectedGameType(&bind.CallOpts{})
+	if err != nil {
+		return fmt.Errorf("failed to get respected game type: %w", err)
+	}
+	if gameType != respected {
+		log.Warn("Game type is not the portal's respected game type, the portal may reject proofs against it", "gameType", gameType, "respectedGameType", respected)
+	}
+	return nil
+}
+
+// gameTypeRejection returns why the portal would reject proofs against the game because of its type, or "" if it
+// wouldn't. Portals accept proofs against games of their respected game type and, once they record it, against games
+// that were of the respected type when they were created. Games created before respectedGameTypeUpdatedAt are
+// rejected whatever their type, which callers check separately.
+func gameTypeRejection(portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, proxy common.Address, gameType uint32) (string, error) {
+	respected, err := portal.RespectedGameType(&bind.CallOpts{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get respected game type: %w", err)
+	}
+	if gameType == respected {
+		return "", nil
+	}
+	wasRespected, err := NewDisputeGame(proxy, caller).WasRespectedGameTypeWhenCreated()
+	if err != nil {
+		log.Debug("Unable to query whether the game was of the respected game type when created, assuming it wasn't", "game", proxy, "error", err)
+		return fmt.Sprintf("the game is of type %d and the portal only accepts its respected game type %d", gameType, respected), nil
+	}
+	if !wasRespected {
+		return fmt.Sprintf("the game is of type %d, which was not the portal's respected game type when it was created", gameType), nil
+	}
+	return "", nil
+}
+
+// GameAtIndex returns the factory's game at index for proving a withdrawal in l2BlockNumber, for users who pick the game
+// themselves rather than leaving it to FindEarliestGame. The game must be of a type the portal accepts, cover
+// l2BlockNumber, have been created after the respected game type was last set, and be neither blacklisted nor
+// disproven, as the portal would otherwise reject the proof. A contested game is returned with a warning, as the user chose it.
+func GameAtIndex(factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, index *big.Int, l2BlockNumber *big.Int) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	gameCount, err := factory.GameCount(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+	if index.Sign() < 0 || index.Cmp(gameCount) >= 0 {
+		return nil, fmt.Errorf("game %s does not exist, the DisputeGameFactory has %s games", index, gameCount)
+	}
+	at, err := factory.GameAtIndex(&bind.CallOpts{}, index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game %s: %w", index, err)
+	}
+	if err := ValidateGameType(factory, portal, at.GameType); err != nil {
+		return nil, fmt.Errorf("cannot prove against game %s: %w", index, err)
+	}
+	game, err := latestGameAtOrBefore(factory, at.GameType, index)
+	if err != nil {
+		return nil, err
+	}
+	if game == nil || game.Index.Cmp(index) != 0 {
+		return nil, fmt.Errorf("failed to look up game %s", index)
+	}
+
+	if gameL2BlockNumber(*game).Cmp(l2BlockNumber) < 0 {
+		return nil, fmt.Errorf("game %s proposes L2 block %s, before the withdrawal's L2 block %s", index, gameL2BlockNumber(*game), l2BlockNumber)
+	}
+	retiredBefore, err := portal.RespectedGameTypeUpdatedAt(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type update time: %w", err)
+	}
+	if game.Timestamp < retiredBefore {
+		return nil, fmt.Errorf("game %s was created at %d, before the respected game type was updated at %d, so the portal rejects it", index, game.Timestamp, retiredBefore)
+	}
+	reason, err := gameRejection(portal, caller, gameProxy(*game))
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		if reason, err = gameTypeRejection(portal, caller, gameProxy(*game), at.GameType); err != nil {
+			return nil, err
+		}
+	}
+	if reason != "" {
+		return nil, fmt.Errorf("the portal would reject proofs against game %s: %s", index, reason)
+	}
+	contention, err := gameContention(caller, gameProxy(*game))
+	if err != nil {
+		return nil, err
+	}
+	if contention != "" {
+		log.Warn("Proving against a dispute game a long way from resolution", "gameIndex", index, "game", gameProxy(*game), "reason", contention)
+	}
+	return game, nil
+}
+
+// gameRejection returns why the portal would refuse to finalize proofs against the game, or "" if it wouldn't: the
+// guardian may blacklist a game, and a game resolved in favor of the challenger disproved its root claim.
+func gameRejection(portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, proxy common.Address) (string, error) {
+	blacklisted, err := portal.DisputeGameBlacklist(&bind.CallOpts{}, proxy)
+	if err != nil {
+		return "", fmt.Errorf("failed to get dispute game blacklist status: %w", err)
+	}
+	if blacklisted {
+		return "the game was blacklisted by the guardian", nil
+	}
+	status, err := NewDisputeGame(proxy, caller).Status()
+	if err != nil {
+		return "", err
+	}
+	if status == GameStatusChallengerWins {
+		return "the game resolved in favor of the challenger", nil
+	}
+	return "", nil
+}
+
+// gameContention returns why the game is a long way from resolution, or "" if it isn't: an unchallenged game resolves
+// once its root claim's clock runs out, but a game whose root claim was countered can take up to twice its max clock
+// duration to resolve, and may still resolve in favor of the challenger.
+func gameContention(caller bind.ContractCaller, proxy common.Address) (string, error) {
+	game := NewDisputeGame(proxy, caller)
+	status, err := game.Status()
+	if err != nil {
+		return "", err
+	}
+	if status != GameStatusInProgress {
+		return "", nil
+	}
+	claims, err := game.ClaimDataLen()
+	if err != nil {
+		log.Debug("Unable to query the claims of the dispute game, assuming it is uncontested", "game", proxy, "error", err)
+		return "", nil
+	}
+	if claims <= 1 {
+		return "", nil
+	}
+	createdAt, err := game.CreatedAt()
+	if err != nil {
+		return "", err
+	}
+	maxClock, err := game.MaxClockDuration()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("its root claim was countered by %d claims, so it may not resolve until %s", claims-1,
+		time.Unix(int64(createdAt+2*maxClock), 0).UTC().Format(time.RFC3339)), nil
+}
+
+// NextUsableGame returns game if the portal would accept proofs against it, or otherwise the next game of the same
+// type covering l2BlockNumber that is neither blacklisted nor resolved against its root claim, inspecting at most
+// maxLinearGameScan games. Games in progress whose root claim was countered are skipped too, as they are a long way
+// from resolution, unless no uncontested game covers l2BlockNumber, in which case the first contested one is returned.
+func NextUsableGame(factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, game *bindings.IDisputeGameFactoryGameSearchResult, l2BlockNumber *big.Int) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	gameCount, err := factory.GameCount(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+
+	var contested *bindings.IDisputeGameFactoryGameSearchResult
+	candidate := game
+	index := new(big.Int).Set(game.Index)
+	for scanned := 0; scanned < maxLinearGameScan && index.Cmp(gameCount) < 0; scanned++ {
+		if candidate != nil && gameL2BlockNumber(*candidate).Cmp(l2BlockNumber) >= 0 {
+			reason, err := gameRejection(portal, caller, gameProxy(*candidate))
+			if err != nil {
+				return nil, err
+			}
+			if reason == "" {
+				contention, err := gameContention(caller, gameProxy(*candidate))
+				if err != nil {
+					return nil, err
+				}
+				if contention == "" {
+					return candidate, nil
+				}
+				log.Warn("Skipping dispute game a long way from resolution", "gameIndex", candidate.Index, "game", gameProxy(*candidate), "reason", contention)
+				if contested == nil {
+					contested = candidate
+				}
+			} else {
+				log.Warn("Skipping dispute game the portal would reject", "gameIndex", candidate.Index, "game", gameProxy(*candidate), "reason", reason)
+			}
+		}
+
+		index.Add(index, common.Big1)
+		candidate = nil
+		if index.Cmp(gameCount) >= 0 {
+			break
+		}
+		at, err := factory.GameAtIndex(&bind.CallOpts{}, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get game %s: %w", index, err)
+		}
+		if at.GameType == gameType(*game) {
+			if candidate, err = latestGameAtOrBefore(factory, at.GameType, index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if contested != nil {
+		log.Warn("No uncontested dispute game covers the withdrawal, proving against a contested one", "gameIndex", contested.Index, "game", gameProxy(*contested))
+		return contested, nil
+	}
+	return nil, fmt.Errorf("no game of type %d after game %s covers L2 block %s without being blacklisted or disproven - wait for a new game to be proposed", gameType(*game), game.Index, l2BlockNumber)
+}
+
+// binarySearchGame finds the lowest factory index whose latest game of the given type covers l2BlockNumber.
+// It returns nil if even the latest game doesn't cover it.
+func binarySearchGame(factory *bindings.DisputeGameFactoryCaller, gameType uint32, gameCount *big.Int, l2BlockNumber *big.Int, retiredBefore uint64) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	lo := big.NewInt(0)
+	hi := new(big.Int).Sub(gameCount, common.Big1)
+	var found *bindings.IDisputeGameFactoryGameSearchResult
+	for lo.Cmp(hi) <= 0 {
+		mid := new(big.Int).Rsh(new(big.Int).Add(lo, hi), 1)
+		game, err := latestGameAtOrBefore(factory, gameType, mid)
+		if err != nil {
+			return nil, err
+		}
+		if game == nil || !coversBlock(*game, l2BlockNumber, retiredBefore) {
+			lo = new(big.Int).Add(mid, common.Big1)
+		} else {
+			found = game
+			if mid.Sign() == 0 {
+				break
+			}
+			hi = new(big.Int).Sub(mid, common.Big1)
+		}
+	}
+	return found, nil
+}
+
+// validateEarliestGame checks the binary search result: the game must cover l2BlockNumber and the previous game
+// of the same type must not, otherwise block numbers are not monotonic and the search may have picked the wrong game.
+func validateEarliestGame(factory *bindings.DisputeGameFactoryCaller, gameType uint32, game *bindings.IDisputeGameFactoryGameSearchResult, l2BlockNumber *big.Int, retiredBefore uint64) (bool, error) {
+	if !coversBlock(*game, l2BlockNumber, retiredBefore) {
+		return false, nil
+	}
+	if game.Index.Sign() == 0 {
+		return true, nil
+	}
+	prev, err := latestGameAtOrBefore(factory, gameType, new(big.Int).Sub(game.Index, common.Big1))
+	if err != nil {
+		return false, err
+	}
+	if prev == nil {
+		return true, nil
+	}
+	if gameL2BlockNumber(*prev).Cmp(gameL2BlockNumber(*game)) > 0 || coversBlock(*prev, l2BlockNumber, retiredBefore) {
+		log.Debug("Dispute game monotonicity violated", "game", game.Index, "gameBlock", gameL2BlockNumber(*game), "prevGame", prev.Index, "prevGameBlock", gameL2BlockNumber(*prev))
+		return false, nil
+	}
+	return true, nil
+}
+
+// linearScanGame walks backwards from the latest game of the given type, inspecting at most maxLinearGameScan
+// games, and returns the lowest-indexed usable game that covers l2BlockNumber, or nil if none was found.
+func linearScanGame(factory *bindings.DisputeGameFactoryCaller, gameType uint32, gameCount *big.Int, l2BlockNumber *big.Int, retiredBefore uint64) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	var found *bindings.IDisputeGameFactoryGameSearchResult
+	start := new(big.Int).Sub(gameCount, common.Big1)
+	for scanned := 0; scanned < maxLinearGameScan && start.Sign() >= 0; {
+		games, err := factory.FindLatestGames(&bind.CallOpts{}, gameType, start, big.NewInt(gameSearchPageSize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest games: %w", err)
+		}
+		if len(games) == 0 {
+			break
+		}
+		for i := range games {
+			if coversBlock(games[i], l2BlockNumber, retiredBefore) {
+				found = &games[i]
+			}
+		}
+		// games are returned newest first, so once they predate the retirement no older game can be used
+		if games[len(games)-1].Timestamp < retiredBefore {
+			break
+		}
+		scanned += len(games)
+		start = new(big.Int).Sub(games[len(games)-1].Index, common.Big1)
+	}
+	return found, nil
+}
+
+// shardedScanGame is linearScanGame spread across several L1 providers, one factory binding each. Each round fetches
+// one page of gameSearchPageSize factory indices per provider in parallel, walking backwards from the latest game,
+// and a page whose provider fails is retried on the others.
+func shardedScanGame(ctx context.Context, factories []*bindings.DisputeGameFactoryCaller, gameType uint32, gameCount *big.Int, l2BlockNumber *big.Int, retiredBefore uint64) (*bindings.IDisputeGameFactoryGameSearchResult, error) {
+	var found *bindings.IDisputeGameFactoryGameSearchResult
+	top := new(big.Int).Sub(gameCount, common.Big1)
+	for scanned := 0; scanned < maxLinearGameScan && top.Sign() >= 0; {
+		pages := make([][]bindings.IDisputeGameFactoryGameSearchResult, len(factories))
+		errs := make([]error, len(factories))
+		var wg sync.WaitGroup
+		for i := range factories {
+			pageTop := new(big.Int).Sub(top, big.NewInt(int64(i*gameSearchPageSize)))
+			if pageTop.Sign() < 0 {
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pages[i], errs[i] = fetchGamePage(ctx, factories, i, gameType, pageTop)
+			}()
+		}
+		wg.Wait()
+
+		retired := false
+		// pages and the games in them are ordered newest first, so the last covering game is the earliest
+		for i, page := range pages {
+			if errs[i] != nil {
+				return nil, errs[i]
+			}
+			for j := range page {
+				if coversBlock(page[j], l2BlockNumber, retiredBefore) {
+					found = &page[j]
+				}
+				// once games predate the retirement no older game can be used
+				retired = retired || page[j].Timestamp < retiredBefore
+			}
+			scanned += len(page)
+		}
+		if retired {
+			break
+		}
+		top.Sub(top, big.NewInt(int64(len(factories)*gameSearchPageSize)))
+	}
+	return found, nil
+}
+
+// fetchGamePage returns the games of the given type among the gameSearchPageSize factory indices at or below top,
+// newest first. It asks factories[shard] first, failing over to the others in turn.
+func fetchGamePage(ctx context.Context, factories []*bindings.DisputeGameFactoryCaller, shard int, gameType uint32, top *big.Int) ([]bindings.IDisputeGameFactoryGameSearchResult, error) {
+	bottom := new(big.Int).Sub(top, big.NewInt(gameSearchPageSize))
+	var err error
+	for attempt := 0; attempt < len(factories); attempt++ {
+		provider := (shard + attempt) % len(factories)
+		var games []bindings.IDisputeGameFactoryGameSearchResult
+		games, err = factories[provider].FindLatestGames(&bind.CallOpts{Context: ctx}, gameType, top, big.NewInt(gameSearchPageSize))
+		if err != nil {
+			log.Warn("L1 provider failed to fetch dispute games, retrying on another", "provider", provider, "start", top, "error", err)
+			continue
+		}
+		// a page holds at most gameSearchPageSize games of the type, the rest are older and belong to the next pages
+		page := games[:0]
+		for _, game := range games {
+			if game.Index.Cmp(bottom) > 0 {
+				page = append(page, game)
+			}
+		}
+		return page, nil
+	}
+	return nil, fmt.Errorf("failed to get latest games from any L1 provider: %w", err)
+}
+
+// gameCadenceSample is the number of recent games used to estimate the game creation cadence.
+const gameCadenceSample = 20
+
+// GameCoverageEstimate predicts when a game covering an L2 block will be created, based on recent games.
+type GameCoverageEstimate struct {
+	LatestL2Block *big.Int      // L2 block proposed by the latest game
+	GamesSampled  int           // Number of recent games the cadence was measured over
+	Interval      time.Duration // Average time between game creations
+	BlocksPerGame uint64        // Average number of L2 blocks each game advances
+	GamesNeeded   uint64        // Number of further games needed to cover the L2 block
+	EstimatedAt   time.Time     // Expected creation time of the first covering game
+}
+
+// Remaining returns how long until the covering game is expected, or 0 if it is already due.
+func (e *GameCoverageEstimate) Remaining(now time.Time) time.Duration {
+	if !e.EstimatedAt.After(now) {
+		return 0
+	}
+	return e.EstimatedAt.Sub(now)
+}
+
+// EstimateGameCoverage estimates when a game of the portal's respected game type proposing an output at or past
+// l2BlockNumber will be created, from the creation times and L2 blocks of the most recent games.
+func EstimateGameCoverage(factory *bindings.DisputeGameFactoryCaller, portal *bindingspreview.OptimismPortal2Caller, l2BlockNumber *big.Int) (*GameCoverageEstimate, error) {
+	gameType, err := portal.RespectedGameType(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get respected game type: %w", err)
+	}
+	return EstimateGameCoverageOfType(factory, gameType, l2BlockNumber)
+}
+
+// EstimateGameCoverageOfType is EstimateGameCoverage for the given game type instead of the portal's respected one.
+func EstimateGameCoverageOfType(factory *bindings.DisputeGameFactoryCaller, gameType uint32, l2BlockNumber *big.Int) (*GameCoverageEstimate, error) {
+	gameCount, err := factory.GameCount(&bind.CallOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game count: %w", err)
+	}
+	if gameCount.Sign() == 0 {
+		return nil, errors.New("no games")
+	}
+
+	games, err := factory.FindLatestGames(&bind.CallOpts{}, gameType, new(big.Int).Sub(gameCount, common.Big1), big.NewInt(gameCadenceSample))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest games: %w", err)
+	}
+	if len(games) < 2 {
+		return nil, errors.New("not enough games to estimate the game creation cadence")
+	}
+
+	// games are returned newest first
+	newest, oldest := games[0], games[len(games)-1]
+	intervals := uint64(len(games) - 1)
+	if newest.Timestamp <= oldest.Timestamp {
+		return nil, errors.New("recent games were not created over time")
+	}
+	blocks := new(big.Int).Sub(gameL2BlockNumber(newest), gameL2BlockNumber(oldest))
+	if blocks.Sign() <= 0 {
+		return nil, errors.New("recent games did not advance the L2 chain")
+	}
+
+	e := &GameCoverageEstimate{
+		LatestL2Block: gameL2BlockNumber(newest),
+		GamesSampled:  len(games),
+		Interval:      time.Duration((newest.Timestamp-oldest.Timestamp)/intervals) * time.Second,
+		BlocksPerGame: max(blocks.Uint64()/intervals, 1),
+	}
+	if behind := new(big.Int).Sub(l2BlockNumber, e.LatestL2Block); behind.Sign() > 0 {
+		e.GamesNeeded = (behind.Uint64() + e.BlocksPerGame - 1) / e.BlocksPerGame
+	}
+	e.EstimatedAt = time.Unix(int64(newest.Timestamp), 0).Add(time.Duration(e.GamesNeeded) * e.Interval)
+	return e, nil
+}
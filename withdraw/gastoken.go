@@ -0,0 +1,85 @@
+package withdraw
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// systemConfigGasTokenABI has the portal's systemConfig getter and the custom gas token getters of the SystemConfig.
+const systemConfigGasTokenABI = `[
+	{"type":"function","name":"systemConfig","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"isCustomGasToken","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"gasPayingToken","stateMutability":"view","inputs":[],"outputs":[{"name":"addr_","type":"address"},{"name":"decimals_","type":"uint8"}]},
+	{"type":"function","name":"gasPayingTokenSymbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]}
+]`
+
+var systemConfigGasTokenParsedABI = mustParseABI(systemConfigGasTokenABI)
+
+// GasToken is the native token of an OP Stack chain. Custom gas token chains bridge an L1 ERC20 instead of ETH: the
+// value of their withdrawals is paid out in that token on L1, while L1 transaction costs are still paid in ETH.
+type GasToken struct {
+	Address common.Address // L1 ERC20 backing the native token
+	Symbol  string
+}
+
+// IsCustom reports whether the chain uses a custom gas token. A nil GasToken is ETH.
+func (t *GasToken) IsCustom() bool {
+	return t != nil && t.Address != (common.Address{})
+}
+
+// Unit returns the symbol withdrawal values are denominated in.
+func (t *GasToken) Unit() string {
+	if !t.IsCustom() {
+		return "ETH"
+	}
+	if t.Symbol == "" {
+		return t.Address.Hex()
+	}
+	return t.Symbol
+}
+
+// FormatValue formats a withdrawal value in its native token. Custom gas tokens always have 18 decimals.
+func (t *GasToken) FormatValue(wei *big.Int) string {
+	return FormatEther(wei) + " " + t.Unit()
+}
+
+// DetectGasToken reads the chain's gas paying token from the SystemConfig of the portal. SystemConfigs released
+// before custom gas tokens don't have the getters, and are treated as ETH chains, so nil is returned for them too.
+func DetectGasToken(portal common.Address, caller bind.ContractCaller) (*GasToken, error) {
+	var out []interface{}
+	if err := bind.NewBoundContract(portal, systemConfigGasTokenParsedABI, caller, nil, nil).Call(&bind.CallOpts{}, &out, "systemConfig"); err != nil {
+		return nil, fmt.Errorf("error querying SystemConfig from OptimismPortal %s: %w", portal, err)
+	}
+	systemConfigAddr := out[0].(common.Address)
+	systemConfig := bind.NewBoundContract(systemConfigAddr, systemConfigGasTokenParsedABI, caller, nil, nil)
+
+	out = nil
+	if err := systemConfig.Call(&bind.CallOpts{}, &out, "isCustomGasToken"); err != nil {
+		log.Debug("SystemConfig has no custom gas token support, assuming ETH", "systemConfig", systemConfigAddr, "error", err)
+		return nil, nil
+	}
+	if !out[0].(bool) {
+		return nil, nil
+	}
+
+	out = nil
+	if err := systemConfig.Call(&bind.CallOpts{}, &out, "gasPayingToken"); err != nil {
+		return nil, fmt.Errorf("error querying gas paying token from SystemConfig %s: %w", systemConfigAddr, err)
+	}
+	token := &GasToken{Address: out[0].(common.Address)}
+	if decimals := out[1].(uint8); decimals != 18 {
+		return nil, fmt.Errorf("gas paying token %s has %d decimals, expected 18", token.Address, decimals)
+	}
+
+	out = nil
+	if err := systemConfig.Call(&bind.CallOpts{}, &out, "gasPayingTokenSymbol"); err != nil {
+		log.Debug("Unable to query gas paying token symbol", "token", token.Address, "error", err)
+	} else {
+		token.Symbol = out[0].(string)
+	}
+	return token, nil
+}
@@ -0,0 +1,235 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// historyBlockRange is the number of L1 blocks requested per eth_getLogs call, which most providers accept.
+const historyBlockRange = 10_000
+
+// withdrawalFinalizedTopic is the topic of the WithdrawalFinalized event, which has the same signature on the legacy
+// and fault proof portals.
+var withdrawalFinalizedTopic = crypto.Keccak256Hash([]byte("WithdrawalFinalized(bytes32,bool)"))
+
+// Activity is a prove or finalize transaction found in the portal's events.
+type Activity struct {
+	TxCost
+	WithdrawalHash common.Hash
+	Success        bool // Whether the withdrawal's call succeeded, for finalizations (always true for proofs)
+}
+
+// FindActivity reconstructs the proofs and finalizations sent by sender, from the portal's WithdrawalProven and
+// WithdrawalFinalized events between fromBlock and toBlock inclusive. The events don't record who sent the
+// transaction, so the blocks that have any are fetched to recover the senders.
+func FindActivity(ctx context.Context, l1 *ethclient.Client, portal common.Address, sender common.Address, fromBlock uint64, toBlock uint64) ([]Activity, error) {
+	chainID, err := l1.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	txSigner := types.LatestSignerForChainID(chainID)
+
+	var activity []Activity
+	for start := fromBlock; start <= toBlock; start += historyBlockRange {
+		end := min(start+historyBlockRange-1, toBlock)
+		logs, err := l1.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{portal},
+			Topics:    [][]common.Hash{{withdrawalProvenTopic, withdrawalFinalizedTopic}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error querying portal events in blocks %d-%d: %w", start, end, err)
+		}
+		log.Info("Scanned portal events", "fromBlock", start, "toBlock", end, "events", len(logs), "found", len(activity))
+
+		blocks := make(map[common.Hash]*types.Block)
+		for _, l := range logs {
+			if l.Removed || len(l.Topics) < 2 {
+				continue
+			}
+			block, ok := blocks[l.BlockHash]
+			if !ok {
+				if block, err = l1.BlockByHash(ctx, l.BlockHash); err != nil {
+					return nil, fmt.Errorf("error querying L1 block %s: %w", l.BlockHash, err)
+				}
+				blocks[l.BlockHash] = block
+			}
+			txs := block.Transactions()
+			if int(l.TxIndex) >= len(txs) {
+				return nil, fmt.Errorf("L1 block %s has no transaction %d", l.BlockHash, l.TxIndex)
+			}
+			from, err := types.Sender(txSigner, txs[l.TxIndex])
+			if err != nil {
+				return nil, fmt.Errorf("error recovering sender of L1 tx %s: %w", l.TxHash, err)
+			}
+			if from != sender {
+				continue
+			}
+
+			receipt, err := l1.TransactionReceipt(ctx, l.TxHash)
+			if err != nil {
+				return nil, fmt.Errorf("error querying L1 receipt %s: %w", l.TxHash, err)
+			}
+			a := Activity{WithdrawalHash: l.Topics[1], Success: true}
+			if l.Topics[0] == withdrawalProvenTopic {
+				a.TxCost = newTxCost("prove", receipt)
+			} else {
+				a.TxCost = newTxCost("finalize", receipt)
+				a.Success = len(l.Data) == 32 && l.Data[31] == 1
+			}
+			activity = append(activity, a)
+		}
+	}
+	return activity, nil
+}
+
+// HistoryProof is a proof of a withdrawal found in the portal's events.
+type HistoryProof struct {
+	Submitter   *common.Address `json:"submitter,omitempty"` // nil on portals without the WithdrawalProvenExtension1 event
+	TxHash      common.Hash     `json:"txHash"`
+	BlockNumber uint64          `json:"blockNumber"`
+	Time        time.Time       `json:"time"`
+}
+
+// HistoryEntry is a past withdrawal reconstructed from the portal's events: its proofs and, once finalized, its
+// finalization.
+type HistoryEntry struct {
+	WithdrawalHash common.Hash    `json:"withdrawalHash"`
+	Sender         common.Address `json:"sender"` // L2 sender of the withdrawal, the L2CrossDomainMessenger for bridge withdrawals
+	Target         common.Address `json:"target"`
+	Proofs         []HistoryProof `json:"proofs"` // Oldest first
+	FinalizeTxHash *common.Hash   `json:"finalizeTxHash,omitempty"`
+	FinalizeBlock  uint64         `json:"finalizeBlock,omitempty"`
+	FinalizedAt    *time.Time     `json:"finalizedAt,omitempty"`
+	Success        *bool          `json:"success,omitempty"`     // Whether the withdrawal's call succeeded, once finalized
+	AsSender       bool           `json:"asSender,omitempty"`    // Whether the address is the withdrawal's L2 sender
+	AsSubmitter    bool           `json:"asSubmitter,omitempty"` // Whether the address submitted one of its proofs
+}
+
+// FindHistory reconstructs the withdrawals proven between fromBlock and toBlock inclusive whose L2 sender, or one of
+// whose proof submitters, is address, from the portal's WithdrawalProven, WithdrawalProvenExtension1 and
+// WithdrawalFinalized events. Proof submitters are only known on fault proof portals, which emit the extension event.
+// A withdrawal finalized in the range is only found if it was also proven in it. Entries are in order of first proof.
+func FindHistory(ctx context.Context, l1 *ethclient.Client, portal common.Address, address common.Address, fromBlock uint64, toBlock uint64) ([]*HistoryEntry, error) {
+	var entries []*HistoryEntry
+	byHash := make(map[common.Hash]*HistoryEntry)
+	blockTimes := make(map[common.Hash]time.Time)
+	blockTime := func(l types.Log) (time.Time, error) {
+		if t, ok := blockTimes[l.BlockHash]; ok {
+			return t, nil
+		}
+		header, err := l1.HeaderByHash(ctx, l.BlockHash)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error querying L1 block %s: %w", l.BlockHash, err)
+		}
+		t := time.Unix(int64(header.Time), 0).UTC()
+		blockTimes[l.BlockHash] = t
+		return t, nil
+	}
+
+	for start := fromBlock; start <= toBlock; start += historyBlockRange {
+		end := min(start+historyBlockRange-1, toBlock)
+		logs, err := l1.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{portal},
+			Topics:    [][]common.Hash{{withdrawalProvenTopic, withdrawalProvenExtension1Topic, withdrawalFinalizedTopic}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error querying portal events in blocks %d-%d: %w", start, end, err)
+		}
+
+		// the fault proof portal emits WithdrawalProvenExtension1 with the submitter right after WithdrawalProven,
+		// so the proofs of a tx are matched once all of its events are seen
+		type txProof struct {
+			proven    *types.Log
+			submitter *common.Address
+		}
+		proofs := make(map[common.Hash]*txProof)
+		var order []common.Hash
+		proofOf := func(l types.Log) *txProof {
+			key := crypto.Keccak256Hash(l.TxHash.Bytes(), l.Topics[1].Bytes())
+			p, ok := proofs[key]
+			if !ok {
+				p = &txProof{}
+				proofs[key] = p
+				order = append(order, key)
+			}
+			return p
+		}
+		var finalized []types.Log
+		for i, l := range logs {
+			if l.Removed || len(l.Topics) < 2 {
+				continue
+			}
+			switch l.Topics[0] {
+			case withdrawalProvenTopic:
+				if len(l.Topics) == 4 {
+					proofOf(l).proven = &logs[i]
+				}
+			case withdrawalProvenExtension1Topic:
+				if len(l.Topics) == 3 {
+					submitter := common.BytesToAddress(l.Topics[2].Bytes())
+					proofOf(l).submitter = &submitter
+				}
+			case withdrawalFinalizedTopic:
+				finalized = append(finalized, l)
+			}
+		}
+
+		for _, key := range order {
+			p := proofs[key]
+			if p.proven == nil {
+				continue
+			}
+			hash := p.proven.Topics[1]
+			sender := common.BytesToAddress(p.proven.Topics[2].Bytes())
+			e, tracked := byHash[hash]
+			bySender, bySubmitter := sender == address, p.submitter != nil && *p.submitter == address
+			if !tracked && !bySender && !bySubmitter {
+				continue
+			}
+			t, err := blockTime(*p.proven)
+			if err != nil {
+				return nil, err
+			}
+			if !tracked {
+				e = &HistoryEntry{
+					WithdrawalHash: hash,
+					Sender:         sender,
+					Target:         common.BytesToAddress(p.proven.Topics[3].Bytes()),
+				}
+				byHash[hash] = e
+				entries = append(entries, e)
+			}
+			e.AsSender = e.AsSender || bySender
+			e.AsSubmitter = e.AsSubmitter || bySubmitter
+			e.Proofs = append(e.Proofs, HistoryProof{Submitter: p.submitter, TxHash: p.proven.TxHash, BlockNumber: p.proven.BlockNumber, Time: t})
+		}
+
+		for _, l := range finalized {
+			e, ok := byHash[l.Topics[1]]
+			if !ok {
+				continue
+			}
+			t, err := blockTime(l)
+			if err != nil {
+				return nil, err
+			}
+			txHash, success := l.TxHash, len(l.Data) == 32 && l.Data[31] == 1
+			e.FinalizeTxHash, e.FinalizeBlock, e.FinalizedAt, e.Success = &txHash, l.BlockNumber, &t, &success
+		}
+		log.Info("Scanned portal events", "fromBlock", start, "toBlock", end, "events", len(logs), "found", len(entries))
+	}
+	return entries, nil
+}
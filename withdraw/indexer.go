@@ -0,0 +1,238 @@
+package withdraw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Indexer follows the portal's WithdrawalProven, WithdrawalProvenExtension1 and WithdrawalFinalized events and the
+// DisputeGameFactory's DisputeGameCreated events block range by block range, up to the L1 safe head, so long-running
+// modes can tell how far along their withdrawals are from the new blocks of each poll alone. Only withdrawals passed to
+// Watch are indexed, seeded with their state read from the portal, and the index is checkpointed to the file at Path
+// after each sync, so a restart picks up from the last block indexed.
+type Indexer struct {
+	Path string
+
+	l1    *ethclient.Client
+	mu    sync.Mutex
+	state indexState
+}
+
+// indexState is the index as checkpointed to its file.
+type indexState struct {
+	L1ChainID    uint64                             `json:"l1ChainId"`
+	Portal       common.Address                     `json:"portal"`
+	Factory      *common.Address                    `json:"factory,omitempty"`
+	NextBlock    uint64                             `json:"nextBlock"` // First L1 block not indexed yet
+	Withdrawals  map[common.Hash]*IndexedWithdrawal `json:"withdrawals"`
+	LatestGames  map[uint32]*IndexedGame            `json:"latestGames,omitempty"` // Keyed by game type
+	GamesCreated uint64                             `json:"gamesCreated"`          // Since the index started
+}
+
+// IndexedWithdrawal is a watched withdrawal's progress, as seeded from the portal and updated from its events.
+type IndexedWithdrawal struct {
+	State          State            `json:"state"`
+	Submitters     []common.Address `json:"submitters,omitempty"` // Proof submitters seen, on fault proof portals
+	ProveTxHash    *common.Hash     `json:"proveTxHash,omitempty"`
+	FinalizeTxHash *common.Hash     `json:"finalizeTxHash,omitempty"`
+}
+
+// IndexedGame is a dispute game seen created by the factory.
+type IndexedGame struct {
+	Proxy       common.Address `json:"proxy"`
+	RootClaim   common.Hash    `json:"rootClaim"`
+	BlockNumber uint64         `json:"blockNumber"`
+}
+
+// NewIndexer returns the indexer of the portal's events, and the factory's if any, checkpointed to the file at path.
+// An existing checkpoint is resumed from, unless it indexes another chain or portal. A new index starts at the L1
+// safe head.
+func NewIndexer(ctx context.Context, l1 *ethclient.Client, portal common.Address, factory *common.Address, path string) (*Indexer, error) {
+	chainID, err := l1.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	idx := &Indexer{Path: path, l1: l1}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		head, err := idx.head(ctx)
+		if err != nil {
+			return nil, err
+		}
+		idx.state = indexState{L1ChainID: chainID.Uint64(), Portal: portal, Factory: factory, NextBlock: head + 1}
+		log.Info("Starting event index", "fromBlock", idx.state.NextBlock, "index", path)
+	case err != nil:
+		return nil, fmt.Errorf("error reading index %s: %w", path, err)
+	default:
+		if err := json.Unmarshal(data, &idx.state); err != nil {
+			return nil, fmt.Errorf("error decoding index %s: %w", path, err)
+		}
+		if idx.state.L1ChainID != chainID.Uint64() || idx.state.Portal != portal {
+			return nil, fmt.Errorf("index %s is of portal %s on L1 chain %d, not %s on chain %s, pass another --index-file",
+				path, idx.state.Portal, idx.state.L1ChainID, portal, chainID)
+		}
+		idx.state.Factory = factory
+		log.Info("Resuming event index", "fromBlock", idx.state.NextBlock, "watched", len(idx.state.Withdrawals), "index", path)
+	}
+	if idx.state.Withdrawals == nil {
+		idx.state.Withdrawals = make(map[common.Hash]*IndexedWithdrawal)
+	}
+	if idx.state.LatestGames == nil {
+		idx.state.LatestGames = make(map[uint32]*IndexedGame)
+	}
+	return idx, idx.save()
+}
+
+// Watch starts indexing the withdrawal from its state as just read from the portal. Watching it again only moves its
+// state forward, as withdrawals never go back to an earlier state.
+func (idx *Indexer) Watch(hash common.Hash, state State) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	w, ok := idx.state.Withdrawals[hash]
+	if !ok {
+		idx.state.Withdrawals[hash] = &IndexedWithdrawal{State: state}
+		return
+	}
+	if slices.Index(States, state) > slices.Index(States, w.State) {
+		w.State = state
+	}
+}
+
+// Forget stops indexing the withdrawal, once it needs no more watching.
+func (idx *Indexer) Forget(hash common.Hash) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.state.Withdrawals, hash)
+}
+
+// Withdrawal returns the watched withdrawal's progress, or false if it isn't watched.
+func (idx *Indexer) Withdrawal(hash common.Hash) (IndexedWithdrawal, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	w, ok := idx.state.Withdrawals[hash]
+	if !ok {
+		return IndexedWithdrawal{}, false
+	}
+	return *w, true
+}
+
+// LatestGame returns the latest dispute game of the type seen created, or nil if none was since the index started.
+func (idx *Indexer) LatestGame(gameType uint32) *IndexedGame {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return idx.state.LatestGames[gameType]
+}
+
+// Sync indexes the events of the blocks up to the L1 safe head that weren't indexed yet and checkpoints the index. It
+// returns the number of dispute games created in them, which may make waiting withdrawals provable.
+func (idx *Indexer) Sync(ctx context.Context) (int, error) {
+	head, err := idx.head(ctx)
+	if err != nil {
+		return 0, err
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	addresses := []common.Address{idx.state.Portal}
+	topics := []common.Hash{withdrawalProvenTopic, withdrawalProvenExtension1Topic, withdrawalFinalizedTopic}
+	if idx.state.Factory != nil {
+		addresses = append(addresses, *idx.state.Factory)
+		topics = append(topics, disputeGameCreatedTopic)
+	}
+
+	games := 0
+	for start := idx.state.NextBlock; start <= head; start += historyBlockRange {
+		end := min(start+historyBlockRange-1, head)
+		logs, err := idx.l1.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: addresses,
+			Topics:    [][]common.Hash{topics},
+		})
+		if err != nil {
+			return games, fmt.Errorf("error querying events in L1 blocks %d-%d: %w", start, end, err)
+		}
+		for _, l := range logs {
+			if l.Removed || len(l.Topics) < 2 {
+				continue
+			}
+			if l.Topics[0] == disputeGameCreatedTopic {
+				if l.Address != *idx.state.Factory || len(l.Topics) < 4 {
+					continue
+				}
+				gameType := uint32(new(big.Int).SetBytes(l.Topics[2].Bytes()).Uint64())
+				idx.state.LatestGames[gameType] = &IndexedGame{
+					Proxy:       common.BytesToAddress(l.Topics[1].Bytes()),
+					RootClaim:   l.Topics[3],
+					BlockNumber: l.BlockNumber,
+				}
+				idx.state.GamesCreated++
+				games++
+				continue
+			}
+			w, ok := idx.state.Withdrawals[l.Topics[1]]
+			if l.Address != idx.state.Portal || !ok {
+				continue
+			}
+			txHash := l.TxHash
+			switch l.Topics[0] {
+			case withdrawalProvenTopic:
+				if w.State == StateInitiated {
+					w.State = StateProven
+				}
+				w.ProveTxHash = &txHash
+			case withdrawalProvenExtension1Topic:
+				if len(l.Topics) > 2 {
+					submitter := common.BytesToAddress(l.Topics[2].Bytes())
+					if !slices.Contains(w.Submitters, submitter) {
+						w.Submitters = append(w.Submitters, submitter)
+					}
+				}
+			case withdrawalFinalizedTopic:
+				w.State = StateFinalized
+				w.FinalizeTxHash = &txHash
+			}
+		}
+		idx.state.NextBlock = end + 1
+		log.Debug("Indexed events", "fromBlock", start, "toBlock", end, "events", len(logs))
+	}
+	return games, idx.save()
+}
+
+// head returns the L1 safe head, up to which events are indexed as they won't be reorged out, or the latest block if
+// the L1 RPC doesn't track the safe head.
+func (idx *Indexer) head(ctx context.Context) (uint64, error) {
+	header, err := idx.l1.HeaderByNumber(ctx, big.NewInt(int64(rpc.SafeBlockNumber)))
+	if err != nil {
+		log.Debug("Unable to query the L1 safe head, using the latest block", "error", err)
+		header, err = idx.l1.HeaderByNumber(ctx, nil)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error querying L1 head: %w", err)
+	}
+	return header.Number.Uint64(), nil
+}
+
+// save checkpoints the index, replacing the file in one step so a process dying mid-write can't leave it truncated.
+func (idx *Indexer) save() error {
+	data, err := json.MarshalIndent(idx.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(idx.Path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing index %s: %w", idx.Path, err)
+	}
+	return nil
+}
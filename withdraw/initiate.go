@@ -0,0 +1,369 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// DefaultBridgeMinGasLimit is the gas the bridge message is given to finalize the withdrawal on L1, enough for the
+// L1StandardBridge to release a standard ERC-20.
+const DefaultBridgeMinGasLimit = 200_000
+
+// l2TokenBridgeABI has the L2StandardBridge's ETH and ERC-20 withdrawal functions and the ERC-20 functions it needs,
+// including the getters OptimismMintableERC20s (and their legacy versions) expose their L1 token and bridge with.
+const l2TokenBridgeABI = `[
+	{"type":"function","name":"bridgeERC20","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"_localToken","type":"address"},{"name":"_remoteToken","type":"address"},{"name":"_amount","type":"uint256"},
+		{"name":"_minGasLimit","type":"uint32"},{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"bridgeERC20To","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"_localToken","type":"address"},{"name":"_remoteToken","type":"address"},{"name":"_to","type":"address"},
+		{"name":"_amount","type":"uint256"},{"name":"_minGasLimit","type":"uint32"},{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"bridgeETH","stateMutability":"payable","outputs":[],"inputs":[
+		{"name":"_minGasLimit","type":"uint32"},{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"bridgeETHTo","stateMutability":"payable","outputs":[],"inputs":[
+		{"name":"_to","type":"address"},{"name":"_minGasLimit","type":"uint32"},{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"allowance","stateMutability":"view","inputs":[{"name":"owner","type":"address"},{"name":"spender","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"approve","stateMutability":"nonpayable","inputs":[{"name":"spender","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"remoteToken","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"l1Token","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"bridge","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"l2Bridge","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
+
+var l2TokenBridgeParsedABI = mustParseABI(l2TokenBridgeABI)
+
+// L2Token is an ERC-20 on L2 to withdraw through the L2StandardBridge.
+type L2Token struct {
+	Address  common.Address
+	L1Token  common.Address // The token it's withdrawn to on L1
+	Symbol   string
+	Decimals uint8
+	Mintable bool // An OptimismMintableERC20 the bridge burns, rather than a token native to L2 it takes custody of
+}
+
+// ResolveL2Token reads the token's symbol and decimals, and resolves its L1 token if it's an OptimismMintableERC20 of
+// the L2StandardBridge. Other tokens are native to L2, so their L1 token can't be read from them and must be given as
+// l1Token. A given l1Token must match the one of an OptimismMintableERC20.
+func ResolveL2Token(caller bind.ContractCaller, token common.Address, l1Token common.Address) (*L2Token, error) {
+	contract := bind.NewBoundContract(token, l2TokenBridgeParsedABI, caller, nil, nil)
+	call := func(method string, args ...interface{}) (interface{}, error) {
+		var out []interface{}
+		if err := contract.Call(&bind.CallOpts{}, &out, method, args...); err != nil {
+			return nil, err
+		}
+		return out[0], nil
+	}
+
+	t := &L2Token{Address: token, L1Token: l1Token}
+	decimals, err := call("decimals")
+	if err != nil {
+		return nil, fmt.Errorf("error querying decimals of token %s, is it an ERC-20 on this L2?: %w", token, err)
+	}
+	t.Decimals = decimals.(uint8)
+	if symbol, err := call("symbol"); err == nil {
+		t.Symbol = symbol.(string)
+	}
+
+	remote, err := call("remoteToken")
+	if err != nil {
+		remote, err = call("l1Token")
+	}
+	if err != nil {
+		if l1Token == (common.Address{}) {
+			return nil, fmt.Errorf("token %s is not an OptimismMintableERC20, so its L1 token can't be resolved, please provide it", token)
+		}
+		log.Info("Token is native to L2, the bridge will take custody of it", "token", token, "l1Token", l1Token)
+		return t, nil
+	}
+
+	bridge, err := call("bridge")
+	if err != nil {
+		bridge, err = call("l2Bridge")
+	}
+	if err == nil && bridge.(common.Address) != predeploys.L2StandardBridgeAddr {
+		return nil, fmt.Errorf("token %s is bridged by %s, not the L2StandardBridge", token, bridge.(common.Address))
+	}
+	resolved := remote.(common.Address)
+	if l1Token != (common.Address{}) && l1Token != resolved {
+		return nil, fmt.Errorf("token %s is withdrawn to L1 token %s, not %s", token, resolved, l1Token)
+	}
+	t.L1Token = resolved
+	t.Mintable = true
+	return t, nil
+}
+
+// FormatAmount formats a token amount in the token's base units as a decimal string.
+func (t *L2Token) FormatAmount(amount *big.Int) string {
+	return new(big.Float).Quo(new(big.Float).SetInt(amount), new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Decimals)), nil))).Text('f', int(t.Decimals))
+}
+
+// ParseAmount parses a decimal token amount, with at most the token's decimals, into base units.
+func (t *L2Token) ParseAmount(amount string) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(amount), ".")
+	if len(frac) > int(t.Decimals) {
+		return nil, fmt.Errorf("invalid %s amount %q: more than %d decimals", t.Symbol, amount, t.Decimals)
+	}
+	units, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", int(t.Decimals)-len(frac)), 10)
+	if !ok || units.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid %s amount %q", t.Symbol, amount)
+	}
+	return units, nil
+}
+
+// TokenWithdrawal initiates the withdrawal of an ERC-20 to an address on L1 through the L2StandardBridge.
+type TokenWithdrawal struct {
+	Ctx           context.Context
+	L2Client      *ethclient.Client
+	Opts          *bind.TransactOpts
+	Token         *L2Token
+	Amount        *big.Int       // In the token's base units
+	To            common.Address // L1 recipient (zero means the sender's address)
+	MinGasLimit   uint32         // Gas the bridge message is given on L1 (zero means DefaultBridgeMinGasLimit)
+	GasMultiplier float64
+	UserGasLimit  uint64
+	DryRun        bool
+	Timeout       time.Duration // Max time to wait for each transaction to confirm (zero means DefaultTxTimeout)
+}
+
+// Initiate approves the bridge to take the amount first if the token is native to L2 and the allowance is short, then
+// sends the bridgeERC20 transaction, or bridgeERC20To for a recipient other than the sender. It returns that
+// transaction's receipt, the withdrawal to prove and finalize on L1 once the L2 output or dispute game covering it is
+// proposed. In dry run, the transactions are only simulated, and nil is returned.
+func (w *TokenWithdrawal) Initiate() (*types.Receipt, error) {
+	token := bind.NewBoundContract(w.Token.Address, l2TokenBridgeParsedABI, w.L2Client, w.L2Client, w.L2Client)
+	bridge := bind.NewBoundContract(predeploys.L2StandardBridgeAddr, l2TokenBridgeParsedABI, w.L2Client, w.L2Client, w.L2Client)
+	from := w.Opts.From
+
+	var out []interface{}
+	if err := token.Call(&bind.CallOpts{Context: w.Ctx}, &out, "balanceOf", from); err != nil {
+		return nil, fmt.Errorf("error querying token balance: %w", err)
+	}
+	balance := out[0].(*big.Int)
+	if balance.Cmp(w.Amount) < 0 {
+		return nil, fmt.Errorf("%s holds %s %s, less than the %s to withdraw", from, w.Token.FormatAmount(balance), w.Token.Symbol, w.Token.FormatAmount(w.Amount))
+	}
+	log.Info("L2 token balance", "token", w.Token.Address, "symbol", w.Token.Symbol, "balance", w.Token.FormatAmount(balance))
+
+	approved := true
+	if !w.Token.Mintable {
+		out = nil
+		if err := token.Call(&bind.CallOpts{Context: w.Ctx}, &out, "allowance", from, predeploys.L2StandardBridgeAddr); err != nil {
+			return nil, fmt.Errorf("error querying token allowance: %w", err)
+		}
+		if allowance := out[0].(*big.Int); allowance.Cmp(w.Amount) < 0 {
+			log.Info("Approving the L2StandardBridge to take the tokens", "token", w.Token.Address, "amount", w.Token.FormatAmount(w.Amount), "allowance", w.Token.FormatAmount(allowance))
+			if _, err := w.send(token, "approve", predeploys.L2StandardBridgeAddr, w.Amount); err != nil {
+				return nil, fmt.Errorf("error approving the L2StandardBridge: %w", err)
+			}
+			approved = !w.DryRun
+		}
+	}
+
+	minGasLimit := w.MinGasLimit
+	if minGasLimit == 0 {
+		minGasLimit = DefaultBridgeMinGasLimit
+	}
+	if !approved {
+		// the bridge can't take the tokens before the approval is sent, so its transaction can't be simulated yet
+		log.Info("DRY RUN: skipping the bridgeERC20 simulation, as it needs the approval to be sent first")
+		return nil, nil
+	}
+	var receipt *types.Receipt
+	var err error
+	if w.To == (common.Address{}) || w.To == from {
+		log.Info("Initiating withdrawal", "token", w.Token.Address, "l1Token", w.Token.L1Token, "amount", w.Token.FormatAmount(w.Amount), "symbol", w.Token.Symbol, "from", from)
+		receipt, err = w.send(bridge, "bridgeERC20", w.Token.Address, w.Token.L1Token, w.Amount, minGasLimit, []byte{})
+	} else {
+		log.Info("Initiating withdrawal", "token", w.Token.Address, "l1Token", w.Token.L1Token, "amount", w.Token.FormatAmount(w.Amount), "symbol", w.Token.Symbol, "from", from, "to", w.To)
+		receipt, err = w.send(bridge, "bridgeERC20To", w.Token.Address, w.Token.L1Token, w.To, w.Amount, minGasLimit, []byte{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error initiating withdrawal: %w", err)
+	}
+	return receipt, nil
+}
+
+// ETHWithdrawal initiates the withdrawal of ETH to an address on L1 through the L2StandardBridge.
+type ETHWithdrawal struct {
+	Ctx           context.Context
+	L2Client      *ethclient.Client
+	Opts          *bind.TransactOpts
+	Amount        *big.Int       // In wei
+	To            common.Address // L1 recipient (zero means the sender's address)
+	MinGasLimit   uint32         // Gas the bridge message is given on L1 (zero means DefaultBridgeMinGasLimit)
+	GasMultiplier float64
+	UserGasLimit  uint64
+	DryRun        bool
+	Timeout       time.Duration // Max time to wait for the transaction to confirm (zero means DefaultTxTimeout)
+}
+
+// Initiate sends the amount to the bridge with bridgeETH, or bridgeETHTo for a recipient other than the sender. It
+// returns that transaction's receipt, the withdrawal to prove and finalize on L1 once the L2 output or dispute game
+// covering it is proposed. In dry run, the transaction is only simulated, and nil is returned.
+func (w *ETHWithdrawal) Initiate() (*types.Receipt, error) {
+	bridge := bind.NewBoundContract(predeploys.L2StandardBridgeAddr, l2TokenBridgeParsedABI, w.L2Client, w.L2Client, w.L2Client)
+	from := w.Opts.From
+
+	balance, err := w.L2Client.BalanceAt(w.Ctx, from, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying balance: %w", err)
+	}
+	if balance.Cmp(w.Amount) < 0 {
+		return nil, fmt.Errorf("%s holds %s ETH, less than the %s to withdraw", from, FormatEther(balance), FormatEther(w.Amount))
+	}
+
+	minGasLimit := w.MinGasLimit
+	if minGasLimit == 0 {
+		minGasLimit = DefaultBridgeMinGasLimit
+	}
+	opts := *w.Opts
+	opts.Value = w.Amount
+	var receipt *types.Receipt
+	if w.To == (common.Address{}) || w.To == from {
+		log.Info("Initiating withdrawal", "amount", FormatEther(w.Amount), "from", from)
+		receipt, err = sendL2Transaction(w.Ctx, w.L2Client, &opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, w.Timeout, bridge, "bridgeETH", minGasLimit, []byte{})
+	} else {
+		log.Info("Initiating withdrawal", "amount", FormatEther(w.Amount), "from", from, "to", w.To)
+		receipt, err = sendL2Transaction(w.Ctx, w.L2Client, &opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, w.Timeout, bridge, "bridgeETHTo", w.To, minGasLimit, []byte{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error initiating withdrawal: %w", err)
+	}
+	return receipt, nil
+}
+
+// send sends a transaction calling the contract's method and waits for it to confirm, or only simulates it in dry
+// run, returning a nil receipt.
+func (w *TokenWithdrawal) send(contract *bind.BoundContract, method string, args ...interface{}) (*types.Receipt, error) {
+	return sendL2Transaction(w.Ctx, w.L2Client, w.Opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, w.Timeout, contract, method, args...)
+}
+
+// sendL2Transaction sends a transaction calling the contract's method and waits for it to confirm within timeout, or
+// only simulates it in dry run, returning a nil receipt.
+func sendL2Transaction(ctx context.Context, client *ethclient.Client, txOpts *bind.TransactOpts, userGasLimit uint64, gasMultiplier float64, dryRun bool, timeout time.Duration, contract *bind.BoundContract, method string, args ...interface{}) (*types.Receipt, error) {
+	opts := *txOpts
+	opts.Context = ctx
+	simulated, err := prepareGasOpts(&opts, userGasLimit, gasMultiplier, dryRun, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return contract.Transact(opts, method, args...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if simulated == nil {
+		// build the transaction without sending it, so the sender can be shown what it costs first
+		buildOpts := opts
+		buildOpts.NoSend = true
+		buildOpts.Signer = unsignedSigner
+		if simulated, err = contract.Transact(&buildOpts, method, args...); err != nil {
+			return nil, err
+		}
+	}
+	fees, err := EstimateL2Fees(ctx, client, opts.From, simulated, opts.GasLimit)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("L2 balance and fees", "method", method, "balanceETH", FormatEther(fees.Balance), "valueETH", FormatEther(fees.Value),
+		"executionFeeETH", FormatEther(fees.ExecutionFee), "maxExecutionFeeETH", FormatEther(fees.MaxExecutionFee), "l1DataFeeETH", FormatEther(fees.L1DataFee))
+	if !fees.Covered() {
+		return nil, fmt.Errorf("%s holds %s ETH on L2, less than the %s ETH the %s transaction needs: %s ETH of value, up to %s ETH of L2 execution fee and %s ETH of L1 data fee",
+			opts.From, FormatEther(fees.Balance), FormatEther(fees.Max()), method, FormatEther(fees.Value), FormatEther(fees.MaxExecutionFee), FormatEther(fees.L1DataFee))
+	}
+	if dryRun {
+		printDryRun(method, simulated, opts.From, opts.GasLimit, 0)
+		return nil, nil
+	}
+
+	tx, err := contract.Transact(&opts, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Sent L2 transaction", "method", method, "txHash", tx.Hash())
+	waitCtx, cancel := context.WithTimeout(ctx, txTimeout(timeout))
+	defer cancel()
+	receipt, err := waitForConfirmation(waitCtx, client, tx.Hash(), 1, false, nil, nil)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%s transaction %s not confirmed within %s", method, tx.Hash(), txTimeout(timeout))
+	}
+	return receipt, err
+}
+
+// gasPriceOracleABI has the GasPriceOracle predeploy's estimate of the L1 data fee of an L2 transaction.
+const gasPriceOracleABI = `[{"type":"function","name":"getL1Fee","stateMutability":"view","inputs":[{"name":"_data","type":"bytes"}],"outputs":[{"name":"","type":"uint256"}]}]`
+
+var gasPriceOracleParsedABI = mustParseABI(gasPriceOracleABI)
+
+// L2Fees is what an L2 transaction costs its sender, next to the sender's ETH balance on L2. Besides the execution
+// fee, L2 transactions pay an L1 data fee for posting them to L1, which users initiating withdrawals tend to forget.
+type L2Fees struct {
+	Balance         *big.Int // Sender's ETH balance on L2
+	Value           *big.Int // ETH sent with the transaction
+	ExecutionFee    *big.Int // Gas limit at the current base fee plus the priority fee
+	MaxExecutionFee *big.Int // Gas limit at the max fee per gas, which the balance must cover for the L2 to accept the tx
+	L1DataFee       *big.Int // Fee for posting the transaction to L1, as estimated by the GasPriceOracle
+}
+
+// Max returns the most the transaction can cost: its value, max execution fee and L1 data fee.
+func (f *L2Fees) Max() *big.Int {
+	total := new(big.Int).Add(f.Value, f.MaxExecutionFee)
+	return total.Add(total, f.L1DataFee)
+}
+
+// Covered reports whether the balance covers the most the transaction can cost.
+func (f *L2Fees) Covered() bool {
+	return f.Balance.Cmp(f.Max()) >= 0
+}
+
+// EstimateL2Fees estimates the fees of the unsigned L2 transaction from the sender, sent with gasLimit if set or else
+// its own gas limit, and reads the sender's balance.
+func EstimateL2Fees(ctx context.Context, client *ethclient.Client, from common.Address, tx *types.Transaction, gasLimit uint64) (*L2Fees, error) {
+	if gasLimit == 0 {
+		gasLimit = tx.Gas()
+	}
+	gas := new(big.Int).SetUint64(gasLimit)
+	balance, err := client.BalanceAt(ctx, from, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L2 balance: %w", err)
+	}
+	head, err := client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L2 head: %w", err)
+	}
+	price := tx.GasPrice()
+	if tx.Type() == types.DynamicFeeTxType && head.BaseFee != nil {
+		if effective := new(big.Int).Add(head.BaseFee, tx.GasTipCap()); effective.Cmp(price) < 0 {
+			price = effective
+		}
+	}
+
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	oracle := bind.NewBoundContract(predeploys.GasPriceOracleAddr, gasPriceOracleParsedABI, client, nil, nil)
+	if err := oracle.Call(&bind.CallOpts{Context: ctx}, &out, "getL1Fee", data); err != nil {
+		return nil, fmt.Errorf("error estimating the L1 data fee: %w", err)
+	}
+
+	return &L2Fees{
+		Balance:         balance,
+		Value:           tx.Value(),
+		ExecutionFee:    new(big.Int).Mul(price, gas),
+		MaxExecutionFee: new(big.Int).Mul(tx.GasFeeCap(), gas),
+		L1DataFee:       out[0].(*big.Int),
+	}, nil
+}
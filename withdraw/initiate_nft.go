@@ -0,0 +1,131 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// l2NFTBridgeABI has the L2ERC721Bridge's withdrawal function and the ERC-721 functions it needs, including the getters
+// OptimismMintableERC721s expose their L1 token and bridge with.
+const l2NFTBridgeABI = `[
+	{"type":"function","name":"bridgeERC721","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"_localToken","type":"address"},{"name":"_remoteToken","type":"address"},{"name":"_tokenId","type":"uint256"},
+		{"name":"_minGasLimit","type":"uint32"},{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"bridgeERC721To","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"_localToken","type":"address"},{"name":"_remoteToken","type":"address"},{"name":"_to","type":"address"},
+		{"name":"_tokenId","type":"uint256"},{"name":"_minGasLimit","type":"uint32"},{"name":"_extraData","type":"bytes"}]},
+	{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+	{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"remoteToken","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"REMOTE_TOKEN","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"bridge","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]},
+	{"type":"function","name":"BRIDGE","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
+
+var l2NFTBridgeParsedABI = mustParseABI(l2NFTBridgeABI)
+
+// L2NFT is an ERC-721 collection on L2 to withdraw tokens of through the L2ERC721Bridge.
+type L2NFT struct {
+	Address common.Address
+	L1Token common.Address // The collection its tokens are withdrawn to on L1
+	Symbol  string
+}
+
+// ResolveL2NFT resolves the L1 collection of an OptimismMintableERC721 of the L2ERC721Bridge. Unlike the
+// L2StandardBridge, the L2ERC721Bridge can't take custody of NFTs native to L2, so other collections can't be
+// withdrawn. A given l1Token must match the resolved one.
+func ResolveL2NFT(caller bind.ContractCaller, token common.Address, l1Token common.Address) (*L2NFT, error) {
+	contract := bind.NewBoundContract(token, l2NFTBridgeParsedABI, caller, nil, nil)
+	call := func(method string, args ...interface{}) (interface{}, error) {
+		var out []interface{}
+		if err := contract.Call(&bind.CallOpts{}, &out, method, args...); err != nil {
+			return nil, err
+		}
+		return out[0], nil
+	}
+
+	remote, err := call("remoteToken")
+	if err != nil {
+		remote, err = call("REMOTE_TOKEN")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("token %s is not an OptimismMintableERC721, only those can be withdrawn through the L2ERC721Bridge: %w", token, err)
+	}
+	bridge, err := call("bridge")
+	if err != nil {
+		bridge, err = call("BRIDGE")
+	}
+	if err == nil && bridge.(common.Address) != predeploys.L2ERC721BridgeAddr {
+		return nil, fmt.Errorf("token %s is bridged by %s, not the L2ERC721Bridge", token, bridge.(common.Address))
+	}
+	resolved := remote.(common.Address)
+	if l1Token != (common.Address{}) && l1Token != resolved {
+		return nil, fmt.Errorf("token %s is withdrawn to L1 token %s, not %s", token, resolved, l1Token)
+	}
+
+	t := &L2NFT{Address: token, L1Token: resolved}
+	if symbol, err := call("symbol"); err == nil {
+		t.Symbol = symbol.(string)
+	}
+	return t, nil
+}
+
+// NFTWithdrawal initiates the withdrawal of an ERC-721 token to an address on L1 through the L2ERC721Bridge.
+type NFTWithdrawal struct {
+	Ctx           context.Context
+	L2Client      *ethclient.Client
+	Opts          *bind.TransactOpts
+	Token         *L2NFT
+	TokenID       *big.Int
+	To            common.Address // L1 recipient (zero means the sender's address)
+	MinGasLimit   uint32         // Gas the bridge message is given on L1 (zero means DefaultBridgeMinGasLimit)
+	GasMultiplier float64
+	UserGasLimit  uint64
+	DryRun        bool
+	Timeout       time.Duration // Max time to wait for the transaction to confirm (zero means DefaultTxTimeout)
+}
+
+// Initiate checks the sender owns the token and sends the bridgeERC721 transaction, or bridgeERC721To for a recipient
+// other than the sender, which burns it on L2 without an approval. It returns that transaction's receipt, the
+// withdrawal to prove and finalize on L1 once the L2 output or dispute game covering it is proposed. In dry run, the
+// transaction is only simulated, and nil is returned.
+func (w *NFTWithdrawal) Initiate() (*types.Receipt, error) {
+	token := bind.NewBoundContract(w.Token.Address, l2NFTBridgeParsedABI, w.L2Client, w.L2Client, w.L2Client)
+	bridge := bind.NewBoundContract(predeploys.L2ERC721BridgeAddr, l2NFTBridgeParsedABI, w.L2Client, w.L2Client, w.L2Client)
+	from := w.Opts.From
+
+	var out []interface{}
+	if err := token.Call(&bind.CallOpts{Context: w.Ctx}, &out, "ownerOf", w.TokenID); err != nil {
+		return nil, fmt.Errorf("error querying the owner of token %s: %w", w.TokenID, err)
+	}
+	if owner := out[0].(common.Address); owner != from {
+		return nil, fmt.Errorf("%s token %s is owned by %s, not %s", w.Token.Symbol, w.TokenID, owner, from)
+	}
+
+	minGasLimit := w.MinGasLimit
+	if minGasLimit == 0 {
+		minGasLimit = DefaultBridgeMinGasLimit
+	}
+	var receipt *types.Receipt
+	var err error
+	if w.To == (common.Address{}) || w.To == from {
+		log.Info("Initiating withdrawal", "token", w.Token.Address, "l1Token", w.Token.L1Token, "tokenId", w.TokenID, "symbol", w.Token.Symbol, "from", from)
+		receipt, err = sendL2Transaction(w.Ctx, w.L2Client, w.Opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, w.Timeout, bridge, "bridgeERC721", w.Token.Address, w.Token.L1Token, w.TokenID, minGasLimit, []byte{})
+	} else {
+		log.Info("Initiating withdrawal", "token", w.Token.Address, "l1Token", w.Token.L1Token, "tokenId", w.TokenID, "symbol", w.Token.Symbol, "from", from, "to", w.To)
+		receipt, err = sendL2Transaction(w.Ctx, w.L2Client, w.Opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, w.Timeout, bridge, "bridgeERC721To", w.Token.Address, w.Token.L1Token, w.To, w.TokenID, minGasLimit, []byte{})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error initiating withdrawal: %w", err)
+	}
+	return receipt, nil
+}
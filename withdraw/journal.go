@@ -0,0 +1,177 @@
+package withdraw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Journal persists the raw signed transaction sent for a withdrawal until it is settled, so that a run that dies
+// after broadcasting it can resume waiting for it instead of leaving it untracked. Entries of all withdrawals share
+// the file at Path, keyed by the L2 withdrawal transaction hash.
+type Journal struct {
+	Path     string
+	L2TxHash common.Hash
+}
+
+// journalEntry is a transaction recorded in the journal.
+type journalEntry struct {
+	TxHash     common.Hash   `json:"txHash"`
+	RawTx      hexutil.Bytes `json:"rawTx"`
+	RecordedAt time.Time     `json:"recordedAt"`
+}
+
+// Record stores the signed transaction as the withdrawal's pending transaction.
+func (j *Journal) Record(tx *types.Transaction) error {
+	raw, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	entries, err := j.read()
+	if err != nil {
+		return err
+	}
+	entries[j.L2TxHash] = journalEntry{TxHash: tx.Hash(), RawTx: raw, RecordedAt: time.Now().UTC()}
+	return j.write(entries)
+}
+
+// Pending returns the withdrawal's pending transaction, or nil if there is none.
+func (j *Journal) Pending() (*types.Transaction, error) {
+	entries, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[j.L2TxHash]
+	if !ok {
+		return nil, nil
+	}
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(entry.RawTx); err != nil {
+		return nil, fmt.Errorf("error decoding pending tx %s from journal %s: %w", entry.TxHash, j.Path, err)
+	}
+	return tx, nil
+}
+
+// Entries returns the pending transactions of every withdrawal in the journal, keyed by L2 withdrawal transaction hash.
+func (j *Journal) Entries() (map[common.Hash]*types.Transaction, error) {
+	entries, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	txs := make(map[common.Hash]*types.Transaction, len(entries))
+	for l2TxHash, entry := range entries {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(entry.RawTx); err != nil {
+			return nil, fmt.Errorf("error decoding pending tx %s from journal %s: %w", entry.TxHash, j.Path, err)
+		}
+		txs[l2TxHash] = tx
+	}
+	return txs, nil
+}
+
+// Clear removes the withdrawal's pending transaction once it is settled.
+func (j *Journal) Clear() error {
+	entries, err := j.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[j.L2TxHash]; !ok {
+		return nil
+	}
+	delete(entries, j.L2TxHash)
+	return j.write(entries)
+}
+
+func (j *Journal) read() (map[common.Hash]journalEntry, error) {
+	entries := make(map[common.Hash]journalEntry)
+	data, err := os.ReadFile(j.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading journal %s: %w", j.Path, err)
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("error decoding journal %s: %w", j.Path, err)
+	}
+	return entries, nil
+}
+
+func (j *Journal) write(entries map[common.Hash]journalEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	// the journal holds signed transactions, which only the user should be able to read or replace, and must survive
+	// the crash it is there for, so it is never left truncated
+	if err := WriteFileAtomic(j.Path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing journal %s: %w", j.Path, err)
+	}
+	return nil
+}
+
+// JournalBackend is a contract backend that records each transaction in the journal before sending it, so the
+// journal never misses a transaction that may have been broadcast.
+type JournalBackend struct {
+	bind.ContractBackend
+	Journal *Journal
+}
+
+// SendTransaction records the signed transaction, then sends it through the wrapped backend.
+func (b *JournalBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.Journal.Record(tx); err != nil {
+		return err
+	}
+	return b.ContractBackend.SendTransaction(ctx, tx)
+}
+
+// ResumePending waits for the transaction a previous run recorded for the withdrawal, if any, so that it isn't sent
+// twice. If the L1 RPC doesn't know the transaction, it is rebroadcast through sender, unless its nonce was used by
+// another transaction since. The journal entry is cleared once the transaction is confirmed, reverted or replaced,
+// and kept otherwise, so the next run resumes again.
+func ResumePending(ctx context.Context, client *ethclient.Client, sender bind.ContractTransactor, journal *Journal, timeout time.Duration, confirmations uint64, waitFinalized bool) error {
+	tx, err := journal.Pending()
+	if err != nil || tx == nil {
+		return err
+	}
+	log.Info("Resuming transaction broadcast by a previous run", "l2TxHash", journal.L2TxHash, "l1TxHash", tx.Hash(), "nonce", tx.Nonce())
+
+	if _, _, err := client.TransactionByHash(ctx, tx.Hash()); errors.Is(err, ethereum.NotFound) {
+		log.Warn("Transaction from the previous run not found on L1, rebroadcasting it", "l1TxHash", tx.Hash())
+		if err := sender.SendTransaction(ctx, tx); err != nil {
+			switch {
+			case strings.Contains(err.Error(), "nonce too low"):
+				log.Warn("Transaction from the previous run was replaced by another with the same nonce, dropping it", "l1TxHash", tx.Hash(), "nonce", tx.Nonce())
+				return journal.Clear()
+			case strings.Contains(err.Error(), "already known"):
+				// the node had it pending after all
+			default:
+				return fmt.Errorf("error rebroadcasting tx %s: %w", tx.Hash(), err)
+			}
+		}
+	} else if err != nil {
+		return fmt.Errorf("error querying tx %s: %w", tx.Hash(), err)
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, txTimeout(timeout))
+	defer cancel()
+	if _, err := waitForConfirmation(ctxWithTimeout, client, tx.Hash(), confirmations, waitFinalized, nil, nil); errors.Is(err, errTxReverted) {
+		log.Warn("Transaction from the previous run reverted", "l1TxHash", tx.Hash())
+	} else if err != nil {
+		return fmt.Errorf("tx %s from the previous run was not confirmed: %w", tx.Hash(), err)
+	} else {
+		log.Info("Transaction from the previous run confirmed", "l1TxHash", tx.Hash())
+	}
+	return journal.Clear()
+}
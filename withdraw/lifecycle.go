@@ -0,0 +1,125 @@
+package withdraw
+
+import (
+	"fmt"
+	"strings"
+)
+
+// State is a stage of a withdrawal's lifecycle on L1.
+type State string
+
+// Withdrawal states, in the order a withdrawal goes through them.
+const (
+	StateInitiated State = "initiated" // Sent on L2, not proven on L1 yet
+	StateProven    State = "proven"    // Proven on L1, waiting for the finalization period
+	StateFinalized State = "finalized" // Executed on L1, nothing left to do
+)
+
+// States lists every state in lifecycle order.
+var States = []State{StateInitiated, StateProven, StateFinalized}
+
+// Action is what moves a withdrawal from one state to the next. Its values match the action of the CLI's JSON result.
+type Action string
+
+const (
+	ActionProve    Action = "prove"
+	ActionFinalize Action = "finalize"
+	ActionNone     Action = "none"
+)
+
+// Transition is an allowed move between two states, with the on-chain evidence that it happened.
+type Transition struct {
+	From     State
+	To       State
+	Action   Action
+	Evidence string
+}
+
+// Transitions lists every allowed transition. Withdrawals never go back to an earlier state: a proof against an
+// invalidated game can be replaced, but the withdrawal stays proven until it is finalized.
+var Transitions = []Transition{
+	{
+		From:     StateInitiated,
+		To:       StateProven,
+		Action:   ActionProve,
+		Evidence: "OptimismPortal provenWithdrawals has a non-zero timestamp for the withdrawal hash (and proof submitter on fault proof chains)",
+	},
+	{
+		From:     StateProven,
+		To:       StateFinalized,
+		Action:   ActionFinalize,
+		Evidence: "OptimismPortal finalizedWithdrawals is true for the withdrawal hash",
+	},
+}
+
+// ParseState parses the name of a state.
+func ParseState(s string) (State, error) {
+	for _, state := range States {
+		if strings.EqualFold(s, string(state)) {
+			return state, nil
+		}
+	}
+	return "", fmt.Errorf("invalid withdrawal state %q (one of: %s, %s, %s)", s, StateInitiated, StateProven, StateFinalized)
+}
+
+// Next returns the transition out of the state, or false if the state is final.
+func (s State) Next() (Transition, bool) {
+	for _, t := range Transitions {
+		if t.From == s {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// NextAction returns the action that moves a withdrawal out of the state, or ActionNone if the state is final.
+func (s State) NextAction() Action {
+	if t, ok := s.Next(); ok {
+		return t.Action
+	}
+	return ActionNone
+}
+
+// CanTransition reports whether a withdrawal can go from one state to another, directly or through other states.
+func CanTransition(from State, to State) bool {
+	for s := from; ; {
+		t, ok := s.Next()
+		if !ok {
+			return false
+		}
+		if t.To == to {
+			return true
+		}
+		s = t.To
+	}
+}
+
+// StateFromEvidence determines the state from what the portal reports about the withdrawal. A withdrawal can't be
+// finalized without having been proven, so finalized takes precedence over any proof.
+func StateFromEvidence(finalized bool, proven bool) State {
+	switch {
+	case finalized:
+		return StateFinalized
+	case proven:
+		return StateProven
+	default:
+		return StateInitiated
+	}
+}
+
+// CurrentState queries the portal for the state of the withdrawal. On fault proof chains, the withdrawal is proven
+// once the withdrawer's proof submitter has proven it.
+func CurrentState(w WithdrawHelper) (State, error) {
+	finalized, err := w.IsProofFinalized()
+	if err != nil {
+		return "", fmt.Errorf("error querying withdrawal finalization status: %w", err)
+	}
+	if finalized {
+		return StateFinalized, nil
+	}
+	provenAt, err := w.GetProvenWithdrawalTime()
+	if err != nil {
+		return "", fmt.Errorf("error querying withdrawal proof: %w", err)
+	}
+	return StateFromEvidence(false, provenAt > 0), nil
+}
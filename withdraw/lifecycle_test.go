@@ -0,0 +1,89 @@
+package withdraw
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStateNextAction(t *testing.T) {
+	tests := []struct {
+		state      State
+		wantAction Action
+		wantNext   State // State the action moves the withdrawal to (empty for a final state)
+	}{
+		{state: StateInitiated, wantAction: ActionProve, wantNext: StateProven},
+		{state: StateProven, wantAction: ActionFinalize, wantNext: StateFinalized},
+		{state: StateFinalized, wantAction: ActionNone},
+		{state: State("unknown"), wantAction: ActionNone},
+	}
+	covered := map[State]bool{}
+	for _, tt := range tests {
+		covered[tt.state] = true
+		t.Run(string(tt.state), func(t *testing.T) {
+			if got := tt.state.NextAction(); got != tt.wantAction {
+				t.Errorf("got next action %q, want %q", got, tt.wantAction)
+			}
+			next, ok := tt.state.Next()
+			if ok != (tt.wantNext != "") {
+				t.Fatalf("got a transition out of the state: %v, want %v", ok, tt.wantNext != "")
+			}
+			if ok && (next.From != tt.state || next.To != tt.wantNext || next.Action != tt.wantAction) {
+				t.Errorf("got transition %s -%s-> %s, want %s -%s-> %s", next.From, next.Action, next.To, tt.state, tt.wantAction, tt.wantNext)
+			}
+		})
+	}
+	for _, state := range States {
+		if !covered[state] {
+			t.Errorf("state %q has no test case", state)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	// withdrawals only move forward, through every state after their own
+	for i, from := range States {
+		for j, to := range States {
+			if got, want := CanTransition(from, to), j > i; got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+// evidenceWithdrawer is a withdrawer whose portal reports a proof time and finalization, or fails to.
+type evidenceWithdrawer struct {
+	WithdrawHelper
+	provenAt  uint64
+	finalized bool
+	err       error
+}
+
+func (w *evidenceWithdrawer) IsProofFinalized() (bool, error) { return w.finalized, w.err }
+
+func (w *evidenceWithdrawer) GetProvenWithdrawalTime() (uint64, error) { return w.provenAt, w.err }
+
+func TestCurrentState(t *testing.T) {
+	tests := []struct {
+		name       string
+		withdrawer *evidenceWithdrawer
+		want       State
+		wantErr    bool
+	}{
+		{name: "not proven", withdrawer: &evidenceWithdrawer{}, want: StateInitiated},
+		{name: "proven", withdrawer: &evidenceWithdrawer{provenAt: 1_700_000_000}, want: StateProven},
+		{name: "finalized", withdrawer: &evidenceWithdrawer{provenAt: 1_700_000_000, finalized: true}, want: StateFinalized},
+		{name: "finalized without proof", withdrawer: &evidenceWithdrawer{finalized: true}, want: StateFinalized},
+		{name: "portal unreachable", withdrawer: &evidenceWithdrawer{err: errors.New("connection refused")}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CurrentState(tt.withdrawer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got state %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
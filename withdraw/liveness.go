@@ -0,0 +1,22 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// DefaultL2HaltThreshold is how old the latest L2 block can be before the chain is considered halted.
+const DefaultL2HaltThreshold = 10 * time.Minute
+
+// L2HeadAge returns the latest L2 block number and how long ago it was produced. L2 blocks are produced every few
+// seconds, so an old head means either the chain or the RPC has halted, and proposals will stall with it.
+func L2HeadAge(ctx context.Context, l2 *ethclient.Client) (uint64, time.Duration, error) {
+	head, err := l2.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error querying L2 head: %w", err)
+	}
+	return head.Number.Uint64(), time.Since(time.Unix(int64(head.Time), 0)), nil
+}
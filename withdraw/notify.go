@@ -0,0 +1,231 @@
+package withdraw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// notifyTimeout bounds how long to wait for a notification to be accepted.
+const notifyTimeout = 10 * time.Second
+
+// Notification events, as sent in the event field of a Notification.
+const (
+	EventProven      = "proven"
+	EventFinalizable = "finalizable"
+	EventFinalized   = "finalized"
+	EventError       = "error"
+	EventLowBalance  = "lowBalance"
+)
+
+// Notification describes a withdrawal lifecycle event.
+type Notification struct {
+	Event          string       `json:"event"`
+	L2TxHash       common.Hash  `json:"l2TxHash"`
+	WithdrawalHash common.Hash  `json:"withdrawalHash"`
+	L1TxHash       *common.Hash `json:"l1TxHash,omitempty"` // Prove or finalize transaction, if one was confirmed
+	Error          string       `json:"error,omitempty"`
+}
+
+// Notifier is told about a withdrawal's progress by the withdrawers, so that embedders can route lifecycle events to
+// their own alerting. Notification failures are logged and never fail the withdrawal.
+type Notifier interface {
+	// OnProven is called once the prove transaction is confirmed, or the delegated proof is verified.
+	OnProven(ctx context.Context, n Notification) error
+	// OnFinalizable is called when the portal's checks show the withdrawal can be finalized, right before finalizing.
+	OnFinalizable(ctx context.Context, n Notification) error
+	// OnFinalized is called once the finalize transaction is confirmed.
+	OnFinalized(ctx context.Context, n Notification) error
+	// OnError is called when proving or finalizing fails, other than because the withdrawal isn't finalizable yet.
+	OnError(ctx context.Context, n Notification, err error) error
+	// OnLowBalance is called when submission is paused because the signer's balance is below the minimum.
+	OnLowBalance(ctx context.Context, n Notification, signer common.Address, balance *big.Int, minBalance *big.Int) error
+}
+
+// Notifiers fans notifications out to several notifiers.
+type Notifiers []Notifier
+
+func (ns Notifiers) OnProven(ctx context.Context, n Notification) error {
+	return ns.each(func(notifier Notifier) error { return notifier.OnProven(ctx, n) })
+}
+
+func (ns Notifiers) OnFinalizable(ctx context.Context, n Notification) error {
+	return ns.each(func(notifier Notifier) error { return notifier.OnFinalizable(ctx, n) })
+}
+
+func (ns Notifiers) OnFinalized(ctx context.Context, n Notification) error {
+	return ns.each(func(notifier Notifier) error { return notifier.OnFinalized(ctx, n) })
+}
+
+func (ns Notifiers) OnError(ctx context.Context, n Notification, err error) error {
+	return ns.each(func(notifier Notifier) error { return notifier.OnError(ctx, n, err) })
+}
+
+func (ns Notifiers) OnLowBalance(ctx context.Context, n Notification, signer common.Address, balance *big.Int, minBalance *big.Int) error {
+	return ns.each(func(notifier Notifier) error { return notifier.OnLowBalance(ctx, n, signer, balance, minBalance) })
+}
+
+func (ns Notifiers) each(notify func(Notifier) error) error {
+	var errs []error
+	for _, notifier := range ns {
+		if err := notify(notifier); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WebhookNotifier POSTs each Notification as JSON to URL.
+type WebhookNotifier struct {
+	URL string
+}
+
+func (w *WebhookNotifier) OnProven(ctx context.Context, n Notification) error {
+	n.Event = EventProven
+	return postJSON(ctx, w.URL, n)
+}
+
+func (w *WebhookNotifier) OnFinalizable(ctx context.Context, n Notification) error {
+	n.Event = EventFinalizable
+	return postJSON(ctx, w.URL, n)
+}
+
+func (w *WebhookNotifier) OnFinalized(ctx context.Context, n Notification) error {
+	n.Event = EventFinalized
+	return postJSON(ctx, w.URL, n)
+}
+
+func (w *WebhookNotifier) OnError(ctx context.Context, n Notification, err error) error {
+	n.Event, n.Error = EventError, err.Error()
+	return postJSON(ctx, w.URL, n)
+}
+
+func (w *WebhookNotifier) OnLowBalance(ctx context.Context, n Notification, signer common.Address, balance *big.Int, minBalance *big.Int) error {
+	n.Event, n.Error = EventLowBalance, lowBalanceMessage(signer, balance, minBalance)
+	return postJSON(ctx, w.URL, n)
+}
+
+// SlackNotifier posts a message per notification to a Slack incoming webhook URL.
+type SlackNotifier struct {
+	WebhookURL string
+}
+
+func (s *SlackNotifier) OnProven(ctx context.Context, n Notification) error {
+	return s.post(ctx, n, "Withdrawal proven")
+}
+
+func (s *SlackNotifier) OnFinalizable(ctx context.Context, n Notification) error {
+	return s.post(ctx, n, "Withdrawal can be finalized")
+}
+
+func (s *SlackNotifier) OnFinalized(ctx context.Context, n Notification) error {
+	return s.post(ctx, n, "Withdrawal finalized")
+}
+
+func (s *SlackNotifier) OnError(ctx context.Context, n Notification, err error) error {
+	return s.post(ctx, n, fmt.Sprintf("Withdrawal failed: %v", err))
+}
+
+func (s *SlackNotifier) OnLowBalance(ctx context.Context, n Notification, signer common.Address, balance *big.Int, minBalance *big.Int) error {
+	return s.post(ctx, n, fmt.Sprintf("Withdrawal paused: %s", lowBalanceMessage(signer, balance, minBalance)))
+}
+
+func (s *SlackNotifier) post(ctx context.Context, n Notification, title string) error {
+	lines := []string{
+		fmt.Sprintf("*%s*", title),
+		fmt.Sprintf("L2 tx: `%s`", n.L2TxHash),
+		fmt.Sprintf("Withdrawal hash: `%s`", n.WithdrawalHash),
+	}
+	if n.L1TxHash != nil {
+		lines = append(lines, fmt.Sprintf("L1 tx: `%s`", n.L1TxHash))
+	}
+	return postJSON(ctx, s.WebhookURL, map[string]string{"text": strings.Join(lines, "\n")})
+}
+
+func lowBalanceMessage(signer common.Address, balance *big.Int, minBalance *big.Int) string {
+	return fmt.Sprintf("signer %s has %s ETH, below the minimum of %s ETH, submissions resume once it is topped up", signer, FormatEther(balance), FormatEther(minBalance))
+}
+
+func postJSON(ctx context.Context, url string, payload interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notification endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// newNotification describes the withdrawal, with the last confirmed transaction if there is one.
+func newNotification(l2TxHash common.Hash, withdrawalHash common.Hash, costs []TxCost) Notification {
+	n := Notification{L2TxHash: l2TxHash, WithdrawalHash: withdrawalHash}
+	if len(costs) > 0 {
+		n.L1TxHash = &costs[len(costs)-1].TxHash
+	}
+	return n
+}
+
+// notifyStep reports the outcome of a prove or finalize step, calling onSuccess (Notifier.OnProven or
+// Notifier.OnFinalized) if it succeeded. Dry runs send nothing, and neither do withdrawals that aren't finalizable
+// yet, as those are expected while waiting.
+func notifyStep(ctx context.Context, notifier Notifier, dryRun bool, n Notification, err error, onSuccess func(Notifier, context.Context, Notification) error) {
+	if notifier == nil || dryRun {
+		return
+	}
+	var notFinalizable *NotFinalizableError
+	if errors.As(err, &notFinalizable) {
+		return
+	}
+	var notifyErr error
+	if err != nil {
+		notifyErr = notifier.OnError(ctx, n, err)
+	} else {
+		notifyErr = onSuccess(notifier, ctx, n)
+	}
+	if notifyErr != nil {
+		log.Warn("Error sending notification", "error", notifyErr)
+	}
+}
+
+// notifyFinalizable reports that the withdrawal can be finalized.
+func notifyFinalizable(ctx context.Context, notifier Notifier, n Notification) {
+	if notifier == nil {
+		return
+	}
+	if err := notifier.OnFinalizable(ctx, n); err != nil {
+		log.Warn("Error sending notification", "error", err)
+	}
+}
+
+// notifyLowBalance reports that submission is paused until the signer is topped up.
+func notifyLowBalance(ctx context.Context, notifier Notifier, n Notification, signer common.Address, balance *big.Int, minBalance *big.Int) {
+	if notifier == nil {
+		return
+	}
+	if err := notifier.OnLowBalance(ctx, n, signer, balance, minBalance); err != nil {
+		log.Warn("Error sending notification", "error", err)
+	}
+}
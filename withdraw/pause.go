@@ -0,0 +1,60 @@
+package withdraw
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// pauseABI has the paused getter shared by the portal and the SuperchainConfig, and the portal's superchainConfig
+// getter.
+const pauseABI = `[
+	{"type":"function","name":"paused","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"superchainConfig","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"address"}]}
+]`
+
+var pauseParsedABI = mustParseABI(pauseABI)
+
+// ErrWithdrawalsPaused is returned when the guardian has paused withdrawals, so that prove and finalize transactions
+// would revert.
+var ErrWithdrawalsPaused = errors.New("withdrawals are paused")
+
+// CheckPaused returns ErrWithdrawalsPaused if withdrawals through the portal are paused. The portal reports the pause
+// of its SuperchainConfig, which is queried directly for portals without the getter. Portals with neither are never
+// paused.
+func CheckPaused(caller bind.ContractCaller, portal common.Address) error {
+	paused, err := queryPaused(caller, portal)
+	if err == nil {
+		if paused {
+			return fmt.Errorf("%w: OptimismPortal %s is paused by the guardian, prove and finalize transactions would revert until it is unpaused", ErrWithdrawalsPaused, portal)
+		}
+		return nil
+	}
+	log.Debug("Unable to query whether the portal is paused, querying its SuperchainConfig", "portal", portal, "error", err)
+
+	var out []interface{}
+	if err := bind.NewBoundContract(portal, pauseParsedABI, caller, nil, nil).Call(&bind.CallOpts{}, &out, "superchainConfig"); err != nil {
+		log.Debug("Portal has no pause or SuperchainConfig, assuming it can't be paused", "portal", portal, "error", err)
+		return nil
+	}
+	superchainConfig := out[0].(common.Address)
+	paused, err = queryPaused(caller, superchainConfig)
+	if err != nil {
+		return fmt.Errorf("error querying whether SuperchainConfig %s is paused: %w", superchainConfig, err)
+	}
+	if paused {
+		return fmt.Errorf("%w: SuperchainConfig %s is paused by the guardian, prove and finalize transactions would revert until it is unpaused", ErrWithdrawalsPaused, superchainConfig)
+	}
+	return nil
+}
+
+func queryPaused(caller bind.ContractCaller, address common.Address) (bool, error) {
+	var out []interface{}
+	if err := bind.NewBoundContract(address, pauseParsedABI, caller, nil, nil).Call(&bind.CallOpts{}, &out, "paused"); err != nil {
+		return false, err
+	}
+	return out[0].(bool), nil
+}
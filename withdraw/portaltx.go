@@ -0,0 +1,107 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// portalTxSender sends the prove and finalize transactions of a withdrawer to the portal, with the withdrawer's
+// submission and confirmation settings.
+type portalTxSender struct {
+	l1              *ethclient.Client
+	l2TxHash        common.Hash
+	opts            *bind.TransactOpts
+	baseFeeMax      *big.Int
+	minBalance      *big.Int
+	notifier        Notifier
+	notification    Notification
+	proveTimeout    time.Duration
+	finalizeTimeout time.Duration
+	confirmations   uint64
+	waitFinalized   bool
+	faults          Faults
+	timing          *Timing
+}
+
+// sendPortalTx sends the portal transaction of the action made by call, once the L1 base fee and the signer's balance
+// allow it, and waits for it to be confirmed, up to the action's timeout. It returns the gas the transaction spent.
+func (s *portalTxSender) sendPortalTx(ctx context.Context, action Action, call func(*bind.TransactOpts) (*types.Transaction, error)) (TxCost, error) {
+	if err := waitForBaseFee(ctx, s.l1, s.baseFeeMax, s.timing); err != nil {
+		return TxCost{}, err
+	}
+	if err := waitForBalance(ctx, s.l1, s.opts.From, s.minBalance, s.notifier, s.notification, s.timing); err != nil {
+		return TxCost{}, err
+	}
+
+	if err := s.faults.injectFault(FaultRevert, "execution reverted"); err != nil {
+		return TxCost{}, err
+	}
+
+	tx, err := call(s.opts)
+	if err != nil {
+		return TxCost{}, err
+	}
+
+	timeout := s.finalizeTimeout
+	if action == ActionProve {
+		timeout = s.proveTimeout
+		log.Info("Proved withdrawal", "l2TxHash", s.l2TxHash, "l1TxHash", tx.Hash())
+	} else {
+		log.Info("Completed withdrawal", "l2TxHash", s.l2TxHash, "l1TxHash", tx.Hash())
+	}
+
+	// Wait for confirmation, up to the configured timeout
+	ctxWithTimeout, cancel := s.timing.withTimeout(ctx, txTimeout(timeout))
+	defer cancel()
+	l1Receipt, err := waitForConfirmation(ctxWithTimeout, s.l1, tx.Hash(), s.confirmations, s.waitFinalized, s.faults, s.timing)
+	if err != nil {
+		return TxCost{}, fmt.Errorf("%s tx %s was submitted but not confirmed: %w", action, tx.Hash(), err)
+	}
+	return newTxCost(string(action), l1Receipt), nil
+}
+
+// portalTxs returns the sender of the withdrawer's portal transactions.
+func (w *Withdrawer) portalTxs() *portalTxSender {
+	return &portalTxSender{
+		l1:              w.L1Client,
+		l2TxHash:        w.L2TxHash,
+		opts:            w.Opts,
+		baseFeeMax:      w.BaseFeeMax,
+		minBalance:      w.MinBalance,
+		notifier:        w.Notifier,
+		notification:    w.notification(),
+		proveTimeout:    w.ProveTimeout,
+		finalizeTimeout: w.FinalizeTimeout,
+		confirmations:   w.Confirmations,
+		waitFinalized:   w.WaitFinalized,
+		faults:          w.Faults,
+		timing:          w.Timing,
+	}
+}
+
+// portalTxs returns the sender of the withdrawer's portal transactions.
+func (w *FPWithdrawer) portalTxs() *portalTxSender {
+	return &portalTxSender{
+		l1:              w.L1Client,
+		l2TxHash:        w.L2TxHash,
+		opts:            w.Opts,
+		baseFeeMax:      w.BaseFeeMax,
+		minBalance:      w.MinBalance,
+		notifier:        w.Notifier,
+		notification:    w.notification(),
+		proveTimeout:    w.ProveTimeout,
+		finalizeTimeout: w.FinalizeTimeout,
+		confirmations:   w.Confirmations,
+		waitFinalized:   w.WaitFinalized,
+		faults:          w.Faults,
+		timing:          w.Timing,
+	}
+}
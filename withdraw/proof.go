@@ -0,0 +1,181 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ProofInputs holds the chain data needed to assemble the parameters for proveWithdrawalTransaction.
+type ProofInputs struct {
+	Receipt     *types.Receipt            // L2 receipt of the withdrawal tx
+	Header      *types.Header             // L2 header of the block the output root was proposed for
+	Proof       *gethclient.AccountResult // eth_getProof response for the withdrawal's L2ToL1MessagePasser storage slot at Header
+	OutputIndex *big.Int                  // Dispute game index for fault proofs, or L2 output index for the L2OutputOracle
+}
+
+// ProofMetadata describes the output root a withdrawal is proven against, so the game (or L2 output) can be
+// monitored independently.
+type ProofMetadata struct {
+	GameIndex   *big.Int       `json:"gameIndex"`             // Dispute game index, or L2 output index for the L2OutputOracle
+	GameAddress common.Address `json:"gameAddress,omitempty"` // Dispute game proxy address (fault proofs only)
+	GameType    uint32         `json:"gameType"`              // Dispute game type (fault proofs only)
+	L2Block     *big.Int       `json:"l2Block"`               // L2 block the output root was proposed for
+	OutputRoot  common.Hash    `json:"outputRoot"`            // Proposed output root
+}
+
+// logFields returns the metadata as key/value pairs for logging.
+func (m *ProofMetadata) logFields() []interface{} {
+	fields := []interface{}{"gameIndex", m.GameIndex}
+	if m.GameAddress != (common.Address{}) {
+		fields = append(fields, "gameAddress", m.GameAddress, "gameType", m.GameType)
+	}
+	return append(fields, "l2Block", m.L2Block, "outputRoot", m.OutputRoot)
+}
+
+// BuildProofParameters assembles and verifies the withdrawal proof parameters from already-fetched chain data.
+// It makes no RPC calls, so it can be driven by fixtures and reused by callers that fetch the data themselves.
+func BuildProofParameters(in ProofInputs) (withdrawals.ProvenWithdrawalParameters, error) {
+	if in.Receipt == nil || in.Header == nil || in.Proof == nil || in.OutputIndex == nil {
+		return withdrawals.ProvenWithdrawalParameters{}, errors.New("incomplete proof inputs")
+	}
+
+	ev, err := withdrawals.ParseMessagePassed(in.Receipt)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	return buildProofParametersForEvent(ev, in.Header, in.Proof, in.OutputIndex)
+}
+
+func buildProofParametersForEvent(ev *bindings.L2ToL1MessagePasserMessagePassed, header *types.Header, proof *gethclient.AccountResult, outputIndex *big.Int) (withdrawals.ProvenWithdrawalParameters, error) {
+	hash, err := withdrawals.WithdrawalHash(ev)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+	if hash != ev.WithdrawalHash {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("computed withdrawal hash %s does not match event withdrawal hash %s", hash, ev.WithdrawalHash)
+	}
+
+	if proof.Address != predeploys.L2ToL1MessagePasserAddr {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("proof is for %s, not the L2ToL1MessagePasser", proof.Address)
+	}
+	if len(proof.StorageProof) != 1 {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("expected 1 storage proof, got %d", len(proof.StorageProof))
+	}
+	slot := withdrawals.StorageSlotOfWithdrawalHash(hash)
+	if common.HexToHash(proof.StorageProof[0].Key) != slot {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("storage proof is for slot %s, expected %s", proof.StorageProof[0].Key, slot)
+	}
+	if err := withdrawals.VerifyProof(header.Root, proof); err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("invalid withdrawal proof at L2 block %d: %w", header.Number.Uint64(), err)
+	}
+
+	// Encode the trie nodes as expected by the contract
+	trieNodes := make([][]byte, len(proof.StorageProof[0].Proof))
+	for i, s := range proof.StorageProof[0].Proof {
+		trieNodes[i] = common.FromHex(s)
+	}
+
+	return withdrawals.ProvenWithdrawalParameters{
+		Nonce:         ev.Nonce,
+		Sender:        ev.Sender,
+		Target:        ev.Target,
+		Value:         ev.Value,
+		GasLimit:      ev.GasLimit,
+		L2OutputIndex: outputIndex,
+		Data:          ev.Data,
+		OutputRootProof: bindings.TypesOutputRootProof{
+			Version:                  [32]byte{}, // Empty for version 1
+			StateRoot:                header.Root,
+			MessagePasserStorageRoot: proof.StorageHash,
+			LatestBlockhash:          header.Hash(),
+		},
+		WithdrawalProof: trieNodes,
+	}, nil
+}
+
+// ErrOutputRootMismatch is returned when the output root proposed on L1 differs from the one recomputed from the L2
+// chain. Either the L2 RPC is out of sync or serving a different chain, or the proposal is invalid, and proving
+// against it would leave the withdrawal stranded once the proposal is disproven.
+var ErrOutputRootMismatch = errors.New("output root mismatch")
+
+// verifyOutputRoot checks that the output root proof hashes to the output root proposed on L1, so a proof that the
+// portal would reject, or against an invalid proposal, is never submitted.
+func verifyOutputRoot(proof bindings.TypesOutputRootProof, proposed common.Hash) error {
+	computed := crypto.Keccak256Hash(proof.Version[:], proof.StateRoot[:], proof.MessagePasserStorageRoot[:], proof.LatestBlockhash[:])
+	if computed != proposed {
+		return fmt.Errorf("%w: keccak(version %s, stateRoot %s, messagePasserStorageRoot %s, latestBlockhash %s) = %s, but the proposed output root is %s - the L2 RPC may be out of sync or serving a different chain, or the proposal is invalid",
+			ErrOutputRootMismatch, common.Hash(proof.Version), common.Hash(proof.StateRoot), common.Hash(proof.MessagePasserStorageRoot), common.Hash(proof.LatestBlockhash), computed, proposed)
+	}
+	return nil
+}
+
+// OutputRootAt recomputes the output root of the L2 block from the L2 chain: the block's state root and hash, and the
+// L2ToL1MessagePasser storage root, which is verified against the state root.
+func OutputRootAt(ctx context.Context, l2 *rpc.Client, l2BlockNumber *big.Int) (common.Hash, error) {
+	header, err := ethclient.NewClient(l2).HeaderByNumber(ctx, l2BlockNumber)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error querying L2 block %s: %w", l2BlockNumber, err)
+	}
+	proof, err := gethclient.New(l2).GetProof(ctx, predeploys.L2ToL1MessagePasserAddr, nil, header.Number)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error fetching L2ToL1MessagePasser proof at L2 block %s: %w", header.Number, err)
+	}
+	if err := withdrawals.VerifyProof(header.Root, proof); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid L2ToL1MessagePasser proof at L2 block %s: %w", header.Number, err)
+	}
+	return crypto.Keccak256Hash(common.Hash{}.Bytes(), header.Root[:], proof.StorageHash[:], header.Hash().Bytes()), nil
+}
+
+// VerifyGame checks the root claim of the dispute game like VerifyProposal. Super root games claim a super root
+// instead, which can't be checked this way.
+func VerifyGame(ctx context.Context, l2 *rpc.Client, game bindings.IDisputeGameFactoryGameSearchResult) error {
+	return VerifyProposal(ctx, l2, gameL2BlockNumber(game), game.RootClaim)
+}
+
+// VerifyProposal checks that the output root proposed for the L2 block matches the one recomputed from the L2 chain,
+// returning an error wrapping ErrOutputRootMismatch if it doesn't.
+func VerifyProposal(ctx context.Context, l2 *rpc.Client, l2BlockNumber *big.Int, proposed common.Hash) error {
+	computed, err := OutputRootAt(ctx, l2, l2BlockNumber)
+	if err != nil {
+		return err
+	}
+	if computed != proposed {
+		return fmt.Errorf("%w: the output root of L2 block %s is %s, but the proposed output root is %s - the L2 RPC may be out of sync or serving a different chain, or the proposal is invalid",
+			ErrOutputRootMismatch, l2BlockNumber, computed, proposed)
+	}
+	return nil
+}
+
+// proveWithdrawalParameters fetches the storage proof for the withdrawal in receipt at the given L2 header,
+// then assembles the proof parameters with BuildProofParameters.
+func proveWithdrawalParameters(ctx context.Context, proofCl withdrawals.ProofClient, receipt *types.Receipt, header *types.Header, outputIndex *big.Int) (withdrawals.ProvenWithdrawalParameters, error) {
+	ev, err := withdrawals.ParseMessagePassed(receipt)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, err
+	}
+
+	slot := withdrawals.StorageSlotOfWithdrawalHash(ev.WithdrawalHash)
+	proof, err := proofCl.GetProof(ctx, predeploys.L2ToL1MessagePasserAddr, []string{slot.String()}, header.Number)
+	if err != nil {
+		return withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("error fetching withdrawal proof: %w", err)
+	}
+
+	return BuildProofParameters(ProofInputs{
+		Receipt:     receipt,
+		Header:      header,
+		Proof:       proof,
+		OutputIndex: outputIndex,
+	})
+}
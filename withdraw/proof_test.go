@@ -0,0 +1,507 @@
+package withdraw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/triedb"
+	"github.com/holiman/uint256"
+)
+
+// The proof fixtures in testdata/proofs hold the withdrawal tx receipt, the proposed L2 block's header and the
+// eth_getProof response for the withdrawal's storage slot, in the JSON the L2 RPC returns them in, along with the
+// proposal proven against and, for super root games, the supervisor_superRootAtTimestamp response. The state they
+// prove is built from go-ethereum tries by the generator below; run the tests with -update to regenerate them.
+var updateFixtures = flag.Bool("update", false, "regenerate the proof fixtures in testdata/proofs")
+
+// proofFixture is a proof fixture, as stored in testdata/proofs.
+type proofFixture struct {
+	Receipt        json.RawMessage        `json:"receipt"`     // eth_getTransactionReceipt
+	Header         json.RawMessage        `json:"header"`      // eth_getBlockByNumber of the proposed L2 block
+	Proof          json.RawMessage        `json:"proof"`       // eth_getProof of the L2ToL1MessagePasser at the proposed block
+	OutputIndex    *hexutil.Big           `json:"outputIndex"` // L2 output index, or dispute game index
+	RootClaim      common.Hash            `json:"rootClaim"`   // Output root proposed to the L2OutputOracle, or the game's root claim
+	ChainID        *hexutil.Big           `json:"chainId,omitempty"`
+	SuperRoot      *eth.SuperRootResponse `json:"superRoot,omitempty"` // supervisor_superRootAtTimestamp, for super root games
+	WithdrawalHash common.Hash            `json:"withdrawalHash"`
+	OutputRoot     common.Hash            `json:"outputRoot"` // Output root of the proposed block the proof hashes to
+}
+
+// rpcAccountResult is an eth_getProof response, as the L2 RPC encodes it.
+type rpcAccountResult struct {
+	Address      common.Address    `json:"address"`
+	AccountProof []string          `json:"accountProof"`
+	Balance      *hexutil.Big      `json:"balance"`
+	CodeHash     common.Hash       `json:"codeHash"`
+	Nonce        hexutil.Uint64    `json:"nonce"`
+	StorageHash  common.Hash       `json:"storageHash"`
+	StorageProof []rpcStorageProof `json:"storageProof"`
+}
+
+type rpcStorageProof struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// proofCase is a fixture decoded into the inputs of proof assembly.
+type proofCase struct {
+	in        ProofInputs
+	rootClaim common.Hash
+	chainID   *big.Int
+	superRoot *eth.SuperRootResponse
+}
+
+func loadProofCase(t *testing.T, name string) (proofCase, proofFixture) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "proofs", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f proofFixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+	var receipt types.Receipt
+	if err := json.Unmarshal(f.Receipt, &receipt); err != nil {
+		t.Fatalf("decoding %s receipt: %v", name, err)
+	}
+	var header types.Header
+	if err := json.Unmarshal(f.Header, &header); err != nil {
+		t.Fatalf("decoding %s header: %v", name, err)
+	}
+	var res rpcAccountResult
+	if err := json.Unmarshal(f.Proof, &res); err != nil {
+		t.Fatalf("decoding %s proof: %v", name, err)
+	}
+	proof := &gethclient.AccountResult{
+		Address:      res.Address,
+		AccountProof: res.AccountProof,
+		Balance:      res.Balance.ToInt(),
+		CodeHash:     res.CodeHash,
+		Nonce:        uint64(res.Nonce),
+		StorageHash:  res.StorageHash,
+	}
+	for _, s := range res.StorageProof {
+		proof.StorageProof = append(proof.StorageProof, gethclient.StorageResult{Key: s.Key, Value: s.Value.ToInt(), Proof: s.Proof})
+	}
+	c := proofCase{
+		in:        ProofInputs{Receipt: &receipt, Header: &header, Proof: proof, OutputIndex: f.OutputIndex.ToInt()},
+		rootClaim: f.RootClaim,
+		superRoot: f.SuperRoot,
+	}
+	if f.ChainID != nil {
+		c.chainID = f.ChainID.ToInt()
+	}
+	return c, f
+}
+
+// assembleProof assembles the proof of the case as proving does: the proof parameters, checked to hash to the
+// proposed output root, or for super root games to the chain's output root in the super root the game claims.
+func assembleProof(c proofCase) (withdrawals.ProvenWithdrawalParameters, error) {
+	params, err := BuildProofParameters(c.in)
+	if err != nil {
+		return params, err
+	}
+	outputRoot := c.rootClaim
+	if c.superRoot != nil {
+		proof, index, err := BuildSuperRootProof(*c.superRoot, c.chainID, c.rootClaim)
+		if err != nil {
+			return params, err
+		}
+		outputRoot = proof.OutputRoots[index.Int64()].Root
+	}
+	return params, verifyOutputRoot(params.OutputRootProof, outputRoot)
+}
+
+func TestAssembleProof(t *testing.T) {
+	if *updateFixtures {
+		writeProofFixtures(t)
+	}
+
+	tests := []struct {
+		name    string
+		fixture string
+		mutate  func(c *proofCase)
+		wantErr error  // Error the assembly must wrap
+		wantMsg string // Text the assembly error must contain
+	}{
+		{name: "legacy output", fixture: "legacy.json"},
+		{name: "fault proof game", fixture: "faultproof.json"},
+		{name: "super root game", fixture: "superroot.json"},
+		{
+			name:    "legacy output root mismatch",
+			fixture: "legacy.json",
+			mutate:  func(c *proofCase) { c.rootClaim[0] ^= 0xff },
+			wantErr: ErrOutputRootMismatch,
+		},
+		{
+			name:    "fault proof root claim mismatch",
+			fixture: "faultproof.json",
+			mutate:  func(c *proofCase) { c.rootClaim = common.Hash{} },
+			wantErr: ErrOutputRootMismatch,
+		},
+		{
+			name:    "tampered storage proof",
+			fixture: "faultproof.json",
+			mutate: func(c *proofCase) {
+				nodes := c.in.Proof.StorageProof[0].Proof
+				nodes[len(nodes)-1] = hexutil.Encode(append(common.FromHex(nodes[len(nodes)-1]), 0))
+			},
+			wantMsg: "invalid withdrawal proof",
+		},
+		{
+			name:    "state root of another block",
+			fixture: "legacy.json",
+			mutate:  func(c *proofCase) { c.in.Header.Root = common.Hash{1} },
+			wantMsg: "invalid withdrawal proof",
+		},
+		{
+			name:    "proof of another slot",
+			fixture: "faultproof.json",
+			mutate:  func(c *proofCase) { c.in.Proof.StorageProof[0].Key = common.Hash{2}.Hex() },
+			wantMsg: "storage proof is for slot",
+		},
+		{
+			name:    "proof of another account",
+			fixture: "legacy.json",
+			mutate:  func(c *proofCase) { c.in.Proof.Address = common.Address{3} },
+			wantMsg: "not the L2ToL1MessagePasser",
+		},
+		{
+			name:    "receipt without withdrawal",
+			fixture: "legacy.json",
+			mutate:  func(c *proofCase) { c.in.Receipt.Logs = nil },
+			wantMsg: "unable to find MessagePassed event",
+		},
+		{
+			name:    "event with another withdrawal hash",
+			fixture: "superroot.json",
+			mutate:  func(c *proofCase) { c.in.Receipt.Logs[0].Data[127] ^= 1 },
+			wantMsg: "does not match event withdrawal hash",
+		},
+		{
+			name:    "super root claim mismatch",
+			fixture: "superroot.json",
+			mutate:  func(c *proofCase) { c.rootClaim[31] ^= 1 },
+			wantMsg: "super root mismatch",
+		},
+		{
+			name:    "chain outside the dependency set",
+			fixture: "superroot.json",
+			mutate:  func(c *proofCase) { c.chainID = big.NewInt(10) },
+			wantMsg: "not in the super root's dependency set",
+		},
+		{
+			name:    "super root with another chain's output root",
+			fixture: "superroot.json",
+			mutate:  func(c *proofCase) { c.chainID = c.superRoot.Chains[0].ChainID.ToBig() },
+			wantErr: ErrOutputRootMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, f := loadProofCase(t, tt.fixture)
+			if tt.mutate != nil {
+				tt.mutate(&c)
+			}
+			params, err := assembleProof(c)
+			if tt.mutate != nil {
+				switch {
+				case err == nil:
+					t.Fatal("expected an error, got none")
+				case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				case !strings.Contains(err.Error(), tt.wantMsg):
+					t.Fatalf("expected an error containing %q, got %v", tt.wantMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ev, err := withdrawals.ParseMessagePassed(c.in.Receipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ev.WithdrawalHash != f.WithdrawalHash {
+				t.Errorf("withdrawal hash %s, want %s", ev.WithdrawalHash, f.WithdrawalHash)
+			}
+			if params.Nonce.Cmp(ev.Nonce) != 0 || params.Sender != ev.Sender || params.Target != ev.Target ||
+				params.Value.Cmp(ev.Value) != 0 || params.GasLimit.Cmp(ev.GasLimit) != 0 || !bytes.Equal(params.Data, ev.Data) {
+				t.Errorf("withdrawal tx %+v does not match the MessagePassed event %+v", params, ev)
+			}
+			if params.L2OutputIndex.Cmp(f.OutputIndex.ToInt()) != 0 {
+				t.Errorf("output index %s, want %s", params.L2OutputIndex, f.OutputIndex)
+			}
+			proof := params.OutputRootProof
+			if proof.Version != [32]byte{} || proof.StateRoot != c.in.Header.Root || proof.LatestBlockhash != c.in.Header.Hash() ||
+				proof.MessagePasserStorageRoot != c.in.Proof.StorageHash {
+				t.Errorf("unexpected output root proof %+v", proof)
+			}
+			if computed := crypto.Keccak256Hash(proof.Version[:], proof.StateRoot[:], proof.MessagePasserStorageRoot[:], proof.LatestBlockhash[:]); computed != f.OutputRoot {
+				t.Errorf("output root %s, want %s", computed, f.OutputRoot)
+			}
+			if len(params.WithdrawalProof) != len(c.in.Proof.StorageProof[0].Proof) {
+				t.Errorf("withdrawal proof has %d nodes, want %d", len(params.WithdrawalProof), len(c.in.Proof.StorageProof[0].Proof))
+			}
+		})
+	}
+}
+
+// writeProofFixtures regenerates the proof fixtures: for each, an L2 state holding the withdrawal in the
+// L2ToL1MessagePasser among others, and the receipt, header, proof and proposals of it.
+func writeProofFixtures(t *testing.T) {
+	t.Helper()
+	dir := filepath.Join("testdata", "proofs")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	base := big.NewInt(8453)
+	fixtures := map[string]proofFixture{
+		"legacy.json":     fixtureAt(t, "legacy", 17_100_000, 17_102_400, big.NewInt(4_821), nil),
+		"faultproof.json": fixtureAt(t, "faultproof", 27_300_000, 27_301_800, big.NewInt(16_532), nil),
+		"superroot.json":  fixtureAt(t, "superroot", 31_000_000, 31_000_600, big.NewInt(2_047), base),
+	}
+	for name, f := range fixtures {
+		data, err := json.MarshalIndent(f, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// fixtureAt generates a fixture of a withdrawal initiated in withdrawalBlock and proven against a proposal of
+// proposedBlock at outputIndex. A chainID makes it a super root fixture, with the chain in a dependency set of two.
+func fixtureAt(t *testing.T, seed string, withdrawalBlock, proposedBlock uint64, outputIndex *big.Int, chainID *big.Int) proofFixture {
+	t.Helper()
+	rand := func(i int) common.Hash { return crypto.Keccak256Hash([]byte(seed), big.NewInt(int64(i)).Bytes()) }
+
+	// the withdrawal, sent through the messengers as bridge withdrawals are
+	ev := &bindings.L2ToL1MessagePasserMessagePassed{
+		Nonce:    new(big.Int).Or(new(big.Int).Lsh(big.NewInt(1), 240), big.NewInt(int64(withdrawalBlock%100_000))),
+		Sender:   predeploys.L2CrossDomainMessengerAddr,
+		Target:   common.HexToAddress("0x866E82a600A1414e583f7F13623F1aC5d58b0Afa"),
+		Value:    big.NewInt(0),
+		GasLimit: big.NewInt(287_624),
+		Data:     append(common.FromHex("0xd764ad0b"), rand(0).Bytes()...),
+	}
+	hash, err := withdrawals.WithdrawalHash(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev.WithdrawalHash = hash
+
+	// the L2ToL1MessagePasser's storage, with the withdrawal among earlier ones, and the state holding it
+	db := triedb.NewDatabase(rawdb.NewMemoryDatabase(), nil)
+	storage := trie.NewEmpty(db)
+	slot := withdrawals.StorageSlotOfWithdrawalHash(hash)
+	for i := 1; i <= 64; i++ {
+		key := withdrawals.StorageSlotOfWithdrawalHash(rand(i))
+		if err := storage.Update(crypto.Keccak256(key[:]), []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := storage.Update(crypto.Keccak256(slot[:]), []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	passer := types.StateAccount{
+		Nonce:    0,
+		Balance:  uint256.NewInt(0),
+		Root:     storage.Hash(),
+		CodeHash: crypto.Keccak256(rand(1000).Bytes()),
+	}
+	state := trie.NewEmpty(db)
+	for i := 1; i <= 64; i++ {
+		account, err := rlp.EncodeToBytes(&types.StateAccount{Nonce: uint64(i), Balance: uint256.NewInt(uint64(i) * 1e15), Root: types.EmptyRootHash, CodeHash: types.EmptyCodeHash[:]})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := state.Update(crypto.Keccak256(rand(2000 + i).Bytes()[:20]), account); err != nil {
+			t.Fatal(err)
+		}
+	}
+	account, err := rlp.EncodeToBytes(&passer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Update(crypto.Keccak256(predeploys.L2ToL1MessagePasserAddr[:]), account); err != nil {
+		t.Fatal(err)
+	}
+
+	var accountProof, storageProof proofList
+	if err := state.Prove(crypto.Keccak256(predeploys.L2ToL1MessagePasserAddr[:]), &accountProof); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Prove(crypto.Keccak256(slot[:]), &storageProof); err != nil {
+		t.Fatal(err)
+	}
+	proof := rpcAccountResult{
+		Address:      predeploys.L2ToL1MessagePasserAddr,
+		AccountProof: accountProof,
+		Balance:      (*hexutil.Big)(passer.Balance.ToBig()),
+		CodeHash:     common.BytesToHash(passer.CodeHash),
+		Nonce:        hexutil.Uint64(passer.Nonce),
+		StorageHash:  passer.Root,
+		StorageProof: []rpcStorageProof{{Key: slot.Hex(), Value: (*hexutil.Big)(big.NewInt(1)), Proof: storageProof}},
+	}
+
+	header := &types.Header{
+		ParentHash:  rand(3000),
+		UncleHash:   types.EmptyUncleHash,
+		Coinbase:    predeploys.SequencerFeeVaultAddr,
+		Root:        state.Hash(),
+		TxHash:      rand(3001),
+		ReceiptHash: rand(3002),
+		Difficulty:  big.NewInt(0),
+		Number:      new(big.Int).SetUint64(proposedBlock),
+		GasLimit:    240_000_000,
+		GasUsed:     41_734_122,
+		Time:        1_686_789_347 + 2*proposedBlock,
+		Extra:       []byte{},
+		BaseFee:     big.NewInt(4_209_531),
+	}
+
+	abi, err := bindings.L2ToL1MessagePasserMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := abi.Events["MessagePassed"]
+	logData, err := event.Inputs.NonIndexed().Pack(ev.Value, ev.GasLimit, ev.Data, ev.WithdrawalHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txHash, blockHash := rand(4000), rand(4001)
+	receipt := &types.Receipt{
+		Type:              types.DynamicFeeTxType,
+		Status:            types.ReceiptStatusSuccessful,
+		CumulativeGasUsed: 9_380_211,
+		Logs: []*types.Log{{
+			Address:     predeploys.L2ToL1MessagePasserAddr,
+			Topics:      []common.Hash{event.ID, common.BigToHash(ev.Nonce), common.BytesToHash(ev.Sender[:]), common.BytesToHash(ev.Target[:])},
+			Data:        logData,
+			BlockNumber: withdrawalBlock,
+			TxHash:      txHash,
+			TxIndex:     7,
+			BlockHash:   blockHash,
+			Index:       31,
+		}},
+		TxHash:           txHash,
+		GasUsed:          184_216,
+		BlockHash:        blockHash,
+		BlockNumber:      new(big.Int).SetUint64(withdrawalBlock),
+		TransactionIndex: 7,
+	}
+	receipt.Bloom = types.CreateBloom(receipt)
+
+	outputRoot := crypto.Keccak256Hash(make([]byte, 32), header.Root[:], passer.Root[:], header.Hash().Bytes())
+	f := proofFixture{
+		OutputIndex:    (*hexutil.Big)(outputIndex),
+		RootClaim:      outputRoot,
+		WithdrawalHash: hash,
+		OutputRoot:     outputRoot,
+	}
+	if chainID != nil {
+		super := eth.NewSuperV1(header.Time,
+			eth.ChainIDAndOutput{ChainID: eth.ChainIDFromUInt64(7777), Output: eth.Bytes32(rand(5000))},
+			eth.ChainIDAndOutput{ChainID: eth.ChainIDFromBig(chainID), Output: eth.Bytes32(outputRoot)})
+		f.RootClaim = common.Hash(eth.SuperRoot(super))
+		f.ChainID = (*hexutil.Big)(chainID)
+		f.SuperRoot = &eth.SuperRootResponse{
+			CrossSafeDerivedFrom: eth.BlockID{Hash: rand(6000), Number: 22_400_118},
+			Timestamp:            header.Time,
+			SuperRoot:            eth.SuperRoot(super),
+			Version:              eth.SuperRootVersionV1,
+		}
+		for _, chain := range super.Chains {
+			f.SuperRoot.Chains = append(f.SuperRoot.Chains, eth.ChainRootInfo{ChainID: chain.ChainID, Canonical: chain.Output})
+		}
+	}
+	if f.Receipt, err = json.Marshal(receipt); err != nil {
+		t.Fatal(err)
+	}
+	if f.Header, err = json.Marshal(header); err != nil {
+		t.Fatal(err)
+	}
+	if f.Proof, err = json.Marshal(proof); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// proofList collects the trie nodes of a proof in order from the root, as eth_getProof returns them.
+type proofList []string
+
+func (l *proofList) Put(_ []byte, value []byte) error {
+	*l = append(*l, hexutil.Encode(value))
+	return nil
+}
+
+func (l *proofList) Delete([]byte) error {
+	panic("not supported")
+}
+
+// fixedProofSource is a ProofSource serving a fixed header and proof.
+type fixedProofSource struct {
+	name   string
+	header *types.Header
+	proof  *gethclient.AccountResult
+}
+
+func (s *fixedProofSource) Name() string { return s.name }
+
+func (s *fixedProofSource) HeaderByNumber(context.Context, *big.Int) (*types.Header, error) {
+	return s.header, nil
+}
+
+func (s *fixedProofSource) GetProof(context.Context, common.Address, []string, *big.Int) (*gethclient.AccountResult, error) {
+	return s.proof, nil
+}
+
+// TestFetchProofParametersVerified moves on from a source serving a well-formed proof of the wrong block, e.g. a
+// reorged one, to the next source.
+func TestFetchProofParametersVerified(t *testing.T) {
+	c, f := loadProofCase(t, "faultproof.json")
+	reorged := types.CopyHeader(c.in.Header)
+	reorged.Extra = []byte("reorged")
+	sources := []ProofSource{
+		&fixedProofSource{name: "reorged", header: reorged, proof: c.in.Proof},
+		&fixedProofSource{name: "canonical", header: c.in.Header, proof: c.in.Proof},
+	}
+	verify := outputRootVerifier(context.Background(), f.OutputRoot, nil, c.in.Receipt.TxHash, nil, c.in.OutputIndex)
+
+	if _, _, err := fetchProofParametersFrom(context.Background(), sources[0], c.in.Receipt, c.in.Header.Number, c.in.OutputIndex, verify); !errors.Is(err, ErrOutputRootMismatch) {
+		t.Fatalf("proof of the reorged block fetched with error %v, want %v", err, ErrOutputRootMismatch)
+	}
+	header, params, err := fetchProofParameters(context.Background(), sources, nil, c.in.Receipt, c.in.Header.Number, c.in.OutputIndex, verify)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.Hash() != c.in.Header.Hash() || common.Hash(params.OutputRootProof.LatestBlockhash) != c.in.Header.Hash() {
+		t.Errorf("fetched the proof of block %s, want %s", header.Hash(), c.in.Header.Hash())
+	}
+}
@@ -0,0 +1,97 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ProofSource serves the L2 header and L2ToL1MessagePasser storage proof a withdrawal is proven with. Both are
+// verified against each other and against the output root proposed on L1 before proving, so a source doesn't need to
+// be trusted, e.g. a light client instead of an archive RPC provider: a source whose proof fails verification is
+// skipped like one that fails to serve it.
+type ProofSource interface {
+	Name() string
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error)
+}
+
+// RPCProofSource is a ProofSource serving the standard eth_getBlockByNumber and eth_getProof JSON-RPC methods.
+type RPCProofSource struct {
+	name string
+	*ethclient.Client
+	proofs *gethclient.Client
+}
+
+// NewRPCProofSource returns the ProofSource of the L2 JSON-RPC client, named for logging.
+func NewRPCProofSource(name string, client *rpc.Client) *RPCProofSource {
+	return &RPCProofSource{name: name, Client: ethclient.NewClient(client), proofs: gethclient.New(client)}
+}
+
+func (s *RPCProofSource) Name() string {
+	return s.name
+}
+
+func (s *RPCProofSource) GetProof(ctx context.Context, account common.Address, keys []string, blockNumber *big.Int) (*gethclient.AccountResult, error) {
+	return s.proofs.GetProof(ctx, account, keys, blockNumber)
+}
+
+// proofVerifier checks the proof parameters fetched from a source at header's block, e.g. against the output root
+// proposed on L1.
+type proofVerifier func(header *types.Header, params withdrawals.ProvenWithdrawalParameters) error
+
+// outputRootVerifier returns the proofVerifier checking the output root proof against outputRoot, and cross-checking
+// the proof parameters against the verification L2 RPC if set.
+func outputRootVerifier(ctx context.Context, outputRoot common.Hash, verify *rpc.Client, l2TxHash common.Hash, logIndex *uint, outputIndex *big.Int) proofVerifier {
+	return func(header *types.Header, params withdrawals.ProvenWithdrawalParameters) error {
+		if err := verifyOutputRoot(params.OutputRootProof, outputRoot); err != nil {
+			return err
+		}
+		if verify == nil {
+			return nil
+		}
+		return crossCheckProofParameters(ctx, verify, l2TxHash, logIndex, header, outputIndex, params)
+	}
+}
+
+// fetchProofParameters fetches the header of the L2 block and the withdrawal's storage proof at it from the first of
+// the sources that serves them and whose proof verify accepts, falling back to the L2 RPC, and assembles the proof
+// parameters. A source serving a wrong header or proof, e.g. one that is stale or on a reorged chain, is skipped like
+// one that fails to serve them.
+func fetchProofParameters(ctx context.Context, sources []ProofSource, l2 *rpc.Client, receipt *types.Receipt, l2BlockNumber *big.Int, outputIndex *big.Int, verify proofVerifier) (*types.Header, withdrawals.ProvenWithdrawalParameters, error) {
+	for _, source := range sources {
+		header, params, err := fetchProofParametersFrom(ctx, source, receipt, l2BlockNumber, outputIndex, verify)
+		if err == nil {
+			log.Info("Fetched withdrawal proof", "source", source.Name(), "l2Block", header.Number)
+			return header, params, nil
+		}
+		log.Warn("Unable to fetch a valid withdrawal proof, trying the next source", "source", source.Name(), "error", err)
+	}
+	return fetchProofParametersFrom(ctx, NewRPCProofSource("L2 RPC", l2), receipt, l2BlockNumber, outputIndex, verify)
+}
+
+func fetchProofParametersFrom(ctx context.Context, source ProofSource, receipt *types.Receipt, l2BlockNumber *big.Int, outputIndex *big.Int, verify proofVerifier) (*types.Header, withdrawals.ProvenWithdrawalParameters, error) {
+	header, err := source.HeaderByNumber(ctx, l2BlockNumber)
+	if err != nil {
+		return nil, withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("failed to get l2Block %s from %s: %w", l2BlockNumber, source.Name(), err)
+	}
+	if header.Number.Cmp(l2BlockNumber) != 0 {
+		return nil, withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("%s returned L2 block %s instead of %s", source.Name(), header.Number, l2BlockNumber)
+	}
+	params, err := proveWithdrawalParameters(ctx, source, receipt, header, outputIndex)
+	if err != nil {
+		return nil, withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("%s: %w", source.Name(), err)
+	}
+	if err := verify(header, params); err != nil {
+		return nil, withdrawals.ProvenWithdrawalParameters{}, fmt.Errorf("%s: %w", source.Name(), err)
+	}
+	return header, params, nil
+}
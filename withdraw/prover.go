@@ -0,0 +1,138 @@
+package withdraw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// proverTimeout bounds how long to wait for a prover service to accept a request.
+const proverTimeout = 30 * time.Second
+
+// withdrawalProvenTopic is the topic of the WithdrawalProven event, which has the same signature on the legacy and
+// fault proof portals.
+var withdrawalProvenTopic = crypto.Keccak256Hash([]byte("WithdrawalProven(bytes32,address,address)"))
+
+// ErrProofMismatch is returned when the transaction submitted by a prover service did not prove the withdrawal.
+var ErrProofMismatch = errors.New("delegated proof does not match the withdrawal")
+
+// ProveRequest asks a prover service to prove a withdrawal.
+type ProveRequest struct {
+	L2ChainID      *big.Int       `json:"l2ChainId"`
+	L2TxHash       common.Hash    `json:"l2TxHash"`
+	WithdrawalHash common.Hash    `json:"withdrawalHash"`
+	Portal         common.Address `json:"portal"`
+}
+
+// Prover submits prove transactions on the withdrawer's behalf, so the gas-heavy prove step isn't paid by the signer.
+type Prover interface {
+	// Prove asks the prover to prove the withdrawal, returning the hash of the L1 prove transaction it submitted.
+	Prove(ctx context.Context, req ProveRequest) (common.Hash, error)
+}
+
+// HTTPProver is a Prover backed by an HTTP service. It POSTs the ProveRequest as JSON to URL, and expects a JSON
+// response of the form {"txHash": "0x..."}.
+type HTTPProver struct {
+	URL string
+}
+
+func (p *HTTPProver) Prove(ctx context.Context, req ProveRequest) (common.Hash, error) {
+	ctx, cancel := context.WithTimeout(ctx, proverTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return common.Hash{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("error querying prover service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return common.Hash{}, fmt.Errorf("prover service returned %s", resp.Status)
+	}
+
+	var out struct {
+		TxHash *common.Hash `json:"txHash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return common.Hash{}, fmt.Errorf("error decoding prover service response: %w", err)
+	}
+	if out.TxHash == nil {
+		return common.Hash{}, errors.New("prover service response has no txHash")
+	}
+	return *out.TxHash, nil
+}
+
+// delegateProof asks the prover to prove the withdrawal and waits for the prove tx it submitted to be confirmed. It
+// checks that the tx emitted a WithdrawalProven event for the withdrawal from the portal, and returns the confirmed
+// receipt and the address whose proof the portal stored, as named by its WithdrawalProvenExtension1 event. That is
+// the portal call's sender, which differs from the tx sender when the prover proves through a contract. Legacy
+// portals store proofs without a submitter and emit no such event, so the returned address is zero on them.
+func delegateProof(ctx context.Context, prover Prover, l1 *ethclient.Client, l2 *rpc.Client, l2TxHash common.Hash, withdrawalHash common.Hash, portal common.Address, timeout time.Duration, confirmations uint64, waitFinalized bool, faults Faults, timing *Timing) (*types.Receipt, common.Address, error) {
+	l2ChainID, err := ethclient.NewClient(l2).ChainID(ctx)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("error querying L2 chain ID: %w", err)
+	}
+	txHash, err := prover.Prove(ctx, ProveRequest{
+		L2ChainID:      l2ChainID,
+		L2TxHash:       l2TxHash,
+		WithdrawalHash: withdrawalHash,
+		Portal:         portal,
+	})
+	if err != nil {
+		return nil, common.Address{}, err
+	}
+	log.Info("Prover service submitted prove tx", "l2TxHash", l2TxHash, "l1TxHash", txHash)
+
+	ctxWithTimeout, cancel := timing.withTimeout(ctx, txTimeout(timeout))
+	defer cancel()
+	receipt, err := waitForConfirmation(ctxWithTimeout, l1, txHash, confirmations, waitFinalized, faults, timing)
+	if err != nil {
+		return nil, common.Address{}, fmt.Errorf("prove tx %s was submitted by the prover service but not confirmed: %w", txHash, err)
+	}
+	proven, submitter := provenBy(receipt, portal, withdrawalHash)
+	if !proven {
+		return nil, common.Address{}, fmt.Errorf("%w: prove tx %s did not prove withdrawal %s on portal %s", ErrProofMismatch, txHash, withdrawalHash, portal)
+	}
+	return receipt, submitter, nil
+}
+
+// provenBy reports whether the receipt contains a WithdrawalProven event for the withdrawal from the portal, and
+// returns the proof submitter of its WithdrawalProvenExtension1 event, or the zero address if it has none.
+func provenBy(receipt *types.Receipt, portal common.Address, withdrawalHash common.Hash) (bool, common.Address) {
+	proven, submitter := false, common.Address{}
+	for _, l := range receipt.Logs {
+		if l.Address != portal || len(l.Topics) < 2 || l.Topics[1] != withdrawalHash {
+			continue
+		}
+		switch l.Topics[0] {
+		case withdrawalProvenTopic:
+			proven = true
+		case withdrawalProvenExtension1Topic:
+			if len(l.Topics) == 3 {
+				submitter = common.BytesToAddress(l.Topics[2].Bytes())
+			}
+		}
+	}
+	return proven, submitter
+}
@@ -0,0 +1,46 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// eip1967ImplementationSlot is bytes32(uint256(keccak256("eip1967.proxy.implementation")) - 1).
+var eip1967ImplementationSlot = common.HexToHash("0x360894a13ba1a3210667c828492db98dca3e2076cc3735a920a3ca505d382bbc")
+
+var semverParsedABI = mustParseABI(`[{"type":"function","name":"version","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]}]`)
+
+// ProxyInfo describes the implementation behind a contract address.
+type ProxyInfo struct {
+	Address        common.Address
+	Implementation common.Address // EIP-1967 implementation, or the zero address if the contract isn't a proxy
+	Version        string         // Semver version reported by the contract, or empty if it has none
+}
+
+// IsProxy reports whether the contract is an EIP-1967 proxy.
+func (p *ProxyInfo) IsProxy() bool {
+	return p.Implementation != (common.Address{})
+}
+
+// InspectProxy reads the EIP-1967 implementation slot of the contract at address, and the version it reports.
+func InspectProxy(ctx context.Context, client *ethclient.Client, address common.Address) (*ProxyInfo, error) {
+	slot, err := client.StorageAt(ctx, address, eip1967ImplementationSlot, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading implementation slot of %s: %w", address, err)
+	}
+	info := &ProxyInfo{
+		Address:        address,
+		Implementation: common.BytesToAddress(slot),
+	}
+
+	var out []interface{}
+	contract := bind.NewBoundContract(address, semverParsedABI, client, nil, nil)
+	if err := contract.Call(&bind.CallOpts{Context: ctx}, &out, "version"); err == nil {
+		info.Version = out[0].(string)
+	}
+	return info, nil
+}
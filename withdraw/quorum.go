@@ -0,0 +1,79 @@
+package withdraw
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ErrQuorumNotReached is returned when not enough L1 providers agree on a critical value.
+var ErrQuorumNotReached = errors.New("L1 providers did not reach quorum")
+
+// Quorum requires critical L1 state (finalized status, proven timestamp, proposed output root) to be read from
+// several providers, and a minimum number of them to agree, guarding against a single compromised RPC lying about
+// state. The primary L1 client always counts as one of the providers.
+type Quorum struct {
+	Clients  []*ethclient.Client // Additional L1 providers, besides the primary one
+	Required int                 // Number of providers that must agree, including the primary one
+}
+
+// ParseQuorum parses a quorum spec of the form "M/N", meaning that M of N providers must agree.
+func ParseQuorum(spec string) (required int, total int, err error) {
+	m, n, ok := strings.Cut(spec, "/")
+	if ok {
+		required, err = strconv.Atoi(m)
+	}
+	if ok && err == nil {
+		total, err = strconv.Atoi(n)
+	}
+	if !ok || err != nil || required < 1 || required > total {
+		return 0, 0, fmt.Errorf("invalid quorum %q, expected M/N with 1 <= M <= N", spec)
+	}
+	return required, total, nil
+}
+
+// quorumRead reads a value with read from the primary client and, if a quorum is configured, from every other
+// provider too, returning the value at least the required number of providers agree on. Providers that fail to
+// respond count as disagreeing.
+func quorumRead[T comparable](q *Quorum, what string, primary *ethclient.Client, read func(bind.ContractCaller) (T, error)) (T, error) {
+	if q == nil {
+		return read(primary)
+	}
+
+	clients := append([]*ethclient.Client{primary}, q.Clients...)
+	counts := make(map[T]int)
+	var results []string
+	for i, client := range clients {
+		value, err := read(client)
+		if err != nil {
+			log.Warn("L1 provider failed to respond for quorum read", "value", what, "provider", i, "error", err)
+			results = append(results, fmt.Sprintf("provider %d: error", i))
+			continue
+		}
+		counts[value]++
+		results = append(results, fmt.Sprintf("provider %d: %v", i, value))
+	}
+	var agreed []T
+	for value, count := range counts {
+		if count >= q.Required {
+			agreed = append(agreed, value)
+		}
+	}
+	if len(agreed) == 1 {
+		if counts[agreed[0]] < len(clients) {
+			log.Warn("L1 providers disagree, using the value a quorum agrees on", "value", what, "results", strings.Join(results, ", "))
+		}
+		return agreed[0], nil
+	}
+
+	var zero T
+	if len(agreed) > 1 {
+		return zero, fmt.Errorf("%w on %s, conflicting values each reached %d of %d providers (%s)", ErrQuorumNotReached, what, q.Required, len(clients), strings.Join(results, ", "))
+	}
+	return zero, fmt.Errorf("%w on %s, %d of %d must agree (%s)", ErrQuorumNotReached, what, q.Required, len(clients), strings.Join(results, ", "))
+}
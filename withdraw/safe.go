@@ -0,0 +1,198 @@
+package withdraw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// safeServiceTimeout bounds how long to wait for the Safe Transaction Service to answer a request.
+const safeServiceTimeout = 30 * time.Second
+
+// safeABI has the Safe methods needed to propose a transaction to its owners.
+const safeABI = `[
+	{"type":"function","name":"nonce","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"getThreshold","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+	{"type":"function","name":"isOwner","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"encodeTransactionData","stateMutability":"view","inputs":[
+		{"name":"to","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"data","type":"bytes"},
+		{"name":"operation","type":"uint8"},
+		{"name":"safeTxGas","type":"uint256"},
+		{"name":"baseGas","type":"uint256"},
+		{"name":"gasPrice","type":"uint256"},
+		{"name":"gasToken","type":"address"},
+		{"name":"refundReceiver","type":"address"},
+		{"name":"_nonce","type":"uint256"}
+	],"outputs":[{"name":"","type":"bytes"}]}
+]`
+
+var safeParsedABI = mustParseABI(safeABI)
+
+// SafeServiceURLs are the Safe Transaction Services of the L1 chains, by chain ID.
+var SafeServiceURLs = map[uint64]string{
+	1:        "https://safe-transaction-mainnet.safe.global",
+	11155111: "https://safe-transaction-sepolia.safe.global",
+}
+
+// SafeTx is a call from a Safe as its owners sign it. It has no gas refund, so the owner executing it pays its gas.
+type SafeTx struct {
+	Safe       common.Address
+	To         common.Address
+	Value      *big.Int
+	Data       []byte
+	Nonce      *big.Int
+	SafeTxHash common.Hash
+	TypedData  []byte // EIP-712 message the owners sign, whose hash is SafeTxHash
+}
+
+// SafeThreshold is how many of a Safe's owners must sign its transactions.
+func SafeThreshold(caller bind.ContractCaller, safe common.Address) (uint64, error) {
+	var out []interface{}
+	if err := bind.NewBoundContract(safe, safeParsedABI, caller, nil, nil).Call(&bind.CallOpts{}, &out, "getThreshold"); err != nil {
+		return 0, fmt.Errorf("error querying the threshold of Safe %s: %w", safe, err)
+	}
+	return out[0].(*big.Int).Uint64(), nil
+}
+
+// NewSafeTx builds the transaction of the Safe making the call with the given nonce, once checked that owner is one
+// of its owners. The typed data and hash are computed by the Safe itself, so they match its version's EIP-712 domain.
+func NewSafeTx(caller bind.ContractCaller, safe common.Address, call *Calldata, nonce *big.Int, owner common.Address) (*SafeTx, error) {
+	contract := bind.NewBoundContract(safe, safeParsedABI, caller, nil, nil)
+	var out []interface{}
+	if err := contract.Call(&bind.CallOpts{}, &out, "isOwner", owner); err != nil {
+		return nil, fmt.Errorf("error querying the owners of Safe %s: %w", safe, err)
+	}
+	if !out[0].(bool) {
+		return nil, fmt.Errorf("signer %s is not an owner of Safe %s", owner, safe)
+	}
+
+	out = nil
+	if err := contract.Call(&bind.CallOpts{}, &out, "encodeTransactionData", call.To, call.Value.ToInt(), []byte(call.Data), uint8(0),
+		common.Big0, common.Big0, common.Big0, common.Address{}, common.Address{}, nonce); err != nil {
+		return nil, fmt.Errorf("error encoding the Safe transaction: %w", err)
+	}
+	typedData := out[0].([]byte)
+	if len(typedData) != 66 || typedData[0] != 0x19 || typedData[1] != 0x01 {
+		return nil, fmt.Errorf("Safe %s encoded its transaction as %d bytes, not an EIP-712 message", safe, len(typedData))
+	}
+	return &SafeTx{
+		Safe:       safe,
+		To:         call.To,
+		Value:      call.Value.ToInt(),
+		Data:       call.Data,
+		Nonce:      nonce,
+		SafeTxHash: crypto.Keccak256Hash(typedData),
+		TypedData:  typedData,
+	}, nil
+}
+
+// SafeService is a Safe Transaction Service, which collects the signatures of a Safe's owners for its transactions
+// until they can be executed, e.g. from the Safe web app.
+type SafeService struct {
+	URL string
+}
+
+// NextNonce returns the nonce of the Safe's next transaction: its on-chain nonce, or the one after the last
+// transaction already proposed and not executed yet, so the new one is queued behind it rather than replacing it.
+func (s *SafeService) NextNonce(ctx context.Context, caller bind.ContractCaller, safe common.Address) (*big.Int, error) {
+	var out []interface{}
+	if err := bind.NewBoundContract(safe, safeParsedABI, caller, nil, nil).Call(&bind.CallOpts{Context: ctx}, &out, "nonce"); err != nil {
+		return nil, fmt.Errorf("error querying the nonce of Safe %s: %w", safe, err)
+	}
+	nonce := out[0].(*big.Int)
+
+	query := url.Values{"executed": {"false"}, "nonce__gte": {nonce.String()}, "ordering": {"-nonce"}, "limit": {"1"}}
+	var pending struct {
+		Results []struct {
+			Nonce json.Number `json:"nonce"`
+		} `json:"results"`
+	}
+	if err := s.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/safes/%s/multisig-transactions/?%s", safe.Hex(), query.Encode()), nil, &pending); err != nil {
+		return nil, err
+	}
+	if len(pending.Results) > 0 {
+		last, ok := new(big.Int).SetString(pending.Results[0].Nonce.String(), 10)
+		if !ok {
+			return nil, fmt.Errorf("Safe Transaction Service returned invalid nonce %q", pending.Results[0].Nonce)
+		}
+		if last.Cmp(nonce) >= 0 {
+			nonce = last.Add(last, common.Big1)
+		}
+	}
+	return nonce, nil
+}
+
+// Propose submits the Safe transaction to the service with the signature of sender, one of the Safe's owners, for the
+// other owners to confirm and any of them to execute.
+func (s *SafeService) Propose(ctx context.Context, tx *SafeTx, sender common.Address, signature []byte) error {
+	body := map[string]interface{}{
+		"to":                      tx.To.Hex(),
+		"value":                   tx.Value.String(),
+		"data":                    hexutil.Encode(tx.Data),
+		"operation":               0,
+		"safeTxGas":               "0",
+		"baseGas":                 "0",
+		"gasPrice":                "0",
+		"gasToken":                common.Address{}.Hex(),
+		"refundReceiver":          common.Address{}.Hex(),
+		"nonce":                   tx.Nonce.Uint64(),
+		"contractTransactionHash": tx.SafeTxHash.Hex(),
+		"sender":                  sender.Hex(),
+		"signature":               hexutil.Encode(signature),
+	}
+	return s.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/safes/%s/multisig-transactions/", tx.Safe.Hex()), body, nil)
+}
+
+// do sends a request to the service, decoding its JSON response into out unless it is nil.
+func (s *SafeService) do(ctx context.Context, method, path string, in interface{}, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, safeServiceTimeout)
+	defer cancel()
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.URL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying Safe Transaction Service: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		// the service explains rejected proposals in the body, e.g. a signature by a non-owner
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("Safe Transaction Service returned %s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding Safe Transaction Service response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum-optimism/optimism/op-service/predeploys"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Topics of the L2 events naming the address that initiated a withdrawal, in an indexed parameter.
+var (
+	messagePassedTopic         = crypto.Keccak256Hash([]byte("MessagePassed(uint256,address,address,uint256,uint256,bytes,bytes32)"))
+	sentMessageExtensionTopic  = crypto.Keccak256Hash([]byte("SentMessageExtension1(address,uint256)"))
+	withdrawalInitiatedTopic   = crypto.Keccak256Hash([]byte("WithdrawalInitiated(address,address,address,address,uint256,bytes)"))
+	erc721BridgeInitiatedTopic = crypto.Keccak256Hash([]byte("ERC721BridgeInitiated(address,address,address,address,uint256,bytes)"))
+)
+
+// FindInitiatedWithdrawals returns the hashes of the L2 transactions between fromBlock and toBlock inclusive that
+// initiated withdrawals from the address, in chain order. Withdrawals sent through the L2StandardBridge or the
+// L2ERC721Bridge are found by their bridge events, messages sent through the L2CrossDomainMessenger by its
+// SentMessageExtension1 event, and direct withdrawals by the L2ToL1MessagePasser's MessagePassed event, as each of
+// them indexes the address. Withdrawals sent by a contract on the address's behalf are only found through the
+// bridges.
+func FindInitiatedWithdrawals(ctx context.Context, l2 *ethclient.Client, from common.Address, fromBlock uint64, toBlock uint64) ([]common.Hash, error) {
+	sender := common.BytesToHash(from.Bytes())
+	queries := []ethereum.FilterQuery{{
+		Addresses: []common.Address{predeploys.L2ToL1MessagePasserAddr},
+		Topics:    [][]common.Hash{{messagePassedTopic}, nil, {sender}},
+	}, {
+		Addresses: []common.Address{predeploys.L2CrossDomainMessengerAddr},
+		Topics:    [][]common.Hash{{sentMessageExtensionTopic}, {sender}},
+	}, {
+		Addresses: []common.Address{predeploys.L2StandardBridgeAddr, predeploys.L2ERC721BridgeAddr},
+		Topics:    [][]common.Hash{{withdrawalInitiatedTopic, erc721BridgeInitiatedTopic}, nil, nil, {sender}},
+	}}
+
+	var txs []common.Hash
+	seen := make(map[common.Hash]bool)
+	for start := fromBlock; start <= toBlock; start += historyBlockRange {
+		end := min(start+historyBlockRange-1, toBlock)
+		// the queries' logs are merged in chain order, as a transaction may match several of them
+		found := make(map[uint64]map[uint]common.Hash)
+		for _, q := range queries {
+			q.FromBlock, q.ToBlock = new(big.Int).SetUint64(start), new(big.Int).SetUint64(end)
+			logs, err := l2.FilterLogs(ctx, q)
+			if err != nil {
+				return nil, fmt.Errorf("error querying L2 withdrawal events in blocks %d-%d: %w", start, end, err)
+			}
+			for _, l := range logs {
+				if l.Removed {
+					continue
+				}
+				if found[l.BlockNumber] == nil {
+					found[l.BlockNumber] = make(map[uint]common.Hash)
+				}
+				found[l.BlockNumber][l.TxIndex] = l.TxHash
+			}
+		}
+		for _, tx := range sortedTxs(found) {
+			if !seen[tx] {
+				seen[tx] = true
+				txs = append(txs, tx)
+			}
+		}
+		log.Info("Scanned L2 withdrawal events", "fromBlock", start, "toBlock", end, "found", len(txs))
+	}
+	return txs, nil
+}
+
+// sortedTxs returns the transaction hashes keyed by block number and transaction index in chain order.
+func sortedTxs(found map[uint64]map[uint]common.Hash) []common.Hash {
+	var blocks []uint64
+	for b := range found {
+		blocks = append(blocks, b)
+	}
+	slices.Sort(blocks)
+	var txs []common.Hash
+	for _, b := range blocks {
+		var indexes []uint
+		for i := range found[b] {
+			indexes = append(indexes, i)
+		}
+		slices.Sort(indexes)
+		for _, i := range indexes {
+			txs = append(txs, found[b][i])
+		}
+	}
+	return txs
+}
+
+// PassedMessage is a withdrawal initiated on L2, as found by its MessagePassed event.
+type PassedMessage struct {
+	L2TxHash       common.Hash
+	LogIndex       uint
+	L2BlockNumber  uint64
+	WithdrawalHash common.Hash
+	Sender         common.Address
+	Target         common.Address
+}
+
+// FindMessagesPassed returns the withdrawals initiated on L2 between fromBlock and toBlock inclusive, in chain order.
+// Only those sent by one of senders and to one of targets are returned, if given. The sender of withdrawals sent through
+// the bridges is the L2CrossDomainMessenger, and their target the L1CrossDomainMessenger.
+func FindMessagesPassed(ctx context.Context, l2 *ethclient.Client, fromBlock uint64, toBlock uint64, senders []common.Address, targets []common.Address) ([]PassedMessage, error) {
+	passer, err := bindings.NewL2ToL1MessagePasserFilterer(predeploys.L2ToL1MessagePasserAddr, l2)
+	if err != nil {
+		return nil, err
+	}
+	q := ethereum.FilterQuery{
+		Addresses: []common.Address{predeploys.L2ToL1MessagePasserAddr},
+		Topics:    [][]common.Hash{{messagePassedTopic}, nil, addressTopics(senders), addressTopics(targets)},
+	}
+
+	var messages []PassedMessage
+	for start := fromBlock; start <= toBlock; start += historyBlockRange {
+		end := min(start+historyBlockRange-1, toBlock)
+		q.FromBlock, q.ToBlock = new(big.Int).SetUint64(start), new(big.Int).SetUint64(end)
+		logs, err := l2.FilterLogs(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("error querying L2 withdrawals in blocks %d-%d: %w", start, end, err)
+		}
+		for _, l := range logs {
+			if l.Removed {
+				continue
+			}
+			ev, err := passer.ParseMessagePassed(l)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding withdrawal of L2 tx %s: %w", l.TxHash, err)
+			}
+			messages = append(messages, PassedMessage{
+				L2TxHash:       l.TxHash,
+				LogIndex:       l.Index,
+				L2BlockNumber:  l.BlockNumber,
+				WithdrawalHash: ev.WithdrawalHash,
+				Sender:         ev.Sender,
+				Target:         ev.Target,
+			})
+		}
+	}
+	return messages, nil
+}
+
+// addressTopics returns the topics matching an indexed parameter equal to one of the addresses, or nil to match any.
+func addressTopics(addresses []common.Address) []common.Hash {
+	var topics []common.Hash
+	for _, a := range addresses {
+		topics = append(topics, common.BytesToHash(a.Bytes()))
+	}
+	return topics
+}
@@ -0,0 +1,378 @@
+package withdraw
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// scheduleProvableRetry is how long to wait before trying again to prove a withdrawal that isn't provable yet, as
+	// the proposals covering withdrawals come at most every few minutes.
+	scheduleProvableRetry = 10 * time.Minute
+	// schedulePausedRetry is how long to wait before trying again while the guardian has paused withdrawals.
+	schedulePausedRetry = 10 * time.Minute
+	// scheduleMaxBackoff caps the wait before retrying a withdrawal whose last attempts failed.
+	scheduleMaxBackoff = time.Hour
+	// defaultSchedulerPollInterval is the time between a Scheduler's passes over its due withdrawals by default.
+	defaultSchedulerPollInterval = time.Minute
+)
+
+// ErrNotProvable is wrapped by the error of a step stopped by a withdrawal that can't be proven yet.
+var ErrNotProvable = errors.New("withdrawal is not provable yet")
+
+// ScheduledWithdrawal is a withdrawal tracked until it's finalized, with the time its next step is due.
+type ScheduledWithdrawal struct {
+	L2TxHash    common.Hash
+	LogIndex    uint
+	L2Block     uint64
+	State       State // As of the last attempt
+	NextAttempt time.Time
+	Failures    int // Consecutive failed attempts, which back off the next one
+	LastError   string
+}
+
+// Reschedule schedules the next attempt of the withdrawal whose step ended at now with err: once its proof matures if
+// it isn't finalizable yet, after 10 minutes if it isn't provable yet or withdrawals are paused, or with a backoff
+// otherwise. It returns whether the step was waiting for the withdrawal rather than failing.
+func (w *ScheduledWithdrawal) Reschedule(hash common.Hash, err error, now time.Time, pollInterval time.Duration) bool {
+	var notFinalizable *NotFinalizableError
+	switch {
+	case errors.As(err, &notFinalizable):
+		w.Failures, w.LastError = 0, ""
+		w.NextAttempt = notFinalizable.FinalizableAt
+		log.Info("Withdrawal not finalizable yet, waiting", "withdrawalHash", hash, "finalizableAt", notFinalizable.FinalizableAt.UTC())
+	case errors.Is(err, ErrNotProvable):
+		w.Failures, w.LastError = 0, ""
+		w.NextAttempt = now.Add(max(pollInterval, scheduleProvableRetry))
+		log.Info("Withdrawal not provable yet, waiting", "withdrawalHash", hash, "retryAt", w.NextAttempt.UTC(), "reason", err)
+	case errors.Is(err, ErrWithdrawalsPaused):
+		// a pause isn't a failure of the withdrawal, so it doesn't back off, and it's waited out until unpaused
+		w.Failures, w.LastError = 0, ""
+		w.NextAttempt = now.Add(max(pollInterval, schedulePausedRetry))
+		log.Warn("Withdrawals are paused, waiting for the guardian to unpause", "withdrawalHash", hash, "retryAt", w.NextAttempt.UTC(), "reason", err)
+	default:
+		w.Failed(hash, err, now, pollInterval)
+		return false
+	}
+	return true
+}
+
+// Failed records the attempt that failed at now and backs off the next one, doubling the wait from pollInterval with
+// each consecutive failure.
+func (w *ScheduledWithdrawal) Failed(hash common.Hash, err error, now time.Time, pollInterval time.Duration) {
+	w.Failures++
+	w.LastError = err.Error()
+	backoff := scheduleMaxBackoff
+	if w.Failures < 16 {
+		backoff = min(pollInterval<<w.Failures, scheduleMaxBackoff)
+	}
+	w.NextAttempt = now.Add(backoff)
+	log.Error("Error processing withdrawal, retrying later", "withdrawalHash", hash, "l2TxHash", w.L2TxHash, "failures", w.Failures,
+		"retryAt", w.NextAttempt.UTC(), "error", err)
+}
+
+// ProvableSoon brings the next attempt forward to now if the withdrawal is waiting to become provable, once a new
+// dispute game may cover it.
+func (w *ScheduledWithdrawal) ProvableSoon(now time.Time) {
+	if w.State == StateInitiated && w.Failures == 0 && w.NextAttempt.After(now) {
+		w.NextAttempt = now
+	}
+}
+
+// SchedulerStore keeps the withdrawals a Scheduler tracks, so a restarted scheduler resumes them.
+type SchedulerStore interface {
+	// Load returns the tracked withdrawals, keyed by withdrawal hash, or none on the first start.
+	Load() (map[common.Hash]*ScheduledWithdrawal, error)
+	// Save replaces the tracked withdrawals.
+	Save(withdrawals map[common.Hash]*ScheduledWithdrawal) error
+}
+
+// SchedulerFile is a SchedulerStore keeping the withdrawals in a JSON file.
+type SchedulerFile struct {
+	Path string
+}
+
+func (f *SchedulerFile) Load() (map[common.Hash]*ScheduledWithdrawal, error) {
+	withdrawals := make(map[common.Hash]*ScheduledWithdrawal)
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return withdrawals, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error reading scheduler file %s: %w", f.Path, err)
+	}
+	if err := json.Unmarshal(data, &withdrawals); err != nil {
+		return nil, fmt.Errorf("error decoding scheduler file %s: %w", f.Path, err)
+	}
+	return withdrawals, nil
+}
+
+func (f *SchedulerFile) Save(withdrawals map[common.Hash]*ScheduledWithdrawal) error {
+	data, err := json.MarshalIndent(withdrawals, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFileAtomic(f.Path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing scheduler file %s: %w", f.Path, err)
+	}
+	return nil
+}
+
+// HelperFactory creates the WithdrawHelper that takes the withdrawal its next step, e.g. with NewWithdrawer or
+// NewFPWithdrawer, sending its transactions with the service's signer.
+type HelperFactory func(ctx context.Context, hash common.Hash, w ScheduledWithdrawal) (WithdrawHelper, error)
+
+// StepFunc takes the withdrawal its next step, unless it's already finalized, returning its state once the step is
+// taken. An error wrapping ErrNotProvable or ErrWithdrawalsPaused, or a NotFinalizableError, makes the scheduler wait
+// for the withdrawal rather than back off.
+type StepFunc func(ctx context.Context, hash common.Hash, w ScheduledWithdrawal) (State, error)
+
+// StepOutcome is how a step of a scheduled withdrawal ended.
+type StepOutcome string
+
+const (
+	StepSucceeded   StepOutcome = "success"     // The withdrawal was proven or finalized, or found finalized
+	StepWaiting     StepOutcome = "waiting"     // The withdrawal isn't provable or finalizable yet, or withdrawals are paused
+	StepFailed      StepOutcome = "failure"     // The step failed, and is retried with a backoff
+	StepInterrupted StepOutcome = "interrupted" // The scheduler was stopped during the step, which is retried on the next run
+)
+
+// SchedulerOptions configures a Scheduler. Every field is optional.
+type SchedulerOptions struct {
+	PollInterval time.Duration                   // Time between passes over the due withdrawals (defaults to a minute)
+	Timing       *Timing                         // Clock the withdrawals are scheduled on (nil means the system clock)
+	Paused       func(ctx context.Context) error // Checked before each step of NewScheduler, returning ErrWithdrawalsPaused while paused, e.g. with CheckPaused
+
+	OnStep      func(hash common.Hash, w ScheduledWithdrawal, outcome StepOutcome, err error) // Called after each step, with the withdrawal rescheduled
+	OnFinalized func(hash common.Hash, w ScheduledWithdrawal)                                 // Called once the withdrawal is finalized, by the scheduler or anyone else, after which it's no longer tracked
+}
+
+// Scheduler takes withdrawals through their lifecycle, for the relay and daemon commands and services embedding the
+// package: every poll interval, each tracked withdrawal whose next attempt is due is given its next step, oldest
+// first. It's proven once provable and finalized once finalizable, and retried later if it isn't ready yet or the
+// step failed, with a backoff that doesn't hold up the other withdrawals. The withdrawals are kept in the store after
+// every change, so a restarted scheduler resumes where it left off.
+type Scheduler struct {
+	store       SchedulerStore
+	step        StepFunc
+	opts        SchedulerOptions
+	mu          sync.Mutex
+	withdrawals map[common.Hash]*ScheduledWithdrawal
+}
+
+// NewScheduler returns a scheduler of the withdrawals the store keeps, whose steps are taken by the helpers the
+// factory creates.
+func NewScheduler(store SchedulerStore, helpers HelperFactory, opts SchedulerOptions) (*Scheduler, error) {
+	if helpers == nil {
+		return nil, errors.New("scheduler needs a helper factory")
+	}
+	return NewStepScheduler(store, HelperSteps(helpers, opts.Paused), opts)
+}
+
+// NewStepScheduler returns a scheduler of the withdrawals the store keeps, whose steps are taken by step, for
+// services that take them with more than a WithdrawHelper, as the relay command does.
+func NewStepScheduler(store SchedulerStore, step StepFunc, opts SchedulerOptions) (*Scheduler, error) {
+	if store == nil || step == nil {
+		return nil, errors.New("scheduler needs a store and a step")
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = defaultSchedulerPollInterval
+	}
+	withdrawals, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if withdrawals == nil {
+		withdrawals = make(map[common.Hash]*ScheduledWithdrawal)
+	}
+	return &Scheduler{store: store, step: step, opts: opts, withdrawals: withdrawals}, nil
+}
+
+// Add tracks the withdrawal until it's finalized, its first step due right away unless it has a next attempt. A
+// withdrawal already tracked is left as it is.
+func (s *Scheduler) Add(hash common.Hash, w ScheduledWithdrawal) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.withdrawals[hash]; ok {
+		return nil
+	}
+	if w.NextAttempt.IsZero() {
+		w.NextAttempt = s.opts.Timing.Now()
+	}
+	s.withdrawals[hash] = &w
+	return s.store.Save(s.withdrawals)
+}
+
+// Withdrawals returns the tracked withdrawals, keyed by withdrawal hash.
+func (s *Scheduler) Withdrawals() map[common.Hash]ScheduledWithdrawal {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	withdrawals := make(map[common.Hash]ScheduledWithdrawal, len(s.withdrawals))
+	for hash, w := range s.withdrawals {
+		withdrawals[hash] = *w
+	}
+	return withdrawals
+}
+
+// SetPollInterval changes the poll interval, e.g. once the service's config is reloaded.
+func (s *Scheduler) SetPollInterval(pollInterval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if pollInterval > 0 {
+		s.opts.PollInterval = pollInterval
+	}
+}
+
+// ProvableSoon brings forward the withdrawals waiting to become provable, e.g. once a new dispute game was created.
+func (s *Scheduler) ProvableSoon() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.opts.Timing.Now()
+	for _, w := range s.withdrawals {
+		w.ProvableSoon(now)
+	}
+}
+
+// Run gives the due withdrawals their next step every poll interval, until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		s.RunDue(ctx)
+		s.mu.Lock()
+		pollInterval := s.opts.PollInterval
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.opts.Timing.After(pollInterval):
+		}
+	}
+}
+
+// RunDue gives each tracked withdrawal whose next attempt is due its next step, oldest first, and reschedules it.
+// Finalized withdrawals stop being tracked.
+func (s *Scheduler) RunDue(ctx context.Context) {
+	s.mu.Lock()
+	now := s.opts.Timing.Now()
+	var due []common.Hash
+	for hash, w := range s.withdrawals {
+		if !w.NextAttempt.After(now) {
+			due = append(due, hash)
+		}
+	}
+	slices.SortFunc(due, func(a, b common.Hash) int {
+		wa, wb := s.withdrawals[a], s.withdrawals[b]
+		if c := cmp.Compare(wa.L2Block, wb.L2Block); c != 0 {
+			return c
+		}
+		return cmp.Compare(wa.LogIndex, wb.LogIndex)
+	})
+	s.mu.Unlock()
+
+	for _, hash := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		s.mu.Lock()
+		w, ok := s.withdrawals[hash]
+		pollInterval := s.opts.PollInterval
+		s.mu.Unlock()
+		if !ok {
+			continue
+		}
+		next := *w
+		finalized := s.stepWithdrawal(ctx, hash, &next, pollInterval)
+
+		s.mu.Lock()
+		if finalized {
+			delete(s.withdrawals, hash)
+		} else {
+			s.withdrawals[hash] = &next
+		}
+		err := s.store.Save(s.withdrawals)
+		s.mu.Unlock()
+		if err != nil {
+			log.Error("Error saving scheduled withdrawals", "error", err)
+		}
+		if finalized && s.opts.OnFinalized != nil {
+			s.opts.OnFinalized(hash, next)
+		}
+	}
+}
+
+// stepWithdrawal takes the withdrawal its next step and schedules its next attempt: after the poll interval once
+// proven, once its proof matures if it isn't finalizable yet, later if it isn't provable yet, or with a backoff if the
+// step failed. It returns whether the withdrawal is finalized.
+func (s *Scheduler) stepWithdrawal(ctx context.Context, hash common.Hash, w *ScheduledWithdrawal, pollInterval time.Duration) bool {
+	state, err := s.step(ctx, hash, *w)
+	if state != "" {
+		w.State = state
+	}
+	now := s.opts.Timing.Now()
+	var outcome StepOutcome
+	switch {
+	case err == nil:
+		outcome = StepSucceeded
+		w.Failures, w.LastError = 0, ""
+		w.NextAttempt = now.Add(pollInterval)
+	case ctx.Err() != nil:
+		// retried on the next run
+		outcome = StepInterrupted
+	case w.Reschedule(hash, err, now, pollInterval):
+		outcome = StepWaiting
+	default:
+		outcome = StepFailed
+	}
+	if s.opts.OnStep != nil {
+		s.opts.OnStep(hash, *w, outcome, err)
+	}
+	return err == nil && w.State == StateFinalized
+}
+
+// HelperSteps returns the StepFunc proving or finalizing the withdrawal with the helper the factory creates for it,
+// depending on its state, once paused, if set, finds withdrawals aren't paused.
+func HelperSteps(helpers HelperFactory, paused func(ctx context.Context) error) StepFunc {
+	return func(ctx context.Context, hash common.Hash, w ScheduledWithdrawal) (State, error) {
+		helper, err := helpers(ctx, hash, w)
+		if err != nil {
+			return "", fmt.Errorf("error creating withdrawer: %w", err)
+		}
+		state, err := CurrentState(helper)
+		if err != nil {
+			return "", err
+		}
+		if state == StateFinalized {
+			return state, nil
+		}
+		if paused != nil {
+			if err := paused(ctx); err != nil {
+				return state, err
+			}
+		}
+
+		switch state.NextAction() {
+		case ActionProve:
+			if err := helper.CheckIfProvable(); err != nil {
+				return state, fmt.Errorf("%w: %w", ErrNotProvable, err)
+			}
+			if err := helper.ProveWithdrawal(); err != nil {
+				return state, fmt.Errorf("error proving withdrawal: %w", err)
+			}
+			return StateProven, nil
+		case ActionFinalize:
+			if err := helper.FinalizeWithdrawal(); err != nil {
+				return state, fmt.Errorf("error finalizing withdrawal: %w", err)
+			}
+			return StateFinalized, nil
+		}
+		return state, nil
+	}
+}
@@ -0,0 +1,214 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestScheduledWithdrawalFailed(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		failures     int // Consecutive failures before this one
+		pollInterval time.Duration
+		want         time.Duration
+	}{
+		{name: "first failure", pollInterval: time.Minute, want: 2 * time.Minute},
+		{name: "doubles", failures: 2, pollInterval: time.Minute, want: 8 * time.Minute},
+		{name: "capped", failures: 6, pollInterval: time.Minute, want: scheduleMaxBackoff},
+		{name: "no overflow", failures: 40, pollInterval: time.Minute, want: scheduleMaxBackoff},
+		{name: "long poll interval", pollInterval: 45 * time.Minute, want: scheduleMaxBackoff},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &ScheduledWithdrawal{Failures: tt.failures}
+			w.Failed(common.HexToHash("0x01"), errors.New("execution reverted"), now, tt.pollInterval)
+			if w.Failures != tt.failures+1 {
+				t.Errorf("failures %d, want %d", w.Failures, tt.failures+1)
+			}
+			if w.LastError != "execution reverted" {
+				t.Errorf("last error %q", w.LastError)
+			}
+			if got := w.NextAttempt.Sub(now); got != tt.want {
+				t.Errorf("next attempt in %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduledWithdrawalProvableSoon(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := now.Add(scheduleProvableRetry)
+	tests := []struct {
+		name string
+		w    ScheduledWithdrawal
+		want time.Time
+	}{
+		{
+			name: "waiting to become provable",
+			w:    ScheduledWithdrawal{State: StateInitiated, NextAttempt: later},
+			want: now,
+		},
+		{
+			name: "already due",
+			w:    ScheduledWithdrawal{State: StateInitiated, NextAttempt: now.Add(-time.Minute)},
+			want: now.Add(-time.Minute),
+		},
+		{
+			name: "backing off a failure",
+			w:    ScheduledWithdrawal{State: StateInitiated, NextAttempt: later, Failures: 1},
+			want: later,
+		},
+		{
+			name: "proven",
+			w:    ScheduledWithdrawal{State: StateProven, NextAttempt: later},
+			want: later,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := tt.w
+			w.ProvableSoon(now)
+			if !w.NextAttempt.Equal(tt.want) {
+				t.Errorf("next attempt %s, want %s", w.NextAttempt, tt.want)
+			}
+		})
+	}
+}
+
+// scheduledWithdrawer is a withdrawer whose withdrawal is proven and finalized on the fake portal, unless it's not
+// provable or finalizable yet.
+type scheduledWithdrawer struct {
+	WithdrawHelper
+	provenAt       uint64
+	finalized      bool
+	notProvable    error
+	notFinalizable error
+	proves         int
+	finalizes      int
+}
+
+func (w *scheduledWithdrawer) IsProofFinalized() (bool, error) { return w.finalized, nil }
+
+func (w *scheduledWithdrawer) GetProvenWithdrawalTime() (uint64, error) { return w.provenAt, nil }
+
+func (w *scheduledWithdrawer) CheckIfProvable() error { return w.notProvable }
+
+func (w *scheduledWithdrawer) ProveWithdrawal() error {
+	w.proves++
+	w.provenAt = 1
+	return nil
+}
+
+func (w *scheduledWithdrawer) FinalizeWithdrawal() error {
+	if w.notFinalizable != nil {
+		return w.notFinalizable
+	}
+	w.finalizes++
+	w.finalized = true
+	return nil
+}
+
+// TestScheduler takes a withdrawal through its lifecycle, backing off a failed step, waiting for it to become provable
+// and finalizable, and resuming it from the store.
+func TestScheduler(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := &SchedulerFile{Path: filepath.Join(t.TempDir(), "scheduler.json")}
+	hash := common.HexToHash("0x01")
+	finalizableAt := clock.now.Add(7 * 24 * time.Hour)
+	helper := &scheduledWithdrawer{
+		notProvable:    errors.New("no dispute game covers the withdrawal yet"),
+		notFinalizable: &NotFinalizableError{Reasons: []string{"proof maturity delay"}, FinalizableAt: finalizableAt},
+	}
+	var events []string
+	var helperErr error
+	opts := SchedulerOptions{
+		PollInterval: time.Minute,
+		Timing:       &Timing{Clock: clock},
+		OnStep: func(_ common.Hash, w ScheduledWithdrawal, outcome StepOutcome, _ error) {
+			events = append(events, string(outcome)+" "+string(w.State))
+		},
+		OnFinalized: func(common.Hash, ScheduledWithdrawal) { events = append(events, "finalized") },
+	}
+	newScheduler := func() *Scheduler {
+		t.Helper()
+		s, err := NewScheduler(store, func(context.Context, common.Hash, ScheduledWithdrawal) (WithdrawHelper, error) {
+			return helper, helperErr
+		}, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	next := func(s *Scheduler) ScheduledWithdrawal {
+		t.Helper()
+		w, ok := s.Withdrawals()[hash]
+		if !ok {
+			t.Fatal("withdrawal no longer tracked")
+		}
+		return w
+	}
+	ctx := context.Background()
+
+	s := newScheduler()
+	if err := s.Add(hash, ScheduledWithdrawal{L2TxHash: common.HexToHash("0xaa")}); err != nil {
+		t.Fatal(err)
+	}
+	// a failed step backs off
+	helperErr = errors.New("connection refused")
+	s.RunDue(ctx)
+	if w := next(s); w.Failures != 1 || !w.NextAttempt.Equal(clock.now.Add(2*time.Minute)) {
+		t.Fatalf("failed withdrawal scheduled as %+v", w)
+	}
+	helperErr = nil
+	clock.now = clock.now.Add(2 * time.Minute)
+	s.RunDue(ctx)
+	if w := next(s); w.State != StateInitiated || !w.NextAttempt.Equal(clock.now.Add(scheduleProvableRetry)) || helper.proves != 0 {
+		t.Fatalf("not provable withdrawal scheduled as %+v, proven %d times", w, helper.proves)
+	}
+
+	// not due yet
+	helper.notProvable = nil
+	s.RunDue(ctx)
+	if helper.proves != 0 {
+		t.Fatal("proved the withdrawal before it was due")
+	}
+	s.ProvableSoon()
+	s.RunDue(ctx)
+	if w := next(s); w.State != StateProven || helper.proves != 1 {
+		t.Fatalf("provable withdrawal scheduled as %+v, proven %d times", w, helper.proves)
+	}
+
+	// a restarted scheduler resumes the proven withdrawal, and waits for it to become finalizable
+	s = newScheduler()
+	clock.now = clock.now.Add(time.Minute)
+	s.RunDue(ctx)
+	if w := next(s); w.State != StateProven || !w.NextAttempt.Equal(finalizableAt) || w.Failures != 0 {
+		t.Fatalf("not finalizable withdrawal scheduled as %+v", w)
+	}
+
+	helper.notFinalizable = nil
+	clock.now = finalizableAt
+	s.RunDue(ctx)
+	if _, ok := s.Withdrawals()[hash]; ok || helper.finalizes != 1 {
+		t.Fatalf("finalized withdrawal still tracked, finalized %d times", helper.finalizes)
+	}
+	if withdrawals, err := store.Load(); err != nil || len(withdrawals) != 0 {
+		t.Errorf("store kept %v, error %v", withdrawals, err)
+	}
+
+	want := []string{"failure ", "waiting initiated", "success proven", "waiting proven", "success finalized", "finalized"}
+	if len(events) != len(want) {
+		t.Fatalf("callbacks %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("callbacks %v, want %v", events, want)
+		}
+	}
+}
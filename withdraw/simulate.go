@@ -0,0 +1,134 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// SimulateWithdrawalCall simulates the call the portal makes to the withdrawal's target when it is finalized,
+// from the portal with the withdrawal's value, data and gas limit. The portal marks a withdrawal as finalized even
+// if this call reverts, so a reverting direct withdrawal loses its value. Targets that check the portal's l2Sender
+// revert in the simulation even though they would succeed on finalization, so a failure is only a warning sign.
+// On custom gas token chains, the portal transfers the value in the gas paying token instead and only calls the
+// target without value if there is data.
+func SimulateWithdrawalCall(ctx context.Context, caller ethereum.ContractCaller, portal common.Address, ev *bindings.L2ToL1MessagePasserMessagePassed, token *GasToken) error {
+	value := ev.Value
+	if token.IsCustom() && value.Sign() > 0 {
+		if ev.Target == token.Address {
+			return fmt.Errorf("withdrawal target %s is the gas paying token, the portal rejects withdrawals with value to it", ev.Target)
+		}
+		if len(ev.Data) == 0 {
+			return nil
+		}
+		value = nil
+	}
+	_, err := caller.CallContract(ctx, ethereum.CallMsg{
+		From:  portal,
+		To:    &ev.Target,
+		Gas:   ev.GasLimit.Uint64(),
+		Value: value,
+		Data:  ev.Data,
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("simulated call to withdrawal target %s reverted: %w", ev.Target, err)
+	}
+	return nil
+}
+
+// erc721TransferABI has the transfer the L1ERC721Bridge releases escrowed NFTs with.
+const erc721TransferABI = `[{"type":"function","name":"safeTransferFrom","stateMutability":"nonpayable","outputs":[],"inputs":[
+	{"name":"from","type":"address"},
+	{"name":"to","type":"address"},
+	{"name":"tokenId","type":"uint256"}]}]`
+
+var erc721TransferParsedABI = mustParseABI(erc721TransferABI)
+
+// FinalizeSimulation is the outcome of simulating, against the current L1 state, the call a withdrawal's
+// finalization ends in: the portal's call to a direct withdrawal's target, the L1StandardBridge's transfer of an ETH
+// withdrawal to its recipient, the L1ERC721Bridge's transfer of an NFT withdrawal to its recipient, or the
+// L1CrossDomainMessenger's call to any other message's target. Simulating it
+// before proving flags withdrawals whose value would not be delivered before any gas is spent on them.
+type FinalizeSimulation struct {
+	Call       string         `json:"call"`            // "target", "bridge transfer", "NFT transfer" or "message"
+	Target     common.Address `json:"target"`          // Address called
+	Replayable bool           `json:"replayable"`      // Whether a failed call can be replayed through the L1CrossDomainMessenger
+	Error      string         `json:"error,omitempty"` // Why the simulated call reverted, empty if it succeeded
+}
+
+// Reverted reports whether the simulated call reverted.
+func (s *FinalizeSimulation) Reverted() bool {
+	return s.Error != ""
+}
+
+// SimulateFinalization simulates the call the withdrawal's finalization ends in. Like SimulateWithdrawalCall, the
+// simulation can't reproduce the cross-domain sender the portal or messenger exposes during the real call, so targets
+// that check it revert here even though they would succeed, and a revert is only a warning sign. It returns nil if
+// there is nothing to simulate, e.g. a messenger withdrawal whose payload can't be decoded.
+func SimulateFinalization(ctx context.Context, caller ethereum.ContractCaller, portal common.Address, details *WithdrawalDetails, token *GasToken) (*FinalizeSimulation, error) {
+	if details.Direct {
+		sim := &FinalizeSimulation{Call: "target", Target: details.Event.Target}
+		if err := SimulateWithdrawalCall(ctx, caller, portal, details.Event, token); err != nil {
+			sim.Error = err.Error()
+		}
+		return sim, nil
+	}
+	msg := details.MessengerCall
+	if msg == nil {
+		return nil, nil
+	}
+
+	// the messenger's call is simulated from the L1CrossDomainMessenger, the portal's target, except for ETH
+	// withdrawals through the standard bridge, whose finalizeBridgeETH only accepts calls relayed from the L2 bridge:
+	// the part that can fail is its transfer to the recipient
+	sim := &FinalizeSimulation{Call: "message", Target: msg.Target, Replayable: true}
+	call := ethereum.CallMsg{From: details.Event.Target, To: &msg.Target, Gas: msg.MinGasLimit.Uint64(), Value: msg.Value, Data: msg.Message}
+	if recipient := details.Recipient(); recipient != msg.Target {
+		sim.Call, sim.Target = "bridge transfer", recipient
+		call = ethereum.CallMsg{From: msg.Target, To: &recipient, Value: msg.Value}
+	}
+	// likewise, the L1ERC721Bridge's finalizeBridgeERC721 ends in its transfer of the escrowed NFT to the recipient
+	if transfer := details.BridgeTransfer(); transfer != nil && transfer.Kind == BridgeERC721 {
+		data, err := erc721TransferParsedABI.Pack("safeTransferFrom", msg.Target, transfer.To, transfer.TokenID)
+		if err != nil {
+			return nil, err
+		}
+		sim.Call, sim.Target = "NFT transfer", transfer.L1Token
+		call = ethereum.CallMsg{From: msg.Target, To: &transfer.L1Token, Data: data}
+	}
+	if token.IsCustom() {
+		// the native token is not ETH, so there is no ETH value to forward
+		call.Value = nil
+	}
+	if _, err := caller.CallContract(ctx, call, nil); err != nil {
+		sim.Error = fmt.Sprintf("simulated %s call to %s reverted: %v", sim.Call, sim.Target, err)
+	}
+	return sim, nil
+}
+
+// warnIfFinalizeReverts logs the call the withdrawal's finalization ends in, then simulates it and logs a warning if
+// it reverts. Failed CrossDomainMessenger messages can be replayed on L1, so their warning is milder.
+func warnIfFinalizeReverts(ctx context.Context, caller ethereum.ContractCaller, portal common.Address, details *WithdrawalDetails, token *GasToken) {
+	log.Info("Finalizing executes the withdrawal's call on L1", details.L1Call().LogFields()...)
+	sim, err := SimulateFinalization(ctx, caller, portal, details, token)
+	if err != nil {
+		log.Warn("Unable to simulate the withdrawal's finalization", "error", err)
+		return
+	}
+	switch {
+	case sim == nil:
+		return
+	case sim.Reverted() && sim.Replayable:
+		log.Warn("Withdrawal message call may revert on finalization, the message would then need to be replayed on L1",
+			"call", sim.Call, "target", sim.Target, "error", sim.Error)
+	case sim.Reverted():
+		log.Warn("Direct withdrawal target call may revert, finalizing would mark the withdrawal finalized without delivering its value",
+			"target", sim.Target, "value", token.FormatValue(details.Event.Value), "error", sim.Error)
+	default:
+		log.Info("Simulated the call the withdrawal's finalization makes", "call", sim.Call, "target", sim.Target)
+	}
+}
@@ -0,0 +1,254 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum-optimism/optimism/op-service/eth"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// superRootPortalABI has the interop portal's super root switch and the proveWithdrawalTransaction overload taking
+// a super root proof, which the preview bindings predate.
+const superRootPortalABI = `[
+	{"type":"function","name":"superRootsActive","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"proveWithdrawalTransaction","stateMutability":"nonpayable","outputs":[],"inputs":[
+		{"name":"_tx","type":"tuple","components":[
+			{"name":"nonce","type":"uint256"},
+			{"name":"sender","type":"address"},
+			{"name":"target","type":"address"},
+			{"name":"value","type":"uint256"},
+			{"name":"gasLimit","type":"uint256"},
+			{"name":"data","type":"bytes"}]},
+		{"name":"_disputeGameProxy","type":"address"},
+		{"name":"_outputRootIndex","type":"uint256"},
+		{"name":"_superRootProof","type":"tuple","components":[
+			{"name":"version","type":"bytes1"},
+			{"name":"timestamp","type":"uint64"},
+			{"name":"outputRoots","type":"tuple[]","components":[
+				{"name":"chainID","type":"uint256"},
+				{"name":"root","type":"bytes32"}]}]},
+		{"name":"_outputRootProof","type":"tuple","components":[
+			{"name":"version","type":"bytes32"},
+			{"name":"stateRoot","type":"bytes32"},
+			{"name":"messagePasserStorageRoot","type":"bytes32"},
+			{"name":"latestBlockhash","type":"bytes32"}]},
+		{"name":"_withdrawalProof","type":"bytes[]"}]}
+]`
+
+var superRootPortalParsedABI = mustParseABI(superRootPortalABI)
+
+// SuperRootsActive reports whether the portal proves withdrawals against super roots, committing to the output roots
+// of every chain in the interop dependency set, instead of the chain's own output roots. Portals released before
+// interop don't have the getter, and are treated as not using super roots.
+func SuperRootsActive(caller bind.ContractCaller, portal common.Address) bool {
+	var out []interface{}
+	if err := bind.NewBoundContract(portal, superRootPortalParsedABI, caller, nil, nil).Call(&bind.CallOpts{}, &out, "superRootsActive"); err != nil {
+		log.Debug("Unable to query whether the portal uses super roots, assuming it doesn't", "portal", portal, "error", err)
+		return false
+	}
+	return out[0].(bool)
+}
+
+// FetchSuperRoot queries the super root at timestamp from the op-supervisor.
+func FetchSuperRoot(ctx context.Context, supervisor *rpc.Client, timestamp uint64) (eth.SuperRootResponse, error) {
+	var resp eth.SuperRootResponse
+	if err := supervisor.CallContext(ctx, &resp, "supervisor_superRootAtTimestamp", hexutil.Uint64(timestamp)); err != nil {
+		return eth.SuperRootResponse{}, fmt.Errorf("error querying super root at timestamp %d: %w", timestamp, err)
+	}
+	return resp, nil
+}
+
+// BuildSuperRootProof converts a super root to the proof the portal expects, and returns the index of the chain's
+// output root in it. The super root is checked to hash to the root claimed by the dispute game.
+func BuildSuperRootProof(resp eth.SuperRootResponse, chainID *big.Int, rootClaim common.Hash) (withdrawals.SuperRootProof, *big.Int, error) {
+	super, err := resp.ToSuper()
+	if err != nil {
+		return withdrawals.SuperRootProof{}, nil, err
+	}
+	if computed := common.Hash(eth.SuperRoot(super)); computed != rootClaim {
+		return withdrawals.SuperRootProof{}, nil, fmt.Errorf("super root mismatch: the supervisor's super root at timestamp %d hashes to %s, but the game claims %s - the supervisor may be out of sync",
+			resp.Timestamp, computed, rootClaim)
+	}
+
+	var index *big.Int
+	outputRoots := make([]withdrawals.SuperRootProofOutputRoot, len(resp.Chains))
+	for i, chain := range resp.Chains {
+		outputRoots[i] = withdrawals.SuperRootProofOutputRoot{
+			ChainID: chain.ChainID.ToBig(),
+			Root:    common.Hash(chain.Canonical),
+		}
+		if outputRoots[i].ChainID.Cmp(chainID) == 0 {
+			index = big.NewInt(int64(i))
+		}
+	}
+	if index == nil {
+		return withdrawals.SuperRootProof{}, nil, fmt.Errorf("L2 chain %s is not in the super root's dependency set", chainID)
+	}
+	return withdrawals.SuperRootProof{
+		Version:     [1]byte{resp.Version},
+		Timestamp:   resp.Timestamp,
+		OutputRoots: outputRoots,
+	}, index, nil
+}
+
+// blockAtTimestamp returns the number of the L2 block at timestamp, from the block time between header and its parent.
+func blockAtTimestamp(ctx context.Context, l2 *ethclient.Client, header *types.Header, timestamp uint64) (*big.Int, error) {
+	if header.Number.Sign() == 0 {
+		return nil, errors.New("cannot derive the L2 block time from the genesis block")
+	}
+	parent, err := l2.HeaderByHash(ctx, header.ParentHash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L2 block %d: %w", header.Number.Uint64()-1, err)
+	}
+	blockTime := header.Time - parent.Time
+	if blockTime == 0 || timestamp < header.Time {
+		return nil, fmt.Errorf("cannot derive the L2 block at timestamp %d from block %d", timestamp, header.Number)
+	}
+	return new(big.Int).Add(header.Number, new(big.Int).SetUint64((timestamp-header.Time)/blockTime)), nil
+}
+
+// withdrawalTimestamp returns the timestamp of the L2 block including the withdrawal, which super root games are
+// ordered by instead of the L2 block number.
+func withdrawalTimestamp(ctx context.Context, l2 *ethclient.Client, l2BlockNumber *big.Int) (uint64, *types.Header, error) {
+	header, err := l2.HeaderByNumber(ctx, l2BlockNumber)
+	if err != nil {
+		return 0, nil, fmt.Errorf("error querying withdrawal tx block: %w", err)
+	}
+	return header.Time, header, nil
+}
+
+// GameTarget returns what a dispute game must propose to cover a withdrawal included in L2 block l2BlockNumber: the
+// block number, or the block's timestamp when the portal proves against super roots, as their games propose
+// timestamps. superRoots reports which of the two it is.
+func GameTarget(ctx context.Context, l1 bind.ContractCaller, l2 *ethclient.Client, portal common.Address, l2BlockNumber *big.Int) (target *big.Int, superRoots bool, err error) {
+	if !SuperRootsActive(l1, portal) {
+		return l2BlockNumber, false, nil
+	}
+	timestamp, _, err := withdrawalTimestamp(ctx, l2, l2BlockNumber)
+	if err != nil {
+		return nil, true, err
+	}
+	return new(big.Int).SetUint64(timestamp), true, nil
+}
+
+// proveWithdrawalSuperRoot proves the withdrawal against the super root of the earliest game covering it, for
+// portals of chains in the interop set.
+func (w *FPWithdrawer) proveWithdrawalSuperRoot() error {
+	if w.Supervisor == nil {
+		return errors.New("the portal proves withdrawals against super roots, which requires a supervisor RPC")
+	}
+
+	l2 := ethclient.NewClient(w.L2Client)
+
+	receipt, _, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
+	if err != nil {
+		return err
+	}
+	timestamp, withdrawalHeader, err := withdrawalTimestamp(w.Ctx, l2, receipt.BlockNumber)
+	if err != nil {
+		return err
+	}
+
+	// super root games propose a timestamp, so the earliest game at or past the withdrawal's block time covers it
+	game, err := w.findGame(new(big.Int).SetUint64(timestamp))
+	if err != nil {
+		return err
+	}
+	gameTimestamp := gameL2BlockNumber(*game).Uint64()
+
+	rootClaim := common.Hash(game.RootClaim)
+	if w.Quorum != nil {
+		rootClaim, err = quorumRead(w.Quorum, "game root claim", w.L1Client, func(caller bind.ContractCaller) (common.Hash, error) {
+			return NewDisputeGame(gameProxy(*game), caller).RootClaim()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	superRoot, err := FetchSuperRoot(w.Ctx, w.Supervisor, gameTimestamp)
+	if err != nil {
+		return err
+	}
+	chainID, err := l2.ChainID(w.Ctx)
+	if err != nil {
+		return fmt.Errorf("error querying L2 chain ID: %w", err)
+	}
+	superRootProof, outputRootIndex, err := BuildSuperRootProof(superRoot, chainID, rootClaim)
+	if err != nil {
+		return err
+	}
+
+	l2BlockNumber, err := blockAtTimestamp(w.Ctx, l2, withdrawalHeader, gameTimestamp)
+	if err != nil {
+		return err
+	}
+	outputRoot := superRootProof.OutputRoots[outputRootIndex.Int64()].Root
+	w.Proof = &ProofMetadata{
+		GameIndex:   game.Index,
+		GameAddress: gameProxy(*game),
+		GameType:    gameType(*game),
+		L2Block:     l2BlockNumber,
+		OutputRoot:  outputRoot,
+	}
+	log.Info("Proving against super root dispute game", append(w.Proof.logFields(), "superRoot", rootClaim, "timestamp", gameTimestamp, "outputRootIndex", outputRootIndex)...)
+
+	_, params, err := fetchProofParameters(w.Ctx, w.ProofSources, w.L2Client, receipt, l2BlockNumber, game.Index,
+		outputRootVerifier(w.Ctx, outputRoot, w.VerifyL2Client, w.L2TxHash, w.LogIndex, game.Index))
+	if err != nil {
+		return err
+	}
+
+	withdrawalTx := bindingspreview.TypesWithdrawalTransaction{
+		Nonce:    params.Nonce,
+		Sender:   params.Sender,
+		Target:   params.Target,
+		Value:    params.Value,
+		GasLimit: params.GasLimit,
+		Data:     params.Data,
+	}
+	var backend bind.ContractBackend = w.L1Client
+	if w.Backend != nil {
+		backend = w.Backend
+	}
+	portal := bind.NewBoundContract(w.PortalAddress, superRootPortalParsedABI, backend, backend, backend)
+	prove := func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return portal.Transact(opts, "proveWithdrawalTransaction",
+			withdrawalTx,
+			gameProxy(*game),
+			outputRootIndex,
+			superRootProof,
+			params.OutputRootProof,
+			params.WithdrawalProof,
+		)
+	}
+
+	// Prepare gas options with multiplier if configured
+	simulatedTx, err := prepareGasOpts(w.Opts, w.UserGasLimit, w.GasMultiplier, w.DryRun, prove)
+	if err != nil {
+		return err
+	}
+
+	if w.DryRun {
+		printDryRun("ProveWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit, w.ETHUSD)
+		w.dryRunTx, err = dryRunTx(w.Ctx, w.L1Client, simulatedTx, w.Opts.GasLimit)
+		return err
+	}
+
+	cost, err := w.portalTxs().sendPortalTx(w.Ctx, ActionProve, prove)
+	if err != nil {
+		return err
+	}
+	w.Costs = append(w.Costs, cost)
+	return nil
+}
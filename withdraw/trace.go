@@ -0,0 +1,407 @@
+package withdraw
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-node/bindings"
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Milestones of a withdrawal's timeline, in the order they happen.
+const (
+	MilestoneInitiated = "initiated" // Withdrawal sent on L2
+	MilestoneCovered   = "covered"   // First dispute game (or L2 output) proposed past the withdrawal's L2 block
+	MilestoneProven    = "proven"    // Proof submitted on L1
+	MilestoneMatured   = "matured"   // Proof maturity and game finality (or finalization period) elapsed
+	MilestoneFinalized = "finalized" // Withdrawal executed on L1
+	MilestonePending   = "pending"   // Transaction sent by a previous run, not confirmed yet
+)
+
+var (
+	disputeGameCreatedTopic         = crypto.Keccak256Hash([]byte("DisputeGameCreated(address,uint32,bytes32)"))
+	outputProposedTopic             = crypto.Keccak256Hash([]byte("OutputProposed(bytes32,uint256,uint256,uint256)"))
+	withdrawalProvenExtension1Topic = crypto.Keccak256Hash([]byte("WithdrawalProvenExtension1(bytes32,address)"))
+)
+
+// TraceEvent is a milestone of a withdrawal's timeline. Milestones that aren't reached by a transaction, such as the
+// proof maturing, have no tx hash.
+type TraceEvent struct {
+	Milestone   string       `json:"milestone"`
+	Time        uint64       `json:"time,omitempty"`      // Unix timestamp, 0 if unknown
+	Estimated   bool         `json:"estimated,omitempty"` // Whether Time is expected rather than observed
+	Chain       string       `json:"chain"`               // "L1" or "L2"
+	BlockNumber uint64       `json:"blockNumber,omitempty"`
+	TxHash      *common.Hash `json:"txHash,omitempty"`
+	Detail      string       `json:"detail,omitempty"`
+}
+
+// Trace is the timeline of a withdrawal, assembled from on-chain data, with events in the order they happened.
+type Trace struct {
+	WithdrawalHash common.Hash  `json:"withdrawalHash"`
+	L2TxHash       common.Hash  `json:"l2TxHash"`
+	State          State        `json:"state"`
+	Delays         *Delays      `json:"delays,omitempty"` // Portal delays the milestones after proving wait for
+	Events         []TraceEvent `json:"events"`
+	// Transfer the L1 bridge makes on finalization, for withdrawals through the standard or ERC-721 bridge
+	BridgeTransfer *BridgeTransfer `json:"bridgeTransfer,omitempty"`
+	// Call the withdrawal makes on L1 once finalized, with its downstream target, value and method
+	L1Call *L1Call `json:"l1Call,omitempty"`
+	// Simulation of the call finalizing the withdrawal ends in, if it isn't finalized yet
+	FinalizeSimulation *FinalizeSimulation `json:"finalizeSimulation,omitempty"`
+}
+
+// tracer holds what the milestones of a trace are looked up with.
+type tracer struct {
+	ctx     context.Context
+	l1      *ethclient.Client
+	head    *types.Header
+	details *WithdrawalDetails
+	trace   *Trace
+	// fromBlock bounds log searches from below, as nothing on L1 can happen for a withdrawal before it was initiated
+	fromBlock uint64
+}
+
+func newTracer(ctx context.Context, l1 *ethclient.Client, l2 *ethclient.Client, l2TxHash common.Hash, logIndex *uint) (*tracer, error) {
+	receipt, err := l2.TransactionReceipt(ctx, l2TxHash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying withdrawal receipt: %w", err)
+	}
+	if receipt, err = SelectOnlyWithdrawal(receipt, logIndex); err != nil {
+		return nil, err
+	}
+	details, err := DecodeWithdrawal(receipt)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding withdrawal: %w", err)
+	}
+	header, err := l2.HeaderByHash(ctx, receipt.BlockHash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L2 block %s: %w", receipt.BlockNumber, err)
+	}
+	head, err := l1.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L1 head: %w", err)
+	}
+
+	t := &tracer{ctx: ctx, l1: l1, head: head, details: details}
+	t.trace = &Trace{
+		WithdrawalHash: details.Hash,
+		L2TxHash:       l2TxHash,
+		BridgeTransfer: details.BridgeTransfer(),
+		L1Call:         details.L1Call(),
+		Events: []TraceEvent{{
+			Milestone:   MilestoneInitiated,
+			Time:        header.Time,
+			Chain:       "L2",
+			BlockNumber: receipt.BlockNumber.Uint64(),
+			TxHash:      &l2TxHash,
+		}},
+	}
+	if t.fromBlock, err = t.blockAtTimestamp(header.Time); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// blockAtTimestamp returns the number of the first L1 block with a timestamp at or after timestamp, or the head if
+// there is none yet. Contracts record the timestamp of the block they were called in, so the block of such an event
+// is found exactly.
+func (t *tracer) blockAtTimestamp(timestamp uint64) (uint64, error) {
+	lo, hi := uint64(0), t.head.Number.Uint64()
+	if timestamp > t.head.Time {
+		return hi, nil
+	}
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		header, err := t.l1.HeaderByNumber(t.ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, fmt.Errorf("error querying L1 block %d: %w", mid, err)
+		}
+		if header.Time < timestamp {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// findLog returns the first log of the contract matching topics in blocks from to to inclusive, or nil if there is
+// none.
+func (t *tracer) findLog(contract common.Address, topics [][]common.Hash, from uint64, to uint64) (*types.Log, error) {
+	for start := from; start <= to; start += historyBlockRange {
+		end := min(start+historyBlockRange-1, to)
+		logs, err := t.l1.FilterLogs(t.ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(start),
+			ToBlock:   new(big.Int).SetUint64(end),
+			Addresses: []common.Address{contract},
+			Topics:    topics,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error querying events in L1 blocks %d-%d: %w", start, end, err)
+		}
+		for i := range logs {
+			if !logs[i].Removed {
+				return &logs[i], nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// addAt records a milestone reached at timestamp by a transaction emitting a log of the contract matching topics, which
+// is looked up in the block at that timestamp. The milestone is recorded without a tx hash if the log isn't found.
+func (t *tracer) addAt(milestone string, timestamp uint64, contract common.Address, topics [][]common.Hash, detail string) error {
+	block, err := t.blockAtTimestamp(timestamp)
+	if err != nil {
+		return err
+	}
+	e := TraceEvent{Milestone: milestone, Time: timestamp, Chain: "L1", BlockNumber: block, Detail: detail}
+	l, err := t.findLog(contract, topics, block, block)
+	if err != nil {
+		return err
+	}
+	if l != nil {
+		e.TxHash = &l.TxHash
+	} else {
+		log.Debug("Transaction of the milestone not found", "milestone", milestone, "block", block)
+	}
+	t.trace.Events = append(t.trace.Events, e)
+	return nil
+}
+
+// addMatured records when the withdrawal is or was expected to become finalizable. The time is an estimate while it is
+// in the future or depends on an unresolved game, otherwise it is the first L1 block the withdrawal was finalizable in.
+func (t *tracer) addMatured(e *FinalizationEstimate, detail string) error {
+	event := TraceEvent{Milestone: MilestoneMatured, Time: e.FinalizableAt, Chain: "L1", Detail: detail}
+	if e.FinalizableAt > t.head.Time || e.GameResolutionEstimated {
+		event.Estimated = true
+	} else {
+		block, err := t.blockAtTimestamp(e.FinalizableAt)
+		if err != nil {
+			return err
+		}
+		event.BlockNumber = block
+	}
+	t.trace.Events = append(t.trace.Events, event)
+	return nil
+}
+
+// addFinalized records the finalization of the withdrawal from the portal's WithdrawalFinalized event, searching from
+// the given L1 block up to the head.
+func (t *tracer) addFinalized(portal common.Address, from uint64) error {
+	l, err := t.findLog(portal, [][]common.Hash{{withdrawalFinalizedTopic}, {t.details.Hash}}, from, t.head.Number.Uint64())
+	if err != nil {
+		return err
+	}
+	if l == nil {
+		return fmt.Errorf("the withdrawal is finalized, but its WithdrawalFinalized event was not found after L1 block %d", from)
+	}
+	header, err := t.l1.HeaderByHash(t.ctx, l.BlockHash)
+	if err != nil {
+		return fmt.Errorf("error querying L1 block %d: %w", l.BlockNumber, err)
+	}
+	e := TraceEvent{Milestone: MilestoneFinalized, Time: header.Time, Chain: "L1", BlockNumber: l.BlockNumber, TxHash: &l.TxHash}
+	if len(l.Data) == 32 && l.Data[31] == 0 {
+		e.Detail = "the withdrawal's L1 call failed"
+	}
+	t.trace.Events = append(t.trace.Events, e)
+	return nil
+}
+
+// lastBlock returns the L1 block of the latest transaction traced so far, which later milestones can't precede. The
+// maturity of the traced proof is skipped, as the withdrawal may have been finalized with another submitter's proof.
+func (t *tracer) lastBlock() uint64 {
+	from := t.fromBlock
+	for _, e := range t.trace.Events {
+		if e.Chain == "L1" && e.TxHash != nil && e.BlockNumber > from {
+			from = e.BlockNumber
+		}
+	}
+	return from
+}
+
+// simulateFinalization simulates the call finalizing the withdrawal ends in, unless it is already finalized, so that
+// a finalization that would not deliver the withdrawal is flagged before proving it.
+func (t *tracer) simulateFinalization(portal common.Address, token *GasToken) {
+	if t.trace.State == StateFinalized {
+		return
+	}
+	sim, err := SimulateFinalization(t.ctx, t.l1, portal, t.details, token)
+	if err != nil {
+		log.Warn("Unable to simulate the withdrawal's finalization", "error", err)
+		return
+	}
+	t.trace.FinalizeSimulation = sim
+}
+
+// TraceFaultProofs assembles the timeline of a withdrawal on a fault proof portal. The proof traced is submitter's, or
+// the first submitted one if submitter is the zero address or didn't prove the withdrawal. The withdrawal is the one
+// at logIndex of the L2 tx, which must be set for txs that initiated several, see SelectOnlyWithdrawal.
+func TraceFaultProofs(ctx context.Context, l1 *ethclient.Client, l2 *ethclient.Client, portalAddress common.Address, factoryAddress common.Address, l2TxHash common.Hash, logIndex *uint, submitter common.Address, token *GasToken) (*Trace, error) {
+	t, err := newTracer(ctx, l1, l2, l2TxHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	portal, err := bindingspreview.NewOptimismPortal2Caller(portalAddress, l1)
+	if err != nil {
+		return nil, fmt.Errorf("error binding OptimismPortal2 contract: %w", err)
+	}
+	factory, err := bindings.NewDisputeGameFactoryCaller(factoryAddress, l1)
+	if err != nil {
+		return nil, fmt.Errorf("error binding DisputeGameFactory contract: %w", err)
+	}
+	hash := t.details.Hash
+	if t.trace.Delays, err = ReadDelays(portal); err != nil {
+		return nil, err
+	}
+
+	target, _, err := GameTarget(ctx, l1, l2, portalAddress, t.details.L2BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if game, err := FindEarliestGame(ctx, factory, portal, target); err != nil {
+		log.Debug("No dispute game covers the withdrawal yet", "error", err)
+	} else {
+		proxy := gameProxy(*game)
+		topics := [][]common.Hash{{disputeGameCreatedTopic}, {common.BytesToHash(proxy.Bytes())}}
+		if err := t.addAt(MilestoneCovered, game.Timestamp, factoryAddress, topics, fmt.Sprintf("dispute game %s (%s)", game.Index, proxy)); err != nil {
+			return nil, err
+		}
+	}
+
+	proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash, submitter)
+	if err != nil {
+		return nil, fmt.Errorf("error querying proven withdrawal: %w", err)
+	}
+	if proven.Timestamp == 0 {
+		numSubmitters, err := portal.NumProofSubmitters(&bind.CallOpts{}, hash)
+		if err != nil {
+			return nil, fmt.Errorf("error querying proof submitters: %w", err)
+		}
+		if numSubmitters.Sign() > 0 {
+			if submitter, err = portal.ProofSubmitters(&bind.CallOpts{}, hash, common.Big0); err != nil {
+				return nil, fmt.Errorf("error querying proof submitters: %w", err)
+			}
+			if proven, err = portal.ProvenWithdrawals(&bind.CallOpts{}, hash, submitter); err != nil {
+				return nil, fmt.Errorf("error querying proven withdrawal: %w", err)
+			}
+		}
+	}
+	if proven.Timestamp > 0 {
+		topics := [][]common.Hash{{withdrawalProvenExtension1Topic}, {hash}, {common.BytesToHash(submitter.Bytes())}}
+		detail := fmt.Sprintf("by %s against dispute game %s", submitter, proven.DisputeGameProxy)
+		if err := t.addAt(MilestoneProven, proven.Timestamp, portalAddress, topics, detail); err != nil {
+			return nil, err
+		}
+		if e, err := EstimateFinalization(portal, l1, hash, submitter); err != nil {
+			log.Debug("Unable to estimate when the withdrawal matures", "error", err)
+		} else if err := t.addMatured(e, fmt.Sprintf("proof matured at %d, dispute game final at %d", e.ProofMaturesAt, e.GameFinalAt)); err != nil {
+			return nil, err
+		}
+	}
+
+	finalized, err := portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying withdrawal finalization status: %w", err)
+	}
+	if finalized {
+		if err := t.addFinalized(portalAddress, t.lastBlock()); err != nil {
+			return nil, err
+		}
+	}
+	t.trace.State = StateFromEvidence(finalized, proven.Timestamp > 0)
+	t.simulateFinalization(portalAddress, token)
+	return t.trace, nil
+}
+
+// TraceLegacy assembles the timeline of a withdrawal on a legacy portal, where it is covered by an L2OutputOracle
+// output instead of a dispute game. The withdrawal is selected by logIndex as with TraceFaultProofs.
+func TraceLegacy(ctx context.Context, l1 *ethclient.Client, l2 *ethclient.Client, portalAddress common.Address, oracleAddress common.Address, l2TxHash common.Hash, logIndex *uint, token *GasToken) (*Trace, error) {
+	t, err := newTracer(ctx, l1, l2, l2TxHash, logIndex)
+	if err != nil {
+		return nil, err
+	}
+	portal, err := bindings.NewOptimismPortalCaller(portalAddress, l1)
+	if err != nil {
+		return nil, fmt.Errorf("error binding OptimismPortal contract: %w", err)
+	}
+	oracle, err := bindings.NewL2OutputOracleCaller(oracleAddress, l1)
+	if err != nil {
+		return nil, fmt.Errorf("error binding L2OutputOracle contract: %w", err)
+	}
+	hash := t.details.Hash
+	if t.trace.Delays, err = ReadLegacyDelays(oracle); err != nil {
+		return nil, err
+	}
+
+	// the oracle reverts if no output covers the block yet
+	if index, err := oracle.GetL2OutputIndexAfter(&bind.CallOpts{}, t.details.L2BlockNumber); err != nil {
+		log.Debug("No L2 output covers the withdrawal yet", "error", err)
+	} else {
+		output, err := oracle.GetL2Output(&bind.CallOpts{}, index)
+		if err != nil {
+			return nil, fmt.Errorf("error querying L2 output %s: %w", index, err)
+		}
+		topics := [][]common.Hash{{outputProposedTopic}, {output.OutputRoot}, {common.BigToHash(index)}}
+		if err := t.addAt(MilestoneCovered, output.Timestamp.Uint64(), oracleAddress, topics, fmt.Sprintf("L2 output %s for L2 block %s", index, output.L2BlockNumber)); err != nil {
+			return nil, err
+		}
+	}
+
+	proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying proven withdrawal: %w", err)
+	}
+	if proven.Timestamp.Sign() > 0 {
+		topics := [][]common.Hash{{withdrawalProvenTopic}, {hash}}
+		if err := t.addAt(MilestoneProven, proven.Timestamp.Uint64(), portalAddress, topics, fmt.Sprintf("against L2 output %s", proven.L2OutputIndex)); err != nil {
+			return nil, err
+		}
+		if e, err := EstimateLegacyFinalization(portal, oracle, hash); err != nil {
+			log.Debug("Unable to estimate when the withdrawal matures", "error", err)
+		} else if err := t.addMatured(e, fmt.Sprintf("proof matured at %d, L2 output final at %d", e.ProofMaturesAt, e.OutputFinalAt)); err != nil {
+			return nil, err
+		}
+	}
+
+	finalized, err := portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying withdrawal finalization status: %w", err)
+	}
+	if finalized {
+		if err := t.addFinalized(portalAddress, t.lastBlock()); err != nil {
+			return nil, err
+		}
+	}
+	t.trace.State = StateFromEvidence(finalized, proven.Timestamp.Sign() > 0)
+	t.simulateFinalization(portalAddress, token)
+	return t.trace, nil
+}
+
+// PendingEvent returns the withdrawal's pending transaction as a timeline event, or nil if there is none.
+func (j *Journal) PendingEvent() (*TraceEvent, error) {
+	entries, err := j.read()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := entries[j.L2TxHash]
+	if !ok {
+		return nil, nil
+	}
+	return &TraceEvent{
+		Milestone: MilestonePending,
+		Time:      uint64(entry.RecordedAt.Unix()),
+		Chain:     "L1",
+		TxHash:    &entry.TxHash,
+		Detail:    "sent by a previous run, not confirmed yet",
+	}, nil
+}
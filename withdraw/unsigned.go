@@ -0,0 +1,111 @@
+package withdraw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// UnsignedTx is an EIP-1559 transaction as built by a dry run, with its nonce, gas limit and fees, to be signed on
+// another machine, e.g. an air-gapped one, and broadcast later.
+type UnsignedTx struct {
+	ChainID              *hexutil.Big   `json:"chainId"`
+	From                 common.Address `json:"from"` // Address expected to sign it, whose nonce it has
+	To                   common.Address `json:"to"`
+	Nonce                hexutil.Uint64 `json:"nonce"`
+	Gas                  hexutil.Uint64 `json:"gas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big   `json:"value"`
+	Data                 hexutil.Bytes  `json:"data"`
+	Method               string         `json:"method,omitempty"` // Portal method called, e.g. proveWithdrawalTransaction
+	SigningHash          common.Hash    `json:"signingHash"`      // Hash the signer signs, to check on the signing device
+	RLP                  hexutil.Bytes  `json:"rlp"`              // Signing payload: 0x02 followed by the RLP of the unsigned fields
+}
+
+// NewUnsignedTx returns the unsigned EIP-1559 transaction for the from address to sign. Legacy transactions, which a
+// --gas-price forces, aren't supported.
+func NewUnsignedTx(from common.Address, tx *types.Transaction) (*UnsignedTx, error) {
+	if tx.Type() != types.DynamicFeeTxType {
+		return nil, errors.New("only EIP-1559 transactions can be exported unsigned, don't pass --gas-price")
+	}
+	if tx.To() == nil {
+		return nil, errors.New("contract creations can't be exported unsigned")
+	}
+	if tx.ChainId() == nil || tx.ChainId().Sign() == 0 {
+		return nil, errors.New("transaction has no chain ID, it can't be signed offline")
+	}
+	signer := types.LatestSignerForChainID(tx.ChainId())
+	u := &UnsignedTx{
+		ChainID:              (*hexutil.Big)(tx.ChainId()),
+		From:                 from,
+		To:                   *tx.To(),
+		Nonce:                hexutil.Uint64(tx.Nonce()),
+		Gas:                  hexutil.Uint64(tx.Gas()),
+		MaxFeePerGas:         (*hexutil.Big)(tx.GasFeeCap()),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tx.GasTipCap()),
+		Value:                (*hexutil.Big)(tx.Value()),
+		Data:                 tx.Data(),
+		Method:               NewCalldata(tx).Method,
+		SigningHash:          signer.Hash(tx),
+	}
+	payload, err := signingPayload(tx)
+	if err != nil {
+		return nil, err
+	}
+	u.RLP = payload
+	return u, nil
+}
+
+// Transaction returns the transaction to sign, after checking that its signing hash and payload are those of its
+// fields, so a file edited by hand or damaged in transit is caught before anything is signed.
+func (u *UnsignedTx) Transaction() (*types.Transaction, error) {
+	if u.ChainID == nil || u.MaxFeePerGas == nil || u.MaxPriorityFeePerGas == nil || u.Value == nil {
+		return nil, errors.New("unsigned transaction is missing its chain ID, fees or value")
+	}
+	to := u.To
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   u.ChainID.ToInt(),
+		Nonce:     uint64(u.Nonce),
+		GasTipCap: u.MaxPriorityFeePerGas.ToInt(),
+		GasFeeCap: u.MaxFeePerGas.ToInt(),
+		Gas:       uint64(u.Gas),
+		To:        &to,
+		Value:     u.Value.ToInt(),
+		Data:      u.Data,
+	})
+	if hash := types.LatestSignerForChainID(tx.ChainId()).Hash(tx); hash != u.SigningHash {
+		return nil, fmt.Errorf("unsigned transaction fields hash to %s, not its signing hash %s", hash, u.SigningHash)
+	}
+	payload, err := signingPayload(tx)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(payload, u.RLP) {
+		return nil, errors.New("unsigned transaction fields don't match its RLP")
+	}
+	return tx, nil
+}
+
+// MaxCost returns the most the transaction can cost its sender: its gas limit at the max fee per gas, plus its value.
+func (u *UnsignedTx) MaxCost() *big.Int {
+	cost := new(big.Int).Mul(u.MaxFeePerGas.ToInt(), new(big.Int).SetUint64(uint64(u.Gas)))
+	return cost.Add(cost, u.Value.ToInt())
+}
+
+// signingPayload returns what the signer of the EIP-1559 transaction signs the hash of.
+func signingPayload(tx *types.Transaction) ([]byte, error) {
+	fields, err := rlp.EncodeToBytes([]interface{}{
+		tx.ChainId(), tx.Nonce(), tx.GasTipCap(), tx.GasFeeCap(), tx.Gas(), tx.To(), tx.Value(), tx.Data(), tx.AccessList(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{types.DynamicFeeTxType}, fields...), nil
+}
@@ -0,0 +1,322 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// EntryPointV07 is the canonical ERC-4337 v0.7 EntryPoint, deployed at the same address on every chain.
+var EntryPointV07 = common.HexToAddress("0x0000000071727De22E5E9d8BAf0edAc6f37da032")
+
+// bundlerRequestTimeout bounds how long to wait for a bundler or paymaster to answer a request.
+const bundlerRequestTimeout = 30 * time.Second
+
+// entryPointABI has the v0.7 EntryPoint methods needed to build and sign a UserOperation.
+const entryPointABI = `[
+	{"type":"function","name":"getNonce","stateMutability":"view","inputs":[
+		{"name":"sender","type":"address"},
+		{"name":"key","type":"uint192"}
+	],"outputs":[{"name":"nonce","type":"uint256"}]},
+	{"type":"function","name":"getUserOpHash","stateMutability":"view","inputs":[
+		{"name":"userOp","type":"tuple","components":[
+			{"name":"sender","type":"address"},
+			{"name":"nonce","type":"uint256"},
+			{"name":"initCode","type":"bytes"},
+			{"name":"callData","type":"bytes"},
+			{"name":"accountGasLimits","type":"bytes32"},
+			{"name":"preVerificationGas","type":"uint256"},
+			{"name":"gasFees","type":"bytes32"},
+			{"name":"paymasterAndData","type":"bytes"},
+			{"name":"signature","type":"bytes"}
+		]}
+	],"outputs":[{"name":"","type":"bytes32"}]}
+]`
+
+// smartAccountABI has the execute method of SimpleAccount-style smart accounts, through which the account makes a call.
+const smartAccountABI = `[
+	{"type":"function","name":"execute","stateMutability":"nonpayable","inputs":[
+		{"name":"dest","type":"address"},
+		{"name":"value","type":"uint256"},
+		{"name":"func","type":"bytes"}
+	],"outputs":[]}
+]`
+
+var (
+	entryPointParsedABI   = mustParseABI(entryPointABI)
+	smartAccountParsedABI = mustParseABI(smartAccountABI)
+)
+
+// dummySignature is a well-formed ECDSA signature, of a key nobody uses, that UserOperations carry while their gas is
+// estimated: accounts recover a signer from it without reverting, as they would from the owner's signature.
+var dummySignature = func() hexutil.Bytes {
+	key, err := crypto.ToECDSA(crypto.Keccak256([]byte("withdrawer dummy UserOperation signature")))
+	if err != nil {
+		panic(err)
+	}
+	sig, err := crypto.Sign(crypto.Keccak256([]byte("dummy")), key)
+	if err != nil {
+		panic(err)
+	}
+	sig[crypto.RecoveryIDOffset] += 27
+	return sig
+}()
+
+// UserOperation is an ERC-4337 v0.7 UserOperation, in the unpacked form bundlers and paymasters take over JSON-RPC.
+// The sender must be a deployed account, so it has no factory.
+type UserOperation struct {
+	Sender                        common.Address  `json:"sender"`
+	Nonce                         *hexutil.Big    `json:"nonce"`
+	CallData                      hexutil.Bytes   `json:"callData"`
+	CallGasLimit                  *hexutil.Big    `json:"callGasLimit"`
+	VerificationGasLimit          *hexutil.Big    `json:"verificationGasLimit"`
+	PreVerificationGas            *hexutil.Big    `json:"preVerificationGas"`
+	MaxFeePerGas                  *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas          *hexutil.Big    `json:"maxPriorityFeePerGas"`
+	Paymaster                     *common.Address `json:"paymaster,omitempty"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit,omitempty"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit,omitempty"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData,omitempty"`
+	Signature                     hexutil.Bytes   `json:"signature"`
+}
+
+// packedUserOperation is a UserOperation as the EntryPoint takes it.
+type packedUserOperation struct {
+	Sender             common.Address
+	Nonce              *big.Int
+	InitCode           []byte
+	CallData           []byte
+	AccountGasLimits   [32]byte
+	PreVerificationGas *big.Int
+	GasFees            [32]byte
+	PaymasterAndData   []byte
+	Signature          []byte
+}
+
+// pack returns the UserOperation as the EntryPoint takes it: gas limits and fees packed as two 128-bit halves, and
+// the paymaster fields concatenated.
+func (op *UserOperation) pack() packedUserOperation {
+	p := packedUserOperation{
+		Sender:             op.Sender,
+		Nonce:              op.Nonce.ToInt(),
+		CallData:           op.CallData,
+		AccountGasLimits:   packUint128s(op.VerificationGasLimit, op.CallGasLimit),
+		PreVerificationGas: op.PreVerificationGas.ToInt(),
+		GasFees:            packUint128s(op.MaxPriorityFeePerGas, op.MaxFeePerGas),
+		Signature:          op.Signature,
+	}
+	if op.Paymaster != nil {
+		limits := packUint128s(op.PaymasterVerificationGasLimit, op.PaymasterPostOpGasLimit)
+		p.PaymasterAndData = append(append(op.Paymaster.Bytes(), limits[:]...), op.PaymasterData...)
+	}
+	return p
+}
+
+// packUint128s packs the two values as the high and low 128 bits of a word. A nil value packs as zero.
+func packUint128s(high, low *hexutil.Big) [32]byte {
+	var word [32]byte
+	if high != nil {
+		high.ToInt().FillBytes(word[:16])
+	}
+	if low != nil {
+		low.ToInt().FillBytes(word[16:])
+	}
+	return word
+}
+
+// UserOpReceipt is the outcome of an included UserOperation, as the bundler reports it.
+type UserOpReceipt struct {
+	UserOpHash    common.Hash  `json:"userOpHash"`
+	Success       bool         `json:"success"`
+	ActualGasCost *hexutil.Big `json:"actualGasCost"`
+	ActualGasUsed *hexutil.Big `json:"actualGasUsed"`
+	Receipt       struct {
+		TransactionHash common.Hash  `json:"transactionHash"`
+		BlockNumber     *hexutil.Big `json:"blockNumber"`
+	} `json:"receipt"` // Receipt of the bundle transaction that included it
+}
+
+// Bundler sends calls from a smart account as ERC-4337 UserOperations through a bundler, with their gas optionally
+// sponsored by an ERC-7677 paymaster service, so the account needs no ETH of its own.
+type Bundler struct {
+	Client     *rpc.Client    // Bundler RPC
+	Paymaster  *rpc.Client    // ERC-7677 paymaster service RPC (nil means the account pays for its gas)
+	EntryPoint common.Address // v0.7 EntryPoint the bundler serves
+	Timing     *Timing
+}
+
+// NewUserOperation builds the UserOperation of the smart account making the call through its execute method, at the
+// account's next EntryPoint nonce and the given fees, with its gas estimated by the bundler and, with a paymaster,
+// sponsored. It carries a dummy signature until signed.
+func (b *Bundler) NewUserOperation(ctx context.Context, l1 *ethclient.Client, account common.Address, call *Calldata, maxFeePerGas, maxPriorityFeePerGas *big.Int) (*UserOperation, error) {
+	code, err := l1.CodeAt(ctx, account, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying the code of smart account %s: %w", account, err)
+	}
+	if len(code) == 0 {
+		return nil, fmt.Errorf("smart account %s is not deployed, deploy it before sending UserOperations from it", account)
+	}
+	var out []interface{}
+	if err := bind.NewBoundContract(b.EntryPoint, entryPointParsedABI, l1, nil, nil).Call(&bind.CallOpts{Context: ctx}, &out, "getNonce", account, common.Big0); err != nil {
+		return nil, fmt.Errorf("error querying the EntryPoint nonce of %s: %w", account, err)
+	}
+	callData, err := smartAccountParsedABI.Pack("execute", call.To, call.Value.ToInt(), []byte(call.Data))
+	if err != nil {
+		return nil, err
+	}
+	op := &UserOperation{
+		Sender:               account,
+		Nonce:                (*hexutil.Big)(out[0].(*big.Int)),
+		CallData:             callData,
+		CallGasLimit:         new(hexutil.Big),
+		VerificationGasLimit: new(hexutil.Big),
+		PreVerificationGas:   new(hexutil.Big),
+		MaxFeePerGas:         (*hexutil.Big)(maxFeePerGas),
+		MaxPriorityFeePerGas: (*hexutil.Big)(maxPriorityFeePerGas),
+		Signature:            dummySignature,
+	}
+
+	chainID, err := l1.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	// the paymaster's stub data has the size and gas of its final data, for the estimate to account for them
+	var stub paymasterData
+	if b.Paymaster != nil {
+		if err := b.call(ctx, b.Paymaster, &stub, "pm_getPaymasterStubData", op, b.EntryPoint, (*hexutil.Big)(chainID), struct{}{}); err != nil {
+			return nil, fmt.Errorf("error querying paymaster stub data: %w", err)
+		}
+		stub.apply(op)
+		if stub.Sponsor != nil {
+			log.Info("Gas is sponsored by paymaster", "paymaster", stub.Paymaster, "sponsor", stub.Sponsor.Name)
+		}
+	}
+
+	var estimate struct {
+		PreVerificationGas            *hexutil.Big `json:"preVerificationGas"`
+		VerificationGasLimit          *hexutil.Big `json:"verificationGasLimit"`
+		CallGasLimit                  *hexutil.Big `json:"callGasLimit"`
+		PaymasterVerificationGasLimit *hexutil.Big `json:"paymasterVerificationGasLimit"`
+	}
+	if err := b.call(ctx, b.Client, &estimate, "eth_estimateUserOperationGas", op, b.EntryPoint); err != nil {
+		return nil, fmt.Errorf("error estimating UserOperation gas: %w", err)
+	}
+	if estimate.PreVerificationGas == nil || estimate.VerificationGasLimit == nil || estimate.CallGasLimit == nil {
+		return nil, errors.New("bundler returned an incomplete UserOperation gas estimate")
+	}
+	op.PreVerificationGas, op.VerificationGasLimit, op.CallGasLimit = estimate.PreVerificationGas, estimate.VerificationGasLimit, estimate.CallGasLimit
+	if op.Paymaster != nil && estimate.PaymasterVerificationGasLimit != nil && op.PaymasterVerificationGasLimit == nil {
+		op.PaymasterVerificationGasLimit = estimate.PaymasterVerificationGasLimit
+	}
+
+	if b.Paymaster != nil && !stub.IsFinal {
+		var final paymasterData
+		if err := b.call(ctx, b.Paymaster, &final, "pm_getPaymasterData", op, b.EntryPoint, (*hexutil.Big)(chainID), struct{}{}); err != nil {
+			return nil, fmt.Errorf("error querying paymaster data: %w", err)
+		}
+		final.apply(op)
+	}
+	return op, nil
+}
+
+// paymasterData is the sponsorship an ERC-7677 paymaster service returns for a UserOperation.
+type paymasterData struct {
+	Paymaster                     *common.Address `json:"paymaster"`
+	PaymasterData                 hexutil.Bytes   `json:"paymasterData"`
+	PaymasterVerificationGasLimit *hexutil.Big    `json:"paymasterVerificationGasLimit"`
+	PaymasterPostOpGasLimit       *hexutil.Big    `json:"paymasterPostOpGasLimit"`
+	Sponsor                       *struct {
+		Name string `json:"name"`
+	} `json:"sponsor"`
+	IsFinal bool `json:"isFinal"` // The stub data is final, so there is no paymaster data to query after the estimate
+}
+
+// apply sets the paymaster fields of the UserOperation, keeping the gas limits it already has if none are returned.
+func (d *paymasterData) apply(op *UserOperation) {
+	op.Paymaster, op.PaymasterData = d.Paymaster, d.PaymasterData
+	if d.PaymasterVerificationGasLimit != nil {
+		op.PaymasterVerificationGasLimit = d.PaymasterVerificationGasLimit
+	}
+	if d.PaymasterPostOpGasLimit != nil {
+		op.PaymasterPostOpGasLimit = d.PaymasterPostOpGasLimit
+	}
+}
+
+// Hash returns the hash of the UserOperation its account's owner signs, as computed by the EntryPoint, so it matches
+// the EntryPoint's chain and version.
+func (b *Bundler) Hash(ctx context.Context, caller bind.ContractCaller, op *UserOperation) (common.Hash, error) {
+	var out []interface{}
+	if err := bind.NewBoundContract(b.EntryPoint, entryPointParsedABI, caller, nil, nil).Call(&bind.CallOpts{Context: ctx}, &out, "getUserOpHash", op.pack()); err != nil {
+		return common.Hash{}, fmt.Errorf("error querying the UserOperation hash from EntryPoint %s: %w", b.EntryPoint, err)
+	}
+	return common.Hash(out[0].([32]byte)), nil
+}
+
+// Send sends the signed UserOperation to the bundler and waits, up to the timeout, for it to be included in a bundle
+// transaction, then for that transaction to be confirmed on client. The returned cost is the UserOperation's share of
+// the bundle, as charged to the account or its paymaster.
+func (b *Bundler) Send(ctx context.Context, client *ethclient.Client, op *UserOperation, action Action, timeout time.Duration, confirmations uint64, waitFinalized bool) (*TxCost, error) {
+	var hash common.Hash
+	if err := b.call(ctx, b.Client, &hash, "eth_sendUserOperation", op, b.EntryPoint); err != nil {
+		return nil, fmt.Errorf("error sending UserOperation: %w", err)
+	}
+	log.Info("Sent UserOperation", "userOpHash", hash, "sender", op.Sender, "nonce", op.Nonce)
+
+	ctxWithTimeout, cancel := b.Timing.withTimeout(ctx, txTimeout(timeout))
+	defer cancel()
+	var receipt *UserOpReceipt
+	for {
+		if err := b.call(ctxWithTimeout, b.Client, &receipt, "eth_getUserOperationReceipt", hash); err != nil {
+			log.Warn("Unable to query UserOperation receipt", "userOpHash", hash, "error", err)
+		}
+		if receipt != nil {
+			break
+		}
+		select {
+		case <-ctxWithTimeout.Done():
+			return nil, fmt.Errorf("UserOperation %s was sent but not included: %w", hash, ctxWithTimeout.Err())
+		case <-b.Timing.After(b.Timing.confirmationPoll()):
+		}
+	}
+	if !receipt.Success {
+		return nil, fmt.Errorf("UserOperation %s was included in tx %s but its call reverted", hash, receipt.Receipt.TransactionHash)
+	}
+	log.Info("UserOperation included", "userOpHash", hash, "l1TxHash", receipt.Receipt.TransactionHash)
+
+	confirmed, err := waitForConfirmation(ctxWithTimeout, client, receipt.Receipt.TransactionHash, confirmations, waitFinalized, nil, b.Timing)
+	if err != nil {
+		return nil, fmt.Errorf("UserOperation %s was included in tx %s but not confirmed: %w", hash, receipt.Receipt.TransactionHash, err)
+	}
+	cost := TxCost{
+		Action:            string(action),
+		TxHash:            confirmed.TxHash,
+		BlockNumber:       confirmed.BlockNumber,
+		EffectiveGasPrice: new(big.Int),
+		Cost:              new(big.Int),
+	}
+	if receipt.ActualGasUsed != nil && receipt.ActualGasCost != nil {
+		cost.GasUsed = receipt.ActualGasUsed.ToInt().Uint64()
+		cost.Cost = receipt.ActualGasCost.ToInt()
+		if cost.GasUsed > 0 {
+			cost.EffectiveGasPrice = new(big.Int).Div(cost.Cost, new(big.Int).SetUint64(cost.GasUsed))
+		}
+	}
+	return &cost, nil
+}
+
+// call sends a JSON-RPC request to the bundler or paymaster, decoding its result into out.
+func (b *Bundler) call(ctx context.Context, client *rpc.Client, out interface{}, method string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, bundlerRequestTimeout)
+	defer cancel()
+	return client.CallContext(ctx, out, method, args...)
+}
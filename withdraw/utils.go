@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
@@ -15,6 +16,8 @@ import (
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
+
+	"github.com/base/withdrawer/price"
 )
 
 type WithdrawHelper interface {
@@ -23,47 +26,117 @@ type WithdrawHelper interface {
 	ProveWithdrawal() error
 	IsProofFinalized() (bool, error)
 	FinalizeWithdrawal() error
+	TxCosts() []TxCost
+	ProvenAgainst() *ProofMetadata
 }
 
-func txBlock(ctx context.Context, l2c *rpc.Client, l2TxHash common.Hash) (*big.Int, error) {
-	l2 := ethclient.NewClient(l2c)
-	// Figure out when our withdrawal was included
-	receipt, err := l2.TransactionReceipt(ctx, l2TxHash)
-	if err != nil {
-		return nil, err
-	}
-	if receipt.Status != types.ReceiptStatusSuccessful {
-		return nil, errors.New("unsuccessful withdrawal receipt status")
+// errTxReverted is returned when a submitted transaction is included but reverted.
+var errTxReverted = errors.New("unsuccessful withdrawal receipt status")
+
+// DefaultTxTimeout is how long to wait for a submitted transaction to be confirmed when no timeout is configured.
+const DefaultTxTimeout = 5 * time.Minute
+
+// txTimeout returns the configured timeout, falling back to DefaultTxTimeout if unset.
+func txTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return DefaultTxTimeout
 	}
-	return receipt.BlockNumber, nil
+	return timeout
 }
 
-func waitForConfirmation(ctx context.Context, client *ethclient.Client, tx common.Hash) error {
-	for {
+// waitForConfirmation polls until the tx is included in a successful receipt and, if requested, until its block
+// has the given number of confirmations or has been finalized. If the tx disappears while waiting for
+// confirmations (e.g. due to a shallow reorg), it goes back to waiting for inclusion. It returns the receipt of
+// the confirmed tx. Each poll logs the elapsed time, attempt number and L1 head, so long waits show progress.
+func waitForConfirmation(ctx context.Context, client *ethclient.Client, tx common.Hash, confirmations uint64, waitFinalized bool, faults Faults, timing *Timing) (*types.Receipt, error) {
+	included, reorged := false, false
+	start := timing.Now()
+	for attempt := 1; ; attempt++ {
+		progress := []interface{}{"elapsed", timing.since(start).Round(time.Second), "attempt", attempt}
+		if err := faults.injectTimeout(); err != nil {
+			return nil, err
+		}
 		receipt, err := client.TransactionReceipt(ctx, tx)
+		if err == nil && !reorged && faults.Has(FaultReorg) {
+			// pretend the tx was reorged out right after its inclusion was observed
+			log.Warn("Injecting fault", "fault", FaultReorg)
+			included, reorged = true, true
+			err = ethereum.NotFound
+		}
 		if err == ethereum.NotFound {
-			log.Info("Waiting for tx confirmation", "txHash", tx.String())
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(5 * time.Second):
+			if included {
+				log.Warn("Transaction no longer found, it may have been reorged out", "txHash", tx.String())
+				included = false
+			}
+			if head, err := client.BlockNumber(ctx); err == nil {
+				progress = append(progress, "l1Head", head)
 			}
+			log.Info("Waiting for tx confirmation", append([]interface{}{"txHash", tx.String()}, progress...)...)
 		} else if err != nil {
-			return err
+			return nil, err
 		} else if receipt.Status != types.ReceiptStatusSuccessful {
-			return errors.New("unsuccessful withdrawal receipt status")
+			return nil, errTxReverted
 		} else {
-			break
+			included = true
+			confirmed, err := isConfirmed(ctx, client, receipt, confirmations, waitFinalized, progress...)
+			if err != nil {
+				return nil, err
+			}
+			if confirmed {
+				log.Info("Transaction confirmed", "txHash", tx.String(), "block", receipt.BlockNumber, "elapsed", timing.since(start).Round(time.Second))
+				return receipt, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timing.After(timing.confirmationPoll()):
+		}
+	}
+}
+
+// isConfirmed reports whether the block containing the receipt has at least the given number of
+// confirmations (counting the block itself), and has been finalized if waitFinalized is set. The progress
+// key/value pairs are added to the logs while waiting.
+func isConfirmed(ctx context.Context, client *ethclient.Client, receipt *types.Receipt, confirmations uint64, waitFinalized bool, progress ...interface{}) (bool, error) {
+	if confirmations > 1 {
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return false, fmt.Errorf("error querying L1 head: %w", err)
+		}
+		have := uint64(0)
+		if head >= receipt.BlockNumber.Uint64() {
+			have = head - receipt.BlockNumber.Uint64() + 1
+		}
+		if have < confirmations {
+			log.Info("Waiting for block confirmations", append([]interface{}{"txHash", receipt.TxHash.String(), "block", receipt.BlockNumber, "l1Head", head, "confirmations", have, "required", confirmations}, progress...)...)
+			return false, nil
+		}
+	}
+
+	if waitFinalized {
+		finalized, err := client.HeaderByNumber(ctx, big.NewInt(int64(rpc.FinalizedBlockNumber)))
+		if err != nil {
+			return false, fmt.Errorf("error querying finalized L1 block: %w", err)
+		}
+		if finalized.Number.Cmp(receipt.BlockNumber) < 0 {
+			log.Info("Waiting for block finalization", append([]interface{}{"txHash", receipt.TxHash.String(), "block", receipt.BlockNumber, "finalized", finalized.Number}, progress...)...)
+			return false, nil
 		}
 	}
-	log.Info("Transaction confirmed", "txHash", tx.String())
-	return nil
+
+	return true, nil
+}
+
+// unsignedSigner is the signer of transactions that are built but never sent, which leaves them unsigned.
+func unsignedSigner(_ common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	return tx, nil
 }
 
 // prepareGasOpts resets the gas limit, applies gas multiplier if needed, and
 // optionally simulates the transaction for dry-run mode. The simulateFn should
 // perform a NoSend transaction and return the resulting *types.Transaction.
-// Returns the simulated tx when a simulation was performed, or nil otherwise.
+// Returns the simulated tx, unsigned, when a simulation was performed, or nil otherwise.
 func prepareGasOpts(opts *bind.TransactOpts, userGasLimit uint64, gasMultiplier float64, dryRun bool,
 	simulateFn func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
 	// Reset gas limit to user-specified value (0 = auto-estimate) before each transaction
@@ -71,9 +144,10 @@ func prepareGasOpts(opts *bind.TransactOpts, userGasLimit uint64, gasMultiplier
 
 	// Simulate when dry-run is requested or when we need to apply a gas multiplier
 	if dryRun || (gasMultiplier > 1.0 && userGasLimit == 0) {
-		// Create a copy for simulation
+		// Create a copy for simulation, which is never sent so isn't signed either: keyless runs can simulate too
 		simulateOpts := *opts
 		simulateOpts.NoSend = true
+		simulateOpts.Signer = unsignedSigner
 
 		simulatedTx, err := simulateFn(&simulateOpts)
 		if err != nil {
@@ -92,7 +166,55 @@ func prepareGasOpts(opts *bind.TransactOpts, userGasLimit uint64, gasMultiplier
 	return nil, nil
 }
 
-func printDryRun(action string, tx *types.Transaction, from common.Address, gasOverride uint64) {
+// dryRunTx returns the simulated tx as it would be sent: for the L1 chain, which the pass-through signer of the
+// simulation leaves unset, and with the gas limit set by the user or the gas multiplier instead of the estimate.
+func dryRunTx(ctx context.Context, client *ethclient.Client, tx *types.Transaction, gasLimit uint64) (*types.Transaction, error) {
+	chainID, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error querying L1 chain ID: %w", err)
+	}
+	return withChainAndGasLimit(tx, chainID, gasLimit), nil
+}
+
+// withChainAndGasLimit returns the simulated tx for the chain, with the gas limit it would be sent with instead of
+// the estimate when one is set. Legacy transactions carry their chain ID in their signature only, so only their gas
+// limit is replaced.
+func withChainAndGasLimit(tx *types.Transaction, chainID *big.Int, gasLimit uint64) *types.Transaction {
+	if gasLimit == 0 {
+		gasLimit = tx.Gas()
+	}
+	switch tx.Type() {
+	case types.DynamicFeeTxType:
+		if gasLimit == tx.Gas() && tx.ChainId().Cmp(chainID) == 0 {
+			return tx
+		}
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:   chainID,
+			Nonce:     tx.Nonce(),
+			GasTipCap: tx.GasTipCap(),
+			GasFeeCap: tx.GasFeeCap(),
+			Gas:       gasLimit,
+			To:        tx.To(),
+			Value:     tx.Value(),
+			Data:      tx.Data(),
+		})
+	case types.LegacyTxType:
+		if gasLimit == tx.Gas() {
+			return tx
+		}
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: tx.GasPrice(),
+			Gas:      gasLimit,
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		})
+	}
+	return tx
+}
+
+func printDryRun(action string, tx *types.Transaction, from common.Address, gasOverride uint64, ethUSD float64) {
 	gas := tx.Gas()
 	if gasOverride > 0 {
 		gas = gasOverride
@@ -115,6 +237,9 @@ func printDryRun(action string, tx *types.Transaction, from common.Address, gasO
 			"maxPriority", tx.GasTipCap().String(),
 			"maxCostETH", maxCostEth.Text('f', 8),
 		)
+		if ethUSD > 0 {
+			logFields = append(logFields, "maxCostUSD", fmt.Sprintf("%.2f", price.ToUSD(maxCost, ethUSD)))
+		}
 	} else {
 		gasPrice := tx.GasPrice()
 		cost := new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(gas))
@@ -123,6 +248,9 @@ func printDryRun(action string, tx *types.Transaction, from common.Address, gasO
 			"gasPrice", gasPrice.String(),
 			"estimatedCostETH", costEth.Text('f', 8),
 		)
+		if ethUSD > 0 {
+			logFields = append(logFields, "estimatedCostUSD", fmt.Sprintf("%.2f", price.ToUSD(cost, ethUSD)))
+		}
 	}
 
 	data := hex.EncodeToString(tx.Data())
@@ -133,3 +261,22 @@ func printDryRun(action string, tx *types.Transaction, from common.Address, gasO
 
 	log.Info("DRY RUN", logFields...)
 }
+
+// FormatEther formats a wei amount as a decimal ETH string.
+func FormatEther(wei *big.Int) string {
+	eth := new(big.Float).Quo(new(big.Float).SetInt(wei), new(big.Float).SetFloat64(1e18))
+	return eth.Text('f', 18)
+}
+
+// ParseEther parses a decimal ETH string, with at most 18 decimals, into a wei amount.
+func ParseEther(eth string) (*big.Int, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(eth), ".")
+	if len(frac) > 18 {
+		return nil, fmt.Errorf("invalid ETH amount %q: more than 18 decimals", eth)
+	}
+	wei, ok := new(big.Int).SetString(whole+frac+strings.Repeat("0", 18-len(frac)), 10)
+	if !ok || wei.Sign() < 0 {
+		return nil, fmt.Errorf("invalid ETH amount %q", eth)
+	}
+	return wei, nil
+}
@@ -0,0 +1,214 @@
+package withdraw
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fakeClock is a Clock that only moves when waited on: After advances it by the wait and fires at once, unless it
+// interrupts, so a poll loop runs without sleeping and the test sees each of its waits.
+type fakeClock struct {
+	now       time.Time
+	waits     []time.Duration
+	interrupt context.CancelFunc // If set, After cancels the context with it instead of firing
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if c.interrupt != nil {
+		c.interrupt()
+		return ch
+	}
+	c.now = c.now.Add(d)
+	ch <- c.now
+	return ch
+}
+
+// WithTimeout never times out: the tests end waits by interrupting them instead.
+func (c *fakeClock) WithTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithCancel(ctx)
+}
+
+// txBlock is the block the fake chain includes the tx in.
+const txBlock = 100
+
+// fakeChain is the eth namespace of an L1 RPC on which a tx is included at txBlock. Each receipt query is a poll, on
+// which the chain grows by a block; whether the tx is found on a poll is the next of found, the last one repeating,
+// so it can be included late or reorged out.
+type fakeChain struct {
+	mu        sync.Mutex
+	found     []bool
+	reverted  bool
+	head      uint64 // L1 head at the first poll
+	finalized uint64 // Finalized L1 block at the first poll
+	polls     int
+}
+
+func (c *fakeChain) GetTransactionReceipt(hash common.Hash) (*types.Receipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.polls++
+	if !c.found[min(c.polls, len(c.found))-1] {
+		return nil, nil
+	}
+	status := types.ReceiptStatusSuccessful
+	if c.reverted {
+		status = types.ReceiptStatusFailed
+	}
+	return &types.Receipt{Status: status, TxHash: hash, BlockNumber: big.NewInt(txBlock), Logs: []*types.Log{}}, nil
+}
+
+func (c *fakeChain) BlockNumber() hexutil.Uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return hexutil.Uint64(c.head + uint64(c.polls) - 1)
+}
+
+func (c *fakeChain) GetBlockByNumber(number rpc.BlockNumber, full bool) (*types.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if number != rpc.FinalizedBlockNumber {
+		return nil, errors.New("only the finalized block is served")
+	}
+	return &types.Header{Number: new(big.Int).SetUint64(c.finalized + uint64(c.polls) - 1), Difficulty: common.Big0}, nil
+}
+
+func TestWaitForConfirmation(t *testing.T) {
+	tx := common.HexToHash("0x5e3c1ad1b1e7f4ee3c2a58c7bd9d5e1a0a6f2b58a7f1c3d8c4e9b1a2f3d4e5f6")
+	tests := []struct {
+		name          string
+		chain         *fakeChain
+		confirmations uint64
+		waitFinalized bool
+		faults        Faults
+		poll          time.Duration
+		interrupt     bool
+		wantPolls     int
+		wantWaits     []time.Duration
+		wantErr       error
+	}{
+		{
+			name:      "confirmed at once",
+			chain:     &fakeChain{found: []bool{true}, head: txBlock},
+			wantPolls: 1,
+		},
+		{
+			name:      "included on the third poll",
+			chain:     &fakeChain{found: []bool{false, false, true}, head: txBlock},
+			wantPolls: 3,
+			wantWaits: []time.Duration{confirmationPollInterval, confirmationPollInterval},
+		},
+		{
+			name:      "configured poll interval",
+			chain:     &fakeChain{found: []bool{false, true}, head: txBlock},
+			poll:      12 * time.Second,
+			wantPolls: 2,
+			wantWaits: []time.Duration{12 * time.Second},
+		},
+		{
+			name:          "waits for confirmations",
+			chain:         &fakeChain{found: []bool{true}, head: txBlock},
+			confirmations: 3,
+			wantPolls:     3,
+			wantWaits:     []time.Duration{confirmationPollInterval, confirmationPollInterval},
+		},
+		{
+			name:          "already has the confirmations",
+			chain:         &fakeChain{found: []bool{true}, head: txBlock + 5},
+			confirmations: 3,
+			wantPolls:     1,
+		},
+		{
+			name:          "waits for finalization",
+			chain:         &fakeChain{found: []bool{true}, head: txBlock, finalized: txBlock - 2},
+			waitFinalized: true,
+			wantPolls:     3,
+			wantWaits:     []time.Duration{confirmationPollInterval, confirmationPollInterval},
+		},
+		{
+			name:          "reorged out while waiting for confirmations",
+			chain:         &fakeChain{found: []bool{true, false, false, true}, head: txBlock},
+			confirmations: 2,
+			wantPolls:     4,
+			wantWaits:     []time.Duration{confirmationPollInterval, confirmationPollInterval, confirmationPollInterval},
+		},
+		{
+			name:      "reverted",
+			chain:     &fakeChain{found: []bool{false, true}, head: txBlock, reverted: true},
+			wantPolls: 2,
+			wantWaits: []time.Duration{confirmationPollInterval},
+			wantErr:   errTxReverted,
+		},
+		{
+			name:      "injected reorg",
+			chain:     &fakeChain{found: []bool{true}, head: txBlock},
+			faults:    Faults{FaultReorg: true},
+			wantPolls: 2,
+			wantWaits: []time.Duration{confirmationPollInterval},
+		},
+		{
+			name:    "injected RPC timeout",
+			chain:   &fakeChain{found: []bool{true}, head: txBlock},
+			faults:  Faults{FaultRPCTimeout: true},
+			wantErr: context.DeadlineExceeded,
+		},
+		{
+			name:      "interrupted while waiting for inclusion",
+			chain:     &fakeChain{found: []bool{false}, head: txBlock},
+			interrupt: true,
+			wantPolls: 1,
+			wantWaits: []time.Duration{confirmationPollInterval},
+			wantErr:   context.Canceled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := tt.chain
+			server := rpc.NewServer()
+			defer server.Stop()
+			if err := server.RegisterName("eth", chain); err != nil {
+				t.Fatal(err)
+			}
+			client := ethclient.NewClient(rpc.DialInProc(server))
+			defer client.Close()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+			if tt.interrupt {
+				clock.interrupt = cancel
+			}
+			timing := &Timing{Clock: clock, ConfirmationPoll: tt.poll}
+
+			receipt, err := waitForConfirmation(ctx, client, tx, tt.confirmations, tt.waitFinalized, tt.faults, timing)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got error %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if receipt.TxHash != tx || receipt.BlockNumber.Uint64() != txBlock {
+				t.Errorf("got receipt of tx %s in block %s, want tx %s in block %d", receipt.TxHash, receipt.BlockNumber, tx, txBlock)
+			}
+			if chain.polls != tt.wantPolls {
+				t.Errorf("got %d receipt polls, want %d", chain.polls, tt.wantPolls)
+			}
+			if !reflect.DeepEqual(clock.waits, tt.wantWaits) {
+				t.Errorf("got waits %v, want %v", clock.waits, tt.wantWaits)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package withdraw
+
+import (
+	"fmt"
+
+	bindingspreview "github.com/ethereum-optimism/optimism/op-node/bindings/preview"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ProofInvalidatedError is returned when a withdrawal's proof can never be finalized, and the withdrawal must be
+// proven again against another dispute game.
+type ProofInvalidatedError struct {
+	Game   common.Address // Dispute game the withdrawal was proven against
+	Reason string
+}
+
+func (e *ProofInvalidatedError) Error() string {
+	return fmt.Sprintf("the proof against dispute game %s can no longer be finalized, the withdrawal must be re-proven: %s", e.Game, e.Reason)
+}
+
+// CheckProofValidity returns a *ProofInvalidatedError if the proof of the withdrawal by submitter was invalidated after
+// it was submitted: the game it was proven against was blacklisted or resolved in favor of the challenger, or
+// governance changed the portal's respected game type, as the portal only finalizes proofs against games of a type it
+// accepts created after the respected type was last updated. It returns nil if the withdrawal isn't proven by submitter.
+func CheckProofValidity(portal *bindingspreview.OptimismPortal2Caller, caller bind.ContractCaller, hash common.Hash, submitter common.Address) error {
+	proven, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash, submitter)
+	if err != nil {
+		return fmt.Errorf("error querying proven withdrawal: %w", err)
+	}
+	if proven.Timestamp == 0 {
+		return nil
+	}
+
+	reason, err := gameRejection(portal, caller, proven.DisputeGameProxy)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return &ProofInvalidatedError{Game: proven.DisputeGameProxy, Reason: reason}
+	}
+
+	game := NewDisputeGame(proven.DisputeGameProxy, caller)
+	gameType, err := game.GameType()
+	if err != nil {
+		return err
+	}
+	if reason, err := gameTypeRejection(portal, caller, proven.DisputeGameProxy, gameType); err != nil {
+		return err
+	} else if reason != "" {
+		return &ProofInvalidatedError{Game: proven.DisputeGameProxy, Reason: reason}
+	}
+
+	createdAt, err := game.CreatedAt()
+	if err != nil {
+		return err
+	}
+	updatedAt, err := portal.RespectedGameTypeUpdatedAt(&bind.CallOpts{})
+	if err != nil {
+		return fmt.Errorf("error querying respected game type update time: %w", err)
+	}
+	if createdAt < updatedAt {
+		return &ProofInvalidatedError{
+			Game:   proven.DisputeGameProxy,
+			Reason: fmt.Sprintf("the game was created at %d, before the respected game type was last updated at %d", createdAt, updatedAt),
+		}
+	}
+	return nil
+}
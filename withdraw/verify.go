@@ -0,0 +1,111 @@
+package withdraw
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/ethclient/gethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ErrProviderMismatch is returned when the verification L2 provider disagrees with the primary one.
+var ErrProviderMismatch = errors.New("L2 providers disagree")
+
+// crossCheckWithdrawal fetches the withdrawal receipt from the verification L2 provider and checks that it agrees
+// with the primary provider's decoded withdrawal on where the withdrawal was included and what it contains.
+func crossCheckWithdrawal(ctx context.Context, want *WithdrawalDetails, verify *rpc.Client, l2TxHash common.Hash, logIndex *uint) error {
+	receipt, err := ethclient.NewClient(verify).TransactionReceipt(ctx, l2TxHash)
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal from verification L2 RPC: %w", err)
+	}
+	receipt, err = SelectWithdrawal(receipt, logIndex)
+	if err != nil {
+		return fmt.Errorf("error selecting withdrawal from verification L2 RPC: %w", err)
+	}
+	got, err := DecodeWithdrawal(receipt)
+	if err != nil {
+		return fmt.Errorf("error decoding withdrawal from verification L2 RPC: %w", err)
+	}
+
+	if want.L2BlockNumber.Cmp(got.L2BlockNumber) != 0 {
+		return fmt.Errorf("%w: withdrawal included in L2 block %s, verification RPC reports %s", ErrProviderMismatch, want.L2BlockNumber, got.L2BlockNumber)
+	}
+	if want.ReceiptSuccess != got.ReceiptSuccess {
+		return fmt.Errorf("%w: withdrawal receipt success is %t, verification RPC reports %t", ErrProviderMismatch, want.ReceiptSuccess, got.ReceiptSuccess)
+	}
+	if want.Hash != got.Hash {
+		return fmt.Errorf("%w: withdrawal hash is %s, verification RPC reports %s", ErrProviderMismatch, want.Hash, got.Hash)
+	}
+	log.Info("Withdrawal cross-checked against verification L2 RPC", "withdrawalHash", want.Hash, "l2Block", want.L2BlockNumber)
+	return nil
+}
+
+// crossCheckProofParameters independently recomputes the proof parameters at header's block number using the
+// verification L2 provider, and checks that they match the ones computed from the primary provider.
+func crossCheckProofParameters(ctx context.Context, verify *rpc.Client, l2TxHash common.Hash, logIndex *uint, header *types.Header, outputIndex *big.Int, want withdrawals.ProvenWithdrawalParameters) error {
+	l2 := ethclient.NewClient(verify)
+
+	receipt, err := l2.TransactionReceipt(ctx, l2TxHash)
+	if err != nil {
+		return fmt.Errorf("error querying withdrawal from verification L2 RPC: %w", err)
+	}
+	receipt, err = SelectWithdrawal(receipt, logIndex)
+	if err != nil {
+		return fmt.Errorf("error selecting withdrawal from verification L2 RPC: %w", err)
+	}
+	verifyHeader, err := l2.HeaderByNumber(ctx, header.Number)
+	if err != nil {
+		return fmt.Errorf("error querying L2 block %s from verification L2 RPC: %w", header.Number, err)
+	}
+	if verifyHeader.Hash() != header.Hash() {
+		return fmt.Errorf("%w: L2 block %s has hash %s, verification RPC reports %s", ErrProviderMismatch, header.Number, header.Hash(), verifyHeader.Hash())
+	}
+
+	got, err := proveWithdrawalParameters(ctx, gethclient.New(verify), receipt, verifyHeader, outputIndex)
+	if err != nil {
+		return fmt.Errorf("error computing proof from verification L2 RPC: %w", err)
+	}
+	if err := compareProofParameters(want, got); err != nil {
+		return fmt.Errorf("%w: %w", ErrProviderMismatch, err)
+	}
+	log.Info("Proof cross-checked against verification L2 RPC", "l2Block", header.Number)
+	return nil
+}
+
+// compareProofParameters returns an error naming the first field that differs between a and b.
+func compareProofParameters(a, b withdrawals.ProvenWithdrawalParameters) error {
+	switch {
+	case a.Nonce.Cmp(b.Nonce) != 0:
+		return fmt.Errorf("nonce %s != %s", a.Nonce, b.Nonce)
+	case a.Sender != b.Sender:
+		return fmt.Errorf("sender %s != %s", a.Sender, b.Sender)
+	case a.Target != b.Target:
+		return fmt.Errorf("target %s != %s", a.Target, b.Target)
+	case a.Value.Cmp(b.Value) != 0:
+		return fmt.Errorf("value %s != %s", a.Value, b.Value)
+	case a.GasLimit.Cmp(b.GasLimit) != 0:
+		return fmt.Errorf("gas limit %s != %s", a.GasLimit, b.GasLimit)
+	case a.L2OutputIndex.Cmp(b.L2OutputIndex) != 0:
+		return fmt.Errorf("output index %s != %s", a.L2OutputIndex, b.L2OutputIndex)
+	case !bytes.Equal(a.Data, b.Data):
+		return errors.New("withdrawal data differs")
+	case a.OutputRootProof != b.OutputRootProof:
+		return errors.New("output root proof differs")
+	case len(a.WithdrawalProof) != len(b.WithdrawalProof):
+		return fmt.Errorf("withdrawal proof has %d nodes != %d", len(a.WithdrawalProof), len(b.WithdrawalProof))
+	}
+	for i := range a.WithdrawalProof {
+		if !bytes.Equal(a.WithdrawalProof[i], b.WithdrawalProof[i]) {
+			return fmt.Errorf("withdrawal proof node %d differs", i)
+		}
+	}
+	return nil
+}
@@ -2,17 +2,15 @@ package withdraw
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"math/big"
 	"time"
 
 	"github.com/ethereum-optimism/optimism/op-node/bindings"
-	"github.com/ethereum-optimism/optimism/op-node/withdrawals"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/ethereum/go-ethereum/ethclient/gethclient"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -25,9 +23,32 @@ type Withdrawer struct {
 	Portal          *bindings.OptimismPortal
 	Oracle          *bindings.L2OutputOracle
 	Opts            *bind.TransactOpts
-	GasMultiplier   float64 // Multiplier for estimated gas (default 1.0)
-	UserGasLimit    uint64  // Original user-specified gas limit (0 means auto-estimate)
-	DryRun          bool    // Simulate transactions without submitting
+	GasMultiplier   float64        // Multiplier for estimated gas (default 1.0)
+	UserGasLimit    uint64         // Original user-specified gas limit (0 means auto-estimate)
+	DryRun          bool           // Simulate transactions without submitting
+	ProveTimeout    time.Duration  // Max time to wait for the prove tx to confirm (0 means default of 5 minutes)
+	FinalizeTimeout time.Duration  // Max time to wait for the finalize tx to confirm (0 means default of 5 minutes)
+	Confirmations   uint64         // Number of L1 confirmations to wait for (0 or 1 means inclusion is enough)
+	WaitFinalized   bool           // Wait for the L1 block containing the tx to be finalized
+	Proof           *ProofMetadata // Output root used by the last ProveWithdrawal call
+	Faults          Faults         // Failures to inject, for rehearsals in test environments only
+	Timing          *Timing        // Clock and poll intervals of the waits (nil means the system clock and default intervals)
+	VerifyL2Client  *rpc.Client    // Second L2 provider to cross-check withdrawal and proof data against (optional)
+	Costs           []TxCost       // Gas spent by the transactions confirmed so far
+	ETHUSD          float64        // ETH price in USD for cost estimates (0 means unknown)
+	PortalAddress   common.Address // OptimismPortal address, which direct withdrawal calls are simulated from
+	BaseFeeMax      *big.Int       // Defer submissions while the L1 base fee is above this (nil means never defer)
+	MinBalance      *big.Int       // Pause submissions while the signer's L1 balance is below this (nil means never pause)
+	Quorum          *Quorum        // Additional L1 providers that must agree on critical state (nil means trust L1Client alone)
+	GasToken        *GasToken      // Native token of the chain, which withdrawal values are paid out in (nil means ETH)
+	Notifier        Notifier       // Told about proofs, finalizations and errors (nil means no notifications)
+	Prover          Prover         // Service to delegate the prove transaction to (nil means prove locally)
+	L2OutputIndex   *big.Int       // Index of the L2 output to prove against (nil means the latest output)
+	ProofSources    []ProofSource  // Tried in order for the withdrawal proof before the L2 RPC, e.g. light clients (optional)
+	LogIndex        *uint          // Log index of the MessagePassed event to prove and finalize, for L2 txs initiating several withdrawals (nil means the first)
+
+	cache    withdrawalCache    // Receipt and decoded event of the withdrawal, fetched once
+	dryRunTx *types.Transaction // Transaction the last dry run built instead of sending it
 }
 
 func (w *Withdrawer) CheckIfProvable() error {
@@ -47,10 +68,17 @@ func (w *Withdrawer) CheckIfProvable() error {
 		return fmt.Errorf("error querying latest proposed block: %w", err)
 	}
 
-	l2WithdrawalBlock, err := txBlock(w.Ctx, w.L2Client, w.L2TxHash)
+	_, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
 		return fmt.Errorf("error querying withdrawal tx block: %w", err)
 	}
+	l2WithdrawalBlock := details.L2BlockNumber
+
+	if w.VerifyL2Client != nil {
+		if err := crossCheckWithdrawal(w.Ctx, details, w.VerifyL2Client, w.L2TxHash, w.LogIndex); err != nil {
+			return err
+		}
+	}
 
 	if l2OutputBlock.Uint64() < l2WithdrawalBlock.Uint64() {
 		return fmt.Errorf("the latest L2 output is %d and is not past L2 block %d that includes the withdrawal, no withdrawal can be proved yet - please wait for the next proposal submission, which happens every %v",
@@ -60,54 +88,125 @@ func (w *Withdrawer) CheckIfProvable() error {
 }
 
 func (w *Withdrawer) getWithdrawalHash() (common.Hash, error) {
-	l2 := ethclient.NewClient(w.L2Client)
-	receipt, err := l2.TransactionReceipt(w.Ctx, w.L2TxHash)
+	_, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
 		return common.Hash{}, err
 	}
+	return details.Hash, nil
+}
 
-	ev, err := withdrawals.ParseMessagePassed(receipt)
+func (w *Withdrawer) GetProvenWithdrawalTime() (uint64, error) {
+	hash, err := w.getWithdrawalHash()
 	if err != nil {
-		return common.Hash{}, err
+		return 0, err
 	}
 
-	hash, err := withdrawals.WithdrawalHash(ev)
-	if err != nil {
-		return common.Hash{}, err
-	}
+	return quorumRead(w.Quorum, "proven timestamp", w.L1Client, func(caller bind.ContractCaller) (uint64, error) {
+		portal, err := bindings.NewOptimismPortalCaller(w.PortalAddress, caller)
+		if err != nil {
+			return 0, err
+		}
+		provenWithdrawal, err := portal.ProvenWithdrawals(&bind.CallOpts{}, hash)
+		if err != nil {
+			return 0, err
+		}
+		return provenWithdrawal.Timestamp.Uint64(), nil
+	})
+}
 
-	return hash, nil
+func (w *Withdrawer) ProveWithdrawal() error {
+	// flag a finalization that would not deliver the withdrawal before spending gas on proving it
+	if _, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex); err == nil {
+		warnIfFinalizeReverts(w.Ctx, w.L1Client, w.PortalAddress, details, w.GasToken)
+	}
+	err := w.proveWithdrawal()
+	notifyStep(w.Ctx, w.Notifier, w.DryRun, w.notification(), err, Notifier.OnProven)
+	return err
 }
 
-func (w *Withdrawer) GetProvenWithdrawalTime() (uint64, error) {
-	hash, err := w.getWithdrawalHash()
+// l2OutputToProve returns the index and L2 block of the output to prove a withdrawal in l2BlockNumber against: the
+// output at L2OutputIndex if set, otherwise the latest one.
+func (w *Withdrawer) l2OutputToProve(l2BlockNumber *big.Int) (*big.Int, *big.Int, error) {
+	if w.L2OutputIndex == nil {
+		l2OutputBlock, err := w.Oracle.LatestBlockNumber(&bind.CallOpts{})
+		if err != nil {
+			return nil, nil, err
+		}
+		l2OutputIndex, err := w.Oracle.GetL2OutputIndexAfter(&bind.CallOpts{}, l2OutputBlock)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get l2OutputIndex: %w", err)
+		}
+		return l2OutputIndex, l2OutputBlock, nil
+	}
+
+	latest, err := w.Oracle.LatestOutputIndex(&bind.CallOpts{})
 	if err != nil {
-		return 0, err
+		return nil, nil, fmt.Errorf("failed to get latest l2OutputIndex: %w", err)
 	}
-
-	provenWithdrawal, err := w.Portal.ProvenWithdrawals(&bind.CallOpts{}, hash)
+	if w.L2OutputIndex.Cmp(latest) > 0 {
+		return nil, nil, fmt.Errorf("L2 output %s does not exist, the latest is %s (outputs past a deleted one are removed with it)", w.L2OutputIndex, latest)
+	}
+	output, err := w.Oracle.GetL2Output(&bind.CallOpts{}, w.L2OutputIndex)
 	if err != nil {
-		return 0, err
+		return nil, nil, fmt.Errorf("failed to get L2 output %s: %w", w.L2OutputIndex, err)
 	}
-
-	return provenWithdrawal.Timestamp.Uint64(), nil
+	if output.L2BlockNumber.Cmp(l2BlockNumber) < 0 {
+		return nil, nil, fmt.Errorf("L2 output %s is for L2 block %s, before the withdrawal's L2 block %s", w.L2OutputIndex, output.L2BlockNumber, l2BlockNumber)
+	}
+	return w.L2OutputIndex, output.L2BlockNumber, nil
 }
 
-func (w *Withdrawer) ProveWithdrawal() error {
-	l2 := ethclient.NewClient(w.L2Client)
-	l2g := gethclient.New(w.L2Client)
+func (w *Withdrawer) proveWithdrawal() error {
+	if w.Prover != nil {
+		return w.proveDelegated()
+	}
 
-	l2OutputBlock, err := w.Oracle.LatestBlockNumber(&bind.CallOpts{})
+	receipt, _, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
 		return err
 	}
-
-	// We generate a proof for the latest L2 output, which shouldn't require archive-node data if it's recent enough.
-	header, err := l2.HeaderByNumber(w.Ctx, l2OutputBlock)
+	l2OutputIndex, l2OutputBlock, err := w.l2OutputToProve(receipt.BlockNumber)
 	if err != nil {
 		return err
 	}
-	params, err := withdrawals.ProveWithdrawalParameters(w.Ctx, l2g, l2, w.L2TxHash, header, &w.Oracle.L2OutputOracleCaller)
+
+	output, err := w.Oracle.GetL2Output(&bind.CallOpts{}, l2OutputIndex)
+	if err != nil {
+		return fmt.Errorf("failed to get L2 output %s: %w", l2OutputIndex, err)
+	}
+	w.Proof = &ProofMetadata{
+		GameIndex:  l2OutputIndex,
+		L2Block:    output.L2BlockNumber,
+		OutputRoot: output.OutputRoot,
+	}
+	log.Info("Proving against L2 output", w.Proof.logFields()...)
+
+	outputRoot := common.Hash(output.OutputRoot)
+	if w.Quorum != nil {
+		outputRoot, err = quorumRead(w.Quorum, "L2 output root", w.L1Client, func(caller bind.ContractCaller) (common.Hash, error) {
+			oracleAddress, err := DiscoverL2OutputOracle(w.PortalAddress, caller)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			oracle, err := bindings.NewL2OutputOracleCaller(oracleAddress, caller)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			output, err := oracle.GetL2Output(&bind.CallOpts{}, l2OutputIndex)
+			if err != nil {
+				return common.Hash{}, err
+			}
+			return output.OutputRoot, nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// We generate a proof for the latest L2 output by default, which shouldn't require archive-node data if it's
+	// recent enough. Older outputs picked with L2OutputIndex may need an archive node.
+	_, params, err := fetchProofParameters(w.Ctx, w.ProofSources, w.L2Client, receipt, l2OutputBlock, l2OutputIndex,
+		outputRootVerifier(w.Ctx, outputRoot, w.VerifyL2Client, w.L2TxHash, w.LogIndex, l2OutputIndex))
 	if err != nil {
 		return err
 	}
@@ -136,28 +235,52 @@ func (w *Withdrawer) ProveWithdrawal() error {
 	}
 
 	if w.DryRun {
-		printDryRun("ProveWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit)
-		return nil
+		printDryRun("ProveWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit, w.ETHUSD)
+		w.dryRunTx, err = dryRunTx(w.Ctx, w.L1Client, simulatedTx, w.Opts.GasLimit)
+		return err
 	}
 
 	// Create the prove tx
-	tx, err := w.Portal.ProveWithdrawalTransaction(
-		w.Opts,
-		withdrawalTx,
-		params.L2OutputIndex,
-		params.OutputRootProof,
-		params.WithdrawalProof,
-	)
+	cost, err := w.portalTxs().sendPortalTx(w.Ctx, ActionProve, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return w.Portal.ProveWithdrawalTransaction(
+			opts,
+			withdrawalTx,
+			params.L2OutputIndex,
+			params.OutputRootProof,
+			params.WithdrawalProof,
+		)
+	})
 	if err != nil {
 		return err
 	}
+	w.Costs = append(w.Costs, cost)
+	return nil
+}
 
-	log.Info("Proved withdrawal", "l2TxHash", w.L2TxHash, "l1TxHash", tx.Hash())
+// proveDelegated has the prover service prove the withdrawal, then checks that the proof is recorded on-chain.
+func (w *Withdrawer) proveDelegated() error {
+	hash, err := w.getWithdrawalHash()
+	if err != nil {
+		return err
+	}
+	if w.DryRun {
+		log.Info("Dry run: the prove transaction would be delegated to the prover service", "withdrawalHash", hash)
+		return nil
+	}
 
-	// Wait 5 mins max for confirmation
-	ctxWithTimeout, cancel := context.WithTimeout(w.Ctx, 5*time.Minute)
-	defer cancel()
-	return waitForConfirmation(ctxWithTimeout, w.L1Client, tx.Hash())
+	// the legacy portal stores a single proof per withdrawal, whoever submitted it
+	if _, _, err := delegateProof(w.Ctx, w.Prover, w.L1Client, w.L2Client, w.L2TxHash, hash, w.PortalAddress, w.ProveTimeout, w.Confirmations, w.WaitFinalized, w.Faults, w.Timing); err != nil {
+		return err
+	}
+	proven, err := w.Portal.ProvenWithdrawals(&bind.CallOpts{}, hash)
+	if err != nil {
+		return fmt.Errorf("error querying delegated proof: %w", err)
+	}
+	if proven.Timestamp.Sign() == 0 {
+		return fmt.Errorf("%w: portal has no proof of withdrawal %s", ErrProofMismatch, hash)
+	}
+	log.Info("Verified delegated proof", "withdrawalHash", hash, "l2OutputIndex", proven.L2OutputIndex, "provenAt", proven.Timestamp)
+	return nil
 }
 
 func (w *Withdrawer) IsProofFinalized() (bool, error) {
@@ -165,25 +288,33 @@ func (w *Withdrawer) IsProofFinalized() (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	return w.Portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	return quorumRead(w.Quorum, "finalized status", w.L1Client, func(caller bind.ContractCaller) (bool, error) {
+		portal, err := bindings.NewOptimismPortalCaller(w.PortalAddress, caller)
+		if err != nil {
+			return false, err
+		}
+		return portal.FinalizedWithdrawals(&bind.CallOpts{}, hash)
+	})
 }
 
 func (w *Withdrawer) FinalizeWithdrawal() error {
+	err := w.finalizeWithdrawal()
+	notifyStep(w.Ctx, w.Notifier, w.DryRun, w.notification(), err, Notifier.OnFinalized)
+	return err
+}
+
+func (w *Withdrawer) finalizeWithdrawal() error {
 	l2 := ethclient.NewClient(w.L2Client)
-	l2g := gethclient.New(w.L2Client)
 
 	// Figure out when our withdrawal was included
-	receipt, err := l2.TransactionReceipt(w.Ctx, w.L2TxHash)
+	_, details, err := w.cache.get(w.Ctx, w.L2Client, w.L2TxHash, w.LogIndex)
 	if err != nil {
 		return fmt.Errorf("cannot get receipt for withdrawal tx %s: %v", w.L2TxHash, err)
 	}
-	if receipt.Status != types.ReceiptStatusSuccessful {
-		return errors.New("unsuccessful withdrawal receipt status")
-	}
 
-	l2WithdrawalBlock, err := l2.HeaderByNumber(w.Ctx, receipt.BlockNumber)
+	l2WithdrawalBlock, err := l2.HeaderByNumber(w.Ctx, details.L2BlockNumber)
 	if err != nil {
-		return fmt.Errorf("error getting header by number for block %s: %v", receipt.BlockNumber, err)
+		return fmt.Errorf("error getting header by number for block %s: %v", details.L2BlockNumber, err)
 	}
 
 	// Figure out what the Output oracle on L1 has seen so far
@@ -202,42 +333,32 @@ func (w *Withdrawer) FinalizeWithdrawal() error {
 		return fmt.Errorf("the latest L2 output is %d and is not past L2 block %d that includes the withdrawal yet, no withdrawal can be completed yet", l2OutputBlock.Number.Uint64(), l2WithdrawalBlock.Number.Uint64())
 	}
 
-	l1Head, err := w.L1Client.HeaderByNumber(w.Ctx, nil)
+	// Check if the withdrawal may be completed yet, which needs the finalization period to have elapsed since both
+	// the proof and the output it was proven against
+	e, err := EstimateLegacyFinalization(&w.Portal.OptimismPortalCaller, &w.Oracle.L2OutputOracleCaller, details.Hash)
 	if err != nil {
 		return err
 	}
-
-	// Check if the withdrawal may be completed yet
-	finalizationPeriod, err := w.Oracle.FINALIZATIONPERIODSECONDS(&bind.CallOpts{})
+	l1Head, err := w.L1Client.HeaderByNumber(w.Ctx, nil)
 	if err != nil {
 		return err
 	}
-
-	if l2WithdrawalBlock.Time+finalizationPeriod.Uint64() >= l1Head.Time {
-		return fmt.Errorf("withdrawal tx %s was included in L2 block %d (time %d) but L1 only knows of L2 proposal %d (time %d) at head %d (time %d) which has not reached output confirmation yet (period is %d)",
-			w.L2TxHash, l2WithdrawalBlock.Number.Uint64(), l2WithdrawalBlock.Time, l2OutputBlock.Number.Uint64(), l2OutputBlock.Time, l1Head.Number.Uint64(), l1Head.Time, finalizationPeriod.Uint64())
+	if notFinalizable := e.notFinalizableError(time.Unix(int64(l1Head.Time), 0)); notFinalizable != nil {
+		return notFinalizable
 	}
 
-	// We generate a proof for the latest L2 output, which shouldn't require archive-node data if it's recent enough.
-	// Note that for the `FinalizeWithdrawalTransaction` function, this proof isn't needed. We simply use some of the
-	// params for the `WithdrawalTransaction` type generated in the bindings.
-	header, err := l2.HeaderByNumber(w.Ctx, l2OutputBlockNr)
-	if err != nil {
-		return err
-	}
-
-	params, err := withdrawals.ProveWithdrawalParameters(w.Ctx, l2g, l2, w.L2TxHash, header, &w.Oracle.L2OutputOracleCaller)
-	if err != nil {
-		return err
-	}
+	// FinalizeWithdrawalTransaction doesn't need a proof, only the withdrawal itself, which comes from the cached event
+	notifyFinalizable(w.Ctx, w.Notifier, w.notification())
+	warnIfFinalizeReverts(w.Ctx, w.L1Client, w.PortalAddress, details, w.GasToken)
 
+	ev := details.Event
 	withdrawalTx := bindings.TypesWithdrawalTransaction{
-		Nonce:    params.Nonce,
-		Sender:   params.Sender,
-		Target:   params.Target,
-		Value:    params.Value,
-		GasLimit: params.GasLimit,
-		Data:     params.Data,
+		Nonce:    ev.Nonce,
+		Sender:   ev.Sender,
+		Target:   ev.Target,
+		Value:    ev.Value,
+		GasLimit: ev.GasLimit,
+		Data:     ev.Data,
 	}
 
 	// Prepare gas options with multiplier if configured
@@ -249,20 +370,67 @@ func (w *Withdrawer) FinalizeWithdrawal() error {
 	}
 
 	if w.DryRun {
-		printDryRun("FinalizeWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit)
-		return nil
+		printDryRun("FinalizeWithdrawal", simulatedTx, w.Opts.From, w.Opts.GasLimit, w.ETHUSD)
+		w.dryRunTx, err = dryRunTx(w.Ctx, w.L1Client, simulatedTx, w.Opts.GasLimit)
+		return err
 	}
 
 	// Create the withdrawal tx
-	tx, err := w.Portal.FinalizeWithdrawalTransaction(w.Opts, withdrawalTx)
+	cost, err := w.portalTxs().sendPortalTx(w.Ctx, ActionFinalize, func(opts *bind.TransactOpts) (*types.Transaction, error) {
+		return w.Portal.FinalizeWithdrawalTransaction(opts, withdrawalTx)
+	})
 	if err != nil {
 		return err
 	}
+	w.Costs = append(w.Costs, cost)
+	return nil
+}
 
-	log.Info("Completed withdrawal", "l2TxHash", w.L2TxHash, "l1TxHash", tx.Hash())
+// FinalizationEstimate returns when the proven withdrawal can be finalized, and whether the proof is final on L1.
+func (w *Withdrawer) FinalizationEstimate() (*FinalizationEstimate, error) {
+	hash, err := w.getWithdrawalHash()
+	if err != nil {
+		return nil, err
+	}
+	e, err := EstimateLegacyFinalization(&w.Portal.OptimismPortalCaller, &w.Oracle.L2OutputOracleCaller, hash)
+	if err != nil {
+		return nil, err
+	}
+	// some L1s, such as local devnets, don't track finality, which only makes it unknown
+	e.ProofFinalized, err = ProofFinalizedOnL1(w.Ctx, w.L1Client, e.ProvenAt)
+	if err != nil {
+		log.Debug("Unable to tell whether the proof is finalized on L1", "error", err)
+	}
+	return e, nil
+}
+
+// Delays returns the L2OutputOracle's finalization period, which proofs must have aged by.
+func (w *Withdrawer) Delays() (*Delays, error) {
+	return ReadLegacyDelays(&w.Oracle.L2OutputOracleCaller)
+}
+
+// notification describes the withdrawal for notifications.
+func (w *Withdrawer) notification() Notification {
+	hash, _ := w.getWithdrawalHash()
+	return newNotification(w.L2TxHash, hash, w.Costs)
+}
+
+// TxCosts returns the gas spent by the transactions confirmed so far.
+func (w *Withdrawer) TxCosts() []TxCost {
+	return w.Costs
+}
+
+// DryRunCall returns the portal call the last dry run built, or nil if none did.
+func (w *Withdrawer) DryRunCall() *Calldata {
+	return NewCalldata(w.dryRunTx)
+}
+
+// DryRunTx returns the unsigned transaction the last dry run built, as it would have been sent, or nil if none did.
+func (w *Withdrawer) DryRunTx() *types.Transaction {
+	return w.dryRunTx
+}
 
-	// Wait 5 mins max for confirmation
-	ctxWithTimeout, cancel := context.WithTimeout(w.Ctx, 5*time.Minute)
-	defer cancel()
-	return waitForConfirmation(ctxWithTimeout, w.L1Client, tx.Hash())
+// ProvenAgainst returns the output root used by the last ProveWithdrawal call, or nil if none was made.
+func (w *Withdrawer) ProvenAgainst() *ProofMetadata {
+	return w.Proof
 }